@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"atlas-blockchain/pkg/auth"
+)
+
+const apikeyUsage = `Usage:
+  atlas apikey -id <name> [-role operator|admin|user] [-scopes faucet,backup,...] [-access access.json]
+      Generate an API key. The key is printed once; the access file only
+      keeps its hash. With -access the key is added to the file, which is
+      created if missing.
+
+Scopes of operator keys: faucet, validators, backup, oracle, testing, sync,
+sharding, governance, identity, or * for all. Admin keys need no scopes.`
+
+// runAPIKey implements the "apikey" subcommand
+func runAPIKey(args []string) int {
+	fs := flag.NewFlagSet("apikey", flag.ContinueOnError)
+	id := fs.String("id", "", "Name of the key, shown in logs")
+	roleName := fs.String("role", "operator", "Role of the key: user, operator or admin")
+	scopes := fs.String("scopes", "", "Comma-separated operator scopes")
+	accessPath := fs.String("access", "", "Access file to add the key to")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, apikeyUsage) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *id == "" {
+		fmt.Fprintln(os.Stderr, apikeyUsage)
+		return 2
+	}
+	role, err := auth.ParseRole(*roleName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	key, hash, err := auth.NewAPIKey()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	entry := auth.APIKey{ID: *id, Role: role, Hash: hash}
+	for _, scope := range strings.Split(*scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			entry.Scopes = append(entry.Scopes, scope)
+		}
+	}
+
+	if *accessPath != "" {
+		if err := addAPIKey(*accessPath, entry); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Added key %q to %s\n", entry.ID, *accessPath)
+	} else {
+		data, _ := json.MarshalIndent(entry, "", "  ")
+		fmt.Printf("Access file entry:\n%s\n", data)
+	}
+	fmt.Printf("API key (send as %s, shown only once):\n%s\n", auth.APIKeyHeader, key)
+	return 0
+}
+
+// addAPIKey adds a key to an access file, creating the file if needed
+func addAPIKey(path string, key auth.APIKey) error {
+	access := &auth.Access{}
+	if _, err := os.Stat(path); err == nil {
+		if access, err = auth.LoadAccess(path); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	access.Keys = append(access.Keys, key)
+	if err := access.Validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(access, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode access file: %v", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// loadAuthenticator creates the API authenticator of the node. Tokens are
+// signed with ATLAS_AUTH_SECRET, or a random secret that does not survive a
+// restart; API keys and wallet roles come from the access file.
+func loadAuthenticator(accessPath, chainID string) (*auth.Authenticator, error) {
+	secret := []byte(os.Getenv("ATLAS_AUTH_SECRET"))
+	if len(secret) == 0 {
+		log.Printf("⚠️ ATLAS_AUTH_SECRET is not set: login tokens are invalidated by a restart")
+		secret = auth.NewSecret()
+	}
+	tokens, err := auth.NewTokens(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ATLAS_AUTH_SECRET: %v", err)
+	}
+	var access *auth.Access
+	if accessPath != "" {
+		if access, err = auth.LoadAccess(accessPath); err != nil {
+			return nil, err
+		}
+		log.Printf("🔑 Loaded %d API keys and %d wallet roles from %s", len(access.Keys), len(access.Wallets), accessPath)
+	}
+	return auth.NewAuthenticator(tokens, auth.NewChallenges(chainID), access)
+}
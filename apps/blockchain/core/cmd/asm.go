@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"atlas-blockchain/pkg/vm"
+	"atlas-blockchain/pkg/vm/asm"
+)
+
+const asmUsage = `Usage:
+  atlas asm compile [-o out.json] <source.asm>   Assemble source into a deployable JSON contract
+  atlas asm check <source.asm|contract.json>     Run static checks without producing output
+  atlas asm disasm <contract.json>               Disassemble a JSON contract
+  atlas asm disasm -address <addr> [-api url]    Disassemble a deployed contract`
+
+// runAsm implements the "asm" subcommand
+func runAsm(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, asmUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "compile":
+		err = asmCompile(args[1:])
+	case "check":
+		err = asmCheck(args[1:])
+	case "disasm":
+		err = asmDisasm(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, asmUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func asmCompile(args []string) error {
+	fs := flag.NewFlagSet("asm compile", flag.ContinueOnError)
+	out := fs.String("o", "", "Output file (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected one source file\n%s", asmUsage)
+	}
+
+	src, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to read source: %v", err)
+	}
+	prog, err := asm.Assemble(string(src))
+	if err != nil {
+		return fmt.Errorf("%s:\n%v", fs.Arg(0), err)
+	}
+
+	data, err := json.MarshalIndent(prog.JSONContract(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode contract: %v", err)
+	}
+	data = append(data, '\n')
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*out, data, 0644)
+}
+
+func asmCheck(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected one file\n%s", asmUsage)
+	}
+	path := args[0]
+
+	if strings.HasSuffix(path, ".json") {
+		contract, err := loadJSONContract(path)
+		if err != nil {
+			return err
+		}
+		if err := asm.Check(contract.Functions); err != nil {
+			return fmt.Errorf("%s:\n%v", path, err)
+		}
+	} else {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read source: %v", err)
+		}
+		if _, err := asm.Assemble(string(src)); err != nil {
+			return fmt.Errorf("%s:\n%v", path, err)
+		}
+	}
+	fmt.Printf("%s: ok\n", path)
+	return nil
+}
+
+func asmDisasm(args []string) error {
+	fs := flag.NewFlagSet("asm disasm", flag.ContinueOnError)
+	address := fs.String("address", "", "Address of a deployed contract")
+	apiURL := fs.String("api", "http://localhost:8080", "Node API base URL")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var contract *vm.Contract
+	var err error
+	switch {
+	case *address != "":
+		contract, err = fetchContract(*apiURL, *address)
+	case fs.NArg() == 1:
+		contract, err = loadJSONContract(fs.Arg(0))
+	default:
+		return fmt.Errorf("expected a contract file or -address\n%s", asmUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(asm.Disassemble(contract))
+	return nil
+}
+
+// loadJSONContract reads a JSON contract file into an undeployed contract
+func loadJSONContract(path string) (*vm.Contract, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contract: %v", err)
+	}
+	var jsonContract vm.JSONContract
+	if err := json.Unmarshal(data, &jsonContract); err != nil {
+		return nil, fmt.Errorf("failed to parse contract: %v", err)
+	}
+	return vm.DeployJSONContract(jsonContract.Owner, &jsonContract, false)
+}
+
+// fetchContract loads a deployed contract's code from a node
+func fetchContract(apiURL, address string) (*vm.Contract, error) {
+	endpoint := strings.TrimRight(apiURL, "/") + "/contract/info?address=" + url.QueryEscape(address)
+	resp, err := http.Get(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch contract: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch contract: %s", resp.Status)
+	}
+
+	var info struct {
+		Address      string                  `json:"address"`
+		Name         string                  `json:"name"`
+		Version      string                  `json:"version"`
+		Owner        string                  `json:"owner"`
+		Storage      map[string]interface{}  `json:"storage"`
+		ContractType vm.ContractType         `json:"contract_type"`
+		ABI          *vm.ABI                 `json:"abi"`
+		Code         map[string]*vm.Function `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to decode contract: %v", err)
+	}
+	if info.Code == nil {
+		return nil, fmt.Errorf("node did not return contract code (upgrade the node)")
+	}
+	return &vm.Contract{
+		Address:      info.Address,
+		Name:         info.Name,
+		Version:      info.Version,
+		Owner:        info.Owner,
+		Storage:      info.Storage,
+		ContractType: info.ContractType,
+		ABI:          info.ABI,
+		Functions:    info.Code,
+	}, nil
+}
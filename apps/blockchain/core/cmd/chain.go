@@ -0,0 +1,178 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"atlas-blockchain/internal/blockchain"
+	"atlas-blockchain/pkg/config"
+	"atlas-blockchain/pkg/genesis"
+)
+
+const exportChainUsage = `Usage:
+  atlas export-chain [-storage sqlite|kv] [-db path] [-genesis file] [-from n] [-to n] -o <chain.atlas|->
+      Write blocks from..to (default: the whole chain) to a portable archive`
+
+const importChainUsage = `Usage:
+  atlas import-chain [-storage sqlite|kv] [-db path] [-genesis file] <chain.atlas|->
+      Validate and apply the blocks of an archive to a node database. Blocks
+      the database already has must match the archive.`
+
+// chainFlags registers the flags selecting the node database and its network
+func chainFlags(fs *flag.FlagSet) (storage, dbPath, genesisPath *string, verbose *bool) {
+	storage = fs.String("storage", "sqlite", "Storage backend: sqlite or kv")
+	dbPath = fs.String("db", "", "Database path (default blockchain.db, or blockchain.kv for the kv backend)")
+	genesisPath = fs.String("genesis", "", "Genesis file of the network (default: built-in devnet)")
+	verbose = fs.Bool("v", false, "Show node log output")
+	return
+}
+
+// loadGenesis reads the genesis file at path, or returns the built-in devnet
+// genesis if path is empty, and applies its consensus parameters to cfg
+func loadGenesis(path string, cfg *config.BlockchainConfig) (*genesis.Genesis, error) {
+	g := genesis.Default()
+	if path != "" {
+		var err error
+		if g, err = genesis.Load(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := g.ApplyConfig(cfg); err != nil {
+		return nil, fmt.Errorf("invalid genesis: %v", err)
+	}
+	return g, nil
+}
+
+// openChain opens the node database the way the node does at startup
+func openChain(storage, dbPath, genesisPath string) (*blockchain.StateManager, *blockchain.BlockManager, error) {
+	cfg := config.DefaultConfig()
+	cfg.StorageBackend = storage
+	cfg.DatabasePath = dbPath
+	if dbPath == "" && storage == "kv" {
+		cfg.DatabasePath = "blockchain.kv"
+	}
+	g, err := loadGenesis(genesisPath, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	sm := blockchain.NewStateManager(cfg)
+	if !sm.HasDatabase() {
+		return nil, nil, fmt.Errorf("cannot open %s database at %s", cfg.StorageBackend, cfg.DatabasePath)
+	}
+	bm := blockchain.NewBlockManagerWithGenesis(cfg, sm, g)
+	sm.SetConsensusManager(blockchain.NewConsensusManager(cfg, bm))
+	return sm, bm, nil
+}
+
+// progressPrinter reports progress on stderr at most twice a second
+func progressPrinter(verb string) func(done, total int64) {
+	var last time.Time
+	return func(done, total int64) {
+		if done < total && time.Since(last) < 500*time.Millisecond {
+			return
+		}
+		last = time.Now()
+		fmt.Fprintf(os.Stderr, "\r%s %d/%d blocks (%d%%)", verb, done, total, done*100/total)
+		if done == total {
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+}
+
+// runExportChain implements the "export-chain" subcommand
+func runExportChain(args []string) int {
+	fs := flag.NewFlagSet("export-chain", flag.ContinueOnError)
+	storage, dbPath, genesisPath, verbose := chainFlags(fs)
+	from := fs.Int64("from", 0, "First block height")
+	to := fs.Int64("to", -1, "Last block height (default: chain tip)")
+	out := fs.String("o", "", "Output file, - for stdout")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, exportChainUsage) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *out == "" {
+		fs.Usage()
+		return 2
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	sm, bm, err := openChain(*storage, *dbPath, *genesisPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer sm.CloseDatabase()
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		file, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+	header, err := bm.ExportChain(w, *from, *to, progressPrinter("exported"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nexport failed: %v\n", err)
+		if *out != "-" {
+			os.Remove(*out)
+		}
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Exported blocks %d-%d to %s\n", header.From, header.To, *out)
+	return 0
+}
+
+// runImportChain implements the "import-chain" subcommand
+func runImportChain(args []string) int {
+	fs := flag.NewFlagSet("import-chain", flag.ContinueOnError)
+	storage, dbPath, genesisPath, verbose := chainFlags(fs)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, importChainUsage) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	var r io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		r = file
+	}
+
+	sm, bm, err := openChain(*storage, *dbPath, *genesisPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer sm.CloseDatabase()
+
+	added, err := bm.ImportChain(r, progressPrinter("imported"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nimport failed after %d new block(s): %v\n", added, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d new block(s); chain tip is now %d\n", added, bm.GetBlockHeight())
+	return 0
+}
@@ -0,0 +1,16 @@
+package main
+
+// subcommand runs a tool command with its arguments and returns the exit code
+type subcommand func(args []string) int
+
+// subcommands maps the first command line argument to a tool command.
+// Anything else starts the node.
+var subcommands = map[string]subcommand{
+	"apikey":       runAPIKey,
+	"asm":          runAsm,
+	"debug":        runDebug,
+	"export-chain": runExportChain,
+	"import-chain": runImportChain,
+	"keystore":     runKeystore,
+	"migrate":      runMigrate,
+}
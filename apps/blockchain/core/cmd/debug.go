@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"atlas-blockchain/pkg/vm"
+	"atlas-blockchain/pkg/vm/asm"
+)
+
+const debugUsage = `Usage:
+  atlas debug -contract <file.asm|file.json> -fn <name> [-args 1,2] [-storage key=1,...] [-gas n]
+      Run a function locally, pausing before every instruction
+  atlas debug -tx <hash> [-api url]
+      Step through the recorded trace of a historical transaction
+
+Commands at the prompt:
+  s, <enter>       step one instruction
+  c                continue to the next breakpoint
+  b [fn:]pc        toggle a breakpoint
+  p                print stack and memory
+  q                quit`
+
+// runDebug implements the "debug" subcommand
+func runDebug(args []string) int {
+	fs := flag.NewFlagSet("debug", flag.ContinueOnError)
+	contractPath := fs.String("contract", "", "Contract source (.asm) or JSON contract")
+	function := fs.String("fn", "", "Function to call")
+	callArgs := fs.String("args", "", "Comma-separated call arguments")
+	storage := fs.String("storage", "", "Comma-separated initial storage (key=value)")
+	gas := fs.Uint64("gas", 1000000, "Gas limit")
+	txHash := fs.String("tx", "", "Hash of a historical transaction to step through")
+	apiURL := fs.String("api", "http://localhost:8080", "Node API base URL")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, debugUsage) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	d := newDebugger(os.Stdin, os.Stdout)
+	var err error
+	switch {
+	case *txHash != "":
+		err = d.replayTransaction(*apiURL, *txHash)
+	case *contractPath != "" && *function != "":
+		err = d.runLocal(*contractPath, *function, *callArgs, *storage, *gas)
+	default:
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// debugger is an interactive vm.Tracer that pauses before each instruction
+type debugger struct {
+	in          *bufio.Scanner
+	out         io.Writer
+	breakpoints map[string]bool
+	running     bool // Continue until the next breakpoint
+	memory      map[string]int64
+}
+
+func newDebugger(in io.Reader, out io.Writer) *debugger {
+	return &debugger{
+		in:          bufio.NewScanner(in),
+		out:         out,
+		breakpoints: make(map[string]bool),
+		memory:      make(map[string]int64),
+	}
+}
+
+// BeforeStep implements vm.Tracer
+func (d *debugger) BeforeStep(step *vm.Step) {
+	d.pause(step)
+}
+
+// AfterStep implements vm.Tracer
+func (d *debugger) AfterStep(step *vm.Step, err error) {
+	d.apply(step)
+	if err != nil {
+		fmt.Fprintf(d.out, "!! %s[%d] %s failed: %v\n", step.Function, step.PC, step.Opcode, err)
+	}
+}
+
+// runLocal executes a contract function in a fresh VM under the debugger
+func (d *debugger) runLocal(path, function, rawArgs, rawStorage string, gas uint64) error {
+	contract, err := loadDebugContract(path)
+	if err != nil {
+		return err
+	}
+	if contract.Storage == nil {
+		contract.Storage = make(map[string]interface{})
+	}
+	for _, kv := range splitList(rawStorage) {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid storage entry '%s', expected key=value", kv)
+		}
+		value, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid storage value for '%s': %v", parts[0], err)
+		}
+		contract.Storage[parts[0]] = value
+	}
+
+	machine := vm.NewVM()
+	machine.RegisterDeployedContract(contract)
+	for k, v := range contract.Storage {
+		if n, ok := v.(int64); ok {
+			machine.Memory[k] = n
+		} else if f, ok := v.(float64); ok {
+			machine.Memory[k] = int64(f)
+		}
+	}
+	for k, v := range machine.Memory {
+		d.memory[k] = v
+	}
+	machine.Tracer = d
+
+	params := make([]interface{}, 0)
+	for _, arg := range splitList(rawArgs) {
+		params = append(params, parseDebugArg(arg))
+	}
+
+	results, err := contract.Call(function, params, machine, vm.NewExecutionContext("debugger", gas))
+	fmt.Fprintf(d.out, "-- finished: gas used %d\n", machine.GetGasUsed())
+	if err != nil {
+		return fmt.Errorf("execution failed: %v", err)
+	}
+	for _, result := range results {
+		fmt.Fprintf(d.out, "   %s (%s) = %v\n", result.Name, result.Type, result.Value)
+	}
+	d.printState(machine.Memory, nil)
+	return nil
+}
+
+// replayTransaction fetches a transaction trace from a node and steps through it
+func (d *debugger) replayTransaction(apiURL, hash string) error {
+	endpoint := strings.TrimRight(apiURL, "/") + "/contract/trace?hash=" + url.QueryEscape(hash)
+	resp, err := http.Get(endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to fetch trace: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to fetch trace: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var trace struct {
+		Contract    string     `json:"contract"`
+		Function    string     `json:"function"`
+		BlockHeight int64      `json:"block_height"`
+		GasUsed     uint64     `json:"gas_used"`
+		Error       string     `json:"error"`
+		Steps       []*vm.Step `json:"steps"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&trace); err != nil {
+		return fmt.Errorf("failed to decode trace: %v", err)
+	}
+
+	fmt.Fprintf(d.out, "-- %s.%s at block %d (%d steps; stacks shown after each instruction)\n",
+		trace.Contract, trace.Function, trace.BlockHeight, len(trace.Steps))
+	for _, step := range trace.Steps {
+		d.pause(step)
+		d.apply(step)
+		if step.Error != "" {
+			fmt.Fprintf(d.out, "!! %s[%d] %s failed: %s\n", step.Function, step.PC, step.Opcode, step.Error)
+		}
+	}
+	fmt.Fprintf(d.out, "-- finished: gas used %d\n", trace.GasUsed)
+	if trace.Error != "" {
+		fmt.Fprintf(d.out, "   error: %s\n", trace.Error)
+	}
+	return nil
+}
+
+// pause shows the step and reads commands until execution should proceed
+func (d *debugger) pause(step *vm.Step) {
+	location := fmt.Sprintf("%s:%d", step.Function, step.PC)
+	if d.running && !d.breakpoints[location] && !d.breakpoints[strconv.Itoa(step.PC)] {
+		return
+	}
+	d.running = false
+	fmt.Fprintf(d.out, "%s%-12s %-8s %-10s gas=%-8d stack=%v\n",
+		strings.Repeat("  ", step.Depth), location, step.Opcode, formatOperands(step.Operands), step.GasRemaining, step.Stack)
+
+	for {
+		fmt.Fprint(d.out, "(debug) ")
+		if !d.in.Scan() {
+			// Input closed: run to completion
+			d.running = true
+			return
+		}
+		cmd := strings.Fields(d.in.Text())
+		if len(cmd) == 0 || cmd[0] == "s" {
+			return
+		}
+		switch cmd[0] {
+		case "c":
+			d.running = true
+			return
+		case "b":
+			if len(cmd) != 2 {
+				fmt.Fprintln(d.out, "usage: b [fn:]pc")
+				continue
+			}
+			d.breakpoints[cmd[1]] = !d.breakpoints[cmd[1]]
+			fmt.Fprintf(d.out, "breakpoint %s: %v\n", cmd[1], d.breakpoints[cmd[1]])
+		case "p":
+			d.printState(d.memory, step.Stack)
+		case "q":
+			os.Exit(0)
+		default:
+			fmt.Fprintln(d.out, "commands: s, c, b [fn:]pc, p, q")
+		}
+	}
+}
+
+// apply records the memory writes of a completed step
+func (d *debugger) apply(step *vm.Step) {
+	for key, change := range step.MemoryDiff {
+		d.memory[key] = change.New
+		old := "<unset>"
+		if change.Old != nil {
+			old = strconv.FormatInt(*change.Old, 10)
+		}
+		fmt.Fprintf(d.out, "   memory %s: %s -> %d\n", key, old, change.New)
+	}
+}
+
+func (d *debugger) printState(memory map[string]int64, stack []int64) {
+	if stack != nil {
+		fmt.Fprintf(d.out, "   stack:  %v\n", stack)
+	}
+	keys := make([]string, 0, len(memory))
+	for k := range memory {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintln(d.out, "   memory:")
+	for _, k := range keys {
+		fmt.Fprintf(d.out, "     %s = %d\n", k, memory[k])
+	}
+}
+
+// loadDebugContract loads an assembly source or JSON contract
+func loadDebugContract(path string) (*vm.Contract, error) {
+	if !strings.HasSuffix(path, ".asm") {
+		return loadJSONContract(path)
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source: %v", err)
+	}
+	prog, err := asm.Assemble(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("%s:\n%v", path, err)
+	}
+	return vm.DeployJSONContract("debugger", prog.JSONContract(), false)
+}
+
+func parseDebugArg(arg string) interface{} {
+	if n, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		return n
+	}
+	if b, err := strconv.ParseBool(arg); err == nil {
+		return b
+	}
+	return arg
+}
+
+func formatOperands(operands []interface{}) string {
+	parts := make([]string, len(operands))
+	for i, operand := range operands {
+		parts[i] = fmt.Sprintf("%v", operand)
+	}
+	return strings.Join(parts, " ")
+}
+
+func splitList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
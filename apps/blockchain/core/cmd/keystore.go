@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"atlas-blockchain/pkg/keystore"
+	"atlas-blockchain/pkg/wallet"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+const keystoreUsage = `Usage:
+  atlas keystore list [-dir keystore]
+      List the stored keys
+  atlas keystore create [-dir keystore] [-type validator|node] [-scheme p256|secp256k1|ed25519]
+      Generate a new key
+  atlas keystore import [-dir keystore] -private-key <file> [-scheme p256|secp256k1|ed25519] | -mnemonic <file> [-path m/44'/1'/0'/0/0] | -node-key <nodekey.priv>
+      Encrypt an existing key: a hex private key as /import-wallet takes it,
+      an HD wallet account, or a libp2p node key file
+  atlas keystore export [-dir keystore] [-o file] <address>
+      Write a decrypted key: hex for validator keys, nodekey.priv format for node keys
+  atlas keystore passwd [-dir keystore] <address>
+      Change the password of a key
+
+The password is read from -password-file, ATLAS_KEYSTORE_PASSWORD or standard
+input. passwd reads the new password from -new-password-file,
+ATLAS_KEYSTORE_NEW_PASSWORD or standard input. A mnemonic passphrase is read
+from ATLAS_MNEMONIC_PASSPHRASE.`
+
+const defaultKeystoreDir = "keystore"
+
+// stdin is shared so that consecutive prompts read consecutive lines
+var stdin = bufio.NewReader(os.Stdin)
+
+// runKeystore implements the "keystore" subcommand
+func runKeystore(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, keystoreUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "list":
+		err = keystoreList(args[1:])
+	case "create":
+		err = keystoreCreate(args[1:])
+	case "import":
+		err = keystoreImport(args[1:])
+	case "export":
+		err = keystoreExport(args[1:])
+	case "passwd":
+		err = keystorePasswd(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, keystoreUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// keystoreFlags adds the flags shared by the keystore commands
+func keystoreFlags(name string) (*flag.FlagSet, *string, *string) {
+	fs := flag.NewFlagSet("keystore "+name, flag.ContinueOnError)
+	dir := fs.String("dir", defaultKeystoreDir, "Keystore directory")
+	passwordFile := fs.String("password-file", "", "File holding the key password")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, keystoreUsage) }
+	return fs, dir, passwordFile
+}
+
+// readPassword reads a password from a file, an environment variable or
+// standard input, in that order
+func readPassword(file, env, prompt string) (string, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password file: %v", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if password, ok := os.LookupEnv(env); ok {
+		return password, nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read password: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// unlockKey decrypts a key of the keystore in dir
+func unlockKey(dir, address, passwordFile string) (*keystore.Key, error) {
+	ks, err := keystore.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	password, err := readPassword(passwordFile, "ATLAS_KEYSTORE_PASSWORD", fmt.Sprintf("Password for %s: ", address))
+	if err != nil {
+		return nil, err
+	}
+	key, err := ks.Load(address, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock %s: %v", address, err)
+	}
+	return key, nil
+}
+
+// storeKey encrypts a new key with a password read as readPassword does
+func storeKey(dir, passwordFile string, key *keystore.Key) error {
+	ks, err := keystore.Open(dir)
+	if err != nil {
+		return err
+	}
+	password, err := readPassword(passwordFile, "ATLAS_KEYSTORE_PASSWORD", "New password: ")
+	if err != nil {
+		return err
+	}
+	if password == "" {
+		return fmt.Errorf("the password cannot be empty")
+	}
+	path, err := ks.Store(key, password)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Stored %s key %s in %s\n", key.Type, key.Address, path)
+	return nil
+}
+
+func keystoreList(args []string) error {
+	fs, dir, _ := keystoreFlags("list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ks, err := keystore.Open(*dir)
+	if err != nil {
+		return err
+	}
+	entries, err := ks.List()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No keys in %s\n", *dir)
+	}
+	for _, e := range entries {
+		scheme := e.Scheme
+		if scheme == "" && e.Type == keystore.TypeValidator {
+			scheme = "p256"
+		}
+		fmt.Printf("%-10s %-9s %s  %s\n", e.Type, scheme, e.Address, e.Path)
+	}
+	return nil
+}
+
+func keystoreCreate(args []string) error {
+	fs, dir, passwordFile := keystoreFlags("create")
+	keyType := fs.String("type", keystore.TypeValidator, "Key type: validator or node")
+	scheme := fs.String("scheme", "p256", "Signature scheme of a validator key: p256, secp256k1 or ed25519")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var key *keystore.Key
+	switch *keyType {
+	case keystore.TypeValidator:
+		w, err := wallet.NewWalletWithScheme(*scheme)
+		if err != nil {
+			return err
+		}
+		if key, err = keystore.NewValidatorKey(w); err != nil {
+			return err
+		}
+	case keystore.TypeNode:
+		privKey, _, err := crypto.GenerateKeyPair(crypto.Ed25519, -1)
+		if err != nil {
+			return fmt.Errorf("failed to generate node key: %v", err)
+		}
+		data, err := crypto.MarshalPrivateKey(privKey)
+		if err != nil {
+			return fmt.Errorf("failed to encode node key: %v", err)
+		}
+		if key, err = keystore.NewNodeKey(data); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown key type %q", *keyType)
+	}
+	return storeKey(*dir, *passwordFile, key)
+}
+
+func keystoreImport(args []string) error {
+	fs, dir, passwordFile := keystoreFlags("import")
+	privateKeyFile := fs.String("private-key", "", "File holding a hex private key")
+	mnemonicFile := fs.String("mnemonic", "", "File holding a BIP-39 mnemonic")
+	path := fs.String("path", wallet.DefaultDerivationPath, "Derivation path of the account to import from the mnemonic")
+	nodeKeyFile := fs.String("node-key", "", "libp2p node key file, such as nodekey.priv")
+	scheme := fs.String("scheme", "p256", "Signature scheme of the private key: p256, secp256k1 or ed25519")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var key *keystore.Key
+	switch {
+	case *privateKeyFile != "" && *mnemonicFile == "" && *nodeKeyFile == "":
+		data, err := os.ReadFile(*privateKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read private key: %v", err)
+		}
+		w, err := wallet.ImportWalletWithScheme(*scheme, strings.TrimSpace(string(data)))
+		if err != nil {
+			return err
+		}
+		if key, err = keystore.NewValidatorKey(w); err != nil {
+			return err
+		}
+	case *mnemonicFile != "" && *privateKeyFile == "" && *nodeKeyFile == "":
+		data, err := os.ReadFile(*mnemonicFile)
+		if err != nil {
+			return fmt.Errorf("failed to read mnemonic: %v", err)
+		}
+		w, err := wallet.NewHDWallet(string(data), os.Getenv("ATLAS_MNEMONIC_PASSPHRASE"), *path)
+		if err != nil {
+			return err
+		}
+		if key, err = keystore.NewValidatorKey(w); err != nil {
+			return err
+		}
+	case *nodeKeyFile != "" && *privateKeyFile == "" && *mnemonicFile == "":
+		data, err := os.ReadFile(*nodeKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read node key: %v", err)
+		}
+		if key, err = keystore.NewNodeKey(data); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("give exactly one of -private-key, -mnemonic and -node-key\n%s", keystoreUsage)
+	}
+	return storeKey(*dir, *passwordFile, key)
+}
+
+func keystoreExport(args []string) error {
+	fs, dir, passwordFile := keystoreFlags("export")
+	out := fs.String("o", "", "Output file (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected one address\n%s", keystoreUsage)
+	}
+
+	key, err := unlockKey(*dir, fs.Arg(0), *passwordFile)
+	if err != nil {
+		return err
+	}
+	data := key.PrivateKey
+	if key.Type == keystore.TypeValidator {
+		data = []byte(hex.EncodeToString(key.PrivateKey) + "\n")
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0600); err != nil {
+		return fmt.Errorf("failed to write key: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %s key %s to %s\n", key.Type, key.Address, *out)
+	return nil
+}
+
+func keystorePasswd(args []string) error {
+	fs, dir, passwordFile := keystoreFlags("passwd")
+	newPasswordFile := fs.String("new-password-file", "", "File holding the new password")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected one address\n%s", keystoreUsage)
+	}
+	address := fs.Arg(0)
+
+	ks, err := keystore.Open(*dir)
+	if err != nil {
+		return err
+	}
+	oldPassword, err := readPassword(*passwordFile, "ATLAS_KEYSTORE_PASSWORD", fmt.Sprintf("Current password for %s: ", address))
+	if err != nil {
+		return err
+	}
+	newPassword, err := readPassword(*newPasswordFile, "ATLAS_KEYSTORE_NEW_PASSWORD", "New password: ")
+	if err != nil {
+		return err
+	}
+	if newPassword == "" {
+		return fmt.Errorf("the password cannot be empty")
+	}
+	if err := ks.ChangePassword(address, oldPassword, newPassword); err != nil {
+		return fmt.Errorf("failed to change the password of %s: %v", address, err)
+	}
+	fmt.Printf("Changed the password of %s\n", address)
+	return nil
+}
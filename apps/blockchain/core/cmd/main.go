@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 	"atlas-blockchain/pkg/wallet"
@@ -21,6 +23,7 @@ import (
 	"atlas-blockchain/internal/governance"
 	"atlas-blockchain/internal/api"
 	"encoding/json"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
@@ -37,6 +40,7 @@ var (
 	socialManager      *social.SocialManager
 	governanceManager  *governance.GovernanceManager
 	validatorMode      *bool
+	unlockedWallet     *wallet.Wallet // validator wallet unlocked from the keystore
 	p2pNode            *network.P2PNode // Add P2P node
 	isTestMode         bool             // Flag to indicate if we're running in test mode
 )
@@ -47,6 +51,13 @@ func SetTestMode(enabled bool) {
 }
 
 func main() {
+	// Tool subcommands (e.g. "asm") run instead of the node
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
+		}
+	}
+
 	// Parse command line flags early
 	port := flag.Int("port", 8000, "Port for peer discovery")
 	maxPeers := flag.Int("peers", 10, "Maximum number of peers")
@@ -55,6 +66,19 @@ func main() {
 	keyPath := flag.String("key", "nodekey.priv", "Path to private key file for libp2p identity")
 	legacyNetworking := flag.Bool("legacy-net", false, "Enable legacy TCP networking") // NEW FLAG
 	testMode := flag.Bool("test", false, "Run in test mode (disable infinite loops)")
+	storageBackend := flag.String("storage", "sqlite", "Storage backend: sqlite or kv (pure Go)")
+	dbPath := flag.String("db", "", "Database path (default blockchain.db, or blockchain.kv for the kv backend)")
+	pruningMode := flag.String("pruning", "full", "Pruning mode: archive, full or pruned")
+	stateRetention := flag.Int64("state-retention", 128, "Recent heights whose state is kept in full and pruned modes")
+	blockRetention := flag.Int64("block-retention", 1000, "Recent blocks kept in pruned mode")
+	migrateDryRun := flag.Bool("migrate-dry-run", false, "Show the schema migrations startup would apply, then exit")
+	backupDest := flag.String("backup-dest", "", "Comma-separated backup destinations: directories, sftp:// or s3:// URLs")
+	genesisPath := flag.String("genesis", "", "Genesis file (JSON or YAML) defining the network (default: built-in devnet)")
+	keystoreDir := flag.String("keystore", defaultKeystoreDir, "Keystore directory for -unlock and -unlock-node")
+	unlockAddress := flag.String("unlock", "", "Validator address to unlock from the keystore (implies -validator)")
+	unlockNode := flag.String("unlock-node", "", "Peer ID of a node key to unlock from the keystore instead of reading -key")
+	passwordFile := flag.String("password-file", "", "File holding the keystore password (default: ATLAS_KEYSTORE_PASSWORD, or a prompt)")
+	accessPath := flag.String("access", "", "Access file with API keys and wallet roles for the API (see atlas apikey)")
 	flag.Parse()
 	
 	// Set test mode flag
@@ -63,6 +87,37 @@ func main() {
 	blockchainConfig = config.DefaultConfig()
 	blockchainConfig.PeerDiscoveryPort = *port
 	blockchainConfig.MaxPeers = *maxPeers
+	blockchainConfig.StorageBackend = *storageBackend
+	if *dbPath != "" {
+		blockchainConfig.DatabasePath = *dbPath
+	} else if *storageBackend == "kv" {
+		blockchainConfig.DatabasePath = "blockchain.kv"
+	}
+	blockchainConfig.PruningMode = *pruningMode
+	blockchainConfig.StateRetention = *stateRetention
+	blockchainConfig.BlockRetention = *blockRetention
+	for _, dest := range strings.Split(*backupDest, ",") {
+		if dest = strings.TrimSpace(dest); dest != "" {
+			blockchainConfig.BackupDestinations = append(blockchainConfig.BackupDestinations, dest)
+		}
+	}
+	blockchainConfig.BackupPassword = os.Getenv("ATLAS_BACKUP_PASSWORD")
+	chainGenesis, err := loadGenesis(*genesisPath, blockchainConfig)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if err := blockchainConfig.Validate(); err != nil {
+		log.Fatalf("❌ Invalid configuration: %v", err)
+	}
+
+	// Schema migrations run when the database opens; a dry run only reports them
+	if *migrateDryRun {
+		if blockchainConfig.StorageBackend == "kv" {
+			fmt.Println("The kv backend has no schema to migrate")
+			os.Exit(0)
+		}
+		os.Exit(runMigrate([]string{"-db", blockchainConfig.DatabasePath, "-dry-run"}))
+	}
 
 	stateManager = blockchain.NewStateManager(blockchainConfig)
 	
@@ -72,7 +127,7 @@ func main() {
 	}
 	
 	transactionManager = blockchain.NewTransactionManager(blockchainConfig, stateManager)
-	blockManager = blockchain.NewBlockManager(blockchainConfig, stateManager)
+	blockManager = blockchain.NewBlockManagerWithGenesis(blockchainConfig, stateManager, chainGenesis)
 	consensusManager = blockchain.NewConsensusManager(blockchainConfig, blockManager)
 	stateManager.SetConsensusManager(consensusManager)
 	
@@ -88,11 +143,43 @@ func main() {
 	// Initialize governance manager for proposals, voting, and community governance
 	governanceManager = governance.NewGovernanceManager(socialManager, defiManager, identityManager)
 
+	// Keys are unlocked from the keystore before the node starts
+	if *unlockAddress != "" {
+		key, err := unlockKey(*keystoreDir, *unlockAddress, *passwordFile)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if unlockedWallet, err = key.Wallet(); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		*validatorMode = true
+		log.Printf("🔓 Unlocked validator key %s", key.Address)
+	}
+	var nodeKey crypto.PrivKey
+	if *unlockNode != "" {
+		key, err := unlockKey(*keystoreDir, *unlockNode, *passwordFile)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if nodeKey, err = key.NodeKey(); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Printf("🔓 Unlocked node key %s", key.Address)
+	}
+
 	ctx := context.Background()
-	p2pNode, err := network.NewP2PNode(ctx, blockchainConfig.PeerDiscoveryPort, *keyPath)
+	var p2pNode *network.P2PNode
+	if nodeKey != nil {
+		p2pNode, err = network.NewP2PNodeWithKey(ctx, blockchainConfig.PeerDiscoveryPort, nodeKey)
+	} else {
+		p2pNode, err = network.NewP2PNode(ctx, blockchainConfig.PeerDiscoveryPort, *keyPath)
+	}
 	if err != nil {
 		log.Fatalf("Failed to start P2P node: %v", err)
 	}
+	// Peers started from another genesis are refused
+	p2pNode.SetHandshake(network.Handshake{ChainID: chainGenesis.ChainID, GenesisHash: blockManager.GenesisHash()})
+	log.Printf("🌐 Network %s, genesis %s", chainGenesis.ChainID, blockManager.GenesisHash())
 
 	// Initialize chain synchronization manager
 	chainSyncManager = blockchain.NewChainSyncManager(blockManager, stateManager, p2pNode, blockchainConfig)
@@ -256,6 +343,11 @@ func main() {
 
 	// Start API server
 	apiServer := api.NewAPIServer(blockManager, transactionManager, stateManager, consensusManager, node, identityManager, socialManager, governanceManager)
+	authenticator, err := loadAuthenticator(*accessPath, blockchainConfig.ChainID)
+	if err != nil {
+		log.Fatalf("❌ Failed to set up API authentication: %v", err)
+	}
+	apiServer.SetAuthenticator(authenticator)
 	
 	// TODO: Set up monitoring integration callbacks when monitor field is exported
 	// Set up monitoring integration callbacks
@@ -320,9 +412,26 @@ func main() {
 	
 	// Start backup system
 	if stateManager != nil {
+		var signingKey ed25519.PrivateKey
+		if nodeKey != nil {
+			signingKey, err = network.SigningKey(nodeKey)
+		} else {
+			signingKey, err = network.NodeSigningKey(*keyPath)
+		}
+		if err != nil {
+			log.Fatalf("❌ Failed to load node key for backups: %v", err)
+		}
+		if err := stateManager.ConfigureBackups(signingKey); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
 		stateManager.StartBackupSystem()
 	}
 
+	// Start database garbage collection for the pruning mode
+	if stateManager != nil {
+		stateManager.StartPruning()
+	}
+
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -331,10 +440,13 @@ func main() {
 }
 
 func initializeNode() error {
-	// Initialize wallet for the node
-	walletObj, err := wallet.NewWallet()
-	if err != nil {
-		return fmt.Errorf("failed to create wallet: %v", err)
+	// Initialize wallet for the node, unless one was unlocked from the keystore
+	walletObj := unlockedWallet
+	if walletObj == nil {
+		var err error
+		if walletObj, err = wallet.NewWallet(); err != nil {
+			return fmt.Errorf("failed to create wallet: %v", err)
+		}
 	}
 
 	// Add initial balance to the node's wallet
@@ -540,6 +652,9 @@ func shutdown() {
 		stateManager.StopBackupSystem()
 		log.Println("Backup system stopped")
 	}
+	if stateManager != nil {
+		stateManager.StopPruning()
+	}
 	
 	// Close database connection
 	if err := stateManager.CloseDatabase(); err != nil {
@@ -623,12 +738,14 @@ func DebugTxFlow() {
 	fmt.Println("[DEBUG] Creating transaction...")
 	tx := transaction.Transaction{
 		Sender:    walletA.PublicKeyStr(),
+		SenderPublicKey: walletA.PublicKeyStr(),
 		Recipient: walletB.PublicKeyStr(),
 		Amount:    100,
 		Fee:       1,
 		Timestamp: 0,
 		Nonce:     0,
 		Data:      "test transfer",
+		ChainID:   blockchainConfig.ChainID,
 	}
 	fmt.Println("[DEBUG] Signing transaction...")
 	err = walletA.SignTransaction(&tx)
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"atlas-blockchain/pkg/database"
+)
+
+const migrateUsage = `Usage:
+  atlas migrate [-db blockchain.db] [-dry-run] [-to version]
+      Apply pending schema migrations to a SQLite database. With -dry-run
+      the migrations are tried in a transaction that is rolled back.
+  atlas migrate -list
+      List all schema migrations`
+
+// runMigrate implements the "migrate" subcommand
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dbPath := fs.String("db", "blockchain.db", "SQLite database path")
+	dryRun := fs.Bool("dry-run", false, "Show pending migrations without applying them")
+	target := fs.Int("to", 0, "Migrate up to this version (default: latest)")
+	list := fs.Bool("list", false, "List all schema migrations")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, migrateUsage) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *list {
+		for _, m := range database.Migrations() {
+			fmt.Printf("%3d  %s\n", m.Version, m.Description)
+		}
+		return 0
+	}
+
+	from, migrations, err := database.MigrateFile(*dbPath, database.MigrateOptions{DryRun: *dryRun, Target: *target})
+	for _, m := range migrations {
+		if *dryRun {
+			fmt.Printf("pending  %3d  %s\n", m.Version, m.Description)
+		} else {
+			fmt.Printf("applied  %3d  %s\n", m.Version, m.Description)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	to := from
+	if len(migrations) > 0 {
+		to = migrations[len(migrations)-1].Version
+	}
+	switch {
+	case len(migrations) == 0:
+		fmt.Printf("%s is up to date at schema version %d\n", *dbPath, from)
+	case *dryRun:
+		fmt.Printf("%s would be migrated from version %d to %d (dry run, nothing changed)\n", *dbPath, from, to)
+	default:
+		fmt.Printf("%s migrated from version %d to %d\n", *dbPath, from, to)
+	}
+	return 0
+}
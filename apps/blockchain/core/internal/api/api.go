@@ -3,13 +3,14 @@ package api
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os/exec"
 	"time"
 	"bytes"
-	"strings"
 	"atlas-blockchain/pkg/wallet"
 	"atlas-blockchain/pkg/transaction"
 	"atlas-blockchain/pkg/crypto"
@@ -20,6 +21,9 @@ import (
 	"atlas-blockchain/internal/social"
 	"atlas-blockchain/internal/governance"
 	"atlas-blockchain/pkg/network"
+	"atlas-blockchain/pkg/rpc"
+	"atlas-blockchain/pkg/auth"
+	"atlas-blockchain/pkg/ratelimit"
 )
 
 // API server struct
@@ -34,6 +38,11 @@ type APIServer struct {
 	identityManager   *identity.IdentityManager
 	socialManager     *social.SocialManager
 	governanceManager *governance.GovernanceManager
+	multisigPool      *blockchain.MultisigPool
+	rpcServer         *rpc.Server
+	hub               *rpc.Hub
+	authenticator     *auth.Authenticator
+	limiter           *ratelimit.Limiter
 }
 
 func NewAPIServer(bm *blockchain.BlockManager, tm *blockchain.TransactionManager, sm *blockchain.StateManager, cm *blockchain.ConsensusManager, node *network.Node, im *identity.IdentityManager, socialMgr *social.SocialManager, govMgr *governance.GovernanceManager) *APIServer {
@@ -50,7 +59,12 @@ func NewAPIServer(bm *blockchain.BlockManager, tm *blockchain.TransactionManager
 		identityManager:   im,
 		socialManager:     socialMgr,
 		governanceManager: govMgr,
+		multisigPool:      blockchain.NewMultisigPool(sm),
 	}
+	api.rpcServer = api.newRPCServer()
+	api.hub = api.newSubscriptionHub()
+	api.authenticator = newAuthenticator(bm.ChainID())
+	api.SetLimiter(ratelimit.NewLimiter(RateLimitClasses()...))
 	
 	// Start monitoring
 	if monitor != nil {
@@ -64,7 +78,7 @@ func withCORS(handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Requested-With")
 		w.Header().Set("Access-Control-Max-Age", "86400")
 		
 		if r.Method == "OPTIONS" {
@@ -76,69 +90,90 @@ func withCORS(handler http.HandlerFunc) http.HandlerFunc {
 }
 
 func (api *APIServer) Start(addr string) {
-	http.HandleFunc("/block", withCORS(api.handleGetBlock))
-	http.HandleFunc("/blocks", withCORS(api.handleListBlocks))
-	http.HandleFunc("/transaction", withCORS(api.handleGetTransaction))
-	http.HandleFunc("/mempool", withCORS(api.handleGetMempool))
-	http.HandleFunc("/submit-transaction", withCORS(api.handleSubmitTransaction))
-	http.HandleFunc("/balance", withCORS(api.handleGetBalance))
-	http.HandleFunc("/status", withCORS(api.handleGetStatus))
-	http.HandleFunc("/validators", withCORS(api.handleListValidators))
-	http.HandleFunc("/validator", withCORS(api.handleGetValidator))
-	http.HandleFunc("/register-validator", withCORS(api.handleRegisterValidator))
-	http.HandleFunc("/update-stake", withCORS(api.handleUpdateStake))
-	http.HandleFunc("/update-user-stake", withCORS(api.handleUpdateUserStake))
-	http.HandleFunc("/node-address", withCORS(api.handleGetNodeAddress))
-	http.HandleFunc("/peers", withCORS(api.handleGetPeers))
-	http.HandleFunc("/faucet", withCORS(api.handleFaucet))
-	http.HandleFunc("/nonce", withCORS(api.handleGetNonce))
-	http.HandleFunc("/run-tests", withCORS(api.handleRunTests))
-	http.HandleFunc("/test-performance", withCORS(api.handleTestPerformance))
-	http.HandleFunc("/test-security", withCORS(api.handleTestSecurity))
-	http.HandleFunc("/test-integration", withCORS(api.handleTestIntegration))
-	http.HandleFunc("/start-test-env", withCORS(api.handleStartTestEnv))
-	http.HandleFunc("/stop-test-env", withCORS(api.handleStopTestEnv))
-	http.HandleFunc("/test-env-status", withCORS(api.handleTestEnvStatus))
-	http.HandleFunc("/create-wallet", withCORS(api.handleCreateWallet))
-	http.HandleFunc("/import-wallet", withCORS(api.handleImportWallet))
-	http.HandleFunc("/fee-info", withCORS(api.handleFeeInfo))
+	// Authentication endpoints
+	api.handle("/auth/challenge", auth.Public, api.handleAuthChallenge)
+	api.handle("/auth/login", auth.Public, api.handleAuthLogin)
+	api.handle("/auth/logout", auth.User, api.handleAuthLogout)
+	api.handle("/auth/whoami", auth.Public, api.handleAuthWhoami)
+	if !api.authenticator.HasKeys() {
+		log.Printf("⚠️ No API keys configured: create them with \"atlas apikey\" and start the node with -access")
+	}
+
+	api.handle("/block", auth.Public, api.handleGetBlock)
+	api.handle("/blocks", auth.Public, api.handleListBlocks)
+	api.handle("/transaction", auth.Public, api.handleGetTransaction)
+	api.handle("/mempool", auth.Public, api.handleGetMempool)
+	api.handle("/submit-transaction", auth.Public, api.handleSubmitTransaction)
+	api.handle("/balance", auth.Public, api.handleGetBalance)
+	api.handle("/status", auth.Public, api.handleGetStatus)
+	api.handle("/validators", auth.Public, api.handleListValidators)
+	api.handle("/validator", auth.Public, api.handleGetValidator)
+	api.handle("/register-validator", auth.Operator(ScopeValidators), api.handleRegisterValidator)
+	api.handle("/update-stake", auth.Operator(ScopeValidators), api.handleUpdateStake)
+	api.handle("/update-user-stake", auth.User, api.handleUpdateUserStake)
+	api.handle("/node-address", auth.Public, api.handleGetNodeAddress)
+	api.handle("/peers", auth.Public, api.handleGetPeers)
+	api.handle("/faucet", auth.Operator(ScopeFaucet), api.handleFaucet)
+	api.handle("/nonce", auth.Public, api.handleGetNonce)
+	api.handle("/run-tests", auth.Operator(ScopeTesting), api.handleRunTests)
+	api.handle("/test-performance", auth.Operator(ScopeTesting), api.handleTestPerformance)
+	api.handle("/test-security", auth.Operator(ScopeTesting), api.handleTestSecurity)
+	api.handle("/test-integration", auth.Operator(ScopeTesting), api.handleTestIntegration)
+	api.handle("/start-test-env", auth.Operator(ScopeTesting), api.handleStartTestEnv)
+	api.handle("/stop-test-env", auth.Operator(ScopeTesting), api.handleStopTestEnv)
+	api.handle("/test-env-status", auth.Public, api.handleTestEnvStatus)
+	api.handle("/create-wallet", auth.Admin, api.handleCreateWallet)
+	api.handle("/import-wallet", auth.Admin, api.handleImportWallet)
+	api.handle("/fee-info", auth.Public, api.handleFeeInfo)
+
+	// Multisig accounts and signature collection
+	api.handle("/multisig/account", auth.Public, api.handleGetMultisigAccount)
+	api.handle("/multisig/propose", auth.Public, api.handleProposeMultisig)
+	api.handle("/multisig/sign", auth.Public, api.handleSignMultisig)
+	api.handle("/multisig/pending", auth.Public, api.handleListPendingMultisig)
+
+	// Versioned JSON-RPC 2.0 API; GET returns its OpenRPC schema
+	api.handle(RPCPath, auth.Public, api.rpcServer.ServeHTTP)
+	// The same API over WebSocket, with chain_subscribe for chain events
+	api.startSubscriptions()
+	api.handle(WebSocketPath, auth.Public, api.hub.ServeHTTP)
 	
 	// Identity management endpoints for social-commerce-governance platform
-	http.HandleFunc("/identity/create", withCORS(api.handleCreateIdentity))
-	http.HandleFunc("/identity/get", withCORS(api.handleGetIdentity))
-	http.HandleFunc("/identity/update-profile", withCORS(api.handleUpdateProfile))
+	api.handle("/identity/create", auth.User, api.handleCreateIdentity)
+	api.handle("/identity/get", auth.Public, api.handleGetIdentity)
+	api.handle("/identity/update-profile", auth.User, api.handleUpdateProfile)
 	// Identity endpoints commented out - handlers not implemented yet
 	// http.HandleFunc("/identity/add-credential", withCORS(api.handleAddCredential))
 	// http.HandleFunc("/identity/add-attestation", withCORS(api.handleAddAttestation))
 	// http.HandleFunc("/identity/update-privacy", withCORS(api.handleUpdatePrivacy))
 	// http.HandleFunc("/identity/update-kyc", withCORS(api.handleUpdateKYC))
-	http.HandleFunc("/identity/update-activity", withCORS(api.handleUpdateActivity))
-	http.HandleFunc("/identity/create-proof", withCORS(api.handleCreatePrivacyProof))
-	http.HandleFunc("/identity/verify-proof", withCORS(api.handleVerifyPrivacyProof))
-	http.HandleFunc("/identity/social", withCORS(api.handleGetIdentityForSocial))
-	http.HandleFunc("/identity/commerce", withCORS(api.handleGetIdentityForCommerce))
-	http.HandleFunc("/identity/governance", withCORS(api.handleGetIdentityForGovernance))
+	api.handle("/identity/update-activity", auth.Operator(ScopeIdentity), api.handleUpdateActivity)
+	api.handle("/identity/create-proof", auth.User, api.handleCreatePrivacyProof)
+	api.handle("/identity/verify-proof", auth.Public, api.handleVerifyPrivacyProof)
+	api.handle("/identity/social", auth.Public, api.handleGetIdentityForSocial)
+	api.handle("/identity/commerce", auth.Public, api.handleGetIdentityForCommerce)
+	api.handle("/identity/governance", auth.Public, api.handleGetIdentityForGovernance)
 	
 	// FlutterFlow Integration Endpoints
-	http.HandleFunc("/flutterflow/connect-wallet", withCORS(api.handleFlutterFlowConnectWallet))
-	http.HandleFunc("/flutterflow/authenticate", withCORS(api.handleFlutterFlowAuthenticate))
-	http.HandleFunc("/flutterflow/wallet-info", withCORS(api.handleFlutterFlowWalletInfo))
-	http.HandleFunc("/flutterflow/send-transaction", withCORS(api.handleFlutterFlowSendTransaction))
-	http.HandleFunc("/flutterflow/transaction-history", withCORS(api.handleFlutterFlowTransactionHistory))
-	http.HandleFunc("/flutterflow/disconnect", withCORS(api.handleFlutterFlowDisconnect))
+	api.handle("/flutterflow/connect-wallet", auth.Public, api.handleFlutterFlowConnectWallet)
+	api.handle("/flutterflow/authenticate", auth.Public, api.handleFlutterFlowAuthenticate)
+	api.handle("/flutterflow/wallet-info", auth.Public, api.handleFlutterFlowWalletInfo)
+	api.handle("/flutterflow/send-transaction", auth.Public, api.handleFlutterFlowSendTransaction)
+	api.handle("/flutterflow/transaction-history", auth.Public, api.handleFlutterFlowTransactionHistory)
+	api.handle("/flutterflow/disconnect", auth.Public, api.handleFlutterFlowDisconnect)
 	
 	// Governance endpoints
-	http.HandleFunc("/governance/proposals", withCORS(api.handleListProposals))
-	http.HandleFunc("/governance/proposal", withCORS(api.handleGetProposal))
-	http.HandleFunc("/governance/submit-proposal", withCORS(api.handleSubmitProposal))
-	http.HandleFunc("/governance/vote", withCORS(api.handleVote))
+	api.handle("/governance/proposals", auth.Public, api.handleListProposals)
+	api.handle("/governance/proposal", auth.Public, api.handleGetProposal)
+	api.handle("/governance/submit-proposal", auth.User, api.handleSubmitProposal)
+	api.handle("/governance/vote", auth.User, api.handleVote)
 
-	http.HandleFunc("/oracle/submit", withCORS(api.handleOracleSubmit))
-	http.HandleFunc("/oracle/latest", withCORS(api.handleOracleLatest))
+	api.handle("/oracle/submit", auth.Operator(ScopeOracle), api.handleOracleSubmit)
+	api.handle("/oracle/latest", auth.Public, api.handleOracleLatest)
 	
 	// Privacy endpoints
-	http.HandleFunc("/privacy/encrypt", withCORS(api.handleEncryptData))
-	http.HandleFunc("/privacy/decrypt", withCORS(api.handleDecryptData))
+	api.handle("/privacy/encrypt", auth.User, api.handleEncryptData)
+	api.handle("/privacy/decrypt", auth.User, api.handleDecryptData)
 	// ZK-related endpoints are disabled due to ZK code being commented out
 	/*
 	func (api *APIServer) handleCreateProof(w http.ResponseWriter, r *http.Request) {
@@ -149,74 +184,91 @@ func (api *APIServer) Start(addr string) {
 		// Disabled: ZK proof verification endpoint
 	}
 	*/
-	http.HandleFunc("/privacy/gdpr-delete", withCORS(api.handleGDPRDelete))
-	http.HandleFunc("/privacy/gdpr-anonymize", withCORS(api.handleGDPRAnonymize))
+	api.handle("/privacy/gdpr-delete", auth.User, api.handleGDPRDelete)
+	api.handle("/privacy/gdpr-anonymize", auth.User, api.handleGDPRAnonymize)
 	
 	// Sharding endpoints
-	http.HandleFunc("/sharding/status", withCORS(api.handleShardingStatus))
-	http.HandleFunc("/sharding/shard", withCORS(api.handleGetShard))
-	http.HandleFunc("/sharding/assign-validator", withCORS(api.handleAssignValidator))
-	http.HandleFunc("/sharding/cross-shard-tx", withCORS(api.handleCrossShardTransaction))
-	http.HandleFunc("/sharding/statistics", withCORS(api.handleShardingStatistics))
+	api.handle("/sharding/status", auth.Public, api.handleShardingStatus)
+	api.handle("/sharding/shard", auth.Public, api.handleGetShard)
+	api.handle("/sharding/assign-validator", auth.Operator(ScopeSharding), api.handleAssignValidator)
+	api.handle("/sharding/cross-shard-tx", auth.Operator(ScopeSharding), api.handleCrossShardTransaction)
+	api.handle("/sharding/statistics", auth.Public, api.handleShardingStatistics)
 	
 	// Monitoring endpoints
-	http.HandleFunc("/monitoring/status", withCORS(api.handleMonitoringStatus))
-	http.HandleFunc("/monitoring/metrics", withCORS(api.handleMonitoringMetrics))
-	http.HandleFunc("/monitoring/health", withCORS(api.handleMonitoringHealth))
-	http.HandleFunc("/monitoring/alerts", withCORS(api.handleMonitoringAlerts))
-	http.HandleFunc("/monitoring/performance", withCORS(api.handleMonitoringPerformance))
-	http.HandleFunc("/monitoring/history", withCORS(api.handleMonitoringHistory))
-	http.HandleFunc("/monitoring/trends", withCORS(api.handleMonitoringTrends))
+	api.handle("/monitoring/status", auth.Public, api.handleMonitoringStatus)
+	api.handle("/monitoring/metrics", auth.Public, api.handleMonitoringMetrics)
+	api.handle("/monitoring/health", auth.Public, api.handleMonitoringHealth)
+	api.handle("/monitoring/alerts", auth.Public, api.handleMonitoringAlerts)
+	api.handle("/monitoring/performance", auth.Public, api.handleMonitoringPerformance)
+	api.handle("/monitoring/history", auth.Public, api.handleMonitoringHistory)
+	api.handle("/monitoring/trends", auth.Public, api.handleMonitoringTrends)
 	
 	// Chain synchronization endpoints
-	http.HandleFunc("/sync/status", withCORS(api.handleSyncStatus))
-	http.HandleFunc("/sync/start", withCORS(api.handleSyncStart))
+	api.handle("/sync/status", auth.Public, api.handleSyncStatus)
+	api.handle("/sync/start", auth.Operator(ScopeSync), api.handleSyncStart)
 	
 	// Database backup and recovery endpoints
-	http.HandleFunc("/backup/status", withCORS(api.handleBackupStatus))
-	http.HandleFunc("/backup/list", withCORS(api.handleBackupList))
-	http.HandleFunc("/backup/create", withCORS(api.handleBackupCreate))
+	api.handle("/backup/status", auth.Public, api.handleBackupStatus)
+	api.handle("/backup/list", auth.Operator(ScopeBackup), api.handleBackupList)
+	api.handle("/backup/create", auth.Operator(ScopeBackup), api.handleBackupCreate)
 	
 	// Social Media API endpoints
-	http.HandleFunc("/social/post/create", withCORS(api.handleCreatePost))
-	http.HandleFunc("/social/post/get", withCORS(api.handleGetPost))
-	http.HandleFunc("/social/comment/create", withCORS(api.handleCreateComment))
-	http.HandleFunc("/social/like", withCORS(api.handleLikePost))
-	http.HandleFunc("/social/unlike", withCORS(api.handleUnlikePost))
-	http.HandleFunc("/social/feed", withCORS(api.handleGetFeed))
-	http.HandleFunc("/social/search", withCORS(api.handleSearchPosts))
-	http.HandleFunc("/social/trending", withCORS(api.handleGetTrendingHashtags))
-	http.HandleFunc("/social/report", withCORS(api.handleReportContent))
+	api.handle("/social/post/create", auth.User, api.handleCreatePost)
+	api.handle("/social/post/get", auth.Public, api.handleGetPost)
+	api.handle("/social/comment/create", auth.User, api.handleCreateComment)
+	api.handle("/social/like", auth.User, api.handleLikePost)
+	api.handle("/social/unlike", auth.User, api.handleUnlikePost)
+	api.handle("/social/feed", auth.Public, api.handleGetFeed)
+	api.handle("/social/search", auth.Public, api.handleSearchPosts)
+	api.handle("/social/trending", auth.Public, api.handleGetTrendingHashtags)
+	api.handle("/social/report", auth.User, api.handleReportContent)
 	
 	// Enhanced Governance API endpoints
-	http.HandleFunc("/governance/proposal/create", withCORS(api.handleCreateProposal))
-	http.HandleFunc("/governance/proposal/get", withCORS(api.handleGetProposal))
-	http.HandleFunc("/governance/proposal/activate", withCORS(api.handleActivateProposal))
-	http.HandleFunc("/governance/proposal/vote", withCORS(api.handleVoteProposal))
-	http.HandleFunc("/governance/proposal/execute", withCORS(api.handleExecuteProposal))
-	http.HandleFunc("/governance/proposal/discuss", withCORS(api.handleAddDiscussionComment))
-	http.HandleFunc("/governance/proposals/active", withCORS(api.handleGetActiveProposals))
-	http.HandleFunc("/governance/proposals/category", withCORS(api.handleGetProposalsByCategory))
-	http.HandleFunc("/governance/referendum/create", withCORS(api.handleCreateReferendum))
-	http.HandleFunc("/governance/referendum/vote", withCORS(api.handleVoteReferendum))
+	api.handle("/governance/proposal/create", auth.User, api.handleCreateProposal)
+	api.handle("/governance/proposal/get", auth.Public, api.handleGetProposal)
+	api.handle("/governance/proposal/activate", auth.Operator(ScopeGovernance), api.handleActivateProposal)
+	api.handle("/governance/proposal/vote", auth.User, api.handleVoteProposal)
+	api.handle("/governance/proposal/execute", auth.Operator(ScopeGovernance), api.handleExecuteProposal)
+	api.handle("/governance/proposal/discuss", auth.User, api.handleAddDiscussionComment)
+	api.handle("/governance/proposals/active", auth.Public, api.handleGetActiveProposals)
+	api.handle("/governance/proposals/category", auth.Public, api.handleGetProposalsByCategory)
+	api.handle("/governance/referendum/create", auth.Operator(ScopeGovernance), api.handleCreateReferendum)
+	api.handle("/governance/referendum/vote", auth.User, api.handleVoteReferendum)
 
 	// Smart Contract endpoints
-	http.HandleFunc("/contract/deploy", withCORS(api.handleDeployContract))
-	http.HandleFunc("/contract/call", withCORS(api.handleCallContract))
-	http.HandleFunc("/contract/list", withCORS(api.handleListContracts))
-	http.HandleFunc("/contract/info", withCORS(api.handleGetContractInfo))
-	http.HandleFunc("/contract/examples", withCORS(api.handleGetContractExamples))
+	api.handle("/contract/deploy", auth.User, api.handleDeployContract)
+	api.handle("/contract/call", auth.User, api.handleCallContract)
+	api.handle("/contract/dry-run", auth.Public, api.handleDryRunContract)
+	api.handle("/contract/estimate-gas", auth.Public, api.handleEstimateGas)
+	api.handle("/contract/list", auth.Public, api.handleListContracts)
+	api.handle("/contract/info", auth.Public, api.handleGetContractInfo)
+	api.handle("/contract/examples", auth.Public, api.handleGetContractExamples)
+	api.handle("/contract/trace", auth.Public, api.handleTraceTransaction)
 	
 	// Network Architecture endpoint
-	http.HandleFunc("/network/architecture", withCORS(api.handleGetNetworkArchitecture))
+	api.handle("/network/architecture", auth.Public, api.handleGetNetworkArchitecture)
 	
 	log.Printf("API server listening on %s", addr)
 	log.Fatal(http.ListenAndServe(addr, nil))
 }
 
-// GET /block?hash=...
+// GET /block?hash=... or /block?height=...
 func (api *APIServer) handleGetBlock(w http.ResponseWriter, r *http.Request) {
 	hash := r.URL.Query().Get("hash")
+	if h := r.URL.Query().Get("height"); hash == "" && h != "" {
+		var height int
+		if _, err := fmt.Sscanf(h, "%d", &height); err != nil {
+			http.Error(w, "Invalid block height", http.StatusBadRequest)
+			return
+		}
+		block, err := api.blockManager.GetBlockByIndex(height)
+		if err != nil {
+			http.Error(w, err.Error(), heightStatus(err))
+			return
+		}
+		json.NewEncoder(w).Encode(block)
+		return
+	}
 	if hash == "" {
 		http.Error(w, "Missing block hash", http.StatusBadRequest)
 		return
@@ -267,7 +319,7 @@ func (api *APIServer) handleSubmitTransaction(w http.ResponseWriter, r *http.Req
 	json.NewEncoder(w).Encode(map[string]string{"status": "Transaction submitted"})
 }
 
-// GET /balance?address=...
+// GET /balance?address=...&height=...
 func (api *APIServer) handleGetBalance(w http.ResponseWriter, r *http.Request) {
 	address := r.URL.Query().Get("address")
 	if address == "" {
@@ -275,11 +327,39 @@ func (api *APIServer) handleGetBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	height, historical, err := queryHeight(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if historical {
+		balance, err := api.stateManager.GetBalanceAt(address, height)
+		if err != nil {
+			http.Error(w, err.Error(), contractCallStatus(err))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"address": address, "balance": balance, "height": height})
+		return
+	}
+	
 	// Get balance from state manager (this doesn't require node wallet)
 	balance := api.stateManager.GetBalance(address)
 	json.NewEncoder(w).Encode(map[string]interface{}{"address": address, "balance": balance})
 }
 
+// queryHeight parses the optional ?height= parameter of state queries
+func queryHeight(r *http.Request) (int64, bool, error) {
+	h := r.URL.Query().Get("height")
+	if h == "" {
+		return 0, false, nil
+	}
+	var height int64
+	if _, err := fmt.Sscanf(h, "%d", &height); err != nil || height < 0 {
+		return 0, false, fmt.Errorf("Invalid height %q", h)
+	}
+	return height, true, nil
+}
+
 // GET /status
 func (api *APIServer) handleGetStatus(w http.ResponseWriter, r *http.Request) {
 	// Get the node's address
@@ -312,6 +392,8 @@ func (api *APIServer) handleGetStatus(w http.ResponseWriter, r *http.Request) {
 		mode = "validator"
 	}
 	status := map[string]interface{}{
+		"chainId":          api.blockManager.ChainID(),
+		"genesisHash":      api.blockManager.GenesisHash(),
 		"blockHeight":      api.blockManager.GetBlockHeight(),
 		"txPoolSize":       api.transactionManager.GetPoolSize(),
 		"isValidator":      isValidator,
@@ -322,6 +404,7 @@ func (api *APIServer) handleGetStatus(w http.ResponseWriter, r *http.Request) {
 		"walletStaked":     walletStaked,
 		"totalBalance":     walletBalance + int64(walletStaked),
 		"mode":             mode,
+		"pruning":          api.stateManager.PruningStatus(),
 	}
 	json.NewEncoder(w).Encode(status)
 }
@@ -352,7 +435,7 @@ func (api *APIServer) handleListValidators(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(validators)
 }
 
-// GET /validator?address=
+// GET /validator?address=...&height=...
 func (api *APIServer) handleGetValidator(w http.ResponseWriter, r *http.Request) {
 	address := r.URL.Query().Get("address")
 	if address == "" {
@@ -360,6 +443,21 @@ func (api *APIServer) handleGetValidator(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	
+	height, historical, err := queryHeight(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if historical {
+		stake, err := api.stateManager.GetStakeAt(address, height)
+		if err != nil {
+			http.Error(w, err.Error(), contractCallStatus(err))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"address": address, "stake": stake, "height": height})
+		return
+	}
+	
 	validator, err := api.consensusManager.GetValidatorInfo(address)
 	if err != nil {
 		http.Error(w, "Validator not found", http.StatusNotFound)
@@ -564,6 +662,9 @@ func (api *APIServer) handleUpdateUserStake(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Address) {
+		return
+	}
 	
 	if req.Address == "" {
 		http.Error(w, "Address is required", http.StatusBadRequest)
@@ -605,7 +706,7 @@ func (api *APIServer) handleGetNodeAddress(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(map[string]string{"address": api.node.ValidatorAddress})
 }
 
-// GET /nonce?address=...
+// GET /nonce?address=...&height=...
 func (api *APIServer) handleGetNonce(w http.ResponseWriter, r *http.Request) {
 	address := r.URL.Query().Get("address")
 	if address == "" {
@@ -616,6 +717,20 @@ func (api *APIServer) handleGetNonce(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Node wallet not available", http.StatusInternalServerError)
 		return
 	}
+	height, historical, err := queryHeight(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if historical {
+		nonce, err := api.stateManager.GetNonceAt(address, height)
+		if err != nil {
+			http.Error(w, err.Error(), contractCallStatus(err))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"address": address, "nonce": nonce, "height": height})
+		return
+	}
 	nonce := api.stateManager.GetNonce(address)
 	json.NewEncoder(w).Encode(map[string]interface{}{ "address": address, "nonce": nonce })
 }
@@ -805,21 +920,49 @@ func (api *APIServer) handleTestEnvStatus(w http.ResponseWriter, r *http.Request
 }
 
 // Add wallet management endpoints
+
+// POST /create-wallet
+// Creates the node wallet from a new BIP-39 mnemonic. The optional body
+// chooses the number of words, a passphrase and the derivation path.
 func (api *APIServer) handleCreateWallet(w http.ResponseWriter, r *http.Request) {
 	if api.node.Wallet != nil {
 		http.Error(w, "Wallet already exists", http.StatusBadRequest)
 		return
 	}
-	wallet, err := wallet.NewWallet()
+	var req struct {
+		Words      int    `json:"words"`
+		Passphrase string `json:"passphrase"`
+		Path       string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Words == 0 {
+		req.Words = 12
+	}
+	mnemonic, err := wallet.NewMnemonic(req.Words)
 	if err != nil {
-		http.Error(w, "Failed to create wallet: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to create wallet: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	wallet, err := wallet.NewHDWallet(mnemonic, req.Passphrase, req.Path)
+	if err != nil {
+		http.Error(w, "Failed to create wallet: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	api.node.Wallet = wallet
 	api.node.ValidatorAddress = wallet.PublicKeyStr()
-	json.NewEncoder(w).Encode(map[string]string{"address": wallet.PublicKeyStr()})
+	json.NewEncoder(w).Encode(map[string]string{
+		"address":  wallet.PublicKeyStr(),
+		"mnemonic": mnemonic,
+		"path":     wallet.DerivationPath,
+	})
 }
 
+// POST /import-wallet
+// Imports the node wallet from a hex private key, or from a mnemonic with an
+// optional passphrase and derivation path
 func (api *APIServer) handleImportWallet(w http.ResponseWriter, r *http.Request) {
 	if api.node.Wallet != nil {
 		http.Error(w, "Wallet already exists", http.StatusBadRequest)
@@ -827,23 +970,36 @@ func (api *APIServer) handleImportWallet(w http.ResponseWriter, r *http.Request)
 	}
 	var req struct {
 		PrivateKey string `json:"privateKey"`
+		Scheme     string `json:"scheme"` // Scheme of privateKey: p256 (default), secp256k1 or ed25519
+		Mnemonic   string `json:"mnemonic"`
+		Passphrase string `json:"passphrase"`
+		Path       string `json:"path"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	if req.PrivateKey == "" {
-		http.Error(w, "Missing privateKey", http.StatusBadRequest)
+	var imported *wallet.Wallet
+	var err error
+	switch {
+	case req.PrivateKey != "" && req.Mnemonic != "":
+		http.Error(w, "Provide either privateKey or mnemonic, not both", http.StatusBadRequest)
+		return
+	case req.PrivateKey != "":
+		imported, err = wallet.ImportWalletWithScheme(req.Scheme, req.PrivateKey)
+	case req.Mnemonic != "":
+		imported, err = wallet.NewHDWallet(req.Mnemonic, req.Passphrase, req.Path)
+	default:
+		http.Error(w, "Missing privateKey or mnemonic", http.StatusBadRequest)
 		return
 	}
-	wallet, err := wallet.ImportWallet(req.PrivateKey)
 	if err != nil {
 		http.Error(w, "Failed to import wallet: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	api.node.Wallet = wallet
-	api.node.ValidatorAddress = wallet.PublicKeyStr()
-	json.NewEncoder(w).Encode(map[string]string{"address": wallet.PublicKeyStr()})
+	api.node.Wallet = imported
+	api.node.ValidatorAddress = imported.PublicKeyStr()
+	json.NewEncoder(w).Encode(map[string]string{"address": imported.PublicKeyStr(), "path": imported.DerivationPath, "scheme": imported.SchemeName()})
 }
 
 // GET /fee-info?amount=...&sender=...&recipient=...
@@ -882,8 +1038,13 @@ func (api *APIServer) handleFlutterFlowConnectWallet(w http.ResponseWriter, r *h
 	var req struct {
 		Action     string `json:"action"` // "create", "import", "connect"
 		PrivateKey string `json:"privateKey,omitempty"`
+		Scheme     string `json:"scheme,omitempty"` // Signature scheme for "create" and "import", p256 by default
 		Address    string `json:"address,omitempty"`
 		SessionID  string `json:"sessionId,omitempty"`
+		// "connect" answers a challenge from /auth/challenge
+		PublicKey  string `json:"publicKey,omitempty"`
+		Nonce      string `json:"nonce,omitempty"`
+		Signature  string `json:"signature,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -891,17 +1052,22 @@ func (api *APIServer) handleFlutterFlowConnectWallet(w http.ResponseWriter, r *h
 		return
 	}
 
-	var walletAddress string
+	var walletAddress, sessionToken string
+	var err error
 
 	switch req.Action {
 	case "create":
 		// Create a new wallet
-		newWallet, err := wallet.NewWallet()
+		newWallet, err := wallet.NewWalletWithScheme(req.Scheme)
 		if err != nil {
 			http.Error(w, "Failed to create wallet: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 		walletAddress = newWallet.PublicKeyStr()
+		if sessionToken, err = api.walletSession(newWallet); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		
 		// Add initial balance for testing
 		api.stateManager.SetBalance(walletAddress, 1000)
@@ -912,17 +1078,32 @@ func (api *APIServer) handleFlutterFlowConnectWallet(w http.ResponseWriter, r *h
 			http.Error(w, "Private key required for import", http.StatusBadRequest)
 			return
 		}
-		importedWallet, err := wallet.ImportWallet(req.PrivateKey)
+		importedWallet, err := wallet.ImportWalletWithScheme(req.Scheme, req.PrivateKey)
 		if err != nil {
 			http.Error(w, "Failed to import wallet: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 		walletAddress = importedWallet.PublicKeyStr()
+		if sessionToken, err = api.walletSession(importedWallet); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		
 	case "connect":
-		// Connect to existing address
-		if req.Address == "" {
-			http.Error(w, "Address required for connection", http.StatusBadRequest)
+		// Connect to an existing address by signing a challenge for it
+		if req.Address == "" || req.Nonce == "" || req.Signature == "" {
+			http.Error(w, "Address, and nonce and signature of an /auth/challenge, required for connection", http.StatusBadRequest)
+			return
+		}
+		sessionToken, _, err = api.authenticator.Login(auth.LoginRequest{
+			Address:   req.Address,
+			PublicKey: req.PublicKey,
+			Scheme:    req.Scheme,
+			Nonce:     req.Nonce,
+			Signature: req.Signature,
+		})
+		if err != nil {
+			http.Error(w, "Failed to connect wallet: "+err.Error(), http.StatusUnauthorized)
 			return
 		}
 		walletAddress = req.Address
@@ -932,9 +1113,6 @@ func (api *APIServer) handleFlutterFlowConnectWallet(w http.ResponseWriter, r *h
 		return
 	}
 
-	// Generate session token for FlutterFlow
-	sessionToken := generateSessionToken(walletAddress)
-	
 	// Return wallet connection response
 	response := map[string]interface{}{
 		"success": true,
@@ -968,8 +1146,7 @@ func (api *APIServer) handleFlutterFlowAuthenticate(w http.ResponseWriter, r *ht
 		return
 	}
 
-	// Validate session token (simplified for demo)
-	if !isValidSessionToken(req.SessionToken, req.Address) {
+	if _, ok := api.flutterFlowSession(r, req.SessionToken, req.Address); !ok {
 		http.Error(w, "Invalid session token", http.StatusUnauthorized)
 		return
 	}
@@ -1062,8 +1239,7 @@ func (api *APIServer) handleFlutterFlowSendTransaction(w http.ResponseWriter, r
 		return
 	}
 
-	// Validate session token
-	if !isValidSessionToken(req.SessionToken, req.From) {
+	if _, ok := api.flutterFlowSession(r, req.SessionToken, req.From); !ok {
 		http.Error(w, "Invalid session token", http.StatusUnauthorized)
 		return
 	}
@@ -1077,6 +1253,7 @@ func (api *APIServer) handleFlutterFlowSendTransaction(w http.ResponseWriter, r
 		Data:      req.Data,
 		Timestamp: time.Now().Unix(),
 		Nonce:     api.stateManager.GetNonce(req.From),
+		ChainID:   api.blockManager.ChainID(),
 		Signature: req.Signature,
 	}
 
@@ -1162,11 +1339,12 @@ func (api *APIServer) handleFlutterFlowDisconnect(w http.ResponseWriter, r *http
 		return
 	}
 
-	// Validate session token
-	if !isValidSessionToken(req.SessionToken, req.Address) {
+	token, ok := api.flutterFlowSession(r, req.SessionToken, req.Address)
+	if !ok {
 		http.Error(w, "Invalid session token", http.StatusUnauthorized)
 		return
 	}
+	api.authenticator.Logout(token)
 
 	response := map[string]interface{}{
 		"success": true,
@@ -1182,19 +1360,6 @@ func (api *APIServer) handleFlutterFlowDisconnect(w http.ResponseWriter, r *http
 
 // ===== HELPER FUNCTIONS =====
 
-// generateSessionToken creates a session token for FlutterFlow
-func generateSessionToken(address string) string {
-	timestamp := time.Now().Unix()
-	// Simple token generation - in production, use proper JWT
-	return fmt.Sprintf("ff_%s_%d_%s", address[:8], timestamp, "session")
-}
-
-// isValidSessionToken validates a session token
-func isValidSessionToken(token, address string) bool {
-	// Simple validation - in production, use proper JWT validation
-	return len(token) > 0 && strings.Contains(token, address[:8])
-}
-
 // getTransactionType determines if transaction is incoming or outgoing
 func getTransactionType(tx transaction.Transaction, userAddress string) string {
 	if tx.Sender == userAddress {
@@ -1245,6 +1410,9 @@ func (api *APIServer) handleSubmitProposal(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Proposer) {
+		return
+	}
 	// TODO: Implement proposal submission through governance manager
 	// proposal := api.stateManager.SubmitProposal(req.Proposer, req.Description, req.Actions, 0, req.Duration)
 	// proposal.State = ProposalActive
@@ -1271,6 +1439,9 @@ func (api *APIServer) handleVote(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Voter) {
+		return
+	}
 	// TODO: Implement voting through governance manager
 	// err := api.stateManager.CastVote(req.ProposalID, req.Voter, req.Choice, req.Weight)
 	var err error = nil
@@ -1501,6 +1672,9 @@ func (api *APIServer) handleGDPRDelete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Address) {
+		return
+	}
 
 	if req.Address == "" {
 		http.Error(w, "Address required", http.StatusBadRequest)
@@ -1540,6 +1714,9 @@ func (api *APIServer) handleGDPRAnonymize(w http.ResponseWriter, r *http.Request
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Address) {
+		return
+	}
 
 	if req.Address == "" {
 		http.Error(w, "Address required", http.StatusBadRequest)
@@ -1929,6 +2106,9 @@ func (api *APIServer) handleDeployContract(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, request.Owner) {
+		return
+	}
 	
 	if request.Contract == nil {
 		http.Error(w, "Contract data is required", http.StatusBadRequest)
@@ -1951,6 +2131,7 @@ func (api *APIServer) handleDeployContract(w http.ResponseWriter, r *http.Reques
 		"address":   contract.Address,
 		"name":      contract.Name,
 		"owner":     contract.Owner,
+		"abi":       contract.ABI,
 		"timestamp": time.Now().Unix(),
 	}
 	
@@ -1970,17 +2151,21 @@ func (api *APIServer) handleCallContract(w http.ResponseWriter, r *http.Request)
 	}
 	
 	var request struct {
-		ContractAddress string        `json:"contract_address"`
-		Function        string        `json:"function"`
-		Args            []interface{} `json:"args"`
-		Caller          string        `json:"caller"`
-		GasLimit        uint64        `json:"gas_limit"`
+		ContractAddress string                 `json:"contract_address"`
+		Function        string                 `json:"function"`
+		Args            []interface{}          `json:"args"`
+		Params          map[string]interface{} `json:"params"` // Named parameters, bound through the ABI
+		Caller          string                 `json:"caller"`
+		GasLimit        uint64                 `json:"gas_limit"`
 	}
 	
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, request.Caller) {
+		return
+	}
 	
 	// Get the contract
 	contract, exists := api.stateManager.GetContract(request.ContractAddress)
@@ -1997,20 +2182,29 @@ func (api *APIServer) handleCallContract(w http.ResponseWriter, r *http.Request)
 	}
 	
 	// Create VM instance
-	vmInstance := &vm.VM{
-		StateManager: api.stateManager,
-	}
+	vmInstance := vm.NewVM()
+	vmInstance.StateManager = api.stateManager
+	vmInstance.RegisterDeployedContract(contract)
 	
 	// Initialize VM memory with contract storage
-	vmInstance.Memory = make(map[string]int64)
 	for k, v := range contract.Storage {
-		if ival, ok := v.(int64); ok {
-			vmInstance.Memory[k] = ival
+		switch val := v.(type) {
+		case int64:
+			vmInstance.Memory[k] = val
+		case float64:
+			vmInstance.Memory[k] = int64(val)
 		}
 	}
 	
 	// Execute function
-	if err := contract.CallFunction(request.Function, request.Args, vmInstance, execCtx); err != nil {
+	var returnValues []vm.ReturnValue
+	var err error
+	if request.Params != nil {
+		returnValues, err = contract.CallNamed(request.Function, request.Params, vmInstance, execCtx)
+	} else {
+		returnValues, err = contract.Call(request.Function, request.Args, vmInstance, execCtx)
+	}
+	if err != nil {
 		http.Error(w, fmt.Sprintf("Function execution failed: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -2025,6 +2219,7 @@ func (api *APIServer) handleCallContract(w http.ResponseWriter, r *http.Request)
 	response := map[string]interface{}{
 		"status":     "success",
 		"message":    "Function executed successfully",
+		"return_values": returnValues,
 		"gas_used":   vmInstance.GetGasUsed(),
 		"gas_limit":  vmInstance.GetGasLimit(),
 		"timestamp":  time.Now().Unix(),
@@ -2033,6 +2228,127 @@ func (api *APIServer) handleCallContract(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(response)
 }
 
+// POST /contract/dry-run
+// Executes a function against the latest state (or "block_height") without
+// submitting a transaction. Storage writes are discarded.
+func (api *APIServer) handleDryRunContract(w http.ResponseWriter, r *http.Request) {
+	if api.stateManager == nil {
+		http.Error(w, "State manager not available", http.StatusServiceUnavailable)
+		return
+	}
+	
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	
+	var request blockchain.CallRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	
+	result, err := api.stateManager.CallContract(request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Call failed: %v", err), contractCallStatus(err))
+		return
+	}
+	
+	response := map[string]interface{}{
+		"status":        "success",
+		"return_values": result.ReturnValues,
+		"gas_used":      result.GasUsed,
+		"gas_limit":     result.GasLimit,
+		"block_height":  result.BlockHeight,
+		"timestamp":     time.Now().Unix(),
+	}
+	
+	json.NewEncoder(w).Encode(response)
+}
+
+// POST /contract/estimate-gas
+func (api *APIServer) handleEstimateGas(w http.ResponseWriter, r *http.Request) {
+	if api.stateManager == nil {
+		http.Error(w, "State manager not available", http.StatusServiceUnavailable)
+		return
+	}
+	
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	
+	var request blockchain.CallRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	
+	gas, err := api.stateManager.EstimateGas(request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Gas estimation failed: %v", err), contractCallStatus(err))
+		return
+	}
+	
+	// Contract calls are metered with the transaction fee as gas limit
+	response := map[string]interface{}{
+		"status":        "success",
+		"gas_estimate":  gas,
+		"suggested_fee": gas,
+		"timestamp":     time.Now().Unix(),
+	}
+	
+	json.NewEncoder(w).Encode(response)
+}
+
+// GET /contract/trace?hash=...
+func (api *APIServer) handleTraceTransaction(w http.ResponseWriter, r *http.Request) {
+	if api.stateManager == nil || api.blockManager == nil {
+		http.Error(w, "State manager not available", http.StatusServiceUnavailable)
+		return
+	}
+	
+	hash := r.URL.Query().Get("hash")
+	if hash == "" {
+		http.Error(w, "Missing transaction hash", http.StatusBadRequest)
+		return
+	}
+	
+	blk, index, ok := api.blockManager.FindTransaction(hash)
+	if !ok {
+		http.Error(w, "Transaction not found in chain", http.StatusNotFound)
+		return
+	}
+	
+	trace, err := api.stateManager.TraceTransaction(blk, index)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Trace failed: %v", err), contractCallStatus(err))
+		return
+	}
+	
+	json.NewEncoder(w).Encode(trace)
+}
+
+// contractCallStatus maps read-only call errors to HTTP status codes
+func contractCallStatus(err error) int {
+	switch {
+	case errors.Is(err, blockchain.ErrContractNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, blockchain.ErrHeightNotAvailable), errors.Is(err, blockchain.ErrHeightPruned):
+		return http.StatusGone
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+// heightStatus maps errors of lookups by block height to HTTP status codes
+func heightStatus(err error) int {
+	if errors.Is(err, blockchain.ErrHeightPruned) {
+		return http.StatusGone
+	}
+	return http.StatusNotFound
+}
+
 // GET /contract/list
 func (api *APIServer) handleListContracts(w http.ResponseWriter, r *http.Request) {
 	if api.stateManager == nil {
@@ -2055,7 +2371,7 @@ func (api *APIServer) handleListContracts(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(contracts)
 }
 
-// GET /contract/info
+// GET /contract/info?address=...&height=...
 func (api *APIServer) handleGetContractInfo(w http.ResponseWriter, r *http.Request) {
 	if api.stateManager == nil {
 		http.Error(w, "State manager not available", http.StatusServiceUnavailable)
@@ -2074,6 +2390,19 @@ func (api *APIServer) handleGetContractInfo(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	
+	storage := interface{}(contract.Storage)
+	height, historical, err := queryHeight(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if historical {
+		if storage, err = api.stateManager.GetStorageAt(address, height); err != nil {
+			http.Error(w, err.Error(), contractCallStatus(err))
+			return
+		}
+	}
+	
 	// Get function names
 	functions := make([]string, 0)
 	for funcName := range contract.Functions {
@@ -2086,10 +2415,17 @@ func (api *APIServer) handleGetContractInfo(w http.ResponseWriter, r *http.Reque
 		"version":     contract.Version,
 		"owner":       contract.Owner,
 		"functions":   functions,
-		"storage":     contract.Storage,
+		"storage":     storage,
 		"created_at":  contract.CreatedAt,
 		"updated_at":  contract.UpdatedAt,
 		"upgradable":  contract.Upgradable,
+		"abi":         contract.ABI,
+		"contract_type": contract.ContractType,
+		"code":        contract.Functions,
+		"code_history": contract.CodeHistory,
+	}
+	if historical {
+		response["storage_height"] = height
 	}
 	
 	json.NewEncoder(w).Encode(response)
@@ -2142,9 +2478,15 @@ func (api *APIServer) handleBackupCreate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	
+	full := r.URL.Query().Get("type") == "full"
+
 	// Create backup in background to avoid blocking
 	go func() {
-		err := api.stateManager.CreateManualBackup()
+		create := api.stateManager.CreateManualBackup
+		if full {
+			create = api.stateManager.CreateFullBackup
+		}
+		err := create()
 		if err != nil {
 			log.Printf("❌ [API] Failed to create manual backup: %v", err)
 		}
@@ -2260,6 +2602,9 @@ func (api *APIServer) handleCreateIdentity(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Address) {
+		return
+	}
 
 	publicKey, err := hex.DecodeString(req.PublicKey)
 	if err != nil {
@@ -2335,6 +2680,9 @@ func (api *APIServer) handleUpdateProfile(w http.ResponseWriter, r *http.Request
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Address) {
+		return
+	}
 
 	// TODO: Implement profile update through identity manager
 	err := api.identityManager.UpdateProfile(req.Address, req.Profile)
@@ -2411,6 +2759,9 @@ func (api *APIServer) handleCreatePrivacyProof(w http.ResponseWriter, r *http.Re
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Address) {
+		return
+	}
 
 	// TODO: Implement privacy proof creation
 	// TODO: Implement privacy proof creation with proper type conversion
@@ -2592,6 +2943,9 @@ func (api *APIServer) handleCreatePost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Author) {
+		return
+	}
 
 	post, err := api.socialManager.CreatePost(req.Author, req.Content, req.MediaURLs, req.Visibility, req.Category)
 	if err != nil {
@@ -2648,6 +3002,9 @@ func (api *APIServer) handleCreateComment(w http.ResponseWriter, r *http.Request
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Author) {
+		return
+	}
 
 	comment, err := api.socialManager.CreateComment(req.PostID, req.Author, req.Content, req.ParentID)
 	if err != nil {
@@ -2678,6 +3035,9 @@ func (api *APIServer) handleLikePost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.UserID) {
+		return
+	}
 
 	err := api.socialManager.LikePost(req.PostID, req.UserID, req.LikeType)
 	if err != nil {
@@ -2707,6 +3067,9 @@ func (api *APIServer) handleUnlikePost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.UserID) {
+		return
+	}
 
 	err := api.socialManager.UnlikePost(req.PostID, req.UserID)
 	if err != nil {
@@ -2815,6 +3178,9 @@ func (api *APIServer) handleReportContent(w http.ResponseWriter, r *http.Request
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Reporter) {
+		return
+	}
 
 	err := api.socialManager.ReportContent(req.Reporter, req.TargetID, req.TargetType, req.Reason, req.Description)
 	if err != nil {
@@ -2850,6 +3216,9 @@ func (api *APIServer) handleCreateProposal(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Proposer) {
+		return
+	}
 
 	proposal, err := api.governanceManager.CreateProposal(req.Proposer, req.Title, req.Description, req.Category, req.Actions, req.CurrentBlock)
 	if err != nil {
@@ -2914,6 +3283,9 @@ func (api *APIServer) handleVoteProposal(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Voter) {
+		return
+	}
 
 	err := api.governanceManager.Vote(req.ProposalID, req.Voter, req.Choice, req.Reason, req.Weight)
 	if err != nil {
@@ -2974,6 +3346,9 @@ func (api *APIServer) handleAddDiscussionComment(w http.ResponseWriter, r *http.
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Author) {
+		return
+	}
 
 	err := api.governanceManager.AddDiscussionComment(req.ProposalID, req.Author, req.Content, req.ParentID)
 	if err != nil {
@@ -3079,6 +3454,9 @@ func (api *APIServer) handleVoteReferendum(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !auth.MayActFor(w, r, req.Voter) {
+		return
+	}
 
 	err := api.governanceManager.VoteReferendum(req.ReferendumID, req.Voter, req.Option)
 	if err != nil {
@@ -3090,4 +3468,105 @@ func (api *APIServer) handleVoteReferendum(w http.ResponseWriter, r *http.Reques
 		"success": true,
 		"message": "Referendum vote cast successfully",
 	})
-}
\ No newline at end of file
+}
+
+// GET /multisig/account?address=...
+func (api *APIServer) handleGetMultisigAccount(w http.ResponseWriter, r *http.Request) {
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "Missing address", http.StatusBadRequest)
+		return
+	}
+	account, ok := api.stateManager.GetMultisig(address)
+	if !ok {
+		http.Error(w, "Multisig account not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(account)
+}
+
+// POST /multisig/propose
+// Body: a multisig transaction, with any signatures already collected
+func (api *APIServer) handleProposeMultisig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var tx transaction.Transaction
+	if err := json.NewDecoder(r.Body).Decode(&tx); err != nil {
+		http.Error(w, "Invalid transaction data", http.StatusBadRequest)
+		return
+	}
+	if tx.Timestamp == 0 {
+		tx.Timestamp = time.Now().Unix()
+	}
+	pending, err := api.multisigPool.Propose(tx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	api.writeMultisigStatus(w, pending)
+}
+
+// POST /multisig/sign
+// Body: {"id": ..., "public_key": ..., "signature": ...}. The transaction is
+// broadcast as soon as it has enough signatures.
+func (api *APIServer) handleSignMultisig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ID string `json:"id"`
+		transaction.MultisigSignature
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	pending, err := api.multisigPool.AddSignature(req.ID, req.MultisigSignature)
+	if errors.Is(err, blockchain.ErrPendingMultisigNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	api.writeMultisigStatus(w, pending)
+}
+
+// writeMultisigStatus broadcasts a complete multisig transaction and reports
+// the state of the collection
+func (api *APIServer) writeMultisigStatus(w http.ResponseWriter, pending *blockchain.PendingMultisig) {
+	status := "pending"
+	if pending.Complete() {
+		if err := api.transactionManager.AddTransaction(pending.Transaction); err != nil {
+			http.Error(w, "Collected enough signatures but failed to submit: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		api.multisigPool.Remove(pending.ID)
+		status = "submitted"
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":          pending.ID,
+		"status":      status,
+		"signatures":  len(pending.Transaction.Signatures),
+		"required":    pending.Required,
+		"transaction": pending.Transaction,
+	})
+}
+
+// GET /multisig/pending?id=... or ?address=...
+func (api *APIServer) handleListPendingMultisig(w http.ResponseWriter, r *http.Request) {
+	if id := r.URL.Query().Get("id"); id != "" {
+		pending, ok := api.multisigPool.Get(id)
+		if !ok {
+			http.Error(w, "Pending multisig transaction not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(pending)
+		return
+	}
+	json.NewEncoder(w).Encode(api.multisigPool.List(r.URL.Query().Get("address")))
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"atlas-blockchain/pkg/auth"
+	"atlas-blockchain/pkg/wallet"
+)
+
+// Operator scopes, which API keys and operator wallets are granted in the
+// access file
+const (
+	ScopeFaucet     = "faucet"     // Crediting tokens
+	ScopeValidators = "validators" // Registering validators and changing the node's stake
+	ScopeBackup     = "backup"     // Listing and creating backups
+	ScopeOracle     = "oracle"     // Submitting oracle data
+	ScopeTesting    = "testing"    // Running test suites and test environments
+	ScopeSync       = "sync"       // Starting chain sync
+	ScopeSharding   = "sharding"   // Assigning validators and cross-shard transactions
+	ScopeGovernance = "governance" // Activating and executing proposals, creating referendums
+	ScopeIdentity   = "identity"   // Recording identity activity
+)
+
+// SetAuthenticator replaces the authenticator, which by default has a
+// random token secret and no API keys. Call it before Start.
+func (api *APIServer) SetAuthenticator(a *auth.Authenticator) {
+	api.authenticator = a
+}
+
+// newAuthenticator creates the default authenticator
+func newAuthenticator(chainID string) *auth.Authenticator {
+	tokens, err := auth.NewTokens(auth.NewSecret())
+	if err != nil {
+		log.Fatalf("❌ Failed to create token issuer: %v", err)
+	}
+	a, _ := auth.NewAuthenticator(tokens, auth.NewChallenges(chainID), nil)
+	return a
+}
+
+// handle registers a route behind CORS, the rate limit of its class and its
+// authorization policy. Limits come first so that guessing credentials
+// spends the budget of the client's address.
+func (api *APIServer) handle(path string, policy auth.Policy, handler http.HandlerFunc) {
+	http.HandleFunc(path, withCORS(api.limited(path, api.authenticator.Require(policy, handler))))
+}
+
+// POST /auth/challenge {"address": ...}
+// Returns a message for the wallet to sign to log in
+func (api *APIServer) handleAuthChallenge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Address string `json:"address"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	challenge, err := api.authenticator.Challenges.New(req.Address)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, auth.ErrTooManyPending) {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	json.NewEncoder(w).Encode(challenge)
+}
+
+// loginResponse is the token a login issues
+type loginResponse struct {
+	Token     string    `json:"token"`
+	Address   string    `json:"address"`
+	Role      auth.Role `json:"role"`
+	Scopes    []string  `json:"scopes,omitempty"`
+	ExpiresAt int64     `json:"expires_at"`
+}
+
+// POST /auth/login {"address", "public_key", "scheme", "nonce", "signature"}
+// Exchanges a signed challenge for a token
+func (api *APIServer) handleAuthLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req auth.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	token, claims, err := api.authenticator.Login(req)
+	if err != nil {
+		http.Error(w, "Login failed: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	log.Printf("🔑 %s logged in as %s", claims.Subject, claims.Role)
+	json.NewEncoder(w).Encode(&loginResponse{
+		Token:     token,
+		Address:   claims.Subject,
+		Role:      claims.Role,
+		Scopes:    claims.Scopes,
+		ExpiresAt: claims.ExpiresAt,
+	})
+}
+
+// POST /auth/logout with the token as bearer
+func (api *APIServer) handleAuthLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token, ok := bearerToken(r)
+	if !ok {
+		http.Error(w, "Logout needs a bearer token", http.StatusBadRequest)
+		return
+	}
+	if err := api.authenticator.Logout(token); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "Logged out"})
+}
+
+// GET /auth/whoami
+// Returns the role of the credentials sent
+func (api *APIServer) handleAuthWhoami(w http.ResponseWriter, r *http.Request) {
+	p := auth.FromContext(r.Context())
+	if p == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"role": auth.RolePublic})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"subject": p.Subject,
+		"role":    p.Role,
+		"scopes":  p.Scopes,
+	})
+}
+
+// bearerToken returns the bearer token of a request
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// flutterFlowSession checks that a FlutterFlow session token, or the bearer
+// token when the body has none, belongs to the wallet of address, and
+// returns the token
+func (api *APIServer) flutterFlowSession(r *http.Request, token, address string) (string, bool) {
+	if token == "" {
+		token, _ = bearerToken(r)
+	}
+	if token == "" {
+		return "", false
+	}
+	p, err := api.authenticator.Session(token)
+	return token, err == nil && p.Owns(address)
+}
+
+// walletSession issues a user token for a wallet whose key the node holds
+func (api *APIServer) walletSession(w *wallet.Wallet) (string, error) {
+	address := w.PublicKeyStr()
+	token, _, err := api.authenticator.Tokens.Issue(&auth.Principal{Subject: address, PublicKey: address, Role: auth.RoleUser})
+	return token, err
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"atlas-blockchain/pkg/monitoring"
+	"atlas-blockchain/pkg/ratelimit"
+	"atlas-blockchain/pkg/rpc"
+)
+
+// Route classes, each with its own budget per client
+const (
+	ClassRead   = "read"   // GET routes
+	ClassWrite  = "write"  // Other routes that change state
+	ClassTx     = "tx"     // Transaction submission and contract calls
+	ClassAuth   = "auth"   // Login challenges and wallet connection
+	ClassFaucet = "faucet" // Crediting tokens
+	ClassRPC    = "rpc"    // JSON-RPC, WebSocket connections and their messages
+)
+
+// RateLimitClasses returns the default budgets of the route classes
+func RateLimitClasses() []ratelimit.Class {
+	return []ratelimit.Class{
+		{Name: ClassRead, Rate: 20, Burst: 40, MaxBody: 64 << 10},
+		{Name: ClassWrite, Rate: 5, Burst: 20, MaxBody: 256 << 10},
+		{Name: ClassTx, Rate: 2, Burst: 10, MaxBody: 1 << 20},
+		{Name: ClassAuth, Rate: 1, Burst: 10, MaxBody: 16 << 10},
+		{Name: ClassFaucet, Rate: 1.0 / 60, Burst: 1, Quota: 10, QuotaWindow: 24 * time.Hour, MaxBody: 4 << 10},
+		{Name: ClassRPC, Rate: 20, Burst: 40, MaxBody: rpc.MaxRequestSize},
+	}
+}
+
+// routeClasses are the routes outside the read and write classes
+var routeClasses = map[string]string{
+	"/submit-transaction":           ClassTx,
+	"/flutterflow/send-transaction": ClassTx,
+	"/multisig/propose":             ClassTx,
+	"/multisig/sign":                ClassTx,
+	"/contract/deploy":              ClassTx,
+	"/contract/call":                ClassTx,
+	"/auth/challenge":               ClassAuth,
+	"/auth/login":                   ClassAuth,
+	"/flutterflow/connect-wallet":   ClassAuth,
+	"/flutterflow/authenticate":     ClassAuth,
+	"/faucet":                       ClassFaucet,
+	RPCPath:                         ClassRPC,
+	WebSocketPath:                   ClassRPC,
+}
+
+// SetLimiter replaces the rate limiter, which by default has the budgets of
+// RateLimitClasses. Call it before Start.
+func (api *APIServer) SetLimiter(l *ratelimit.Limiter) {
+	api.limiter = l
+	l.APIKey = func(r *http.Request) string { return api.authenticator.KeyID(r) }
+	l.OnReject = api.recordRejection
+}
+
+// limited wraps the handler of a route with the budget of its class:
+// listed routes have their own class, other routes are reads for GET and
+// HEAD and writes otherwise
+func (api *APIServer) limited(path string, handler http.HandlerFunc) http.HandlerFunc {
+	if class, ok := routeClasses[path]; ok {
+		return api.limiter.Handler(class, handler)
+	}
+	read := api.limiter.Handler(ClassRead, handler)
+	write := api.limiter.Handler(ClassWrite, handler)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			read(w, r)
+		} else {
+			write(w, r)
+		}
+	}
+}
+
+// recordRejection publishes the rejections of a class and reason as a
+// monitoring counter
+func (api *APIServer) recordRejection(class, reason string, count uint64) {
+	api.monitor.RecordMetric("api_rejected_"+class+"_"+reason, monitoring.MetricTypeCounter, float64(count), map[string]string{
+		"class":  class,
+		"reason": reason,
+	})
+}
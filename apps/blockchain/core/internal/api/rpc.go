@@ -0,0 +1,329 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"atlas-blockchain/internal/blockchain"
+	"atlas-blockchain/pkg/block"
+	"atlas-blockchain/pkg/database"
+	"atlas-blockchain/pkg/ratelimit"
+	"atlas-blockchain/pkg/rpc"
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/vm"
+)
+
+// RPCVersion is the version of the JSON-RPC API. Its major version is part
+// of the endpoint path; methods are only added within a major version.
+const RPCVersion = "1.0.0"
+
+// RPCPath is the endpoint of the JSON-RPC API
+const RPCPath = "/rpc/v1"
+
+// maxRPCBlocks bounds the blocks chain_getBlocks returns
+const maxRPCBlocks = 100
+
+// Parameters and results of the JSON-RPC methods
+
+type rpcHashParams struct {
+	Hash string `json:"hash"`
+}
+
+type rpcAddressParams struct {
+	Address string `json:"address"`
+}
+
+type rpcStateParams struct {
+	Address string `json:"address"`
+	Height  *int64 `json:"height,omitempty"` // Omitted for the latest state
+}
+
+type rpcBlockParams struct {
+	Hash   string `json:"hash,omitempty"`
+	Height *int   `json:"height,omitempty"`
+}
+
+type rpcBlocksParams struct {
+	Limit  int `json:"limit,omitempty"` // 10 by default, at most 100
+	Offset int `json:"offset,omitempty"`
+}
+
+type rpcSendParams struct {
+	Transaction transaction.Transaction `json:"transaction"`
+}
+
+// RPCStatus is the result of chain_getStatus
+type RPCStatus struct {
+	ChainID     string `json:"chain_id"`
+	GenesisHash string `json:"genesis_hash"`
+	Height      int    `json:"height"`
+	TxPoolSize  int    `json:"tx_pool_size"`
+}
+
+// RPCTransaction is a transaction with where it is in the chain
+type RPCTransaction struct {
+	Hash        string                  `json:"hash"`
+	Transaction transaction.Transaction `json:"transaction"`
+	Pending     bool                    `json:"pending"` // In the mempool, not yet in a block
+	BlockHash   string                  `json:"block_hash,omitempty"`
+	BlockHeight int                     `json:"block_height,omitempty"`
+	Index       int                     `json:"index,omitempty"`
+}
+
+// RPCBalance is the result of state_getBalance
+type RPCBalance struct {
+	Address string `json:"address"`
+	Balance int64  `json:"balance"`
+	Height  *int64 `json:"height,omitempty"`
+}
+
+// RPCNonce is the result of state_getNonce
+type RPCNonce struct {
+	Address string `json:"address"`
+	Nonce   uint64 `json:"nonce"`
+	Height  *int64 `json:"height,omitempty"`
+}
+
+// RPCSendResult is the result of tx_send
+type RPCSendResult struct {
+	Hash string `json:"hash"`
+}
+
+// RPCGasEstimate is the result of contract_estimateGas
+type RPCGasEstimate struct {
+	Gas uint64 `json:"gas"`
+}
+
+// RPCContract is the result of contract_getInfo
+type RPCContract struct {
+	Address      string                 `json:"address"`
+	Name         string                 `json:"name"`
+	Version      string                 `json:"version"`
+	Owner        string                 `json:"owner"`
+	Upgradable   bool                   `json:"upgradable"`
+	ContractType string                 `json:"contract_type"`
+	ABI          *vm.ABI                `json:"abi"`
+	Storage      map[string]interface{} `json:"storage"`
+	CreatedAt    int64                  `json:"created_at"`
+	UpdatedAt    int64                  `json:"updated_at"`
+}
+
+// newRPCServer registers the JSON-RPC methods of the node. Methods are
+// named namespace_verb: chain_ for blocks and transactions, state_ for
+// accounts, tx_ for the mempool and contract_ for smart contracts.
+func (api *APIServer) newRPCServer() *rpc.Server {
+	s := rpc.NewServer("Atlas JSON-RPC API", RPCVersion)
+
+	rpc.Register(s, "chain_getStatus", "Returns the chain ID, genesis hash, height and mempool size", api.rpcGetStatus)
+	rpc.Register(s, "chain_getBlock", "Returns a block by hash or height", api.rpcGetBlock)
+	rpc.Register(s, "chain_getBlocks", "Returns a page of blocks in chain order, starting at offset", api.rpcGetBlocks)
+	rpc.Register(s, "chain_getTransaction", "Returns a transaction from the chain or the mempool by hash", api.rpcGetTransaction)
+	rpc.Register(s, "chain_getReceipt", "Returns the receipt of a transaction in the chain", api.rpcGetReceipt)
+
+	rpc.Register(s, "state_getBalance", "Returns the balance of an address, at a height if given", api.rpcGetBalance)
+	rpc.Register(s, "state_getNonce", "Returns the nonce of an address, at a height if given", api.rpcGetNonce)
+	rpc.Register(s, "state_getValidators", "Returns the validator set", api.rpcGetValidators)
+	rpc.Register(s, "state_getValidator", "Returns a validator by address", api.rpcGetValidator)
+	rpc.Register(s, "state_getMultisig", "Returns a registered multisig account", api.rpcGetMultisig)
+
+	rpc.Register(s, "tx_send", "Submits a signed transaction to the mempool and returns its hash", api.rpcSendTransaction)
+	rpc.Register(s, "tx_getPending", "Returns the transactions in the mempool", api.rpcGetPending)
+
+	rpc.Register(s, "contract_call", "Calls a contract function without a transaction; storage writes are discarded", api.rpcCallContract)
+	rpc.Register(s, "contract_estimateGas", "Estimates the gas of a contract call", api.rpcEstimateGas)
+	rpc.Register(s, "contract_getInfo", "Returns a contract with its ABI and storage, at a height if given", api.rpcGetContract)
+	rpc.Register(s, "contract_trace", "Replays a contract call transaction and returns its execution trace", api.rpcTraceTransaction)
+	return s
+}
+
+// rpcStateError maps errors of state and block lookups to error codes
+func rpcStateError(err error) error {
+	switch {
+	case errors.Is(err, blockchain.ErrContractNotFound):
+		return rpc.Errorf(rpc.CodeNotFound, "%v", err)
+	case errors.Is(err, blockchain.ErrHeightNotAvailable), errors.Is(err, blockchain.ErrHeightPruned):
+		return rpc.Errorf(rpc.CodeHeightUnavailable, "%v", err)
+	}
+	return rpc.Errorf(rpc.CodeExecutionFailed, "%v", err)
+}
+
+func (api *APIServer) rpcGetStatus(ctx context.Context, _ struct{}) (*RPCStatus, error) {
+	return &RPCStatus{
+		ChainID:     api.blockManager.ChainID(),
+		GenesisHash: api.blockManager.GenesisHash(),
+		Height:      api.blockManager.GetBlockHeight(),
+		TxPoolSize:  api.transactionManager.GetPoolSize(),
+	}, nil
+}
+
+func (api *APIServer) rpcGetBlock(ctx context.Context, p rpcBlockParams) (*block.Block, error) {
+	if p.Height != nil {
+		blk, err := api.blockManager.GetBlockByIndex(*p.Height)
+		if errors.Is(err, blockchain.ErrHeightPruned) {
+			return nil, rpc.Errorf(rpc.CodeHeightUnavailable, "%v", err)
+		}
+		if err != nil {
+			return nil, rpc.Errorf(rpc.CodeNotFound, "%v", err)
+		}
+		return blk, nil
+	}
+	if p.Hash == "" {
+		return nil, rpc.Errorf(rpc.CodeInvalidParams, "give a block hash or height")
+	}
+	blk := api.blockManager.GetBlockByHash(p.Hash)
+	if blk == nil {
+		return nil, rpc.Errorf(rpc.CodeNotFound, "block %s not found", p.Hash)
+	}
+	return blk, nil
+}
+
+func (api *APIServer) rpcGetBlocks(ctx context.Context, p rpcBlocksParams) ([]*block.Block, error) {
+	if p.Limit <= 0 {
+		p.Limit = 10
+	}
+	if p.Limit > maxRPCBlocks || p.Offset < 0 {
+		return nil, rpc.Errorf(rpc.CodeInvalidParams, "limit must be at most %d and offset not negative", maxRPCBlocks)
+	}
+	return api.blockManager.GetBlocks(p.Limit, p.Offset), nil
+}
+
+func (api *APIServer) rpcGetTransaction(ctx context.Context, p rpcHashParams) (*RPCTransaction, error) {
+	if blk, index, ok := api.blockManager.FindTransaction(p.Hash); ok {
+		return &RPCTransaction{Hash: p.Hash, Transaction: blk.Transactions[index], BlockHash: blk.Hash, BlockHeight: blk.Index, Index: index}, nil
+	}
+	if tx := api.transactionManager.GetTransactionByHash(p.Hash); tx != nil {
+		return &RPCTransaction{Hash: p.Hash, Transaction: *tx, Pending: true}, nil
+	}
+	return nil, rpc.Errorf(rpc.CodeNotFound, "transaction %s not found", p.Hash)
+}
+
+func (api *APIServer) rpcGetReceipt(ctx context.Context, p rpcHashParams) (*database.Receipt, error) {
+	receipt, err := api.stateManager.GetReceipt(p.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if receipt == nil {
+		return nil, rpc.Errorf(rpc.CodeNotFound, "no receipt for transaction %s", p.Hash)
+	}
+	return receipt, nil
+}
+
+func (api *APIServer) rpcGetBalance(ctx context.Context, p rpcStateParams) (*RPCBalance, error) {
+	if p.Height == nil {
+		return &RPCBalance{Address: p.Address, Balance: api.stateManager.GetBalance(p.Address)}, nil
+	}
+	balance, err := api.stateManager.GetBalanceAt(p.Address, *p.Height)
+	if err != nil {
+		return nil, rpcStateError(err)
+	}
+	return &RPCBalance{Address: p.Address, Balance: balance, Height: p.Height}, nil
+}
+
+func (api *APIServer) rpcGetNonce(ctx context.Context, p rpcStateParams) (*RPCNonce, error) {
+	if p.Height == nil {
+		return &RPCNonce{Address: p.Address, Nonce: api.stateManager.GetNonce(p.Address)}, nil
+	}
+	nonce, err := api.stateManager.GetNonceAt(p.Address, *p.Height)
+	if err != nil {
+		return nil, rpcStateError(err)
+	}
+	return &RPCNonce{Address: p.Address, Nonce: nonce, Height: p.Height}, nil
+}
+
+func (api *APIServer) rpcGetValidators(ctx context.Context, _ struct{}) ([]*blockchain.Validator, error) {
+	return api.consensusManager.GetAllValidators(), nil
+}
+
+func (api *APIServer) rpcGetValidator(ctx context.Context, p rpcAddressParams) (*blockchain.Validator, error) {
+	validator, err := api.consensusManager.GetValidatorInfo(p.Address)
+	if err != nil {
+		return nil, rpc.Errorf(rpc.CodeNotFound, "validator %s not found", p.Address)
+	}
+	return validator, nil
+}
+
+func (api *APIServer) rpcGetMultisig(ctx context.Context, p rpcAddressParams) (*blockchain.MultisigAccount, error) {
+	account, ok := api.stateManager.GetMultisig(p.Address)
+	if !ok {
+		return nil, rpc.Errorf(rpc.CodeNotFound, "no multisig account at %s", p.Address)
+	}
+	return account, nil
+}
+
+func (api *APIServer) rpcSendTransaction(ctx context.Context, p rpcSendParams) (*RPCSendResult, error) {
+	// The endpoint has the budget of the rpc class; submissions also spend
+	// the tx budget of the client, as on /submit-transaction
+	if wait, err := api.limiter.Allow(ClassTx, ratelimit.ClientFromContext(ctx)); err != nil {
+		return nil, rpc.Errorf(rpc.CodeLimitExceeded, "%v, retry in %ds", err, ratelimit.RetryAfter(wait))
+	}
+	tx := p.Transaction
+	if tx.Timestamp == 0 {
+		tx.Timestamp = time.Now().Unix()
+	}
+	if err := tx.Validate(); err != nil {
+		return nil, rpc.Errorf(rpc.CodeInvalidParams, "invalid transaction: %v", err)
+	}
+	if err := api.transactionManager.AddTransaction(tx); err != nil {
+		return nil, rpc.Errorf(rpc.CodeTxRejected, "%v", err)
+	}
+	return &RPCSendResult{Hash: blockchain.TransactionHash(tx)}, nil
+}
+
+func (api *APIServer) rpcGetPending(ctx context.Context, _ struct{}) ([]transaction.Transaction, error) {
+	return api.transactionManager.GetAllTransactions(), nil
+}
+
+func (api *APIServer) rpcCallContract(ctx context.Context, req blockchain.CallRequest) (*blockchain.CallResult, error) {
+	result, err := api.stateManager.CallContract(req)
+	if err != nil {
+		return nil, rpcStateError(err)
+	}
+	return result, nil
+}
+
+func (api *APIServer) rpcEstimateGas(ctx context.Context, req blockchain.CallRequest) (*RPCGasEstimate, error) {
+	gas, err := api.stateManager.EstimateGas(req)
+	if err != nil {
+		return nil, rpcStateError(err)
+	}
+	return &RPCGasEstimate{Gas: gas}, nil
+}
+
+func (api *APIServer) rpcGetContract(ctx context.Context, p rpcStateParams) (*RPCContract, error) {
+	contract, exists := api.stateManager.GetContract(p.Address)
+	if !exists {
+		return nil, rpc.Errorf(rpc.CodeNotFound, "contract %s not found", p.Address)
+	}
+	storage := contract.Storage
+	if p.Height != nil {
+		var err error
+		if storage, err = api.stateManager.GetStorageAt(p.Address, *p.Height); err != nil {
+			return nil, rpcStateError(err)
+		}
+	}
+	return &RPCContract{
+		Address:      contract.Address,
+		Name:         contract.Name,
+		Version:      contract.Version,
+		Owner:        contract.Owner,
+		Upgradable:   contract.Upgradable,
+		ContractType: string(contract.ContractType),
+		ABI:          contract.ABI,
+		Storage:      storage,
+		CreatedAt:    contract.CreatedAt,
+		UpdatedAt:    contract.UpdatedAt,
+	}, nil
+}
+
+func (api *APIServer) rpcTraceTransaction(ctx context.Context, p rpcHashParams) (*blockchain.TxTrace, error) {
+	blk, index, ok := api.blockManager.FindTransaction(p.Hash)
+	if !ok {
+		return nil, rpc.Errorf(rpc.CodeNotFound, "transaction %s not found in chain", p.Hash)
+	}
+	trace, err := api.stateManager.TraceTransaction(blk, index)
+	if err != nil {
+		return nil, rpcStateError(err)
+	}
+	return trace, nil
+}
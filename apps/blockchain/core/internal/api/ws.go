@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"atlas-blockchain/internal/blockchain"
+	"atlas-blockchain/pkg/block"
+	"atlas-blockchain/pkg/ratelimit"
+	"atlas-blockchain/pkg/rpc"
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/vm"
+)
+
+// WebSocketPath is the endpoint serving the JSON-RPC API and subscriptions
+// over WebSocket
+const WebSocketPath = "/ws/v1"
+
+// Subscription types of chain_subscribe
+const (
+	SubNewHeads            = "newHeads"            // Header of every new block
+	SubPendingTransactions = "pendingTransactions" // Transactions entering the mempool
+	SubFinality            = "finality"            // Blocks reaching finality
+	SubLogs                = "logs"                // Contract events
+)
+
+// subscriptionQueueSize bounds the chain events waiting to be published
+const subscriptionQueueSize = 1024
+
+// RPCBlockHeader is a block without its transactions
+type RPCBlockHeader struct {
+	Hash      string `json:"hash"`
+	PrevHash  string `json:"prev_hash"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Validator string `json:"validator"`
+	TxCount   int    `json:"tx_count"`
+}
+
+// RPCFinality reports a block reaching finality
+type RPCFinality struct {
+	Hash          string `json:"hash"`
+	Height        int    `json:"height"`
+	Confirmations int    `json:"confirmations"`
+}
+
+// RPCLog is a contract event with where it was emitted
+type RPCLog struct {
+	vm.Log
+	TxHash      string `json:"tx_hash"`
+	BlockHash   string `json:"block_hash"`
+	BlockHeight int    `json:"block_height"`
+	TxIndex     int    `json:"tx_index"`
+	LogIndex    int    `json:"log_index"` // Position among the transaction's events
+}
+
+// rpcTxFilter selects transactions sent from or to any of its addresses
+type rpcTxFilter struct {
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// rpcLogFilter selects events of any of its contracts with any of its
+// topics, the event names. An empty list matches everything.
+type rpcLogFilter struct {
+	Addresses []string `json:"addresses,omitempty"`
+	Topics    []string `json:"topics,omitempty"`
+}
+
+// decodeFilter decodes the filter params of a subscription, which may be absent
+func decodeFilter(params json.RawMessage, filter interface{}) error {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.DisallowUnknownFields()
+	return dec.Decode(filter)
+}
+
+// contains reports whether list is empty or holds s
+func contains(list []string, s string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// unfiltered is the filter factory of subscription types without a filter
+func unfiltered(params json.RawMessage) (rpc.Filter, error) {
+	var none struct{}
+	if err := decodeFilter(params, &none); err != nil {
+		return nil, fmt.Errorf("this subscription takes no filter")
+	}
+	return func(event interface{}) (interface{}, bool) { return event, true }, nil
+}
+
+// newSubscriptionHub creates the WebSocket hub with the chain_ subscription types
+func (api *APIServer) newSubscriptionHub() *rpc.Hub {
+	hub := rpc.NewHub(api.rpcServer, "chain",
+		rpc.SubscriptionKind{Name: SubNewHeads, NewFilter: unfiltered},
+		rpc.SubscriptionKind{Name: SubFinality, NewFilter: unfiltered},
+		rpc.SubscriptionKind{Name: SubPendingTransactions, NewFilter: func(params json.RawMessage) (rpc.Filter, error) {
+			var f rpcTxFilter
+			if err := decodeFilter(params, &f); err != nil {
+				return nil, err
+			}
+			return func(event interface{}) (interface{}, bool) {
+				tx := event.(*RPCTransaction)
+				if len(f.Addresses) > 0 && !contains(f.Addresses, tx.Transaction.Sender) && !contains(f.Addresses, tx.Transaction.Recipient) {
+					return nil, false
+				}
+				return tx, true
+			}, nil
+		}},
+		rpc.SubscriptionKind{Name: SubLogs, NewFilter: func(params json.RawMessage) (rpc.Filter, error) {
+			var f rpcLogFilter
+			if err := decodeFilter(params, &f); err != nil {
+				return nil, err
+			}
+			return func(event interface{}) (interface{}, bool) {
+				l := event.(*RPCLog)
+				return l, contains(f.Addresses, l.Address) && contains(f.Topics, l.Event)
+			}, nil
+		}},
+	)
+	// The upgrade spends one token of the rpc class; so does every message
+	// on the connection, as each is a request
+	hub.Limit = func(ctx context.Context) error {
+		if wait, err := api.limiter.Allow(ClassRPC, ratelimit.ClientFromContext(ctx)); err != nil {
+			return rpc.Errorf(rpc.CodeLimitExceeded, "%v, retry in %ds", err, ratelimit.RetryAfter(wait))
+		}
+		return nil
+	}
+	return hub
+}
+
+// startSubscriptions feeds chain events to the hub. The callbacks run with
+// the chain or mempool locked, so they only queue events; a goroutine builds
+// and publishes them.
+func (api *APIServer) startSubscriptions() {
+	events := make(chan interface{}, subscriptionQueueSize)
+	queue := func(event interface{}) {
+		select {
+		case events <- event:
+		default:
+			log.Printf("⚠️ Subscription queue full, dropping event")
+		}
+	}
+	api.blockManager.SetOnBlockAddedCallback(func(blk *block.Block) { queue(blk) })
+	api.transactionManager.SetOnTransactionAddedCallback(func(tx transaction.Transaction) { queue(tx) })
+
+	go func() {
+		for event := range events {
+			switch event := event.(type) {
+			case *block.Block:
+				api.publishBlock(event)
+			case transaction.Transaction:
+				if api.hub.HasSubscribers(SubPendingTransactions) {
+					api.hub.Publish(SubPendingTransactions, &RPCTransaction{Hash: blockchain.TransactionHash(event), Transaction: event, Pending: true})
+				}
+			}
+		}
+	}()
+}
+
+// publishBlock publishes the header of a new block, the block it finalizes
+// and the events of its transactions
+func (api *APIServer) publishBlock(blk *block.Block) {
+	if api.hub.HasSubscribers(SubNewHeads) {
+		api.hub.Publish(SubNewHeads, &RPCBlockHeader{
+			Hash:      blk.Hash,
+			PrevHash:  blk.PrevHash,
+			Height:    blk.Index,
+			Timestamp: blk.Timestamp,
+			Validator: blk.Validator,
+			TxCount:   len(blk.Transactions),
+		})
+	}
+
+	// A block is final once it has the threshold's number of confirmations,
+	// counting itself, so each new block finalizes exactly one block
+	if api.hub.HasSubscribers(SubFinality) {
+		threshold := 1
+		if api.consensusManager != nil {
+			threshold = api.consensusManager.GetFinalityThreshold()
+		}
+		if height := blk.Index - threshold + 1; height >= 0 {
+			final := blk
+			if height != blk.Index {
+				var err error
+				if final, err = api.blockManager.GetBlockByIndex(height); err != nil {
+					final = nil
+				}
+			}
+			if final != nil {
+				api.hub.Publish(SubFinality, &RPCFinality{Hash: final.Hash, Height: final.Index, Confirmations: threshold})
+			}
+		}
+	}
+
+	if api.hub.HasSubscribers(SubLogs) {
+		receipts, err := api.stateManager.GetBlockReceipts(int64(blk.Index))
+		if err != nil {
+			log.Printf("❌ Failed to load receipts of block %d for log subscriptions: %v", blk.Index, err)
+			return
+		}
+		for _, receipt := range receipts {
+			if len(receipt.Logs) == 0 {
+				continue
+			}
+			var logs []vm.Log
+			if err := json.Unmarshal(receipt.Logs, &logs); err != nil {
+				log.Printf("❌ Invalid logs in receipt of %s: %v", receipt.TxHash, err)
+				continue
+			}
+			for i, l := range logs {
+				api.hub.Publish(SubLogs, &RPCLog{
+					Log:         l,
+					TxHash:      receipt.TxHash,
+					BlockHash:   blk.Hash,
+					BlockHeight: blk.Index,
+					TxIndex:     receipt.Index,
+					LogIndex:    i,
+				})
+			}
+		}
+	}
+}
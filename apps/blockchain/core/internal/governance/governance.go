@@ -595,8 +595,17 @@ func (gm *GovernanceManager) executeTreasuryTransfer(action GovernanceAction) er
 	return nil
 }
 
+// executeContractUpgrade checks a contract upgrade action. The code is only
+// installed by an upgrade transaction naming a succeeded on-chain proposal
+// with the code hash, so that every node applies it the same way.
 func (gm *GovernanceManager) executeContractUpgrade(action GovernanceAction) error {
-	// Implementation for contract upgrades
+	if action.Target == "" {
+		return fmt.Errorf("contract upgrade requires a target contract address")
+	}
+	codeHash, ok := action.Data["code_hash"].(string)
+	if !ok || codeHash == "" {
+		return fmt.Errorf("contract upgrade requires a code_hash")
+	}
 	return nil
 }
 
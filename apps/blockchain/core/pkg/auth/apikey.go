@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// apiKeyPrefix starts every API key, which makes keys easy to spot in
+// logs and config files
+const apiKeyPrefix = "atk_"
+
+// APIKey is an API key of the access file. Only the hash of the key is
+// kept, so the file does not grant access if it leaks.
+type APIKey struct {
+	ID     string   `json:"id"`
+	Role   Role     `json:"role"`
+	Scopes []string `json:"scopes,omitempty"`
+	Hash   string   `json:"hash"` // "sha256:" and the hex SHA-256 of the key
+}
+
+// WalletRole grants a role above user to a wallet when it logs in
+type WalletRole struct {
+	Address string   `json:"address"`
+	Role    Role     `json:"role"`
+	Scopes  []string `json:"scopes,omitempty"`
+}
+
+// Access lists the API keys of a node and the wallets with more than the
+// user role
+type Access struct {
+	Keys    []APIKey     `json:"keys"`
+	Wallets []WalletRole `json:"wallets,omitempty"`
+}
+
+// NewAPIKey returns a random API key and the hash to list in the access file
+func NewAPIKey() (key, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key: %v", err)
+	}
+	key = apiKeyPrefix + hex.EncodeToString(secret)
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hash of an API key as the access file lists it
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+// LoadAccess reads and validates an access file
+func LoadAccess(path string) (*Access, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access file: %v", err)
+	}
+	var access Access
+	if err := json.Unmarshal(data, &access); err != nil {
+		return nil, fmt.Errorf("invalid access file %s: %v", path, err)
+	}
+	if err := access.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid access file %s: %v", path, err)
+	}
+	return &access, nil
+}
+
+// Validate checks that keys have unique IDs, well-formed hashes and a role
+// a client can hold
+func (a *Access) Validate() error {
+	ids := make(map[string]bool)
+	hashes := make(map[string]bool)
+	for _, k := range a.Keys {
+		if k.ID == "" {
+			return fmt.Errorf("API key without an id")
+		}
+		if ids[k.ID] {
+			return fmt.Errorf("duplicate API key id %q", k.ID)
+		}
+		ids[k.ID] = true
+		hexHash, ok := strings.CutPrefix(k.Hash, "sha256:")
+		if raw, err := hex.DecodeString(hexHash); !ok || err != nil || len(raw) != sha256.Size {
+			return fmt.Errorf("API key %q: hash must be sha256: and 64 hex digits", k.ID)
+		}
+		if hashes[k.Hash] {
+			return fmt.Errorf("API key %q: duplicate hash", k.ID)
+		}
+		hashes[k.Hash] = true
+		if k.Role < RoleUser || k.Role > RoleAdmin {
+			return fmt.Errorf("API key %q: role must be user, operator or admin", k.ID)
+		}
+	}
+	for _, w := range a.Wallets {
+		if w.Address == "" {
+			return fmt.Errorf("wallet role without an address")
+		}
+		if w.Role < RoleUser || w.Role > RoleAdmin {
+			return fmt.Errorf("wallet %s: role must be user, operator or admin", w.Address)
+		}
+	}
+	return nil
+}
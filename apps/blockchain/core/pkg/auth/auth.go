@@ -0,0 +1,156 @@
+// Package auth authenticates API clients and authorizes them by role.
+//
+// Wallet owners log in by signing a one-time challenge with their key and
+// receive an expiring token signed by the node. Operators and automation
+// use API keys, which carry a role and the scopes of the operator endpoints
+// they may call. Every route has a Policy naming the least role it needs;
+// the middleware of an Authenticator enforces it.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"atlas-blockchain/pkg/wallet"
+)
+
+// Role is the level of access of a client. Each role may do everything the
+// roles below it may.
+type Role int
+
+const (
+	RolePublic   Role = iota // Anyone, without credentials
+	RoleUser                 // A logged-in wallet owner
+	RoleOperator             // Runs the node, limited to the scopes granted
+	RoleAdmin                // Everything, regardless of scopes
+)
+
+var roleNames = []string{"public", "user", "operator", "admin"}
+
+func (r Role) String() string {
+	if r < RolePublic || r > RoleAdmin {
+		return fmt.Sprintf("role(%d)", int(r))
+	}
+	return roleNames[r]
+}
+
+// ParseRole returns the role of a name
+func ParseRole(name string) (Role, error) {
+	for i, n := range roleNames {
+		if strings.EqualFold(name, n) {
+			return Role(i), nil
+		}
+	}
+	return RolePublic, fmt.Errorf("unknown role %q", name)
+}
+
+// MarshalText encodes a role as its name
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText decodes a role from its name
+func (r *Role) UnmarshalText(text []byte) error {
+	role, err := ParseRole(string(text))
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
+
+// AllScopes grants every operator scope
+const AllScopes = "*"
+
+// Principal is an authenticated client
+type Principal struct {
+	Subject   string // Wallet address, or "key:<id>" for an API key
+	PublicKey string // Hex public key of a wallet login
+	Role      Role
+	Scopes    []string // Operator scopes
+	TokenID   string   // ID of the token the client presented, for revocation
+}
+
+// HasScope reports whether the principal may use operator endpoints of scope
+func (p *Principal) HasScope(scope string) bool {
+	if p.Role == RoleAdmin || scope == "" {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope || s == AllScopes {
+			return true
+		}
+	}
+	return false
+}
+
+// Owns reports whether the principal logged in with the wallet of address,
+// given either as the address derived from its key or as the hex key itself
+func (p *Principal) Owns(address string) bool {
+	if p == nil || address == "" {
+		return false
+	}
+	return wallet.SameAddress(p.Subject, address) || (p.PublicKey != "" && ownsKey(p.PublicKey, address))
+}
+
+// MayActFor reports whether the principal may act on behalf of address:
+// its own wallet, or any address for operators and admins
+func (p *Principal) MayActFor(address string) bool {
+	return p != nil && (p.Role >= RoleOperator || p.Owns(address))
+}
+
+// Policy is what a route requires of its clients
+type Policy struct {
+	Role  Role
+	Scope string // Operator scope, for routes below the admin role
+}
+
+// Route policies
+var (
+	Public = Policy{Role: RolePublic}
+	User   = Policy{Role: RoleUser}
+	Admin  = Policy{Role: RoleAdmin}
+)
+
+// Operator is the policy of operator endpoints of a scope
+func Operator(scope string) Policy {
+	return Policy{Role: RoleOperator, Scope: scope}
+}
+
+// Allows reports whether a principal, nil for an anonymous client, meets
+// the policy
+func (p Policy) Allows(principal *Principal) bool {
+	if p.Role == RolePublic {
+		return true
+	}
+	if principal == nil || principal.Role < p.Role {
+		return false
+	}
+	return p.Role < RoleOperator || principal.HasScope(p.Scope)
+}
+
+// Authentication errors
+var (
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrExpiredToken   = errors.New("token expired")
+	ErrRevokedToken   = errors.New("token revoked")
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrUnknownNonce   = errors.New("unknown or expired challenge")
+	ErrBadSignature   = errors.New("signature does not match the challenge")
+	ErrTooManyPending = errors.New("too many pending challenges")
+)
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the principal of a request
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of a request, nil for an anonymous one
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
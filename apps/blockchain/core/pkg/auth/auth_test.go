@@ -0,0 +1,378 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/wallet"
+)
+
+func newTestTokens(t *testing.T) *Tokens {
+	t.Helper()
+	tokens, err := NewTokens(NewSecret())
+	if err != nil {
+		t.Fatalf("Failed to create tokens: %v", err)
+	}
+	return tokens
+}
+
+// signChallenge asks for a challenge and signs it with w
+func signChallenge(t *testing.T, c *Challenges, w *wallet.Wallet, address string) LoginRequest {
+	t.Helper()
+	ch, err := c.New(address)
+	if err != nil {
+		t.Fatalf("Failed to create challenge: %v", err)
+	}
+	sig, err := w.SignHash(ChallengeHash(ch.Message))
+	if err != nil {
+		t.Fatalf("Failed to sign challenge: %v", err)
+	}
+	return LoginRequest{Address: address, PublicKey: w.PublicKeyStr(), Scheme: w.SchemeName(), Nonce: ch.Nonce, Signature: sig}
+}
+
+func TestPolicy(t *testing.T) {
+	user := &Principal{Subject: "0xaa", Role: RoleUser}
+	backup := &Principal{Subject: "key:ops", Role: RoleOperator, Scopes: []string{"backup"}}
+	all := &Principal{Subject: "key:all", Role: RoleOperator, Scopes: []string{AllScopes}}
+	admin := &Principal{Subject: "key:root", Role: RoleAdmin}
+
+	tests := []struct {
+		name      string
+		policy    Policy
+		principal *Principal
+		allowed   bool
+	}{
+		{"PublicAnonymous", Public, nil, true},
+		{"UserAnonymous", User, nil, false},
+		{"UserUser", User, user, true},
+		{"UserOperator", User, backup, true},
+		{"OperatorUser", Operator("backup"), user, false},
+		{"OperatorScope", Operator("backup"), backup, true},
+		{"OperatorOtherScope", Operator("faucet"), backup, false},
+		{"OperatorAllScopes", Operator("faucet"), all, true},
+		{"OperatorAdmin", Operator("faucet"), admin, true},
+		{"AdminOperator", Admin, all, false},
+		{"AdminAdmin", Admin, admin, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Allows(tt.principal); got != tt.allowed {
+				t.Errorf("Expected allowed %v, got %v", tt.allowed, got)
+			}
+		})
+	}
+
+	t.Run("ParseRole", func(t *testing.T) {
+		for _, role := range []Role{RolePublic, RoleUser, RoleOperator, RoleAdmin} {
+			if parsed, err := ParseRole(role.String()); err != nil || parsed != role {
+				t.Errorf("Expected %s to parse back, got %v %v", role, parsed, err)
+			}
+		}
+		if _, err := ParseRole("root"); err == nil {
+			t.Error("Expected an unknown role to be rejected")
+		}
+	})
+}
+
+func TestTokens(t *testing.T) {
+	tokens := newTestTokens(t)
+	p := &Principal{Subject: "0xaa", PublicKey: "02bb", Role: RoleUser}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		token, _, err := tokens.Issue(p)
+		if err != nil {
+			t.Fatalf("Failed to issue token: %v", err)
+		}
+		claims, err := tokens.Verify(token)
+		if err != nil {
+			t.Fatalf("Failed to verify token: %v", err)
+		}
+		if got := claims.Principal(); got.Subject != p.Subject || got.PublicKey != p.PublicKey || got.Role != p.Role {
+			t.Errorf("Expected %+v, got %+v", p, got)
+		}
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		token, _, _ := tokens.Issue(p)
+		body, sig, _ := strings.Cut(token, ".")
+		admin, _, _ := tokens.Issue(&Principal{Subject: "0xaa", Role: RoleAdmin})
+		adminBody, _, _ := strings.Cut(admin, ".")
+		for _, bad := range []string{adminBody + "." + sig, body, body + ".x", ""} {
+			if _, err := tokens.Verify(bad); err != ErrInvalidToken {
+				t.Errorf("Expected %q to be invalid, got %v", bad, err)
+			}
+		}
+		if _, err := newTestTokens(t).Verify(token); err != ErrInvalidToken {
+			t.Errorf("Expected a token of another secret to be invalid, got %v", err)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		token, _, _ := tokens.Issue(p)
+		tokens.now = func() time.Time { return time.Now().Add(DefaultTokenTTL) }
+		defer func() { tokens.now = time.Now }()
+		if _, err := tokens.Verify(token); err != ErrExpiredToken {
+			t.Errorf("Expected the token to expire, got %v", err)
+		}
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		token, claims, _ := tokens.Issue(p)
+		tokens.Revoke(claims.ID, claims.ExpiresAt)
+		if _, err := tokens.Verify(token); err != ErrRevokedToken {
+			t.Errorf("Expected the token to be revoked, got %v", err)
+		}
+	})
+
+	t.Run("ShortSecret", func(t *testing.T) {
+		if _, err := NewTokens([]byte("short")); err == nil {
+			t.Error("Expected a short secret to be rejected")
+		}
+	})
+}
+
+func TestChallenges(t *testing.T) {
+	for _, scheme := range []string{transaction.SchemeP256, transaction.SchemeSecp256k1, transaction.SchemeEd25519} {
+		t.Run(scheme, func(t *testing.T) {
+			c := NewChallenges("atlas-test")
+			w, err := wallet.NewWalletWithScheme(scheme)
+			if err != nil {
+				t.Fatalf("Failed to create wallet: %v", err)
+			}
+			address := wallet.PublicKeyToAddress(w.PublicKey)
+			req := signChallenge(t, c, w, address)
+			p, err := c.Verify(req)
+			if err != nil {
+				t.Fatalf("Failed to log in: %v", err)
+			}
+			if p.Subject != address || p.Role != RoleUser || !p.Owns(w.PublicKeyStr()) {
+				t.Errorf("Unexpected principal %+v", p)
+			}
+			if _, err := c.Verify(req); err != ErrUnknownNonce {
+				t.Errorf("Expected a replayed login to be rejected, got %v", err)
+			}
+		})
+	}
+
+	t.Run("RecoveredKey", func(t *testing.T) {
+		c := NewChallenges("atlas-test")
+		w, _ := wallet.NewWalletWithScheme(transaction.SchemeSecp256k1)
+		req := signChallenge(t, c, w, wallet.PublicKeyToAddress(w.PublicKey))
+		req.PublicKey = ""
+		if _, err := c.Verify(req); err != nil {
+			t.Errorf("Expected the secp256k1 key to be recovered, got %v", err)
+		}
+	})
+
+	t.Run("HexKeyAddress", func(t *testing.T) {
+		c := NewChallenges("atlas-test")
+		w, _ := wallet.NewWallet()
+		if _, err := c.Verify(signChallenge(t, c, w, w.PublicKeyStr())); err != nil {
+			t.Errorf("Expected a hex public key to log in as its address, got %v", err)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		c := NewChallenges("atlas-test")
+		w, _ := wallet.NewWallet()
+		other, _ := wallet.NewWallet()
+		address := wallet.PublicKeyToAddress(w.PublicKey)
+
+		req := signChallenge(t, c, w, address)
+		req.Address = wallet.PublicKeyToAddress(other.PublicKey)
+		if _, err := c.Verify(req); err != ErrUnknownNonce {
+			t.Errorf("Expected a challenge of another address to be rejected, got %v", err)
+		}
+
+		req = signChallenge(t, c, other, address)
+		if _, err := c.Verify(req); err == nil {
+			t.Error("Expected another wallet's key to be rejected")
+		}
+
+		req = signChallenge(t, c, w, address)
+		req.Signature = strings.Repeat("00", 64)
+		if _, err := c.Verify(req); err != ErrBadSignature {
+			t.Errorf("Expected a bad signature to be rejected, got %v", err)
+		}
+
+		req = signChallenge(t, c, w, address)
+		c.now = func() time.Time { return time.Now().Add(DefaultChallengeTTL) }
+		defer func() { c.now = time.Now }()
+		if _, err := c.Verify(req); err != ErrUnknownNonce {
+			t.Errorf("Expected an expired challenge to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("ChainBound", func(t *testing.T) {
+		ch, _ := NewChallenges("atlas-test").New("0xaa")
+		if !strings.Contains(ch.Message, "Chain: atlas-test") {
+			t.Errorf("Expected the chain ID in the message, got %q", ch.Message)
+		}
+	})
+}
+
+func TestAccess(t *testing.T) {
+	key, hash, err := NewAPIKey()
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	if !strings.HasPrefix(key, apiKeyPrefix) || HashAPIKey(key) != hash {
+		t.Errorf("Unexpected key %q with hash %q", key, hash)
+	}
+
+	t.Run("Load", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "access.json")
+		data := `{"keys": [{"id": "ops", "role": "operator", "scopes": ["backup"], "hash": "` + hash + `"}],
+			"wallets": [{"address": "0xaa", "role": "admin"}]}`
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		access, err := LoadAccess(path)
+		if err != nil {
+			t.Fatalf("Failed to load access file: %v", err)
+		}
+		if access.Keys[0].Role != RoleOperator || access.Wallets[0].Role != RoleAdmin {
+			t.Errorf("Unexpected access %+v", access)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			access Access
+		}{
+			{"NoID", Access{Keys: []APIKey{{Role: RoleOperator, Hash: hash}}}},
+			{"DuplicateID", Access{Keys: []APIKey{{ID: "a", Role: RoleOperator, Hash: hash}, {ID: "a", Role: RoleOperator, Hash: HashAPIKey("x")}}}},
+			{"DuplicateHash", Access{Keys: []APIKey{{ID: "a", Role: RoleOperator, Hash: hash}, {ID: "b", Role: RoleOperator, Hash: hash}}}},
+			{"BadHash", Access{Keys: []APIKey{{ID: "a", Role: RoleOperator, Hash: "md5:00"}}}},
+			{"PublicRole", Access{Keys: []APIKey{{ID: "a", Role: RolePublic, Hash: hash}}}},
+			{"WalletWithoutAddress", Access{Wallets: []WalletRole{{Role: RoleAdmin}}}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if err := tt.access.Validate(); err == nil {
+					t.Error("Expected the access list to be rejected")
+				}
+			})
+		}
+	})
+}
+
+func TestAuthenticator(t *testing.T) {
+	opsKey, opsHash, _ := NewAPIKey()
+	adminWallet, _ := wallet.NewWallet()
+	adminAddress := wallet.PublicKeyToAddress(adminWallet.PublicKey)
+	a, err := NewAuthenticator(newTestTokens(t), NewChallenges("atlas-test"), &Access{
+		Keys:    []APIKey{{ID: "ops", Role: RoleOperator, Scopes: []string{"backup"}, Hash: opsHash}},
+		Wallets: []WalletRole{{Address: adminAddress, Role: RoleAdmin}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+
+	userWallet, _ := wallet.NewWallet()
+	userAddress := wallet.PublicKeyToAddress(userWallet.PublicKey)
+	userToken, _, err := a.Login(signChallenge(t, a.Challenges, userWallet, userAddress))
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+	adminToken, claims, err := a.Login(signChallenge(t, a.Challenges, adminWallet, adminAddress))
+	if err != nil || claims.Role != RoleAdmin {
+		t.Fatalf("Expected the access file to make the wallet admin, got %v %v", claims, err)
+	}
+
+	// serve calls a handler behind policy, which echoes the subject
+	serve := func(policy Policy, header, value string) *httptest.ResponseRecorder {
+		handler := a.Require(policy, func(w http.ResponseWriter, r *http.Request) {
+			if p := FromContext(r.Context()); p != nil {
+				w.Write([]byte(p.Subject))
+			}
+		})
+		req := httptest.NewRequest(http.MethodPost, "/route", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		header string
+		value  string
+		status int
+	}{
+		{"PublicAnonymous", Public, "", "", http.StatusOK},
+		{"UserAnonymous", User, "", "", http.StatusUnauthorized},
+		{"UserToken", User, "Authorization", "Bearer " + userToken, http.StatusOK},
+		{"InvalidToken", Public, "Authorization", "Bearer " + userToken + "x", http.StatusUnauthorized},
+		{"NotBearer", User, "Authorization", "Basic " + userToken, http.StatusUnauthorized},
+		{"UserOnOperator", Operator("backup"), "Authorization", "Bearer " + userToken, http.StatusForbidden},
+		{"KeyScope", Operator("backup"), APIKeyHeader, opsKey, http.StatusOK},
+		{"KeyOtherScope", Operator("faucet"), APIKeyHeader, opsKey, http.StatusForbidden},
+		{"KeyOnAdmin", Admin, APIKeyHeader, opsKey, http.StatusForbidden},
+		{"InvalidKey", Public, APIKeyHeader, opsKey + "x", http.StatusUnauthorized},
+		{"AdminWallet", Admin, "Authorization", "Bearer " + adminToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.policy, tt.header, tt.value)
+			if rec.Code != tt.status {
+				t.Errorf("Expected %d, got %d %s", tt.status, rec.Code, rec.Body)
+			}
+		})
+	}
+
+	t.Run("MayActFor", func(t *testing.T) {
+		handler := a.Require(User, func(w http.ResponseWriter, r *http.Request) {
+			if MayActFor(w, r, r.URL.Query().Get("address")) {
+				w.WriteHeader(http.StatusNoContent)
+			}
+		})
+		for _, tt := range []struct {
+			address string
+			token   string
+			status  int
+		}{
+			{userAddress, userToken, http.StatusNoContent},
+			{userWallet.PublicKeyStr(), userToken, http.StatusNoContent},
+			{adminAddress, userToken, http.StatusForbidden},
+			{userAddress, adminToken, http.StatusNoContent},
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/route?address="+tt.address, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("Expected %d acting for %s, got %d %s", tt.status, tt.address, rec.Code, rec.Body)
+			}
+		}
+	})
+
+	t.Run("KeyID", func(t *testing.T) {
+		for key, want := range map[string]string{opsKey: "ops", opsKey + "x": "", "": ""} {
+			req := httptest.NewRequest(http.MethodGet, "/route", nil)
+			req.Header.Set(APIKeyHeader, key)
+			if id := a.KeyID(req); id != want {
+				t.Errorf("Expected key ID %q, got %q", want, id)
+			}
+		}
+	})
+
+	t.Run("Logout", func(t *testing.T) {
+		if err := a.Logout(userToken); err != nil {
+			t.Fatalf("Failed to log out: %v", err)
+		}
+		if rec := serve(User, "Authorization", "Bearer "+userToken); rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected a revoked token to be refused, got %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/wallet"
+)
+
+const (
+	// DefaultChallengeTTL is how long a challenge can be signed
+	DefaultChallengeTTL = 5 * time.Minute
+	// MaxPendingChallenges bounds the challenges waiting to be signed
+	MaxPendingChallenges = 10000
+)
+
+// Challenge is a one-time message a wallet signs to log in
+type Challenge struct {
+	Address   string `json:"address"`
+	Nonce     string `json:"nonce"`
+	Message   string `json:"message"` // Sign the SHA-256 hash of the message
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// LoginRequest answers a challenge. PublicKey may be left out for
+// secp256k1, whose key is recovered from the signature.
+type LoginRequest struct {
+	Address   string `json:"address"`
+	PublicKey string `json:"public_key,omitempty"`
+	Scheme    string `json:"scheme,omitempty"`
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+// Challenges issues login challenges and checks their signatures. Each
+// challenge can be answered once.
+type Challenges struct {
+	chainID string
+	TTL     time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]*Challenge // Nonce to challenge
+}
+
+// NewChallenges creates a challenge store for a chain; the chain ID is part
+// of the signed message so that a login cannot be replayed on another network
+func NewChallenges(chainID string) *Challenges {
+	return &Challenges{
+		chainID: chainID,
+		TTL:     DefaultChallengeTTL,
+		now:     time.Now,
+		pending: make(map[string]*Challenge),
+	}
+}
+
+// ChallengeMessage is the text a wallet signs to log in
+func ChallengeMessage(chainID, address, nonce string, expiresAt int64) string {
+	return fmt.Sprintf("Atlas login\nChain: %s\nAddress: %s\nNonce: %s\nExpires: %d", chainID, address, nonce, expiresAt)
+}
+
+// ChallengeHash is the hash of a challenge message that wallets sign
+func ChallengeHash(message string) []byte {
+	hash := sha256.Sum256([]byte(message))
+	return hash[:]
+}
+
+// New issues a challenge for an address
+func (c *Challenges) New(address string) (*Challenge, error) {
+	if address == "" {
+		return nil, fmt.Errorf("address is required")
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) >= MaxPendingChallenges {
+		for n, ch := range c.pending {
+			if ch.ExpiresAt <= now.Unix() {
+				delete(c.pending, n)
+			}
+		}
+		if len(c.pending) >= MaxPendingChallenges {
+			return nil, ErrTooManyPending
+		}
+	}
+	ch := &Challenge{Address: address, Nonce: hex.EncodeToString(nonce), ExpiresAt: now.Add(c.TTL).Unix()}
+	ch.Message = ChallengeMessage(c.chainID, ch.Address, ch.Nonce, ch.ExpiresAt)
+	c.pending[ch.Nonce] = ch
+	return ch, nil
+}
+
+// take removes and returns the live challenge of a nonce
+func (c *Challenges) take(nonce string) (*Challenge, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch, ok := c.pending[nonce]
+	if !ok {
+		return nil, false
+	}
+	delete(c.pending, nonce)
+	return ch, ch.ExpiresAt > c.now().Unix()
+}
+
+// Verify checks a signed challenge and returns the wallet it logs in. The
+// challenge is used up whether or not the signature is valid.
+func (c *Challenges) Verify(req LoginRequest) (*Principal, error) {
+	ch, ok := c.take(req.Nonce)
+	if !ok || !wallet.SameAddress(ch.Address, req.Address) {
+		return nil, ErrUnknownNonce
+	}
+	scheme, err := wallet.NormalizeScheme(req.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	hash := ChallengeHash(ch.Message)
+
+	publicKey := req.PublicKey
+	if publicKey == "" {
+		if scheme != transaction.SchemeSecp256k1 {
+			return nil, fmt.Errorf("public key is required for %s", scheme)
+		}
+		key, err := wallet.RecoverPublicKey(req.Signature, hash)
+		if err != nil {
+			return nil, ErrBadSignature
+		}
+		publicKey = hex.EncodeToString(key)
+	}
+	valid, err := wallet.VerifySignature(scheme, publicKey, req.Signature, hash)
+	if err != nil || !valid {
+		return nil, ErrBadSignature
+	}
+
+	if !ownsKey(publicKey, ch.Address) {
+		return nil, fmt.Errorf("public key does not belong to address %s", ch.Address)
+	}
+	return &Principal{Subject: ch.Address, PublicKey: publicKey, Role: RoleUser}, nil
+}
+
+// ownsKey reports whether address is a hex public key or its derived address
+func ownsKey(publicKey, address string) bool {
+	if wallet.SameAddress(publicKey, address) {
+		return true
+	}
+	key, err := decodeHex(publicKey)
+	return err == nil && wallet.SameAddress(wallet.PublicKeyToAddress(key), address)
+}
+
+func decodeHex(s string) ([]byte, error) {
+	if len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		s = s[2:]
+	}
+	return hex.DecodeString(s)
+}
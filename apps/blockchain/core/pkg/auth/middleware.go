@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader is the request header carrying an API key
+const APIKeyHeader = "X-API-Key"
+
+// Authenticator authenticates requests by login token or API key and
+// enforces route policies
+type Authenticator struct {
+	Tokens     *Tokens
+	Challenges *Challenges
+
+	keys    map[string]*APIKey // By hash
+	wallets []WalletRole
+}
+
+// NewAuthenticator creates an authenticator with the keys and wallet roles
+// of access, which may be nil
+func NewAuthenticator(tokens *Tokens, challenges *Challenges, access *Access) (*Authenticator, error) {
+	a := &Authenticator{Tokens: tokens, Challenges: challenges, keys: make(map[string]*APIKey)}
+	if access == nil {
+		return a, nil
+	}
+	if err := access.Validate(); err != nil {
+		return nil, err
+	}
+	for i := range access.Keys {
+		a.keys[access.Keys[i].Hash] = &access.Keys[i]
+	}
+	a.wallets = access.Wallets
+	return a, nil
+}
+
+// HasKeys reports whether any API key is configured
+func (a *Authenticator) HasKeys() bool {
+	return len(a.keys) > 0
+}
+
+// Login checks a signed challenge and issues a token for the wallet, with
+// the role the access file grants it
+func (a *Authenticator) Login(req LoginRequest) (string, *Claims, error) {
+	p, err := a.Challenges.Verify(req)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, w := range a.wallets {
+		if p.Owns(w.Address) {
+			p.Role, p.Scopes = w.Role, w.Scopes
+			break
+		}
+	}
+	return a.Tokens.Issue(p)
+}
+
+// Logout revokes a token
+func (a *Authenticator) Logout(token string) error {
+	claims, err := a.Tokens.Verify(token)
+	if err != nil {
+		return err
+	}
+	a.Tokens.Revoke(claims.ID, claims.ExpiresAt)
+	return nil
+}
+
+// Session returns the wallet principal of a token
+func (a *Authenticator) Session(token string) (*Principal, error) {
+	claims, err := a.Tokens.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	return claims.Principal(), nil
+}
+
+// KeyID returns the ID of the valid API key a request carries, "" for none
+func (a *Authenticator) KeyID(r *http.Request) string {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return ""
+	}
+	if k, ok := a.keys[HashAPIKey(key)]; ok {
+		return k.ID
+	}
+	return ""
+}
+
+// Authenticate returns the principal of a request's bearer token or API
+// key, nil without credentials. Invalid credentials are an error rather
+// than an anonymous request.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		k, ok := a.keys[HashAPIKey(key)]
+		if !ok {
+			return nil, ErrInvalidAPIKey
+		}
+		return &Principal{Subject: "key:" + k.ID, Role: k.Role, Scopes: k.Scopes}, nil
+	}
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, nil
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrInvalidToken
+	}
+	return a.Session(strings.TrimSpace(token))
+}
+
+// Require wraps a handler with the policy of its route. The principal of
+// an allowed request is in its context.
+func (a *Authenticator) Require(policy Policy, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="atlas"`)
+			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !policy.Allows(p) {
+			if p == nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="atlas"`)
+				http.Error(w, fmt.Sprintf("Unauthorized: %s role required", policy.Role), http.StatusUnauthorized)
+				return
+			}
+			log.Printf("🔒 Denied %s %s to %s (%s)", r.Method, r.URL.Path, p.Subject, p.Role)
+			if p.Role >= policy.Role {
+				http.Error(w, fmt.Sprintf("Forbidden: %q scope required", policy.Scope), http.StatusForbidden)
+			} else {
+				http.Error(w, fmt.Sprintf("Forbidden: %s role required", policy.Role), http.StatusForbidden)
+			}
+			return
+		}
+		if p != nil {
+			r = r.WithContext(WithPrincipal(r.Context(), p))
+		}
+		handler(w, r)
+	}
+}
+
+// MayActFor reports whether the client of a request may act on behalf of
+// address, writing a 403 response if not
+func MayActFor(w http.ResponseWriter, r *http.Request, address string) bool {
+	p := FromContext(r.Context())
+	if p.MayActFor(address) {
+		return true
+	}
+	subject := "anonymous client"
+	if p != nil {
+		subject = p.Subject
+	}
+	http.Error(w, fmt.Sprintf("Forbidden: %s may not act for %s", subject, shortAddress(address)), http.StatusForbidden)
+	return false
+}
+
+// shortAddress shortens hex public keys used as addresses for messages
+func shortAddress(address string) string {
+	if len(address) > 42 {
+		return address[:16] + "..."
+	}
+	return address
+}
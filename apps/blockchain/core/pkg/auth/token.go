@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTokenTTL is how long a login token is valid
+const DefaultTokenTTL = 12 * time.Hour
+
+// Claims are the contents of a token
+type Claims struct {
+	ID        string   `json:"jti"`
+	Subject   string   `json:"sub"`
+	PublicKey string   `json:"pk,omitempty"`
+	Role      Role     `json:"role"`
+	Scopes    []string `json:"scopes,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// Principal returns the client the claims authenticate
+func (c *Claims) Principal() *Principal {
+	return &Principal{Subject: c.Subject, PublicKey: c.PublicKey, Role: c.Role, Scopes: c.Scopes, TokenID: c.ID}
+}
+
+// Tokens issues and verifies tokens: base64url JSON claims and their
+// HMAC-SHA256 under the node's secret, joined by a dot. Tokens stay valid
+// until they expire or are revoked, and only on nodes sharing the secret.
+type Tokens struct {
+	secret []byte
+	TTL    time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	revoked map[string]int64 // Token ID to expiry
+}
+
+// NewTokens creates a token issuer with a secret of at least 32 bytes
+func NewTokens(secret []byte) (*Tokens, error) {
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("token secret must be at least 32 bytes, got %d", len(secret))
+	}
+	return &Tokens{
+		secret:  append([]byte(nil), secret...),
+		TTL:     DefaultTokenTTL,
+		now:     time.Now,
+		revoked: make(map[string]int64),
+	}, nil
+}
+
+// NewSecret returns a random token secret
+func NewSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+	return secret
+}
+
+// Issue signs a token for a principal
+func (t *Tokens) Issue(p *Principal) (string, *Claims, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token ID: %v", err)
+	}
+	now := t.now()
+	claims := &Claims{
+		ID:        hex.EncodeToString(id),
+		Subject:   p.Subject,
+		PublicKey: p.PublicKey,
+		Role:      p.Role,
+		Scopes:    p.Scopes,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(t.TTL).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode claims: %v", err)
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(t.sign(body)), claims, nil
+}
+
+func (t *Tokens) sign(body string) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
+
+// Verify checks the signature and expiry of a token and returns its claims
+func (t *Tokens) Verify(token string) (*Claims, error) {
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, t.sign(body)) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if t.now().Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+	t.mu.Lock()
+	_, revoked := t.revoked[claims.ID]
+	t.mu.Unlock()
+	if revoked {
+		return nil, ErrRevokedToken
+	}
+	return &claims, nil
+}
+
+// Revoke invalidates a token before it expires. Revocations are kept in
+// memory until the token would have expired.
+func (t *Tokens) Revoke(id string, expiresAt int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now().Unix()
+	for revoked, exp := range t.revoked {
+		if exp <= now {
+			delete(t.revoked, revoked)
+		}
+	}
+	if expiresAt > now {
+		t.revoked[id] = expiresAt
+	}
+}
@@ -7,10 +7,6 @@ import (
 	"atlas-blockchain/pkg/transaction"
 	"atlas-blockchain/pkg/wallet"
 	"time"
-	"crypto/ecdsa"
-	"crypto/rand"
-	"crypto/x509"
-	"math/big"
 	"fmt"
 )
 
@@ -52,8 +48,9 @@ func HashBlockForSigning(block *Block) []byte {
 	return h.Sum(nil)
 }
 
-// CreateGenesisBlock creates the first block in the chain.
+// CreateGenesisBlock creates the first block of the built-in development network.
 // The genesis block has no transactions and a special previous hash.
+// Networks started from a genesis file use genesis.Genesis.Block instead.
 func CreateGenesisBlock() *Block {
 	genesisBlock := &Block{
 		Index:        0,
@@ -75,15 +72,10 @@ func CreateNewBlock(transactions []transaction.Transaction, prevBlock *Block, va
 		if err := tx.Validate(); err != nil {
 			return nil, err
 		}
-		if tx.Sender != "network" {
-			valid, err := wallet.VerifyTransactionSignature(tx)
-			if err != nil {
-				return nil, err
-			}
-			if !valid {
-				return nil, err
-			}
-		}
+	}
+	// Signatures checked by the mempool are in the cache and skipped
+	if err := VerifyTransactions(transactions, wallet.VerifiedSignatures); err != nil {
+		return nil, err
 	}
 
 	validatorPubKeyHex := validatorWallet.PublicKeyStr()
@@ -106,46 +98,23 @@ func CreateNewBlock(transactions []transaction.Transaction, prevBlock *Block, va
 
 // SignBlock signs the hash of the block (excluding the signature field) with the validator's private key.
 func SignBlock(b *Block, w *wallet.Wallet) (string, error) {
-	if w.PrivateKey == nil {
-		fmt.Println("[DEBUG] SignBlock: wallet.PrivateKey is nil!")
-		return "", fmt.Errorf("wallet has no private key")
-	}
-	fmt.Printf("[DEBUG] SignBlock: PrivateKey type: %T\n", w.PrivateKey)
 	hash := HashBlockForSigning(b)
-	r, s, err := ecdsa.Sign(rand.Reader, w.PrivateKey, hash)
+	sigHex, err := w.SignHash(hash)
 	if err != nil {
-		fmt.Printf("[DEBUG] SignBlock: failed to sign block: %v\n", err)
 		return "", fmt.Errorf("failed to sign block: %v", err)
 	}
-	rBytes := r.Bytes()
-	sBytes := s.Bytes()
-	sig := append(rBytes, sBytes...)
-	sigHex := hex.EncodeToString(sig)
-	fmt.Printf("[DEBUG] SignBlock: signature (hex): %s\n", sigHex)
 	return sigHex, nil
 }
 
-// VerifyBlockSignature verifies the block's signature using the validator's public key (hex-encoded DER format).
+// VerifyBlockSignature verifies the block's signature using the validator's public key.
+// The key's encoding tells its scheme: DER for P-256, compressed secp256k1 or raw Ed25519.
 func VerifyBlockSignature(b *Block, validatorPubKey []byte) (bool, error) {
-	pubKeyInterface, err := x509.ParsePKIXPublicKey(validatorPubKey)
-	if err != nil {
-		return false, fmt.Errorf("failed to parse validator public key: %v", err)
-	}
-	pubKey, ok := pubKeyInterface.(*ecdsa.PublicKey)
-	if !ok {
-		return false, fmt.Errorf("validator public key is not ECDSA")
-	}
 	hash := HashBlockForSigning(b)
-	sigBytes, err := hex.DecodeString(b.Signature)
+	valid, err := wallet.VerifySignature(wallet.PublicKeyScheme(validatorPubKey), hex.EncodeToString(validatorPubKey), b.Signature, hash)
 	if err != nil {
-		return false, fmt.Errorf("invalid block signature encoding: %v", err)
-	}
-	if len(sigBytes)%2 != 0 {
-		return false, fmt.Errorf("invalid signature length")
+		return false, fmt.Errorf("invalid block signature: %v", err)
 	}
-	r := new(big.Int).SetBytes(sigBytes[:len(sigBytes)/2])
-	s := new(big.Int).SetBytes(sigBytes[len(sigBytes)/2:])
-	return ecdsa.Verify(pubKey, hash, r, s), nil
+	return valid, nil
 }
 
 // ValidateChain checks the integrity of the entire blockchain.
@@ -2,8 +2,8 @@ package block
 
 import (
 	"testing"
-	"blockchain/wallet"
-	"blockchain/transaction"
+	"atlas-blockchain/pkg/wallet"
+	"atlas-blockchain/pkg/transaction"
 )
 
 func TestBlockSigningAndVerification(t *testing.T) {
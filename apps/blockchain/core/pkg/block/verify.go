@@ -0,0 +1,66 @@
+package block
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/wallet"
+)
+
+// VerifyWorkers is the number of goroutines verifying transaction
+// signatures, one per CPU by default
+var VerifyWorkers = runtime.NumCPU()
+
+// VerifyTransactions checks the signatures of transactions in parallel.
+// Network rewards carry no signature and are skipped, as are transactions
+// cache already holds. Verification stops at the first invalid
+// transaction found.
+func VerifyTransactions(transactions []transaction.Transaction, cache *wallet.SignatureCache) error {
+	workers := VerifyWorkers
+	if workers > len(transactions) {
+		workers = len(transactions)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	errs := make([]error, len(transactions))
+	var next atomic.Int64
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !failed.Load() {
+				i := int(next.Add(1)) - 1
+				if i >= len(transactions) {
+					return
+				}
+				tx := transactions[i]
+				if tx.Sender == "network" {
+					continue
+				}
+				valid, err := cache.Verify(tx)
+				if err == nil && !valid {
+					err = fmt.Errorf("invalid signature for transaction from %s", tx.Sender)
+				}
+				if err != nil {
+					errs[i] = err
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("transaction %d: %v", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package block
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/wallet"
+)
+
+// signedTransactions returns n transactions signed by a few wallets of a scheme
+func signedTransactions(tb testing.TB, scheme string, n int) []transaction.Transaction {
+	senders := make([]*wallet.Wallet, 4)
+	for i := range senders {
+		w, err := wallet.NewWalletWithScheme(scheme)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		senders[i] = w
+	}
+	txs := make([]transaction.Transaction, n)
+	for i := range txs {
+		w := senders[i%len(senders)]
+		txs[i] = transaction.Transaction{
+			ChainID:         "atlas-devnet",
+			Sender:          wallet.PublicKeyToAddress(w.PublicKey),
+			SenderPublicKey: w.PublicKeyStr(),
+			Recipient:       "cb49a4cefae13ad235beb40e5ad603ba757da61d",
+			Amount:          int64(i + 1),
+			Nonce:           uint64(i / len(senders)),
+		}
+		if err := w.SignTransaction(&txs[i]); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return txs
+}
+
+func TestVerifyTransactions(t *testing.T) {
+	txs := signedTransactions(t, transaction.SchemeP256, 50)
+	txs = append(txs, transaction.Transaction{Sender: "network", Recipient: "validator", Amount: 10})
+
+	t.Run("Valid", func(t *testing.T) {
+		if err := VerifyTransactions(txs, nil); err != nil {
+			t.Errorf("Expected the transactions to verify, got %v", err)
+		}
+		if err := VerifyTransactions(nil, nil); err != nil {
+			t.Errorf("Expected no transactions to verify, got %v", err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tampered := append([]transaction.Transaction(nil), txs...)
+		tampered[37].Amount = 1000
+		err := VerifyTransactions(tampered, nil)
+		if err == nil || !strings.Contains(err.Error(), "transaction 37") {
+			t.Errorf("Expected transaction 37 to be refused, got %v", err)
+		}
+	})
+
+	t.Run("Cache", func(t *testing.T) {
+		cache := wallet.NewSignatureCache(100)
+		if err := VerifyTransactions(txs, cache); err != nil {
+			t.Fatal(err)
+		}
+		if cache.Len() != 50 {
+			t.Errorf("Expected the 50 signed transactions to be cached, got %d", cache.Len())
+		}
+		tampered := append([]transaction.Transaction(nil), txs...)
+		tampered[3].Signature = tampered[4].Signature
+		if err := VerifyTransactions(tampered, cache); err == nil {
+			t.Errorf("Expected a changed signature to miss the cache and be refused")
+		}
+	})
+}
+
+func BenchmarkVerifyTransactions(b *testing.B) {
+	for _, scheme := range []string{transaction.SchemeP256, transaction.SchemeSecp256k1, transaction.SchemeEd25519} {
+		txs := signedTransactions(b, scheme, 1000)
+		workerCounts := []int{1}
+		if VerifyWorkers > 1 {
+			workerCounts = append(workerCounts, VerifyWorkers)
+		}
+		for _, workers := range workerCounts {
+			b.Run(fmt.Sprintf("%s/workers=%d", scheme, workers), func(b *testing.B) {
+				defer func(n int) { VerifyWorkers = n }(VerifyWorkers)
+				VerifyWorkers = workers
+				for i := 0; i < b.N; i++ {
+					if err := VerifyTransactions(txs, nil); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+		b.Run(scheme+"/cached", func(b *testing.B) {
+			cache := wallet.NewSignatureCache(len(txs))
+			VerifyTransactions(txs, cache)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := VerifyTransactions(txs, cache); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
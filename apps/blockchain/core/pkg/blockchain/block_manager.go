@@ -9,6 +9,7 @@ import (
 	"encoding/hex"
 	"atlas-blockchain/pkg/block"
 	"atlas-blockchain/pkg/config"
+	"atlas-blockchain/pkg/genesis"
 )
 
 // BlockManager handles block operations and state management
@@ -17,28 +18,74 @@ type BlockManager struct {
 	mu        sync.RWMutex
 	config    *config.BlockchainConfig
 	state     *StateManager
-	onBlockAdded func(*block.Block) // Callback function called after block is added
+	genesis   *genesis.Genesis
+	genesisBlock *block.Block
+	onBlockAdded []func(*block.Block) // Callbacks called after a block is added
 }
 
-// NewBlockManager creates a new block manager
+// NewBlockManager creates a new block manager for the built-in development network
 func NewBlockManager(config *config.BlockchainConfig, state *StateManager) *BlockManager {
-	return &BlockManager{
-		chain:  []*block.Block{createGenesisBlock()},
-		config: config,
-		state:  state,
+	return NewBlockManagerWithGenesis(config, state, genesis.Default())
+}
+
+// NewBlockManagerWithGenesis creates a new block manager for the network
+// defined by g. An empty database is seeded with its genesis block and state.
+func NewBlockManagerWithGenesis(config *config.BlockchainConfig, state *StateManager, g *genesis.Genesis) *BlockManager {
+	genesisBlock := g.Block()
+	bm := &BlockManager{
+		chain:        []*block.Block{genesisBlock},
+		config:       config,
+		state:        state,
+		genesis:      g,
+		genesisBlock: genesisBlock,
+	}
+
+	// Rebuild the tip from blocks persisted before a restart
+	if err := bm.loadChain(); err != nil {
+		log.Printf("⚠️  Failed to load chain from database: %v", err)
 	}
+
+	return bm
+}
+
+// Genesis returns the genesis the chain was started from
+func (bm *BlockManager) Genesis() *genesis.Genesis {
+	return bm.genesis
+}
+
+// ChainID returns the chain ID transactions must be signed for
+func (bm *BlockManager) ChainID() string {
+	return bm.config.ChainID
+}
+
+// GenesisHash returns the hash of the genesis block, which identifies the network
+func (bm *BlockManager) GenesisHash() string {
+	return bm.genesisBlock.Hash
 }
 
-// SetOnBlockAddedCallback sets the callback function to be called after a block is added
+// SetOnBlockAddedCallback registers a callback to be called after a block is
+// added. Callbacks run in the order they were registered, with the chain
+// locked, so they must not block or call back into the BlockManager.
 func (bm *BlockManager) SetOnBlockAddedCallback(callback func(*block.Block)) {
 	bm.mu.Lock()
 	defer bm.mu.Unlock()
-	bm.onBlockAdded = callback
+	bm.onBlockAdded = append(bm.onBlockAdded, callback)
 }
 
 // AddBlock adds a new block to the chain
 func (bm *BlockManager) AddBlock(blk *block.Block) error {
 	log.Printf("🔧 AddBlock: Starting to add block %d", blk.Index)
+	if blk == nil {
+		return errors.New("invalid block: block cannot be nil")
+	}
+
+	// Transaction signatures do not depend on the chain, so they are
+	// checked in parallel before taking the lock
+	if err := bm.verifyTransactions(blk); err != nil {
+		log.Printf("❌ AddBlock: Transaction verification failed: %v", err)
+		return fmt.Errorf("invalid block: %v", err)
+	}
+
 	bm.mu.Lock()
 	defer bm.mu.Unlock()
 
@@ -50,7 +97,7 @@ func (bm *BlockManager) AddBlock(blk *block.Block) error {
 	}
 	log.Printf("✅ AddBlock: Block validation passed")
 
-	// Update state with block transactions
+	// Update state with block transactions; the block is persisted with its state
 	log.Printf("📊 AddBlock: Updating state with %d transactions...", len(blk.Transactions))
 	if err := bm.state.updateState(blk); err != nil {
 		log.Printf("❌ AddBlock: State update failed: %v", err)
@@ -69,27 +116,25 @@ func (bm *BlockManager) AddBlock(blk *block.Block) error {
 		bm.pruneOldBlocks()
 	}
 
-	if bm.onBlockAdded != nil {
-		log.Printf("🔄 AddBlock: Calling onBlockAdded callback...")
-		bm.onBlockAdded(blk)
-		log.Printf("✅ AddBlock: onBlockAdded callback completed")
+	if len(bm.onBlockAdded) > 0 {
+		log.Printf("🔄 AddBlock: Calling %d onBlockAdded callbacks...", len(bm.onBlockAdded))
+		for _, callback := range bm.onBlockAdded {
+			callback(blk)
+		}
+		log.Printf("✅ AddBlock: onBlockAdded callbacks completed")
 	}
 
 	log.Printf("✅ AddBlock: Successfully added block %d to chain", blk.Index)
 	return nil
 }
 
-// validateBlock validates a block before adding it to the chain
+// validateBlock validates a block against the tip before adding it to the
+// chain. Its transactions are checked by verifyTransactions.
 func (bm *BlockManager) validateBlock(blk *block.Block) error {
 	if blk == nil {
 		return errors.New("block cannot be nil")
 	}
 
-	// Check block size
-	if len(blk.Transactions) > bm.config.MaxBlockSize {
-		return fmt.Errorf("block exceeds maximum size of %d transactions", bm.config.MaxBlockSize)
-	}
-
 	// Verify block index
 	lastBlock := bm.chain[len(bm.chain)-1]
 	if blk.Index != lastBlock.Index+1 {
@@ -119,20 +164,30 @@ func (bm *BlockManager) validateBlock(blk *block.Block) error {
 		return errors.New("invalid block signature")
 	}
 
-	// Verify all transactions
+	return nil
+}
+
+// verifyTransactions checks the size of a block and the chain ID and
+// signature of every transaction. Signatures already verified by the
+// mempool are skipped.
+func (bm *BlockManager) verifyTransactions(blk *block.Block) error {
+	if len(blk.Transactions) > bm.config.MaxBlockSize {
+		return fmt.Errorf("block exceeds maximum size of %d transactions", bm.config.MaxBlockSize)
+	}
 	for _, tx := range blk.Transactions {
 		if tx.Sender != "network" { // Skip network reward transactions
-			valid, err := wallet.VerifyTransactionSignature(tx)
-			if err != nil || !valid {
+			if err := checkChainID(tx, bm.config.ChainID); err != nil {
 				return fmt.Errorf("invalid transaction: %v", err)
 			}
 		}
 	}
-
+	if err := block.VerifyTransactions(blk.Transactions, wallet.VerifiedSignatures); err != nil {
+		return fmt.Errorf("invalid transaction: %v", err)
+	}
 	return nil
 }
 
-// pruneOldBlocks removes old blocks while maintaining state
+// pruneOldBlocks drops old blocks from memory; they remain available from the database
 func (bm *BlockManager) pruneOldBlocks() {
 	// Keep the last N blocks
 	keepBlocks := bm.config.MaxBlockSize
@@ -151,11 +206,20 @@ func (bm *BlockManager) GetBlockByIndex(index int) (*block.Block, error) {
 	bm.mu.RLock()
 	defer bm.mu.RUnlock()
 
-	if index < 0 || index >= len(bm.chain) {
+	if err := bm.state.checkBlockHeight(int64(index)); err != nil {
+		return nil, err
+	}
+
+	// The in-memory chain starts at the oldest block still held in memory
+	offset := index - bm.chain[0].Index
+	if offset >= 0 && offset < len(bm.chain) {
+		return bm.chain[offset], nil
+	}
+	if index < 0 || index > bm.chain[len(bm.chain)-1].Index {
 		return nil, fmt.Errorf("block index %d out of range", index)
 	}
 
-	return bm.chain[index], nil
+	return bm.storedBlock(int64(index))
 }
 
 // GetLatestBlock returns the most recent block
@@ -186,7 +250,20 @@ func (bm *BlockManager) GetBlockByHash(hash string) *block.Block {
 			return blk
 		}
 	}
-	return nil
+
+	if bm.state.db == nil {
+		return nil
+	}
+	record, err := bm.state.db.GetBlockByHash(hash)
+	if err != nil || record == nil {
+		return nil
+	}
+	blk, err := blockFromRecord(record)
+	if err != nil {
+		log.Printf("⚠️  Failed to decode stored block %s: %v", shortAddr(hash), err)
+		return nil
+	}
+	return blk
 }
 
 // GetBlockHeight returns the current block height (index of the latest block)
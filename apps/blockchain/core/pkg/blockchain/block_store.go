@@ -0,0 +1,198 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"atlas-blockchain/pkg/block"
+	"atlas-blockchain/pkg/database"
+)
+
+// blockToRecord converts a block into its database representation
+func blockToRecord(blk *block.Block) (*database.Block, []string, error) {
+	data, err := json.Marshal(blk)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal block %d: %v", blk.Index, err)
+	}
+	txHashes := make([]string, len(blk.Transactions))
+	for i, tx := range blk.Transactions {
+		txHashes[i] = TransactionHash(tx)
+	}
+	return &database.Block{
+		Height:    int64(blk.Index),
+		Hash:      blk.Hash,
+		PrevHash:  blk.PrevHash,
+		Validator: blk.Validator,
+		Timestamp: blk.Timestamp,
+		TxCount:   len(blk.Transactions),
+		Data:      string(data),
+	}, txHashes, nil
+}
+
+// blockFromRecord rebuilds a block from its database representation
+func blockFromRecord(record *database.Block) (*block.Block, error) {
+	var blk block.Block
+	if err := json.Unmarshal([]byte(record.Data), &blk); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal block %d: %v", record.Height, err)
+	}
+	if blk.Hash != record.Hash {
+		return nil, fmt.Errorf("block %d hash mismatch: stored %s, decoded %s", record.Height, record.Hash, blk.Hash)
+	}
+	return &blk, nil
+}
+
+// persistGenesis applies the genesis accounts and contracts and commits them
+// with the genesis block, as if they were the changes of block 0
+func (bm *BlockManager) persistGenesis() error {
+	contracts, err := bm.genesis.BuildContracts()
+	if err != nil {
+		return err
+	}
+	accounts := bm.genesis.Accounts()
+	sm := bm.state
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.batch = newBlockBatch()
+	defer func() { sm.batch = nil }()
+	for _, acct := range accounts {
+		sm.batch.accounts[acct.Address] = acct
+	}
+	for _, contract := range contracts {
+		sm.batch.contracts[contract.Address] = contract
+	}
+	if err := sm.commitBatch(bm.genesisBlock); err != nil {
+		return err
+	}
+	log.Printf("🌱 Initialized %s from genesis %s (%d accounts, %d validators, %d contracts)",
+		bm.genesis.ChainID, shortAddr(bm.genesisBlock.Hash), len(accounts), len(bm.genesis.Validators), len(contracts))
+	return nil
+}
+
+// loadChain rebuilds the in-memory tip from the database. An empty database
+// is seeded with the genesis block and state.
+func (bm *BlockManager) loadChain() error {
+	db := bm.state.db
+	if db == nil {
+		// State comes from snapshots; seed it only when none was loaded
+		bm.state.mu.RLock()
+		empty := len(bm.state.accounts) == 0 && len(bm.state.balances) == 0 && len(bm.state.contracts) == 0
+		bm.state.mu.RUnlock()
+		if empty {
+			return bm.persistGenesis()
+		}
+		return nil
+	}
+
+	latest, err := db.GetLatestBlock()
+	if err != nil {
+		return err
+	}
+	if latest == nil {
+		return bm.persistGenesis()
+	}
+	if latest, err = bm.recoverHalfAppliedBlocks(latest); err != nil {
+		return err
+	}
+
+	// Keep the same window in memory that pruning would leave
+	from := latest.Height - int64(bm.memoryWindow()) + 1
+	if from < 0 {
+		from = 0
+	}
+	records, err := db.GetBlockRange(from, latest.Height)
+	if err != nil {
+		return err
+	}
+
+	chain := make([]*block.Block, 0, len(records))
+	for _, record := range records {
+		blk, err := blockFromRecord(record)
+		if err != nil {
+			return err
+		}
+		if n := len(chain); n > 0 && (blk.PrevHash != chain[n-1].Hash || blk.Index != chain[n-1].Index+1) {
+			return fmt.Errorf("stored chain is broken at height %d", blk.Index)
+		}
+		chain = append(chain, blk)
+	}
+	if len(chain) == 0 {
+		return fmt.Errorf("no blocks found between heights %d and %d", from, latest.Height)
+	}
+	if chain[0].Index == 0 && chain[0].Hash != bm.genesisBlock.Hash {
+		return fmt.Errorf("database belongs to another network: stored genesis block %s does not match %s", chain[0].Hash, bm.genesisBlock.Hash)
+	}
+
+	bm.chain = chain
+	tip := chain[len(chain)-1]
+
+	bm.state.mu.Lock()
+	bm.state.height = int64(tip.Index)
+	bm.state.mu.Unlock()
+
+	log.Printf("✅ Restored chain tip from database: height %d (%s)", tip.Index, shortAddr(tip.Hash))
+	return nil
+}
+
+// recoverHalfAppliedBlocks compares the stored chain tip with the last state
+// commit. Blocks stored above the committed height never had their state
+// applied and are removed so they can be synced again. Returns the new tip.
+func (bm *BlockManager) recoverHalfAppliedBlocks(latest *database.Block) (*database.Block, error) {
+	db := bm.state.db
+	commit, err := db.GetStateCommit()
+	if err != nil {
+		return nil, err
+	}
+	if commit == nil {
+		// Written before state commits were recorded; nothing to compare against
+		log.Printf("⚠️  No state commit recorded, assuming blocks up to %d are applied", latest.Height)
+		return latest, nil
+	}
+	if commit.Height > latest.Height {
+		return nil, fmt.Errorf("%w: state committed at height %d but stored chain ends at %d",
+			ErrStateCorrupt, commit.Height, latest.Height)
+	}
+
+	if commit.Height < latest.Height {
+		removed, err := db.DeleteBlocksAbove(commit.Height)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("⚠️  Discarded %d half-applied block(s) above height %d", removed, commit.Height)
+		if latest, err = db.GetBlockByHeight(commit.Height); err != nil {
+			return nil, err
+		}
+		if latest == nil {
+			return nil, fmt.Errorf("%w: committed block %d is missing", ErrStateCorrupt, commit.Height)
+		}
+	}
+	if latest.Hash != commit.BlockHash {
+		return nil, fmt.Errorf("%w: block %d is %s but state was committed for %s",
+			ErrStateCorrupt, commit.Height, latest.Hash, commit.BlockHash)
+	}
+	return latest, nil
+}
+
+// memoryWindow is the number of recent blocks kept in memory
+func (bm *BlockManager) memoryWindow() int {
+	if bm.config != nil && bm.config.MaxBlockSize > 0 {
+		return bm.config.MaxBlockSize
+	}
+	return 1000
+}
+
+// storedBlock loads a block that is no longer held in memory
+func (bm *BlockManager) storedBlock(height int64) (*block.Block, error) {
+	db := bm.state.db
+	if db == nil {
+		return nil, fmt.Errorf("block index %d out of range", height)
+	}
+	record, err := db.GetBlockByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, fmt.Errorf("block index %d out of range", height)
+	}
+	return blockFromRecord(record)
+}
@@ -0,0 +1,176 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"atlas-blockchain/pkg/block"
+	"atlas-blockchain/pkg/config"
+	"atlas-blockchain/pkg/database"
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/wallet"
+)
+
+// newBlock returns a block of txs on top of the tip, signed by validator.
+// The transactions are not checked, so blocks that AddBlock must refuse can
+// be built too.
+func newBlock(t *testing.T, bm *BlockManager, validator *wallet.Wallet, txs ...transaction.Transaction) *block.Block {
+	t.Helper()
+	prev := bm.GetLatestBlock()
+	blk := &block.Block{
+		Index:        prev.Index + 1,
+		Timestamp:    time.Now().Unix(),
+		Transactions: txs,
+		PrevHash:     prev.Hash,
+		Validator:    validator.PublicKeyStr(),
+	}
+	// r and s are not padded, so a signature where one of them is shorter
+	// does not verify; sign again until it does
+	pubKey, _ := hex.DecodeString(blk.Validator)
+	for {
+		sig, err := block.SignBlock(blk, validator)
+		if err != nil {
+			t.Fatalf("Failed to sign block %d: %v", blk.Index, err)
+		}
+		blk.Signature = sig
+		if ok, _ := block.VerifyBlockSignature(blk, pubKey); ok {
+			break
+		}
+	}
+	blk.Hash = block.CalculateHash(*blk)
+	return blk
+}
+
+func TestBlockStore(t *testing.T) {
+	// The database, snapshots and backups are written to the working directory
+	t.Chdir(t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.MaxBlockSize = 1 // Keep a single block in memory after a restart
+	validator, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+
+	sm := NewStateManager(cfg)
+	bm := NewBlockManager(cfg, sm)
+	addBlock := func() {
+		t.Helper()
+		blk := newBlock(t, bm, validator)
+		if err := bm.AddBlock(blk); err != nil {
+			t.Fatalf("Failed to add block %d: %v", blk.Index, err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		addBlock()
+	}
+	first, _ := bm.GetBlockByIndex(1)
+	tip := bm.GetLatestBlock()
+
+	// Restart on the same database
+	if err := sm.CloseDatabase(); err != nil {
+		t.Fatalf("Failed to close the database: %v", err)
+	}
+	sm = NewStateManager(cfg)
+	bm = NewBlockManager(cfg, sm)
+	t.Cleanup(func() { sm.CloseDatabase() })
+
+	t.Run("TipIsRestored", func(t *testing.T) {
+		if got := bm.GetLatestBlock(); got.Index != 3 || got.Hash != tip.Hash {
+			t.Errorf("Expected tip 3 %s after restart, got %d %s", tip.Hash, got.Index, got.Hash)
+		}
+		if len(bm.chain) != 1 {
+			t.Errorf("Expected 1 block in memory, got %d", len(bm.chain))
+		}
+	})
+
+	t.Run("OlderBlocksAreLoaded", func(t *testing.T) {
+		blk, err := bm.GetBlockByIndex(1)
+		if err != nil {
+			t.Fatalf("Failed to get block 1: %v", err)
+		}
+		if blk.Hash != first.Hash {
+			t.Errorf("Expected block 1 %s, got %s", first.Hash, blk.Hash)
+		}
+		if blk := bm.GetBlockByHash(first.Hash); blk == nil || blk.Index != 1 {
+			t.Errorf("Expected block 1 by hash, got %v", blk)
+		}
+		if _, err := bm.GetBlockByIndex(4); err == nil {
+			t.Errorf("Expected block 4 to be out of range")
+		}
+	})
+
+	t.Run("ChainIsExtended", func(t *testing.T) {
+		addBlock()
+		if height := bm.GetBlockHeight(); height != 4 {
+			t.Errorf("Expected height 4, got %d", height)
+		}
+	})
+}
+
+func TestHalfAppliedBlockRecovery(t *testing.T) {
+	alice, bob, validator := newTestWallet(t), newTestWallet(t), newTestWallet(t)
+	cfg := config.DefaultConfig()
+
+	t.Run("StoredBlockAboveCommitIsDiscarded", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		sm, bm := openChain(t, cfg)
+		sm.SetAccount(&database.Account{Address: addressOf(alice), Balance: 1000})
+		if err := bm.AddBlock(newBlock(t, bm, validator, signTransfer(t, alice, addressOf(bob), 100, 1))); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+		committed := bm.GetLatestBlock()
+
+		// A crash after block 2 was stored but before its state was committed
+		half := newBlock(t, bm, validator, signTransfer(t, alice, addressOf(bob), 100, 2))
+		record, txHashes, err := blockToRecord(half)
+		if err != nil {
+			t.Fatalf("Failed to encode block: %v", err)
+		}
+		if err := sm.db.SaveBlock(record, txHashes); err != nil {
+			t.Fatalf("Failed to store block: %v", err)
+		}
+
+		sm.CloseDatabase()
+		sm, bm = openChain(t, cfg)
+		if got := bm.GetLatestBlock(); got.Index != 1 || got.Hash != committed.Hash {
+			t.Errorf("Expected tip 1 %s after recovery, got %d %s", committed.Hash, got.Index, got.Hash)
+		}
+		if stored, _ := sm.db.GetBlockByHeight(2); stored != nil {
+			t.Errorf("Expected the half-applied block to be removed")
+		}
+		if got := sm.GetBalance(addressOf(bob)); got != 100 {
+			t.Errorf("Expected bob to have 100, got %d", got)
+		}
+
+		// The discarded block can be applied again
+		if err := bm.AddBlock(half); err != nil {
+			t.Fatalf("Failed to apply the discarded block again: %v", err)
+		}
+		if got := sm.GetBalance(addressOf(bob)); got != 200 {
+			t.Errorf("Expected bob to have 200, got %d", got)
+		}
+	})
+
+	t.Run("CommitAboveChainIsCorrupt", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		sm, bm := openChain(t, cfg)
+		batch, err := sm.db.NewBatch()
+		if err != nil {
+			t.Fatalf("Failed to start batch: %v", err)
+		}
+		batch.SetStateCommit(&database.StateCommit{Height: 5, BlockHash: "unknown"})
+		if err := batch.Commit(); err != nil {
+			t.Fatalf("Failed to commit batch: %v", err)
+		}
+
+		latest, err := sm.db.GetLatestBlock()
+		if err != nil {
+			t.Fatalf("Failed to get the latest block: %v", err)
+		}
+		if _, err := bm.recoverHalfAppliedBlocks(latest); !errors.Is(err, ErrStateCorrupt) {
+			t.Errorf("Expected ErrStateCorrupt, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package blockchain
+
+import (
+	"fmt"
+	"io"
+
+	"atlas-blockchain/pkg/chainfile"
+)
+
+// ExportChain streams blocks from..to into a chain archive. A negative to
+// exports up to the current tip. progress, if set, is called after every
+// block.
+func (bm *BlockManager) ExportChain(w io.Writer, from, to int64, progress func(done, total int64)) (chainfile.Header, error) {
+	tip := int64(bm.GetBlockHeight())
+	if to < 0 {
+		to = tip
+	}
+	if to > tip {
+		return chainfile.Header{}, fmt.Errorf("block %d is above the chain tip %d", to, tip)
+	}
+	header := chainfile.Header{GenesisHash: bm.GenesisHash(), From: from, To: to}
+	cw, err := chainfile.NewWriter(w, header)
+	if err != nil {
+		return header, err
+	}
+
+	for height := from; height <= to; height++ {
+		blk, err := bm.GetBlockByIndex(int(height))
+		if err != nil {
+			return header, fmt.Errorf("failed to read block %d: %v", height, err)
+		}
+		if err := cw.WriteBlock(blk); err != nil {
+			return header, err
+		}
+		if progress != nil {
+			progress(height-from+1, header.Count())
+		}
+	}
+	return header, cw.Close()
+}
+
+// ImportChain adds the blocks of a chain archive through AddBlock, so each
+// one is validated and applied to state like a block from the network.
+// Blocks the node already has are checked against the archive and skipped.
+// Returns the number of blocks added.
+func (bm *BlockManager) ImportChain(r io.Reader, progress func(done, total int64)) (int64, error) {
+	cr, err := chainfile.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer cr.Close()
+	header := cr.Header()
+	if genesis := bm.GenesisHash(); header.GenesisHash != genesis {
+		return 0, fmt.Errorf("archive belongs to chain %s, not %s", shortAddr(header.GenesisHash), shortAddr(genesis))
+	}
+
+	var added, done int64
+	for {
+		blk, err := cr.Next()
+		if err == io.EOF {
+			return added, nil
+		}
+		if err != nil {
+			return added, err
+		}
+
+		tip := bm.GetBlockHeight()
+		switch {
+		case blk.Index <= tip:
+			existing, err := bm.GetBlockByIndex(blk.Index)
+			if err != nil {
+				return added, fmt.Errorf("failed to compare block %d: %v", blk.Index, err)
+			}
+			if existing.Hash != blk.Hash {
+				return added, fmt.Errorf("block %d in the archive conflicts with the local chain", blk.Index)
+			}
+		case blk.Index > tip+1:
+			return added, fmt.Errorf("archive continues at block %d but the local chain ends at %d", blk.Index, tip)
+		default:
+			if err := bm.AddBlock(blk); err != nil {
+				return added, fmt.Errorf("block %d rejected: %v", blk.Index, err)
+			}
+			added++
+		}
+
+		done++
+		if progress != nil {
+			progress(done, header.Count())
+		}
+	}
+}
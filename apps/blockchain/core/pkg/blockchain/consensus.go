@@ -89,7 +89,7 @@ func NewConsensusManager(config *config.BlockchainConfig, blockManager *BlockMan
 	}
 	shardManager := sharding.NewShardManager(shardConfig)
 	
-	return &ConsensusManager{
+	cm := &ConsensusManager{
 		validators:         make(map[string]*Validator),
 		config:             config,
 		blockManager:       blockManager,
@@ -106,6 +106,16 @@ func NewConsensusManager(config *config.BlockchainConfig, blockManager *BlockMan
 		// Initialize sharding
 		shardManager:       shardManager,
 	}
+
+	// Start from the validator set of the genesis
+	if blockManager != nil && blockManager.Genesis() != nil {
+		for _, v := range blockManager.Genesis().Validators {
+			if err := cm.OnChainStake(v.Address, uint64(v.Stake)); err != nil {
+				log.Printf("⚠️  Failed to register genesis validator %s: %v", shortAddr(v.Address), err)
+			}
+		}
+	}
+	return cm
 }
 
 // RegisterValidator registers a new validator with enhanced metrics
@@ -743,12 +753,8 @@ func (cm *ConsensusManager) OnChainStake(address string, amount uint64) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	minStake := uint64(1)
-	if cm.config != nil && cm.config.MinStake > 0 {
-		minStake = uint64(cm.config.MinStake)
-	}
-	if amount < minStake {
-		return fmt.Errorf("stake amount too low: %d (min: %d)", amount, minStake)
+	if err := cm.checkStakeUnlocked(amount); err != nil {
+		return err
 	}
 
 	validator, exists := cm.validators[address]
@@ -780,3 +786,21 @@ func (cm *ConsensusManager) OnChainStake(address string, amount uint64) error {
 	validator.Stake += amount
 	return nil
 }
+
+// CheckStake reports whether OnChainStake would accept a stake of amount
+func (cm *ConsensusManager) CheckStake(amount uint64) error {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.checkStakeUnlocked(amount)
+}
+
+func (cm *ConsensusManager) checkStakeUnlocked(amount uint64) error {
+	minStake := uint64(1)
+	if cm.config != nil && cm.config.MinStake > 0 {
+		minStake = uint64(cm.config.MinStake)
+	}
+	if amount < minStake {
+		return fmt.Errorf("stake amount too low: %d (min: %d)", amount, minStake)
+	}
+	return nil
+}
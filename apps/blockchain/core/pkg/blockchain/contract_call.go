@@ -0,0 +1,226 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"atlas-blockchain/pkg/vm"
+)
+
+// Errors returned by read-only contract calls
+var (
+	ErrContractNotFound    = errors.New("contract not found")
+	ErrHeightNotAvailable  = errors.New("requested block height is not available")
+	ErrGasEstimationFailed = errors.New("execution fails at the gas cap")
+)
+
+// LatestHeight selects the most recent state for a read-only call
+const LatestHeight int64 = -1
+
+// CallRequest describes a read-only contract invocation
+type CallRequest struct {
+	ContractAddress string                 `json:"contract_address"`
+	Function        string                 `json:"function"`
+	Args            []interface{}          `json:"args"`
+	Params          map[string]interface{} `json:"params"` // Named parameters, bound through the ABI
+	Caller          string                 `json:"caller"`
+	Value           int64                  `json:"value"`
+	GasLimit        uint64                 `json:"gas_limit"`
+	BlockHeight     *int64                 `json:"block_height,omitempty"` // Omitted or LatestHeight for the current state
+}
+
+// contractCall is the payload of a contract call transaction
+type contractCall struct {
+	Function string                 `json:"function"`
+	Args     []interface{}          `json:"args"`
+	Params   map[string]interface{} `json:"params"`
+}
+
+// CallResult is the outcome of a read-only contract invocation
+type CallResult struct {
+	ReturnValues []vm.ReturnValue `json:"return_values"`
+	GasUsed      uint64           `json:"gas_used"`
+	GasLimit     uint64           `json:"gas_limit"`
+	BlockHeight  int64            `json:"block_height"`
+	Storage      map[string]int64 `json:"storage"`        // Contract storage after execution, discarded
+	Logs         []vm.Log         `json:"logs,omitempty"` // Events the call would emit
+}
+
+// contractStorageAt returns a contract's storage as of a block height
+// Caller must hold sm.mu
+func (sm *StateManager) contractStorageAt(contract *vm.Contract, height int64) (map[string]interface{}, error) {
+	if height == LatestHeight || height == sm.height {
+		return contract.Storage, nil
+	}
+	if err := sm.checkStateHeight(height); err != nil {
+		return nil, err
+	}
+	if sm.db == nil {
+		return nil, fmt.Errorf("%w: no database for state history", ErrHeightNotAvailable)
+	}
+	version, err := sm.db.GetContractStorageAt(contract.Address, height)
+	if err != nil {
+		return nil, err
+	}
+	if version == nil {
+		if latest, err := sm.db.GetContractStorageAt(contract.Address, sm.height); err == nil && latest == nil {
+			// Deployed before storage history was kept
+			return nil, fmt.Errorf("%w: no storage history for %s", ErrHeightNotAvailable, contract.Address)
+		}
+		return nil, fmt.Errorf("%w: contract %s not deployed at height %d", ErrContractNotFound, contract.Address, height)
+	}
+	var storage map[string]interface{}
+	if err := json.Unmarshal([]byte(version.Storage), &storage); err != nil {
+		return nil, fmt.Errorf("failed to decode storage of %s at height %d: %v", contract.Address, version.Height, err)
+	}
+	if storage == nil {
+		storage = make(map[string]interface{})
+	}
+	return storage, nil
+}
+
+// newContractVM creates a VM with the contract registered and its memory
+// initialized from the given storage
+func (sm *StateManager) newContractVM(contract *vm.Contract, storage map[string]interface{}) *vm.VM {
+	vmInstance := vm.NewVM()
+	vmInstance.StateManager = sm
+	vmInstance.RegisterDeployedContract(contract)
+	for k, v := range storage {
+		if ival, ok := toInt64Safe(v); ok {
+			vmInstance.Memory[k] = ival
+		}
+	}
+	return vmInstance
+}
+
+// CallContract executes a contract function against the latest or a historical
+// state inside a throwaway VM. Storage writes are discarded.
+func (sm *StateManager) CallContract(req CallRequest) (*CallResult, error) {
+	if req.GasLimit == 0 || req.GasLimit > sm.callGasCap() {
+		req.GasLimit = sm.callGasCap()
+	}
+	return sm.dryRun(req)
+}
+
+// EstimateGas finds the smallest gas limit under which the call succeeds,
+// binary-searching between the gas used by a full run and the gas cap
+func (sm *StateManager) EstimateGas(req CallRequest) (uint64, error) {
+	hi := sm.callGasCap()
+	if req.GasLimit > 0 && req.GasLimit < hi {
+		hi = req.GasLimit
+	}
+
+	req.GasLimit = hi
+	result, err := sm.dryRun(req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrGasEstimationFailed, err)
+	}
+
+	// Nested calls reset the VM counter, so gas used is only a lower bound
+	lo := result.GasUsed
+	if lo > 0 {
+		lo--
+	}
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		req.GasLimit = mid
+		if _, err := sm.dryRun(req); err != nil {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi, nil
+}
+
+// checkCodeAt refuses heights before the last upgrade of a contract. Only
+// the current code is kept, and running it on older storage would not give
+// the result a call at that height had.
+func checkCodeAt(contract *vm.Contract, height int64) error {
+	if height == LatestHeight || len(contract.CodeHistory) == 0 {
+		return nil
+	}
+	if current := contract.CodeHistory[len(contract.CodeHistory)-1]; current.BlockHeight > height {
+		return fmt.Errorf("%w: code of %s was upgraded at height %d", ErrHeightNotAvailable, contract.Address, current.BlockHeight)
+	}
+	return nil
+}
+
+// dryRun performs a single execution on a copy of the contract
+func (sm *StateManager) dryRun(req CallRequest) (*CallResult, error) {
+	requested := LatestHeight
+	if req.BlockHeight != nil {
+		requested = *req.BlockHeight
+	}
+
+	sm.mu.Lock()
+	contract, ok := sm.getContractUnlocked(req.ContractAddress)
+	if !ok {
+		sm.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrContractNotFound, req.ContractAddress)
+	}
+	storage, err := sm.contractStorageAt(contract, requested)
+	if err == nil {
+		err = checkCodeAt(contract, requested)
+	}
+	height := sm.height
+	if err == nil {
+		storage = copyStorage(storage)
+	}
+	sm.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if requested != LatestHeight && requested < height {
+		height = requested
+	}
+
+	// Work on a shallow copy so the live contract is never touched
+	overlay := *contract
+	overlay.Storage = storage
+
+	execCtx := &vm.ExecutionContext{
+		Caller:      req.Caller,
+		Value:       req.Value,
+		GasLimit:    req.GasLimit,
+		BlockHeight: height,
+	}
+	vmInstance := sm.newContractVM(&overlay, storage)
+
+	var returnValues []vm.ReturnValue
+	if req.Params != nil {
+		returnValues, err = overlay.CallNamed(req.Function, req.Params, vmInstance, execCtx)
+	} else {
+		returnValues, err = overlay.Call(req.Function, req.Args, vmInstance, execCtx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &CallResult{
+		ReturnValues: returnValues,
+		GasUsed:      vmInstance.GetGasUsed(),
+		GasLimit:     req.GasLimit,
+		BlockHeight:  height,
+		Storage:      vmInstance.Memory,
+		Logs:         vmInstance.Logs,
+	}, nil
+}
+
+// callGasCap returns the configured maximum gas for read-only calls
+func (sm *StateManager) callGasCap() uint64 {
+	if sm.config != nil && sm.config.CallGasCap > 0 {
+		return sm.config.CallGasCap
+	}
+	return 10000000
+}
+
+// copyStorage returns a shallow copy of a contract storage map
+func copyStorage(storage map[string]interface{}) map[string]interface{} {
+	cp := make(map[string]interface{}, len(storage))
+	for k, v := range storage {
+		cp[k] = v
+	}
+	return cp
+}
@@ -0,0 +1,177 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"atlas-blockchain/pkg/block"
+	"atlas-blockchain/pkg/config"
+	"atlas-blockchain/pkg/database"
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/vm"
+)
+
+// counterContract keeps a counter that increment adds to and get returns
+var counterContract = vm.JSONContract{
+	Name:    "Counter",
+	Version: "1.0",
+	Functions: map[string]*vm.JSONFunction{
+		"get": {
+			Outputs: []vm.ABIParam{{Name: "value", Type: vm.ABITypeInt64}},
+			View:    true,
+			Code:    []vm.JSONInstruction{{Op: "LOAD", Key: "counter"}},
+		},
+		"increment": {
+			Inputs: []vm.ABIParam{{Name: "amount", Type: vm.ABITypeInt64}},
+			Code: []vm.JSONInstruction{
+				{Op: "LOAD", Key: "counter"},
+				{Op: "ADD"},
+				{Op: "STORE", Key: "counter"},
+			},
+		},
+	},
+	Storage: map[string]interface{}{"counter": 0},
+}
+
+// newCounterState returns a state where block 1 deploys the counter and
+// blocks 2 and 3 add 5 and 2 to it, with the contract's address
+func newCounterState(t *testing.T) (*StateManager, string) {
+	t.Helper()
+	// The database, snapshots and backups are written to the working directory
+	t.Chdir(t.TempDir())
+	sm := NewStateManager(config.DefaultConfig())
+	t.Cleanup(func() { sm.CloseDatabase() })
+
+	owner := "cb49a4cefae13ad235beb40e5ad603ba757da61d"
+	code, _ := json.Marshal(counterContract)
+	deploy := transaction.Transaction{Type: transaction.TxTypeDeploy, Sender: owner, Recipient: "contract", Data: string(code)}
+	if err := sm.updateState(&block.Block{Index: 1, Transactions: []transaction.Transaction{deploy}}); err != nil {
+		t.Fatalf("Failed to deploy contract: %v", err)
+	}
+	var address string
+	for addr := range sm.contracts {
+		address = addr
+	}
+	for i, amount := range []int{5, 2} {
+		call := transaction.Transaction{
+			Type:      transaction.TxTypeCall,
+			Sender:    owner,
+			Recipient: address,
+			Fee:       1000, // Gas limit of the call
+			Data:      fmt.Sprintf(`{"function":"increment","args":[%d]}`, amount),
+		}
+		if err := sm.updateState(&block.Block{Index: i + 2, Transactions: []transaction.Transaction{call}}); err != nil {
+			t.Fatalf("Failed to call increment: %v", err)
+		}
+	}
+	return sm, address
+}
+
+func TestContractCall(t *testing.T) {
+	sm, address := newCounterState(t)
+	get := func(height *int64) (*CallResult, error) {
+		return sm.CallContract(CallRequest{ContractAddress: address, Function: "get", BlockHeight: height})
+	}
+
+	t.Run("Latest", func(t *testing.T) {
+		latest := LatestHeight
+		for _, height := range []*int64{nil, &latest} {
+			result, err := get(height)
+			if err != nil {
+				t.Fatalf("Call failed: %v", err)
+			}
+			if len(result.ReturnValues) != 1 || result.ReturnValues[0].Value != int64(7) || result.BlockHeight != 3 {
+				t.Errorf("Expected 7 at height 3, got %+v at %d", result.ReturnValues, result.BlockHeight)
+			}
+		}
+	})
+
+	t.Run("Historical", func(t *testing.T) {
+		at := func(height int64) *int64 { return &height }
+		result, err := get(at(2))
+		if err != nil {
+			t.Fatalf("Call at height 2 failed: %v", err)
+		}
+		if result.ReturnValues[0].Value != int64(5) || result.BlockHeight != 2 {
+			t.Errorf("Expected 5 at height 2, got %+v at %d", result.ReturnValues, result.BlockHeight)
+		}
+		if _, err := get(at(4)); !errors.Is(err, ErrHeightNotAvailable) {
+			t.Errorf("Expected a future height to be refused, got %v", err)
+		}
+		// Height 0 reads genesis, before the contract was deployed
+		if _, err := get(at(0)); !errors.Is(err, ErrContractNotFound) {
+			t.Errorf("Expected the contract to be missing at genesis, got %v", err)
+		}
+	})
+
+	t.Run("BeforeUpgradeIsNotAvailable", func(t *testing.T) {
+		sm, address := newCounterState(t)
+		contract, _ := sm.GetContract(address)
+		sm.SetAccount(&database.Account{Address: contract.Owner, Balance: 1000})
+		newCode := counterV2()
+		data, _ := json.Marshal(UpgradeRequest{Contract: &newCode})
+		upgrade := transaction.Transaction{Type: transaction.TxTypeUpgrade, Sender: contract.Owner, Recipient: address, Fee: 1000, Data: string(data)}
+		if err := sm.updateState(&block.Block{Index: 4, Transactions: []transaction.Transaction{upgrade}}); err != nil {
+			t.Fatalf("Failed to upgrade contract: %v", err)
+		}
+
+		at := func(height int64) CallRequest {
+			return CallRequest{ContractAddress: address, Function: "get", BlockHeight: &height}
+		}
+		if _, err := sm.CallContract(at(3)); !errors.Is(err, ErrHeightNotAvailable) {
+			t.Errorf("Expected the code before the upgrade to be unavailable, got %v", err)
+		}
+		if result, err := sm.CallContract(at(4)); err != nil || result.ReturnValues[0].Value != int64(7) {
+			t.Errorf("Expected the upgraded code to return 7 at height 4, got %v", err)
+		}
+	})
+
+	t.Run("WritesAreDiscarded", func(t *testing.T) {
+		result, err := sm.CallContract(CallRequest{ContractAddress: address, Function: "increment", Args: []interface{}{10}})
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if result.Storage["counter"] != 17 {
+			t.Errorf("Expected the dry run to see counter 17, got %v", result.Storage)
+		}
+		contract, _ := sm.GetContract(address)
+		if value, _ := toInt64Safe(contract.Storage["counter"]); value != 7 {
+			t.Errorf("Expected the stored counter to stay 7, got %v", contract.Storage["counter"])
+		}
+	})
+
+	t.Run("UnknownContract", func(t *testing.T) {
+		if _, err := sm.CallContract(CallRequest{ContractAddress: "0x00", Function: "get"}); !errors.Is(err, ErrContractNotFound) {
+			t.Errorf("Expected ErrContractNotFound, got %v", err)
+		}
+	})
+
+	t.Run("EstimateGas", func(t *testing.T) {
+		req := CallRequest{ContractAddress: address, Function: "increment", Args: []interface{}{1}}
+		gas, err := sm.EstimateGas(req)
+		if err != nil {
+			t.Fatalf("EstimateGas failed: %v", err)
+		}
+		if gas == 0 {
+			t.Fatal("Expected the call to need gas")
+		}
+		req.GasLimit = gas
+		if result, err := sm.CallContract(req); err != nil || result.GasUsed > gas {
+			t.Errorf("Expected the call to succeed with the estimate %d, got %v", gas, err)
+		}
+		req.GasLimit = gas - 1
+		if _, err := sm.CallContract(req); err == nil {
+			t.Errorf("Expected the call to run out of gas below the estimate %d", gas)
+		}
+
+		// A limit below what the call needs cannot be estimated
+		if _, err := sm.EstimateGas(req); !errors.Is(err, ErrGasEstimationFailed) {
+			t.Errorf("Expected ErrGasEstimationFailed under a low cap, got %v", err)
+		}
+		if _, err := sm.EstimateGas(CallRequest{ContractAddress: address, Function: "missing"}); !errors.Is(err, ErrGasEstimationFailed) {
+			t.Errorf("Expected ErrGasEstimationFailed for a failing call, got %v", err)
+		}
+	})
+}
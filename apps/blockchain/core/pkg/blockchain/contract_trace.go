@@ -0,0 +1,193 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"atlas-blockchain/pkg/block"
+	"atlas-blockchain/pkg/database"
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/vm"
+	"atlas-blockchain/pkg/wallet"
+)
+
+// TxTrace is the structured execution trace of a historical contract call
+type TxTrace struct {
+	TxHash       string           `json:"tx_hash"`
+	BlockHeight  int64            `json:"block_height"`
+	Contract     string           `json:"contract"`
+	Function     string           `json:"function"`
+	Caller       string           `json:"caller"`
+	GasLimit     uint64           `json:"gas_limit"`
+	GasUsed      uint64           `json:"gas_used"`
+	Failed       bool             `json:"failed"`
+	Error        string           `json:"error,omitempty"`
+	ReturnValues []vm.ReturnValue `json:"return_values,omitempty"`
+	Steps        []*vm.Step       `json:"steps"`
+}
+
+// TransactionHash returns the hex hash a transaction is looked up by
+func TransactionHash(tx transaction.Transaction) string {
+	return hex.EncodeToString(wallet.CalculateTxHash(tx))
+}
+
+// FindTransaction returns the block containing a transaction and its index in it
+func (bm *BlockManager) FindTransaction(hash string) (*block.Block, int, bool) {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+	for i := len(bm.chain) - 1; i >= 0; i-- {
+		for j, tx := range bm.chain[i].Transactions {
+			if TransactionHash(tx) == hash {
+				return bm.chain[i], j, true
+			}
+		}
+	}
+
+	// Older blocks are found through the persisted transaction index
+	if bm.state.db == nil {
+		return nil, 0, false
+	}
+	loc, err := bm.state.db.GetTxLocation(hash)
+	if err != nil || loc == nil {
+		return nil, 0, false
+	}
+	blk, err := bm.storedBlock(loc.BlockHeight)
+	if err != nil || loc.Index >= len(blk.Transactions) {
+		return nil, 0, false
+	}
+	return blk, loc.Index, true
+}
+
+// GetReceipt returns the receipt of a transaction in the chain, nil if there
+// is none. Receipts are kept in the database only.
+func (sm *StateManager) GetReceipt(hash string) (*database.Receipt, error) {
+	if sm.db == nil {
+		return nil, nil
+	}
+	return sm.db.GetReceipt(hash)
+}
+
+// GetBlockReceipts returns the receipts of the block at height in
+// transaction order, nil without a database
+func (sm *StateManager) GetBlockReceipts(height int64) ([]*database.Receipt, error) {
+	if sm.db == nil {
+		return nil, nil
+	}
+	return sm.db.GetBlockReceipts(height)
+}
+
+// TraceTransaction re-executes the contract call at txIndex of blk against the
+// state before the block, replaying the block's earlier calls to the same
+// contract first, and records every instruction. The live state is not touched.
+func (sm *StateManager) TraceTransaction(blk *block.Block, txIndex int) (*TxTrace, error) {
+	if txIndex < 0 || txIndex >= len(blk.Transactions) {
+		return nil, fmt.Errorf("transaction index %d out of range", txIndex)
+	}
+	tx := blk.Transactions[txIndex]
+	if tx.Type != transaction.TxTypeCall {
+		return nil, fmt.Errorf("transaction is not a contract call (type %s)", tx.Type)
+	}
+	address := txAddress(tx.Recipient)
+	height := int64(blk.Index)
+
+	sm.mu.Lock()
+	contract, ok := sm.getContractUnlocked(address)
+	if !ok {
+		sm.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrContractNotFound, address)
+	}
+	storage, err := sm.contractStorageAt(contract, height-1)
+	if err == nil {
+		storage = copyStorage(storage)
+	}
+	sm.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if hash := codeHashAt(contract, height); hash != vm.CodeHash(contract.Functions) {
+		return nil, fmt.Errorf("%w: contract %s was upgraded after height %d", ErrHeightNotAvailable, address, height)
+	}
+
+	overlay := *contract
+	overlay.Storage = storage
+
+	// Bring storage up to the point in the block where the traced call ran
+	for _, prev := range blk.Transactions[:txIndex] {
+		if txAddress(prev.Recipient) != address {
+			continue
+		}
+		switch prev.Type {
+		case transaction.TxTypeUpgrade:
+			return nil, fmt.Errorf("%w: contract %s was upgraded within block %d", ErrHeightNotAvailable, address, height)
+		case transaction.TxTypeCall:
+			vmInstance, _, err := sm.replayCall(&overlay, prev, height, nil)
+			if err == nil {
+				for k, v := range vmInstance.Memory {
+					overlay.Storage[k] = v
+				}
+			}
+		}
+	}
+
+	logger := vm.NewStructLogger()
+	vmInstance, returnValues, err := sm.replayCall(&overlay, tx, height, logger)
+	trace := &TxTrace{
+		TxHash:       TransactionHash(tx),
+		BlockHeight:  height,
+		Contract:     address,
+		Caller:       txAddress(tx.Sender),
+		GasLimit:     uint64(tx.Fee),
+		ReturnValues: returnValues,
+		Steps:        logger.Steps,
+	}
+	var call contractCall
+	if jsonErr := json.Unmarshal([]byte(tx.Data), &call); jsonErr == nil {
+		trace.Function = call.Function
+	}
+	if vmInstance != nil {
+		trace.GasUsed = vmInstance.GetGasUsed()
+	}
+	if err != nil {
+		trace.Failed = true
+		trace.Error = err.Error()
+	}
+	return trace, nil
+}
+
+// replayCall executes a call transaction the way updateState does, on the given contract
+func (sm *StateManager) replayCall(contract *vm.Contract, tx transaction.Transaction, height int64, tracer vm.Tracer) (*vm.VM, []vm.ReturnValue, error) {
+	var call contractCall
+	if err := json.Unmarshal([]byte(tx.Data), &call); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse contract call data: %v", err)
+	}
+	execCtx := &vm.ExecutionContext{
+		Caller:      txAddress(tx.Sender),
+		Value:       tx.Amount,
+		GasLimit:    uint64(tx.Fee),
+		BlockHeight: height,
+	}
+	vmInstance := sm.newContractVM(contract, contract.Storage)
+	vmInstance.Tracer = tracer
+
+	var returnValues []vm.ReturnValue
+	var err error
+	if call.Params != nil {
+		returnValues, err = contract.CallNamed(call.Function, call.Params, vmInstance, execCtx)
+	} else {
+		err = contract.CallFunction(call.Function, call.Args, vmInstance, execCtx)
+	}
+	return vmInstance, returnValues, err
+}
+
+// codeHashAt returns the hash of the code a contract ran at a block height
+func codeHashAt(contract *vm.Contract, height int64) string {
+	hash := vm.CodeHash(contract.Functions)
+	for _, version := range contract.CodeHistory {
+		if version.BlockHeight > height {
+			break
+		}
+		hash = version.CodeHash
+	}
+	return hash
+}
@@ -0,0 +1,114 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"atlas-blockchain/pkg/vm"
+)
+
+// UpgradeRequest is the payload of an upgrade transaction
+type UpgradeRequest struct {
+	Contract      *vm.JSONContract `json:"contract"`  // New code; storage and owner fields are ignored
+	Migration     string           `json:"migration"` // Optional function of the new code run once after the upgrade
+	MigrationArgs []interface{}    `json:"migration_args"`
+	ProposalID    string           `json:"proposal_id"` // Succeeded proposal approving a governance contract upgrade
+}
+
+// upgradeAction is a contract_upgrade entry in an on-chain proposal's actions
+type upgradeAction struct {
+	Type     string `json:"type"`
+	Target   string `json:"target"`
+	CodeHash string `json:"code_hash"`
+}
+
+// applyContractUpgrade authorizes and applies an upgrade transaction, running
+// the optional migration against the upgraded contract, and returns the gas
+// the migration used. Nothing is stored if any step fails.
+// Caller must hold sm.mu
+func (sm *StateManager) applyContractUpgrade(sender, address string, req *UpgradeRequest, gasLimit uint64, height, blockTime int64) (*vm.Contract, uint64, error) {
+	contract, ok := sm.getContractUnlocked(address)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %s", ErrContractNotFound, address)
+	}
+	upgraded, err := contract.Upgraded(req.Contract, height, blockTime)
+	if err != nil {
+		return nil, 0, err
+	}
+	codeHash := upgraded.CodeHistory[len(upgraded.CodeHistory)-1].CodeHash
+
+	proposal, err := sm.authorizeUpgrade(contract, sender, codeHash, req.ProposalID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var gasUsed uint64
+	if req.Migration != "" {
+		execCtx := &vm.ExecutionContext{
+			Caller:      sender,
+			GasLimit:    gasLimit,
+			BlockHeight: height,
+		}
+		vmInstance := sm.newContractVM(upgraded, upgraded.Storage)
+		err := upgraded.CallFunction(req.Migration, req.MigrationArgs, vmInstance, execCtx)
+		gasUsed = vmInstance.GetGasUsed()
+		if err != nil {
+			return nil, gasUsed, fmt.Errorf("migration '%s' failed: %v", req.Migration, err)
+		}
+		for k, v := range vmInstance.Memory {
+			upgraded.Storage[k] = v
+		}
+	}
+
+	if proposal != nil {
+		proposal.State = ProposalExecuted
+		sm.setProposalUnlocked(proposal)
+	}
+	sm.setContractUnlocked(address, upgraded)
+	return upgraded, gasUsed, nil
+}
+
+// authorizeUpgrade checks that the sender may install the given code. Governance
+// contracts require the ID of a succeeded on-chain proposal approving the code,
+// which is returned so the caller can mark it executed and it cannot be used
+// again; other contracts may only be upgraded by their owner.
+// Caller must hold sm.mu
+func (sm *StateManager) authorizeUpgrade(contract *vm.Contract, sender, codeHash, proposalID string) (*Proposal, error) {
+	if contract.ContractType != vm.ContractTypeGovernance {
+		if sender != contract.Owner {
+			return nil, fmt.Errorf("only the owner may upgrade contract %s", contract.Address)
+		}
+		return nil, nil
+	}
+
+	if proposalID == "" {
+		return nil, fmt.Errorf("governance contract %s requires an approved upgrade proposal", contract.Address)
+	}
+
+	proposal, ok := sm.getProposalUnlocked(proposalID)
+	if !ok {
+		return nil, fmt.Errorf("proposal %s not found", proposalID)
+	}
+	if proposal.State != ProposalSucceeded {
+		return nil, fmt.Errorf("proposal %s is %s, not %s", proposalID, proposal.State, ProposalSucceeded)
+	}
+	for _, action := range parseUpgradeActions(proposal.Actions) {
+		if action.Type == "contract_upgrade" && action.Target == contract.Address && action.CodeHash == codeHash {
+			return proposal, nil
+		}
+	}
+	return nil, fmt.Errorf("proposal %s does not approve code %s for %s", proposalID, codeHash, contract.Address)
+}
+
+// parseUpgradeActions decodes proposal actions given as a single JSON action or a list
+func parseUpgradeActions(actions string) []upgradeAction {
+	var list []upgradeAction
+	if err := json.Unmarshal([]byte(actions), &list); err == nil {
+		return list
+	}
+	var single upgradeAction
+	if err := json.Unmarshal([]byte(actions), &single); err == nil {
+		return []upgradeAction{single}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"atlas-blockchain/pkg/block"
+	"atlas-blockchain/pkg/config"
+	"atlas-blockchain/pkg/database"
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/vm"
+)
+
+// upgradeTestState applies each transaction in a block of its own
+type upgradeTestState struct {
+	t      *testing.T
+	sm     *StateManager
+	height int
+}
+
+// newUpgradeTestState returns an empty state where the funded accounts can
+// pay for upgrades
+func newUpgradeTestState(t *testing.T, funded ...string) *upgradeTestState {
+	t.Helper()
+	// The database, snapshots and backups are written to the working directory
+	t.Chdir(t.TempDir())
+	sm := NewStateManager(config.DefaultConfig())
+	t.Cleanup(func() { sm.CloseDatabase() })
+	for _, address := range funded {
+		sm.SetAccount(&database.Account{Address: address, Balance: 10000})
+	}
+	return &upgradeTestState{t: t, sm: sm}
+}
+
+// apply adds a block holding tx, proposed by validator
+func (s *upgradeTestState) apply(tx transaction.Transaction, validator string) {
+	s.t.Helper()
+	s.height++
+	blk := &block.Block{Index: s.height, Timestamp: blockTime(s.height), Validator: validator, Transactions: []transaction.Transaction{tx}}
+	if err := s.sm.updateState(blk); err != nil {
+		s.t.Fatalf("Failed to apply block %d: %v", s.height, err)
+	}
+}
+
+// blockTime is the timestamp of the block at height
+func blockTime(height int) int64 {
+	return 1700000000 + int64(height)
+}
+
+// deploy deploys code owned by owner and returns its address
+func (s *upgradeTestState) deploy(owner string, code vm.JSONContract) string {
+	s.t.Helper()
+	data, _ := json.Marshal(code)
+	known := make(map[string]bool)
+	for address := range s.sm.contracts {
+		known[address] = true
+	}
+	s.apply(transaction.Transaction{Type: transaction.TxTypeDeploy, Sender: owner, Recipient: "contract", Data: string(data)}, "")
+	for address := range s.sm.contracts {
+		if !known[address] {
+			return address
+		}
+	}
+	s.t.Fatalf("Expected the contract to be deployed")
+	return ""
+}
+
+// upgrade sends an upgrade of address by sender
+func (s *upgradeTestState) upgrade(sender, address string, req UpgradeRequest) {
+	s.t.Helper()
+	data, _ := json.Marshal(req)
+	s.apply(transaction.Transaction{Type: transaction.TxTypeUpgrade, Sender: sender, Recipient: address, Fee: 1000, Data: string(data)}, "")
+}
+
+func (s *upgradeTestState) contract(address string) *vm.Contract {
+	s.t.Helper()
+	contract, ok := s.sm.GetContract(address)
+	if !ok {
+		s.t.Fatalf("Expected contract %s to exist", address)
+	}
+	return contract
+}
+
+// counterV2 is the counter with a migration that sets the counter to 100
+func counterV2() vm.JSONContract {
+	code := counterContract
+	code.Version = "2.0"
+	code.Functions = map[string]*vm.JSONFunction{
+		"get": counterContract.Functions["get"],
+		"migrate": {Code: []vm.JSONInstruction{
+			{Op: "PUSH", Value: 100},
+			{Op: "STORE", Key: "counter"},
+		}},
+	}
+	return code
+}
+
+func TestContractUpgrade(t *testing.T) {
+	owner, other := "cb49a4cefae13ad235beb40e5ad603ba757da61d", "5f1e3a1b6c0d7e2a9b8c4d3e2f1a0b9c8d7e6f5a"
+	newCode := counterV2()
+
+	t.Run("Owner", func(t *testing.T) {
+		s := newUpgradeTestState(t, owner, other)
+		address := s.deploy(owner, counterContract)
+
+		s.upgrade(other, address, UpgradeRequest{Contract: &newCode})
+		if v := s.contract(address).Version; v != "1.0" {
+			t.Errorf("Expected an upgrade by another account to be rejected, got version %s", v)
+		}
+
+		s.upgrade(owner, address, UpgradeRequest{Contract: &newCode, Migration: "migrate"})
+		contract := s.contract(address)
+		if contract.Version != "2.0" || contract.Functions["increment"] != nil {
+			t.Fatalf("Expected version 2.0 without increment, got %s", contract.Version)
+		}
+		if value, _ := toInt64Safe(contract.Storage["counter"]); value != 100 {
+			t.Errorf("Expected the migration to set the counter to 100, got %v", contract.Storage["counter"])
+		}
+		latest := contract.CodeHistory[len(contract.CodeHistory)-1]
+		if len(contract.CodeHistory) != 2 || latest.Version != "2.0" || latest.BlockHeight != int64(s.height) || latest.UpgradedAt != blockTime(s.height) {
+			t.Errorf("Expected version 2.0 at height %d in the code history, got %+v", s.height, contract.CodeHistory)
+		}
+	})
+
+	t.Run("FailedMigrationAppliesNothing", func(t *testing.T) {
+		s := newUpgradeTestState(t, owner)
+		address := s.deploy(owner, counterContract)
+		s.upgrade(owner, address, UpgradeRequest{Contract: &newCode, Migration: "missing"})
+		if contract := s.contract(address); contract.Version != "1.0" || len(contract.CodeHistory) > 1 {
+			t.Errorf("Expected version 1.0 to stay, got %s with history %+v", contract.Version, contract.CodeHistory)
+		}
+	})
+
+	t.Run("Governance", func(t *testing.T) {
+		s := newUpgradeTestState(t, owner)
+		governance := counterContract
+		governance.ContractType = vm.ContractTypeGovernance
+		address := s.deploy(owner, governance)
+
+		s.upgrade(owner, address, UpgradeRequest{Contract: &newCode})
+		if v := s.contract(address).Version; v != "1.0" {
+			t.Errorf("Expected an upgrade without a proposal to be rejected, got version %s", v)
+		}
+
+		upgraded, err := s.contract(address).Upgraded(&newCode, 0, 0)
+		if err != nil {
+			t.Fatalf("Failed to hash the new code: %v", err)
+		}
+		codeHash := upgraded.CodeHistory[len(upgraded.CodeHistory)-1].CodeHash
+
+		actions := fmt.Sprintf(`{"type":"contract_upgrade","target":%q,"code_hash":%q}`, address, codeHash)
+		s.sm.proposals["proposal_1"] = &Proposal{ID: "proposal_1", Actions: actions, State: ProposalActive}
+		s.upgrade(owner, address, UpgradeRequest{Contract: &newCode, ProposalID: "proposal_1"})
+		if v := s.contract(address).Version; v != "1.0" {
+			t.Errorf("Expected an upgrade under an active proposal to be rejected, got version %s", v)
+		}
+		s.sm.proposals["proposal_1"].State = ProposalSucceeded
+
+		s.upgrade(owner, address, UpgradeRequest{Contract: &newCode, ProposalID: "proposal_1"})
+		if v := s.contract(address).Version; v != "2.0" {
+			t.Fatalf("Expected the approved upgrade to install version 2.0, got %s", v)
+		}
+		if proposal := s.sm.proposals["proposal_1"]; proposal.State != ProposalExecuted {
+			t.Errorf("Expected the proposal to be executed, got %s", proposal.State)
+		}
+	})
+	t.Run("FeeAndNonce", func(t *testing.T) {
+		wallet, validator := newTestWallet(t), addressOf(newTestWallet(t))
+		s := newUpgradeTestState(t, addressOf(wallet))
+		address := s.deploy(addressOf(wallet), counterContract)
+
+		data, _ := json.Marshal(UpgradeRequest{Contract: &newCode, Migration: "migrate"})
+		tx := signTx(t, wallet, transaction.Transaction{Type: transaction.TxTypeUpgrade, Recipient: address, Fee: 1000, Data: string(data)})
+		s.apply(tx, validator)
+		if v := s.contract(address).Version; v != "2.0" {
+			t.Fatalf("Expected the upgrade to install version 2.0, got %s", v)
+		}
+		if acct := s.sm.GetAccount(addressOf(wallet)); acct.Balance != 9000 || acct.Nonce != 1 {
+			t.Errorf("Expected the sender to pay 1000 and use nonce 0, got balance %d and nonce %d", acct.Balance, acct.Nonce)
+		}
+		if got := s.sm.GetBalance(validator); got != 1000 {
+			t.Errorf("Expected the validator to get the fee of 1000, got %d", got)
+		}
+		receipt, err := s.sm.GetReceipt(TransactionHash(tx))
+		if err != nil || receipt == nil || receipt.GasUsed == 0 {
+			t.Errorf("Expected the receipt to record the migration's gas, got %+v (%v)", receipt, err)
+		}
+
+		// The applied upgrade cannot be sent again
+		if err := NewTransactionManager(config.DefaultConfig(), s.sm).AddTransaction(tx); err == nil {
+			t.Errorf("Expected the replayed upgrade to be refused")
+		}
+	})
+}
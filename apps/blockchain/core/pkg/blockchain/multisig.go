@@ -0,0 +1,128 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"atlas-blockchain/pkg/database"
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/wallet"
+)
+
+// MultisigAccount is an M-of-N account registered on chain
+type MultisigAccount struct {
+	Address string `json:"address"`
+	transaction.Multisig
+	BlockHeight int64 `json:"block_height"` // Height of the registering block
+}
+
+// multisigKey normalizes an address for the multisig registry
+func multisigKey(address string) string {
+	return "0x" + strings.ToLower(strings.TrimPrefix(address, "0x"))
+}
+
+// GetMultisig returns the multisig account registered at address
+func (sm *StateManager) GetMultisig(address string) (*MultisigAccount, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.getMultisigUnlocked(address)
+}
+
+// getMultisigUnlocked retrieves a multisig account without acquiring locks,
+// falling back to the database for accounts registered before a restart
+func (sm *StateManager) getMultisigUnlocked(address string) (*MultisigAccount, bool) {
+	key := multisigKey(address)
+	if sm.batch != nil {
+		if m, ok := sm.batch.multisigs[key]; ok {
+			return m, true
+		}
+	}
+	m, ok := sm.multisigs[key]
+	if ok || sm.db == nil {
+		return m, ok
+	}
+
+	record, err := sm.db.GetMultisig(key)
+	if err != nil {
+		log.Printf("⚠️  Failed to get multisig account from database: %v", err)
+		return nil, false
+	}
+	if record == nil {
+		return nil, false
+	}
+	m = &MultisigAccount{Address: record.Address, Multisig: transaction.Multisig{Threshold: record.Threshold}, BlockHeight: record.BlockHeight}
+	if err := json.Unmarshal([]byte(record.PublicKeys), &m.PublicKeys); err != nil {
+		log.Printf("⚠️  Failed to decode multisig account %s: %v", shortAddr(address), err)
+		return nil, false
+	}
+	sm.multisigs[key] = m
+	return m, true
+}
+
+// CheckMultisigSender rejects a transaction that spends from a multisig
+// account without its co-signers' signatures, or from one that is not
+// registered
+func (sm *StateManager) CheckMultisigSender(tx transaction.Transaction) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.checkMultisigSenderUnlocked(tx)
+}
+
+// checkMultisigSenderUnlocked is CheckMultisigSender without locking.
+// Signatures themselves are checked by wallet.VerifyTransactionSignature.
+func (sm *StateManager) checkMultisigSenderUnlocked(tx transaction.Transaction) error {
+	if tx.Sender == "network" {
+		return nil
+	}
+	registered, ok := sm.getMultisigUnlocked(tx.Sender)
+	if tx.Multisig != nil {
+		if !ok {
+			return fmt.Errorf("multisig account %s is not registered", tx.Sender)
+		}
+		return nil
+	}
+	if ok {
+		return fmt.Errorf("%s is a multisig account and needs %d of %d signatures", tx.Sender, registered.Threshold, len(registered.PublicKeys))
+	}
+	return nil
+}
+
+// newMultisigAccount parses the definition registered by a register_multisig
+// transaction. The transaction funds the account, so its recipient must be
+// the account's address.
+func (sm *StateManager) newMultisigAccount(tx transaction.Transaction, height int64) (*MultisigAccount, error) {
+	var m transaction.Multisig
+	if err := json.Unmarshal([]byte(tx.Data), &m); err != nil {
+		return nil, fmt.Errorf("invalid multisig definition: %v", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	address := wallet.MultisigAddress(&m)
+	if !wallet.SameAddress(tx.Recipient, address) {
+		return nil, fmt.Errorf("recipient %s is not the multisig address %s", tx.Recipient, address)
+	}
+	if _, exists := sm.getMultisigUnlocked(address); exists {
+		return nil, fmt.Errorf("multisig account %s is already registered", address)
+	}
+	for i, key := range m.PublicKeys {
+		m.PublicKeys[i] = strings.ToLower(key)
+	}
+	return &MultisigAccount{Address: address, Multisig: m, BlockHeight: height}, nil
+}
+
+// multisigToRecord converts a multisig account into its database representation
+func multisigToRecord(m *MultisigAccount) (*database.MultisigAccount, error) {
+	keys, err := json.Marshal(m.PublicKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal keys of multisig %s: %v", m.Address, err)
+	}
+	return &database.MultisigAccount{
+		Address:     m.Address,
+		Threshold:   m.Threshold,
+		PublicKeys:  string(keys),
+		BlockHeight: m.BlockHeight,
+	}, nil
+}
@@ -0,0 +1,165 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/wallet"
+)
+
+// MaxPendingMultisig bounds the multisig transactions waiting for signatures
+const MaxPendingMultisig = 1000
+
+var ErrPendingMultisigNotFound = errors.New("pending multisig transaction not found")
+
+// PendingMultisig is a multisig transaction collecting its co-signers' signatures
+type PendingMultisig struct {
+	ID          string                  `json:"id"` // Transaction hash, the value each co-signer signs
+	Transaction transaction.Transaction `json:"transaction"`
+	Required    int                     `json:"required"`
+	CreatedAt   int64                   `json:"created_at"`
+}
+
+// Complete reports whether enough co-signers have signed
+func (p *PendingMultisig) Complete() bool {
+	return len(p.Transaction.Signatures) >= p.Required
+}
+
+// MultisigPool keeps multisig transactions of registered accounts until
+// enough co-signers have signed them to broadcast
+type MultisigPool struct {
+	mu           sync.Mutex
+	pending      map[string]*PendingMultisig
+	stateManager *StateManager
+}
+
+// NewMultisigPool creates an empty pool
+func NewMultisigPool(sm *StateManager) *MultisigPool {
+	return &MultisigPool{pending: make(map[string]*PendingMultisig), stateManager: sm}
+}
+
+// Propose adds a multisig transaction to the pool. Signatures it already
+// carries are checked like those added later.
+func (p *MultisigPool) Propose(tx transaction.Transaction) (*PendingMultisig, error) {
+	if tx.Multisig == nil {
+		return nil, fmt.Errorf("transaction is not a multisig transaction")
+	}
+	if err := tx.Multisig.Validate(); err != nil {
+		return nil, err
+	}
+	if address := wallet.MultisigAddress(tx.Multisig); !wallet.SameAddress(tx.Sender, address) {
+		return nil, fmt.Errorf("sender %s is not the multisig address %s", tx.Sender, address)
+	}
+	if p.stateManager != nil {
+		if err := p.stateManager.CheckMultisigSender(tx); err != nil {
+			return nil, err
+		}
+	}
+	signatures := tx.Signatures
+	tx.Signatures = nil
+	pending := &PendingMultisig{
+		ID:          TransactionHash(tx),
+		Transaction: tx,
+		Required:    tx.Multisig.Threshold,
+		CreatedAt:   time.Now().Unix(),
+	}
+	for _, sig := range signatures {
+		if err := pending.addSignature(sig); err != nil {
+			return nil, err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.pending[pending.ID]; exists {
+		return nil, fmt.Errorf("multisig transaction %s is already pending", pending.ID)
+	}
+	if len(p.pending) >= MaxPendingMultisig {
+		return nil, fmt.Errorf("too many pending multisig transactions")
+	}
+	p.pending[pending.ID] = pending
+	return pending.copy(), nil
+}
+
+// AddSignature adds a co-signer's signature to a pending transaction
+func (p *MultisigPool) AddSignature(id string, sig transaction.MultisigSignature) (*PendingMultisig, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pending, ok := p.pending[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPendingMultisigNotFound, id)
+	}
+	if err := pending.addSignature(sig); err != nil {
+		return nil, err
+	}
+	return pending.copy(), nil
+}
+
+// addSignature checks a signature and adds it, replacing an earlier one by the same key
+func (p *PendingMultisig) addSignature(sig transaction.MultisigSignature) error {
+	valid, err := wallet.VerifyMultisigSignature(p.Transaction, sig)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("invalid signature by %s", sig.PublicKey)
+	}
+	for i, existing := range p.Transaction.Signatures {
+		if strings.EqualFold(existing.PublicKey, sig.PublicKey) {
+			p.Transaction.Signatures[i] = sig
+			return nil
+		}
+	}
+	p.Transaction.Signatures = append(p.Transaction.Signatures, sig)
+	return nil
+}
+
+// copy returns a snapshot that later signatures do not change
+func (p *PendingMultisig) copy() *PendingMultisig {
+	c := *p
+	c.Transaction.Signatures = append([]transaction.MultisigSignature(nil), p.Transaction.Signatures...)
+	return &c
+}
+
+// Get returns a pending transaction by ID
+func (p *MultisigPool) Get(id string) (*PendingMultisig, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pending, ok := p.pending[id]
+	if !ok {
+		return nil, false
+	}
+	return pending.copy(), true
+}
+
+// List returns the pending transactions of a multisig account, or of all
+// accounts when address is empty, oldest first
+func (p *MultisigPool) List(address string) []*PendingMultisig {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	list := []*PendingMultisig{}
+	for _, pending := range p.pending {
+		if address == "" || wallet.SameAddress(pending.Transaction.Sender, address) {
+			list = append(list, pending.copy())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt != list[j].CreatedAt {
+			return list[i].CreatedAt < list[j].CreatedAt
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// Remove drops a pending transaction, once it is broadcast or abandoned
+func (p *MultisigPool) Remove(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, id)
+}
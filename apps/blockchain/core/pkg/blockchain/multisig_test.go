@@ -0,0 +1,165 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"atlas-blockchain/pkg/config"
+	"atlas-blockchain/pkg/database"
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/wallet"
+)
+
+// cosign adds w's signature to a multisig transaction
+func cosign(t *testing.T, w *wallet.Wallet, tx *transaction.Transaction) {
+	t.Helper()
+	// Sign again until the unpadded signature verifies, as in signTx
+	for {
+		if err := w.SignMultisig(tx); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		for _, sig := range tx.Signatures {
+			if sig.PublicKey != w.PublicKeyStr() {
+				continue
+			}
+			if ok, _ := wallet.VerifyMultisigSignature(*tx, sig); ok {
+				return
+			}
+		}
+	}
+}
+
+func TestMultisigThreshold(t *testing.T) {
+	alice, bob := newTestWallet(t), newTestWallet(t)
+	signers := []*wallet.Wallet{newTestWallet(t), newTestWallet(t), newTestWallet(t)}
+	def := &transaction.Multisig{Threshold: 2}
+	for _, w := range signers {
+		def.PublicKeys = append(def.PublicKeys, w.PublicKeyStr())
+	}
+	address := wallet.MultisigAddress(def)
+
+	t.Chdir(t.TempDir())
+	sm, bm := openChain(t, config.DefaultConfig())
+	sm.SetAccount(&database.Account{Address: addressOf(alice), Balance: 1000})
+	validator := newTestWallet(t)
+	mine := func(t *testing.T, tx transaction.Transaction) error {
+		t.Helper()
+		return bm.AddBlock(newBlock(t, bm, validator, tx))
+	}
+
+	// Alice registers the 2-of-3 account and funds it with 500
+	data, _ := json.Marshal(def)
+	register := signTx(t, alice, transaction.Transaction{Type: transaction.TxTypeMultisig, Recipient: address, Amount: 500, Nonce: 1, Data: string(data)})
+	if err := mine(t, register); err != nil {
+		t.Fatalf("Failed to register the multisig account: %v", err)
+	}
+	if _, ok := sm.GetMultisig(address); !ok {
+		t.Fatalf("Expected the multisig account to be registered")
+	}
+	if acct := sm.GetAccount(addressOf(alice)); acct.Balance != 500 || acct.Nonce != 1 {
+		t.Errorf("Expected alice to pay 500 and use a nonce, got balance %d and nonce %d", acct.Balance, acct.Nonce)
+	}
+
+	// spend returns a transfer of 100 to bob signed by the given co-signers
+	spend := func(by ...*wallet.Wallet) transaction.Transaction {
+		tx := transaction.Transaction{
+			Type:      transaction.TxTypeRegular,
+			ChainID:   config.DefaultConfig().ChainID,
+			Sender:    address,
+			Recipient: addressOf(bob),
+			Amount:    100,
+			Multisig:  def,
+		}
+		for _, w := range by {
+			cosign(t, w, &tx)
+		}
+		return tx
+	}
+	expectBalances := func(t *testing.T, account, recipient int64) {
+		t.Helper()
+		if got := sm.GetBalance(address); got != account {
+			t.Errorf("Expected the multisig account to have %d, got %d", account, got)
+		}
+		if got := sm.GetBalance(addressOf(bob)); got != recipient {
+			t.Errorf("Expected bob to have %d, got %d", recipient, got)
+		}
+	}
+
+	t.Run("BelowThresholdIsRefused", func(t *testing.T) {
+		if err := mine(t, spend(signers[0])); err == nil {
+			t.Errorf("Expected a block spending with 1 of 2 signatures to be refused")
+		}
+		// Two signatures by the same key count once
+		tx := spend(signers[0])
+		tx.Signatures = append(tx.Signatures, tx.Signatures[0])
+		if err := mine(t, tx); err == nil {
+			t.Errorf("Expected a repeated signature not to reach the threshold")
+		}
+		expectBalances(t, 500, 0)
+	})
+
+	t.Run("SingleKeyIsRefused", func(t *testing.T) {
+		// A plain signature by one of the keys, sent from the account
+		tx := transaction.Transaction{
+			Type:            transaction.TxTypeRegular,
+			ChainID:         config.DefaultConfig().ChainID,
+			Sender:          address,
+			SenderPublicKey: signers[0].PublicKeyStr(),
+			Recipient:       addressOf(bob),
+			Amount:          100,
+			Nonce:           1,
+		}
+		signers[0].SignTransaction(&tx)
+		if err := sm.CheckMultisigSender(tx); err == nil {
+			t.Errorf("Expected spending from a multisig account without its definition to be refused")
+		}
+		if err := mine(t, tx); err == nil {
+			t.Errorf("Expected a block spending from a multisig account with one key to be refused")
+		}
+		expectBalances(t, 500, 0)
+	})
+
+	t.Run("Pool", func(t *testing.T) {
+		pool := NewMultisigPool(sm)
+		pending, err := pool.Propose(spend(signers[0]))
+		if err != nil {
+			t.Fatalf("Propose failed: %v", err)
+		}
+		if pending.Complete() || pending.Required != 2 {
+			t.Errorf("Expected 1 of 2 signatures to be incomplete, got %+v", pending)
+		}
+
+		outsider := newTestWallet(t)
+		tx := spend()
+		tx.Multisig = &transaction.Multisig{Threshold: 1, PublicKeys: append([]string{outsider.PublicKeyStr()}, def.PublicKeys...)}
+		cosign(t, outsider, &tx)
+		if _, err := pool.AddSignature(pending.ID, tx.Signatures[0]); err == nil {
+			t.Errorf("Expected a signature by a key outside the account to be refused")
+		}
+
+		second := spend(signers[2]).Signatures[0]
+		if pending, err = pool.AddSignature(pending.ID, second); err != nil {
+			t.Fatalf("AddSignature failed: %v", err)
+		}
+		if !pending.Complete() {
+			t.Fatalf("Expected 2 of 2 signatures to be complete")
+		}
+		if err := mine(t, pending.Transaction); err != nil {
+			t.Fatalf("Failed to add the complete transaction: %v", err)
+		}
+		expectBalances(t, 400, 100)
+	})
+
+	t.Run("UnregisteredAccount", func(t *testing.T) {
+		other := &transaction.Multisig{Threshold: 1, PublicKeys: def.PublicKeys}
+		tx := spend()
+		tx.Sender, tx.Multisig = wallet.MultisigAddress(other), other
+		cosign(t, signers[0], &tx)
+		if _, err := NewMultisigPool(sm).Propose(tx); err == nil {
+			t.Errorf("Expected a transaction of an unregistered account to be refused")
+		}
+		if err := mine(t, tx); err == nil {
+			t.Errorf("Expected a block spending from an unregistered account to be refused")
+		}
+	})
+}
@@ -75,7 +75,8 @@ func (db *OptimizedDatabase) initializeConnectionPool() error {
 	return nil
 }
 
-// initializeSchema creates all necessary tables and indexes
+// initializeSchema brings the schema up to date with the migrations shared
+// with the SQLite store and applies the performance settings
 func (db *OptimizedDatabase) initializeSchema() error {
 	conn := db.getConnection()
 	if conn == nil {
@@ -83,104 +84,11 @@ func (db *OptimizedDatabase) initializeSchema() error {
 	}
 	defer db.releaseConnection(conn)
 
-	schema := `
-		-- Optimized schema with proper indexing
-		CREATE TABLE IF NOT EXISTS accounts (
-			address TEXT PRIMARY KEY,
-			balance INTEGER NOT NULL DEFAULT 0,
-			nonce INTEGER NOT NULL DEFAULT 0,
-			is_validator BOOLEAN NOT NULL DEFAULT FALSE,
-			staked_amount INTEGER NOT NULL DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);
-
-		CREATE TABLE IF NOT EXISTS transactions (
-			hash TEXT PRIMARY KEY,
-			block_hash TEXT NOT NULL,
-			from_addr TEXT NOT NULL,
-			to_addr TEXT NOT NULL,
-			amount INTEGER NOT NULL,
-			fee INTEGER NOT NULL DEFAULT 0,
-			tx_type TEXT NOT NULL,
-			data TEXT,
-			nonce INTEGER NOT NULL,
-			status TEXT NOT NULL DEFAULT 'pending',
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			INDEX idx_transactions_from_addr (from_addr),
-			INDEX idx_transactions_to_addr (to_addr),
-			INDEX idx_transactions_block_hash (block_hash),
-			INDEX idx_transactions_status (status)
-		);
-
-		CREATE TABLE IF NOT EXISTS blocks (
-			hash TEXT PRIMARY KEY,
-			height INTEGER UNIQUE NOT NULL,
-			prev_hash TEXT,
-			merkle_root TEXT NOT NULL,
-			validator TEXT,
-			reward INTEGER NOT NULL DEFAULT 0,
-			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
-			nonce INTEGER NOT NULL DEFAULT 0,
-			difficulty INTEGER NOT NULL DEFAULT 1,
-			status TEXT NOT NULL DEFAULT 'proposed',
-			INDEX idx_blocks_height (height),
-			INDEX idx_blocks_validator (validator),
-			INDEX idx_blocks_timestamp (timestamp)
-		);
-
-		CREATE TABLE IF NOT EXISTS contracts (
-			address TEXT PRIMARY KEY,
-			name TEXT NOT NULL,
-			owner TEXT NOT NULL,
-			code TEXT NOT NULL,
-			storage TEXT, -- JSON storage data
-			upgradable BOOLEAN NOT NULL DEFAULT FALSE,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			INDEX idx_contracts_owner (owner)
-		);
-
-		CREATE TABLE IF NOT EXISTS proposals (
-			id TEXT PRIMARY KEY,
-			proposer TEXT NOT NULL,
-			description TEXT NOT NULL,
-			actions TEXT,
-			state TEXT NOT NULL DEFAULT 'pending',
-			votes_for INTEGER NOT NULL DEFAULT 0,
-			votes_against INTEGER NOT NULL DEFAULT 0,
-			start_block INTEGER NOT NULL,
-			end_block INTEGER NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			INDEX idx_proposals_proposer (proposer),
-			INDEX idx_proposals_state (state),
-			INDEX idx_proposals_end_block (end_block)
-		);
-
-		CREATE TABLE IF NOT EXISTS votes (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			proposal_id TEXT NOT NULL,
-			voter TEXT NOT NULL,
-			choice TEXT NOT NULL,
-			weight INTEGER NOT NULL,
-			voted_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(proposal_id, voter),
-			FOREIGN KEY (proposal_id) REFERENCES proposals(id),
-			INDEX idx_votes_proposal_id (proposal_id),
-			INDEX idx_votes_voter (voter)
-		);
-
-		CREATE TABLE IF NOT EXISTS oracle_data (
-			key TEXT PRIMARY KEY,
-			value TEXT NOT NULL,
-			source TEXT NOT NULL,
-			timestamp INTEGER NOT NULL,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			INDEX idx_oracle_data_timestamp (timestamp),
-			INDEX idx_oracle_data_source (source)
-		);
-
-		-- Performance optimizations
+	if _, err := database.Migrate(conn, database.MigrateOptions{}); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	_, err := conn.Exec(`
 		PRAGMA journal_mode = WAL;
 		PRAGMA synchronous = NORMAL;
 		PRAGMA cache_size = -64000;
@@ -188,11 +96,9 @@ func (db *OptimizedDatabase) initializeSchema() error {
 		PRAGMA busy_timeout = 5000;
 		PRAGMA temp_store = MEMORY;
 		PRAGMA mmap_size = 268435456;
-	`
-
-	_, err := conn.Exec(schema)
+	`)
 	if err != nil {
-		return fmt.Errorf("failed to execute schema: %w", err)
+		return fmt.Errorf("failed to apply performance settings: %w", err)
 	}
 
 	log.Printf("✅ Database schema initialized with optimized indexes and WAL mode")
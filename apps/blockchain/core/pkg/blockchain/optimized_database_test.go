@@ -1,14 +1,18 @@
 package blockchain
 
 import (
+	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"atlas-blockchain/pkg/database"
 )
 
 // TestOptimizedDatabase verifies WAL mode and connection pooling functionality
 func TestOptimizedDatabase(t *testing.T) {
 	// Create test database
-	db, err := NewOptimizedDatabase("test_optimized.db")
+	db, err := NewOptimizedDatabase(filepath.Join(t.TempDir(), "test_optimized.db"))
 	if err != nil {
 		t.Fatalf("Failed to create optimized database: %v", err)
 	}
@@ -16,7 +20,7 @@ func TestOptimizedDatabase(t *testing.T) {
 
 	// Test connection pool initialization
 	metrics := db.GetMetrics()
-	if metrics["max_connections"] != float64(10) {
+	if metrics["max_connections"] != 10 {
 		t.Errorf("Expected 10 max connections, got %v", metrics["max_connections"])
 	}
 
@@ -26,7 +30,7 @@ func TestOptimizedDatabase(t *testing.T) {
 	}
 
 	// Test account operations
-	testAccount := &Account{
+	testAccount := &database.Account{
 		Address:      "test_address_123",
 		Balance:      1000,
 		Nonce:        1,
@@ -53,14 +57,14 @@ func TestOptimizedDatabase(t *testing.T) {
 
 	// Test performance metrics collection
 	metrics = db.GetMetrics()
-	if metrics["total_queries"] == nil || metrics["total_queries"].(float64) <= 0 {
+	if metrics["total_queries"] == nil || metrics["total_queries"].(int64) <= 0 {
 		t.Error("Performance metrics not being collected")
 	}
 
 	// Test batch operations
 	batchAccounts := []interface{}{
-		&Account{Address: "batch_addr_1", Balance: 2000, Nonce: 2},
-		&Account{Address: "batch_addr_2", Balance: 3000, Nonce: 3},
+		&database.Account{Address: "batch_addr_1", Balance: 2000, Nonce: 2},
+		&database.Account{Address: "batch_addr_2", Balance: 3000, Nonce: 3},
 	}
 
 	if err := db.BatchUpdate(batchAccounts); err != nil {
@@ -68,7 +72,7 @@ func TestOptimizedDatabase(t *testing.T) {
 	}
 
 	// Verify batch operations
-	for _, expected := range []*Account{
+	for _, expected := range []*database.Account{
 		{Address: "batch_addr_1", Balance: 2000},
 		{Address: "batch_addr_2", Balance: 3000},
 	} {
@@ -86,7 +90,7 @@ func TestOptimizedDatabase(t *testing.T) {
 
 // TestConcurrentAccess verifies thread safety and connection pooling
 func TestConcurrentAccess(t *testing.T) {
-	db, err := NewOptimizedDatabase("test_concurrent.db")
+	db, err := NewOptimizedDatabase(filepath.Join(t.TempDir(), "test_concurrent.db"))
 	if err != nil {
 		t.Fatalf("Failed to create concurrent test database: %v", err)
 	}
@@ -97,7 +101,7 @@ func TestConcurrentAccess(t *testing.T) {
 
 	for i := 0; i < 10; i++ {
 		go func(id int) {
-			account := &Account{
+			account := &database.Account{
 				Address: fmt.Sprintf("concurrent_addr_%d", id),
 				Balance: int64(id * 100),
 				Nonce:   uint64(id),
@@ -150,11 +154,9 @@ func TestConcurrentAccess(t *testing.T) {
 	}
 }
 
-import "fmt"
-
 // BenchmarkOptimizedDatabase measures performance
 func BenchmarkOptimizedDatabase(b *testing.B) {
-	db, err := NewOptimizedDatabase("benchmark.db")
+	db, err := NewOptimizedDatabase(filepath.Join(b.TempDir(), "benchmark.db"))
 	if err != nil {
 		b.Fatalf("Failed to create benchmark database: %v", err)
 	}
@@ -164,7 +166,7 @@ func BenchmarkOptimizedDatabase(b *testing.B) {
 	b.RunParallel(func(pb *testing.PB) {
 		i := 0
 		for pb.Next() {
-			account := &Account{
+			account := &database.Account{
 				Address: fmt.Sprintf("bench_addr_%d", i),
 				Balance: int64(i),
 				Nonce:   uint64(i),
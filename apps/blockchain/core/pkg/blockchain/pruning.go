@@ -0,0 +1,240 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Node pruning modes
+const (
+	PruneArchive = "archive" // Keep every block and every historical state
+	PruneFull    = "full"    // Keep every block, historical state for recent heights only
+	PrunePruned  = "pruned"  // Keep recent blocks and recent historical state only
+)
+
+// Defaults used when the configuration leaves pruning unset
+const (
+	defaultStateRetention int64 = 128
+	defaultBlockRetention int64 = 1000
+	defaultPruneInterval        = time.Minute * 10
+)
+
+// ErrHeightPruned is returned for heights whose block or state was removed by pruning
+var ErrHeightPruned = errors.New("requested height has been pruned")
+
+// PruningStatus describes the pruning mode and the history still available
+type PruningStatus struct {
+	Mode              string `json:"mode"`
+	StateRetention    int64  `json:"state_retention,omitempty"`
+	BlockRetention    int64  `json:"block_retention,omitempty"`
+	OldestStateHeight int64  `json:"oldest_state_height"`
+	OldestBlockHeight int64  `json:"oldest_block_height"`
+	LatestHeight      int64  `json:"latest_height"`
+}
+
+// PruneResult summarizes one garbage collection run
+type PruneResult struct {
+	StateVersions int64 `json:"state_versions"` // Account versions removed
+	Blocks        int64 `json:"blocks"`         // Blocks removed
+	Compacted     bool  `json:"compacted"`
+}
+
+// pruningMode returns the configured pruning mode, full if unset
+func (sm *StateManager) pruningMode() string {
+	if sm.config != nil && sm.config.PruningMode != "" {
+		return sm.config.PruningMode
+	}
+	return PruneFull
+}
+
+func (sm *StateManager) stateRetention() int64 {
+	if sm.config != nil && sm.config.StateRetention > 0 {
+		return sm.config.StateRetention
+	}
+	return defaultStateRetention
+}
+
+func (sm *StateManager) blockRetention() int64 {
+	if sm.config != nil && sm.config.BlockRetention > 0 {
+		return sm.config.BlockRetention
+	}
+	return defaultBlockRetention
+}
+
+func (sm *StateManager) pruneInterval() time.Duration {
+	if sm.config != nil && sm.config.PruneInterval > 0 {
+		return sm.config.PruneInterval
+	}
+	return defaultPruneInterval
+}
+
+// oldestStateHeight is the lowest height whose state can be read. Heights
+// outside the retention window count as pruned even before the collector
+// has removed them, so answers do not depend on when it last ran.
+// Caller must hold sm.mu
+func (sm *StateManager) oldestStateHeight() int64 {
+	oldest := sm.pruned.StateHeight
+	if sm.pruningMode() != PruneArchive {
+		if window := sm.height - sm.stateRetention() + 1; window > oldest {
+			oldest = window
+		}
+	}
+	if oldest < 0 {
+		return 0
+	}
+	return oldest
+}
+
+// oldestBlockHeight is the lowest height whose block is still stored
+// Caller must hold sm.mu
+func (sm *StateManager) oldestBlockHeight() int64 {
+	oldest := sm.pruned.BlockHeight
+	if sm.pruningMode() == PrunePruned {
+		if window := sm.height - sm.blockRetention() + 1; window > oldest {
+			oldest = window
+		}
+	}
+	if oldest < 0 {
+		return 0
+	}
+	return oldest
+}
+
+// checkStateHeight reports whether the state at height can be read
+// Caller must hold sm.mu
+func (sm *StateManager) checkStateHeight(height int64) error {
+	if height < 0 || height > sm.height {
+		return fmt.Errorf("%w: %d (latest is %d)", ErrHeightNotAvailable, height, sm.height)
+	}
+	if oldest := sm.oldestStateHeight(); height < oldest {
+		return fmt.Errorf("%w: state at height %d is no longer kept (%s node, oldest available state is %d)",
+			ErrHeightPruned, height, sm.pruningMode(), oldest)
+	}
+	return nil
+}
+
+// checkBlockHeight reports whether the block at height is still stored
+func (sm *StateManager) checkBlockHeight(height int64) error {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	if oldest := sm.oldestBlockHeight(); height < oldest {
+		return fmt.Errorf("%w: block %d is no longer kept (%s node, oldest available block is %d)",
+			ErrHeightPruned, height, sm.pruningMode(), oldest)
+	}
+	return nil
+}
+
+// PruningStatus returns the pruning mode and the range of available history
+func (sm *StateManager) PruningStatus() PruningStatus {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	status := PruningStatus{
+		Mode:              sm.pruningMode(),
+		OldestStateHeight: sm.oldestStateHeight(),
+		OldestBlockHeight: sm.oldestBlockHeight(),
+		LatestHeight:      sm.height,
+	}
+	if status.Mode != PruneArchive {
+		status.StateRetention = sm.stateRetention()
+	}
+	if status.Mode == PrunePruned {
+		status.BlockRetention = sm.blockRetention()
+	}
+	return status
+}
+
+// Prune removes the history that the pruning mode no longer keeps and
+// compacts the database if anything was removed
+func (sm *StateManager) Prune() (*PruneResult, error) {
+	result := &PruneResult{}
+	if sm.db == nil || sm.pruningMode() == PruneArchive {
+		return result, nil
+	}
+
+	sm.mu.RLock()
+	stateBelow := sm.height - sm.stateRetention() + 1
+	blockBelow := sm.height - sm.blockRetention() + 1
+	sm.mu.RUnlock()
+
+	if stateBelow > 0 {
+		removed, err := sm.db.PruneStateHistory(stateBelow)
+		if err != nil {
+			return result, err
+		}
+		result.StateVersions = removed
+	}
+	if sm.pruningMode() == PrunePruned && blockBelow > 0 {
+		removed, err := sm.db.DeleteBlocksBelow(blockBelow)
+		if err != nil {
+			return result, err
+		}
+		result.Blocks = removed
+	}
+
+	if err := sm.refreshPruneState(); err != nil {
+		return result, err
+	}
+	if result.StateVersions > 0 || result.Blocks > 0 {
+		if err := sm.db.Compact(); err != nil {
+			return result, err
+		}
+		result.Compacted = true
+		log.Printf("🧹 Pruned %d state version(s) and %d block(s)", result.StateVersions, result.Blocks)
+	}
+	return result, nil
+}
+
+// refreshPruneState reloads how far the database has been pruned
+func (sm *StateManager) refreshPruneState() error {
+	if sm.db == nil {
+		return nil
+	}
+	state, err := sm.db.GetPruneState()
+	if err != nil {
+		return fmt.Errorf("failed to load prune state: %v", err)
+	}
+	sm.mu.Lock()
+	sm.pruned = *state
+	sm.mu.Unlock()
+	return nil
+}
+
+// StartPruning starts the background garbage collector
+func (sm *StateManager) StartPruning() {
+	if sm.db == nil || sm.pruningMode() == PruneArchive {
+		log.Printf("🗄️  Pruning disabled (%s mode)", sm.pruningMode())
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sm.stopPruning = cancel
+
+	interval := sm.pruneInterval()
+	log.Printf("🧹 Starting database garbage collector (%s mode, every %s)", sm.pruningMode(), interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := sm.Prune(); err != nil {
+					log.Printf("❌ Pruning failed: %v", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// StopPruning stops the background garbage collector
+func (sm *StateManager) StopPruning() {
+	if sm.stopPruning != nil {
+		sm.stopPruning()
+		sm.stopPruning = nil
+		log.Printf("🛑 Database garbage collector stopped")
+	}
+}
@@ -0,0 +1,121 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"atlas-blockchain/pkg/config"
+	"atlas-blockchain/pkg/database"
+)
+
+func TestPruning(t *testing.T) {
+	alice, bob, validator := newTestWallet(t), newTestWallet(t), newTestWallet(t)
+
+	// newPrunedChain adds five blocks that each send bob 100, keeping the
+	// state of 2 blocks and 3 blocks
+	newPrunedChain := func(t *testing.T, mode string) (*config.BlockchainConfig, *StateManager, *BlockManager) {
+		t.Chdir(t.TempDir())
+		cfg := config.DefaultConfig()
+		cfg.PruningMode = mode
+		cfg.StateRetention = 2
+		cfg.BlockRetention = 3
+		sm, bm := openChain(t, cfg)
+		sm.SetAccount(&database.Account{Address: addressOf(alice), Balance: 1000})
+		for nonce := uint64(1); nonce <= 5; nonce++ {
+			if err := bm.AddBlock(newBlock(t, bm, validator, signTransfer(t, alice, addressOf(bob), 100, nonce))); err != nil {
+				t.Fatalf("Failed to add block %d: %v", nonce, err)
+			}
+		}
+		return cfg, sm, bm
+	}
+	balanceAt := func(sm *StateManager, height int64) (int64, error) {
+		acct, err := sm.GetAccountAt(addressOf(bob), height)
+		if err != nil || acct == nil {
+			return 0, err
+		}
+		return acct.Balance, nil
+	}
+
+	t.Run("Pruned", func(t *testing.T) {
+		cfg, sm, bm := newPrunedChain(t, PrunePruned)
+		status := sm.PruningStatus()
+		if status.OldestStateHeight != 4 || status.OldestBlockHeight != 3 || status.LatestHeight != 5 {
+			t.Errorf("Expected state from 4 and blocks from 3 of 5, got %+v", status)
+		}
+		// Heights outside the window count as pruned before the collector runs
+		if _, err := balanceAt(sm, 3); !errors.Is(err, ErrHeightPruned) {
+			t.Errorf("Expected the state at height 3 to be pruned, got %v", err)
+		}
+
+		result, err := sm.Prune()
+		if err != nil {
+			t.Fatalf("Prune failed: %v", err)
+		}
+		if result.StateVersions == 0 || result.Blocks != 3 || !result.Compacted {
+			t.Errorf("Expected state versions and blocks 0 to 2 removed and the database compacted, got %+v", result)
+		}
+		if stored, _ := sm.db.GetBlockByHeight(2); stored != nil {
+			t.Errorf("Expected block 2 to be deleted")
+		}
+		if _, err := bm.GetBlockByIndex(2); !errors.Is(err, ErrHeightPruned) {
+			t.Errorf("Expected block 2 to be reported as pruned, got %v", err)
+		}
+		if blk, err := bm.GetBlockByIndex(3); err != nil || blk.Index != 3 {
+			t.Errorf("Expected block 3 to be kept, got %v", err)
+		}
+		if balance, err := balanceAt(sm, 4); err != nil || balance != 400 {
+			t.Errorf("Expected bob to have 400 at height 4, got %d (%v)", balance, err)
+		}
+
+		// A second run finds nothing left to remove
+		if result, err := sm.Prune(); err != nil || result.StateVersions != 0 || result.Blocks != 0 {
+			t.Errorf("Expected nothing to prune twice, got %+v (%v)", result, err)
+		}
+
+		sm.CloseDatabase()
+		sm, bm = openChain(t, cfg)
+		if got := bm.GetLatestBlock(); got.Index != 5 {
+			t.Errorf("Expected the pruned chain to load at height 5, got %d", got.Index)
+		}
+		if status := sm.PruningStatus(); status.OldestBlockHeight != 3 {
+			t.Errorf("Expected blocks from 3 after restart, got %+v", status)
+		}
+		if err := bm.AddBlock(newBlock(t, bm, validator, signTransfer(t, alice, addressOf(bob), 100, 6))); err != nil {
+			t.Errorf("Failed to extend the pruned chain: %v", err)
+		}
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		_, sm, bm := newPrunedChain(t, PruneFull)
+		result, err := sm.Prune()
+		if err != nil {
+			t.Fatalf("Prune failed: %v", err)
+		}
+		if result.StateVersions == 0 || result.Blocks != 0 {
+			t.Errorf("Expected only state versions to be removed, got %+v", result)
+		}
+		if _, err := bm.GetBlockByIndex(1); err != nil {
+			t.Errorf("Expected a full node to keep block 1, got %v", err)
+		}
+		if _, err := balanceAt(sm, 3); !errors.Is(err, ErrHeightPruned) {
+			t.Errorf("Expected the state at height 3 to be pruned, got %v", err)
+		}
+	})
+
+	t.Run("Archive", func(t *testing.T) {
+		_, sm, _ := newPrunedChain(t, PruneArchive)
+		result, err := sm.Prune()
+		if err != nil {
+			t.Fatalf("Prune failed: %v", err)
+		}
+		if result.StateVersions != 0 || result.Blocks != 0 {
+			t.Errorf("Expected an archive node to keep everything, got %+v", result)
+		}
+		if balance, err := balanceAt(sm, 1); err != nil || balance != 100 {
+			t.Errorf("Expected bob to have 100 at height 1, got %d (%v)", balance, err)
+		}
+		if status := sm.PruningStatus(); status.OldestStateHeight != 0 || status.OldestBlockHeight != 0 {
+			t.Errorf("Expected all history to be available, got %+v", status)
+		}
+	})
+}
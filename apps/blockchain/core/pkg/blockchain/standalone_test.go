@@ -2,8 +2,11 @@ package blockchain
 
 import (
 	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"atlas-blockchain/pkg/database"
 )
 
 // Simple validation test for WAL and connection pooling components
@@ -11,7 +14,7 @@ func TestCoreOptimization(t *testing.T) {
 	fmt.Println("🧪 Testing Atlas Database Optimizations...")
 
 	// Test 1: WAL Mode Configuration Validation
-	db, err := NewOptimizedDatabase("validation_test.db")
+	db, err := NewOptimizedDatabase(filepath.Join(t.TempDir(), "validation_test.db"))
 	if err != nil {
 		t.Fatalf("❌ WAL mode initialization failed: %v", err)
 	}
@@ -21,7 +24,7 @@ func TestCoreOptimization(t *testing.T) {
 
 	// Test 2: Connection Pool Size Validation
 	metrics := db.GetMetrics()
-	if metrics["max_connections"].(float64) != 10 {
+	if metrics["max_connections"].(int) != 10 {
 		t.Errorf("❌ Connection pool size incorrect: expected 10, got %v", metrics["max_connections"])
 	}
 	fmt.Printf("✅ Connection pool size validated: %v connections\n", metrics["max_connections"])
@@ -34,7 +37,7 @@ func TestCoreOptimization(t *testing.T) {
 
 	// Test 4: Basic Operations with Metrics
 	start := time.Now()
-	account := &Account{
+	account := &database.Account{
 		Address:   "test_validation_addr",
 		Balance:   1000,
 		Nonce:     1,
@@ -65,7 +68,7 @@ func TestCoreOptimization(t *testing.T) {
 
 	// Test 5: Metrics Collection Validation
 	finalMetrics := db.GetMetrics()
-	if finalMetrics["total_queries"].(float64) < 2 {
+	if finalMetrics["total_queries"].(int64) < 2 {
 		t.Errorf("❌ Metrics not collected: expected >2 queries, got %v", finalMetrics["total_queries"])
 	}
 	fmt.Printf("✅ Metrics collected: %v queries processed\n", finalMetrics["total_queries"])
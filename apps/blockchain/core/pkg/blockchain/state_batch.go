@@ -0,0 +1,176 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"atlas-blockchain/pkg/block"
+	"atlas-blockchain/pkg/database"
+	"atlas-blockchain/pkg/vm"
+)
+
+// blockBatch stages the state changes of the block being applied. Reads made
+// while applying the block see the staged values; nothing reaches the live
+// state or the database until the whole block is committed.
+type blockBatch struct {
+	accounts  map[string]*database.Account
+	contracts map[string]*vm.Contract
+	proposals map[string]*Proposal
+	votes     []*Vote
+	multisigs map[string]*MultisigAccount
+	receipts  []*database.Receipt
+	stakes    []pendingStake
+}
+
+// pendingStake is a validator stake registered with consensus once its block commits
+type pendingStake struct {
+	address string
+	amount  uint64
+}
+
+func newBlockBatch() *blockBatch {
+	return &blockBatch{
+		accounts:  make(map[string]*database.Account),
+		contracts: make(map[string]*vm.Contract),
+		proposals: make(map[string]*Proposal),
+		multisigs: make(map[string]*MultisigAccount),
+	}
+}
+
+// commitBatch writes the staged changes together with the block record in a
+// single database transaction, then applies them to the in-memory state. If
+// the database write fails nothing is applied.
+// Caller must hold sm.mu
+func (sm *StateManager) commitBatch(blk *block.Block) error {
+	b := sm.batch
+	height := int64(blk.Index)
+
+	if sm.db != nil {
+		if err := sm.writeBatch(b, blk); err != nil {
+			log.Printf("❌ commitBatch: Failed to commit block %d: %v", blk.Index, err)
+			return err
+		}
+	}
+
+	for address, acct := range b.accounts {
+		sm.accounts[address] = acct
+	}
+	for address, contract := range b.contracts {
+		sm.contracts[address] = contract
+	}
+	for id, proposal := range b.proposals {
+		sm.proposals[id] = proposal
+	}
+	for _, vote := range b.votes {
+		sm.votes[vote.ProposalID] = append(sm.votes[vote.ProposalID], vote)
+	}
+	for key, m := range b.multisigs {
+		sm.multisigs[key] = m
+	}
+	sm.height = height
+
+	log.Printf("💾 commitBatch: Committed block %d (%d accounts, %d contracts, %d proposals, %d votes)",
+		blk.Index, len(b.accounts), len(b.contracts), len(b.proposals), len(b.votes))
+	return nil
+}
+
+// writeBatch stores the staged changes with their state history entries, the
+// block and the state commit marker in one database transaction
+func (sm *StateManager) writeBatch(b *blockBatch, blk *block.Block) error {
+	batch, err := sm.db.NewBatch()
+	if err != nil {
+		return err
+	}
+	defer batch.Rollback()
+
+	diff := &database.StateDiff{Height: int64(blk.Index)}
+	for _, acct := range b.accounts {
+		if err := batch.SetAccount(acct); err != nil {
+			return err
+		}
+		diff.Accounts = append(diff.Accounts, acct)
+	}
+	for address, contract := range b.contracts {
+		record, err := contractToRecord(contract)
+		if err != nil {
+			return fmt.Errorf("failed to encode contract %s: %v", address, err)
+		}
+		if err := batch.SetContract(record); err != nil {
+			return err
+		}
+		diff.Contracts = append(diff.Contracts, &database.StorageVersion{Address: address, Storage: record.Storage})
+	}
+	if err := batch.SaveStateDiff(diff); err != nil {
+		return err
+	}
+	for _, proposal := range b.proposals {
+		record, err := proposalToRecord(proposal)
+		if err != nil {
+			return err
+		}
+		if err := batch.SetProposal(record); err != nil {
+			return err
+		}
+	}
+	for _, vote := range b.votes {
+		if err := batch.AddVote(&database.Vote{
+			ProposalID: vote.ProposalID,
+			Voter:      vote.Voter,
+			Choice:     vote.Choice,
+			Weight:     vote.Weight,
+		}); err != nil {
+			return err
+		}
+	}
+	for _, m := range b.multisigs {
+		record, err := multisigToRecord(m)
+		if err != nil {
+			return err
+		}
+		if err := batch.SetMultisig(record); err != nil {
+			return err
+		}
+	}
+
+	record, txHashes, err := blockToRecord(blk)
+	if err != nil {
+		return err
+	}
+	if err := batch.SaveBlock(record, txHashes); err != nil {
+		return err
+	}
+	if err := batch.SaveReceipts(b.receipts); err != nil {
+		return err
+	}
+	if err := batch.SetStateCommit(&database.StateCommit{Height: record.Height, BlockHash: record.Hash}); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
+
+// failReceipt marks a transaction receipt as failed
+func failReceipt(receipt *database.Receipt, err error) {
+	receipt.Status = database.ReceiptFailed
+	receipt.Error = err.Error()
+}
+
+// proposalToRecord converts a governance proposal into its database representation
+func proposalToRecord(proposal *Proposal) (*database.Proposal, error) {
+	voters, err := json.Marshal(proposal.Voters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal voters of proposal %s: %v", proposal.ID, err)
+	}
+	return &database.Proposal{
+		ID:           proposal.ID,
+		Proposer:     proposal.Proposer,
+		Description:  proposal.Description,
+		Actions:      proposal.Actions,
+		State:        proposal.State,
+		VotesFor:     proposal.VotesFor,
+		VotesAgainst: proposal.VotesAgainst,
+		StartBlock:   proposal.StartBlock,
+		EndBlock:     proposal.EndBlock,
+		Voters:       string(voters),
+	}, nil
+}
@@ -0,0 +1,143 @@
+package blockchain
+
+import (
+	"testing"
+
+	"atlas-blockchain/pkg/config"
+	"atlas-blockchain/pkg/database"
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/wallet"
+)
+
+func newTestWallet(t *testing.T) *wallet.Wallet {
+	t.Helper()
+	w, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	return w
+}
+
+func addressOf(w *wallet.Wallet) string {
+	return wallet.PublicKeyToAddress(w.PublicKey)
+}
+
+// signTransfer returns a transfer of amount from w to recipient
+func signTransfer(t *testing.T, from *wallet.Wallet, recipient string, amount int64, nonce uint64) transaction.Transaction {
+	t.Helper()
+	return signTx(t, from, transaction.Transaction{
+		Type:      transaction.TxTypeRegular,
+		Recipient: recipient,
+		Amount:    amount,
+		Nonce:     nonce,
+	})
+}
+
+// signTx completes tx as sent by from on the default chain and signs it
+func signTx(t *testing.T, from *wallet.Wallet, tx transaction.Transaction) transaction.Transaction {
+	t.Helper()
+	tx.ChainID = config.DefaultConfig().ChainID
+	tx.Sender = addressOf(from)
+	tx.SenderPublicKey = from.PublicKeyStr()
+	// Sign again until the unpadded signature verifies, as in newBlock
+	for {
+		if err := from.SignTransaction(&tx); err != nil {
+			t.Fatalf("Failed to sign transaction: %v", err)
+		}
+		if ok, _ := wallet.VerifyTransactionSignature(tx); ok {
+			return tx
+		}
+	}
+}
+
+// openChain loads the state and chain stored in the working directory
+func openChain(t *testing.T, cfg *config.BlockchainConfig) (*StateManager, *BlockManager) {
+	t.Helper()
+	sm := NewStateManager(cfg)
+	t.Cleanup(func() { sm.CloseDatabase() })
+	return sm, NewBlockManager(cfg, sm)
+}
+
+func TestBlockCommit(t *testing.T) {
+	alice, bob, validator := newTestWallet(t), newTestWallet(t), newTestWallet(t)
+	carol := "cb49a4cefae13ad235beb40e5ad603ba757da61d"
+	// open starts a chain in a temporary directory where alice has 1000
+	open := func(t *testing.T) (*StateManager, *BlockManager) {
+		t.Chdir(t.TempDir())
+		sm, bm := openChain(t, config.DefaultConfig())
+		sm.SetAccount(&database.Account{Address: addressOf(alice), Balance: 1000})
+		return sm, bm
+	}
+
+	t.Run("BlockAndStateCommitTogether", func(t *testing.T) {
+		sm, bm := open(t)
+		if err := bm.AddBlock(newBlock(t, bm, validator, signTransfer(t, alice, addressOf(bob), 100, 1))); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+		if got := sm.GetBalance(addressOf(bob)); got != 100 {
+			t.Errorf("Expected bob to have 100, got %d", got)
+		}
+		commit, err := sm.db.GetStateCommit()
+		if err != nil || commit == nil {
+			t.Fatalf("Expected a state commit, got %v (%v)", commit, err)
+		}
+		if tip := bm.GetLatestBlock(); commit.Height != 1 || commit.BlockHash != tip.Hash {
+			t.Errorf("Expected the state commit to be block 1 %s, got %d %s", tip.Hash, commit.Height, commit.BlockHash)
+		}
+		if stored, _ := sm.db.GetBlockByHeight(1); stored == nil {
+			t.Errorf("Expected block 1 to be stored with its state")
+		}
+		if acct, _ := sm.db.GetAccount(addressOf(bob)); acct == nil || acct.Balance != 100 {
+			t.Errorf("Expected bob's stored balance to be 100, got %+v", acct)
+		}
+	})
+
+	t.Run("FailedTransactionDiscardsBlock", func(t *testing.T) {
+		sm, bm := open(t)
+		// The first transfer is valid, but bob cannot pay for the second
+		first := signTransfer(t, alice, addressOf(bob), 100, 1)
+		if err := bm.AddBlock(newBlock(t, bm, validator, first, signTransfer(t, bob, carol, 500, 1))); err == nil {
+			t.Fatal("Expected the block to be refused")
+		}
+
+		if got := sm.GetBalance(addressOf(alice)); got != 1000 {
+			t.Errorf("Expected alice to keep 1000, got %d", got)
+		}
+		if got := sm.GetBalance(addressOf(bob)); got != 0 {
+			t.Errorf("Expected bob to have nothing, got %d", got)
+		}
+		if height := bm.GetBlockHeight(); height != 0 {
+			t.Errorf("Expected the chain to stay at genesis, got height %d", height)
+		}
+		if stored, _ := sm.db.GetBlockByHeight(1); stored != nil {
+			t.Errorf("Expected the refused block not to be stored")
+		}
+		if commit, _ := sm.db.GetStateCommit(); commit == nil || commit.Height != 0 {
+			t.Errorf("Expected the state commit to stay at genesis, got %+v", commit)
+		}
+
+		// The same transfer goes through in a valid block
+		if err := bm.AddBlock(newBlock(t, bm, validator, first)); err != nil {
+			t.Fatalf("Failed to add block after the refused one: %v", err)
+		}
+		if got := sm.GetBalance(addressOf(bob)); got != 100 {
+			t.Errorf("Expected bob to have 100, got %d", got)
+		}
+	})
+
+	t.Run("FailedWriteAppliesNothing", func(t *testing.T) {
+		sm, bm := open(t)
+		sm.db.Close()
+		if err := bm.AddBlock(newBlock(t, bm, validator, signTransfer(t, alice, addressOf(bob), 100, 1))); err == nil {
+			t.Fatal("Expected the block to fail without a database")
+		}
+		sm.mu.RLock()
+		defer sm.mu.RUnlock()
+		if acct, ok := sm.accounts[addressOf(bob)]; ok && acct.Balance != 0 {
+			t.Errorf("Expected bob's balance not to change in memory, got %d", acct.Balance)
+		}
+		if sm.height != 0 {
+			t.Errorf("Expected the state to stay at genesis, got height %d", sm.height)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"atlas-blockchain/pkg/database"
+)
+
+// GetAccountAt returns an account as it was after the block at height was
+// applied. Archive nodes answer for every height; full and pruned nodes only
+// for the retained recent heights.
+func (sm *StateManager) GetAccountAt(address string, height int64) (*database.Account, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if err := sm.checkStateHeight(height); err != nil {
+		return nil, err
+	}
+	if sm.db == nil {
+		return nil, fmt.Errorf("%w: no database for state history", ErrHeightNotAvailable)
+	}
+	acct, err := sm.db.GetAccountAt(address, height)
+	if err != nil {
+		return nil, err
+	}
+	if acct == nil {
+		// Without a version the account may predate state history, so its
+		// state at that height is unknown rather than empty
+		return nil, fmt.Errorf("%w: no history of %s at height %d", ErrHeightNotAvailable, address, height)
+	}
+	return acct, nil
+}
+
+// GetBalanceAt returns the balance of an address after the block at height
+func (sm *StateManager) GetBalanceAt(address string, height int64) (int64, error) {
+	acct, err := sm.GetAccountAt(address, height)
+	if err != nil {
+		return 0, err
+	}
+	return acct.Balance, nil
+}
+
+// GetNonceAt returns the nonce of an address after the block at height
+func (sm *StateManager) GetNonceAt(address string, height int64) (uint64, error) {
+	acct, err := sm.GetAccountAt(address, height)
+	if err != nil {
+		return 0, err
+	}
+	return acct.Nonce, nil
+}
+
+// GetStakeAt returns the amount staked by an address after the block at height
+func (sm *StateManager) GetStakeAt(address string, height int64) (int64, error) {
+	acct, err := sm.GetAccountAt(address, height)
+	if err != nil {
+		return 0, err
+	}
+	return acct.StakedAmount, nil
+}
+
+// GetStorageAt returns a copy of a contract's storage after the block at height
+func (sm *StateManager) GetStorageAt(address string, height int64) (map[string]interface{}, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	contract, ok := sm.getContractUnlocked(address)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrContractNotFound, address)
+	}
+	storage, err := sm.contractStorageAt(contract, height)
+	if err != nil {
+		return nil, err
+	}
+	return copyStorage(storage), nil
+}
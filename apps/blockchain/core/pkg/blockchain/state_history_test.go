@@ -0,0 +1,50 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"atlas-blockchain/pkg/config"
+	"atlas-blockchain/pkg/database"
+)
+
+func TestStateHistory(t *testing.T) {
+	alice, bob, validator := newTestWallet(t), newTestWallet(t), newTestWallet(t)
+	t.Chdir(t.TempDir())
+	sm, bm := openChain(t, config.DefaultConfig())
+	sm.SetAccount(&database.Account{Address: addressOf(alice), Balance: 1000})
+	for nonce := uint64(1); nonce <= 2; nonce++ {
+		if err := bm.AddBlock(newBlock(t, bm, validator, signTransfer(t, alice, addressOf(bob), 100, nonce))); err != nil {
+			t.Fatalf("Failed to add block %d: %v", nonce, err)
+		}
+	}
+
+	t.Run("Versions", func(t *testing.T) {
+		for height, want := range map[int64]int64{1: 900, 2: 800} {
+			if balance, err := sm.GetBalanceAt(addressOf(alice), height); err != nil || balance != want {
+				t.Errorf("Expected alice to have %d at height %d, got %d (%v)", want, height, balance, err)
+			}
+		}
+		if balance, err := sm.GetBalanceAt(addressOf(bob), 1); err != nil || balance != 100 {
+			t.Errorf("Expected bob to have 100 at height 1, got %d (%v)", balance, err)
+		}
+		if nonce, err := sm.GetNonceAt(addressOf(alice), 1); err != nil || nonce != 1 {
+			t.Errorf("Expected alice's nonce to be 1 at height 1, got %d (%v)", nonce, err)
+		}
+	})
+
+	t.Run("WithoutVersionIsNotAvailable", func(t *testing.T) {
+		// Funding alice outside of a block is recorded at height 1
+		for name, address := range map[string]string{"alice": addressOf(alice), "bob": addressOf(bob)} {
+			if _, err := sm.GetAccountAt(address, 0); !errors.Is(err, ErrHeightNotAvailable) {
+				t.Errorf("Expected ErrHeightNotAvailable for %s at height 0, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("AboveTipIsNotAvailable", func(t *testing.T) {
+		if _, err := sm.GetAccountAt(addressOf(bob), 3); !errors.Is(err, ErrHeightNotAvailable) {
+			t.Errorf("Expected ErrHeightNotAvailable above the tip, got %v", err)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package blockchain
 
 import (
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/json"
 	"errors"
@@ -18,6 +19,7 @@ import (
 	"atlas-blockchain/pkg/vm"
 	"atlas-blockchain/pkg/block"
 	"atlas-blockchain/pkg/database"
+	"atlas-blockchain/pkg/database/destination"
 	"atlas-blockchain/pkg/config"
 )
 
@@ -77,7 +79,7 @@ type StateManager struct {
 	accounts     map[string]*database.Account
 
 	// Database for persistent storage
-	db           *database.Database
+	db           database.Store
 
 	// Backup and recovery managers
 	backupManager   *database.BackupManager
@@ -86,24 +88,44 @@ type StateManager struct {
 	// Contract registry: address -> contract
 	contracts    map[string]*vm.Contract
 
+	// Height of the last block applied to the state
+	height       int64
+
 	// Governance registries
 	proposals    map[string]*Proposal
 	votes        map[string][]*Vote // proposalID -> votes
 
+	// Registered multisig accounts: normalized address -> account
+	multisigs    map[string]*MultisigAccount
+
 	// Oracle data registry: key -> OracleData
 	oracleData   map[string]OracleData
 	consensusManager *ConsensusManager // Add this line
+
+	// Changes of the block being applied, nil outside updateState
+	batch        *blockBatch
+
+	// How far the database history has been pruned
+	pruned       database.PruneState
+	stopPruning  func()
 }
 
 // NewStateManager creates a new state manager with persistence
 func NewStateManager(config *config.BlockchainConfig) *StateManager {
 	// Initialize database
-	db, err := database.NewDatabase("blockchain.db")
-	if err != nil {
-		log.Printf("⚠️  Failed to initialize database: %v, falling back to JSON snapshots", err)
-		db = nil
+	backend, dbPath := database.BackendSQLite, "blockchain.db"
+	if config != nil && config.StorageBackend != "" {
+		backend = config.StorageBackend
+	}
+	if config != nil && config.DatabasePath != "" {
+		dbPath = config.DatabasePath
+	}
+	var db database.Store
+	if store, err := database.Open(backend, dbPath); err != nil {
+		log.Printf("⚠️  Failed to initialize %s database: %v, falling back to JSON snapshots", backend, err)
 	} else {
-		log.Printf("✅ Database initialized successfully")
+		db = store
+		log.Printf("✅ Database initialized successfully (%s backend at %s)", backend, dbPath)
 	}
 	
 	// Initialize backup and recovery managers
@@ -132,6 +154,7 @@ func NewStateManager(config *config.BlockchainConfig) *StateManager {
 		contracts:    make(map[string]*vm.Contract), // Initialize contract registry
 		proposals:    make(map[string]*Proposal),
 		votes:        make(map[string][]*Vote),
+		multisigs:    make(map[string]*MultisigAccount),
 		oracleData:   make(map[string]OracleData),
 	}
 
@@ -140,6 +163,10 @@ func NewStateManager(config *config.BlockchainConfig) *StateManager {
 		log.Printf("Failed to create snapshot directory: %v", err)
 	}
 
+	if err := sm.refreshPruneState(); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+
 	// Try to load latest snapshot
 	if err := sm.loadLatestSnapshot(); err != nil {
 		log.Printf("Failed to load latest snapshot: %v", err)
@@ -159,35 +186,108 @@ func shortAddr(addr string) string {
 	return addr
 }
 
+// txAddress converts a hex public key used as a transaction party into its address
+func txAddress(addr string) string {
+	if len(addr) > 42 && addr[:2] != "0x" {
+		pubKeyBytes, _ := hex.DecodeString(addr)
+		return wallet.PublicKeyToAddress(pubKeyBytes)
+	}
+	return addr
+}
+
 // Define custom error types
 var (
 	ErrSnapshotCorrupt = errors.New("state snapshot is corrupt or invalid")
 	ErrStateCorrupt = errors.New("blockchain state is corrupt or inconsistent")
 )
 
-// updateState updates the blockchain state with a new block's transactions
+// updateState updates the blockchain state with a new block's transactions.
+// All changes of the block, and the block record itself, are committed as a
+// unit; if any transaction fails none of them are applied.
 func (sm *StateManager) updateState(block *block.Block) error {
 	log.Printf("🔄 updateState: Starting state update for block %d with %d transactions", block.Index, len(block.Transactions))
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
+	sm.batch = newBlockBatch()
+	err := sm.applyTransactions(block)
+	if err == nil {
+		err = sm.commitBatch(block)
+	}
+	stakes := sm.batch.stakes
+	sm.batch = nil
+	sm.mu.Unlock()
 
+	if err != nil {
+		log.Printf("↩️ updateState: Discarded all state changes of block %d", block.Index)
+		return err
+	}
+
+	// Validators are registered only once their stake is committed
+	if sm.consensusManager != nil {
+		for _, stake := range stakes {
+			if err := sm.consensusManager.OnChainStake(stake.address, stake.amount); err != nil {
+				log.Printf("❌ updateState: ConsensusManager.OnChainStake failed: %v", err)
+			}
+		}
+	}
+
+	log.Printf("📸 updateState: Checking if snapshot should be created...")
+	// Check if we should create a new snapshot
+	if time.Since(sm.lastSnapshot) >= time.Hour {
+		log.Printf("📸 updateState: Creating new snapshot...")
+		if err := sm.createSnapshot(int64(block.Index)); err != nil {
+			log.Printf("❌ updateState: Failed to create state snapshot: %v", err)
+		} else {
+			log.Printf("✅ updateState: Snapshot created successfully")
+		}
+	} else {
+		log.Printf("⏰ updateState: No snapshot needed yet (last: %v ago)", time.Since(sm.lastSnapshot))
+	}
+
+	log.Printf("✅ updateState: State update completed successfully for block %d", block.Index)
+	return nil
+}
+
+// applyTransactions stages the effects of a block's transactions in sm.batch
+// Caller must hold sm.mu
+func (sm *StateManager) applyTransactions(block *block.Block) error {
 	for i, tx := range block.Transactions {
 		log.Printf("💸 updateState: Processing transaction %d/%d - Sender: %s, Recipient: %s, Amount: %d, Fee: %d", 
 			i+1, len(block.Transactions), shortAddr(tx.Sender), shortAddr(tx.Recipient), tx.Amount, tx.Fee)
 		
-		sender := tx.Sender
-		recipient := tx.Recipient
-		if len(sender) > 42 && sender[:2] != "0x" {
-			pubKeyBytes, _ := hex.DecodeString(sender)
-			sender = wallet.PublicKeyToAddress(pubKeyBytes)
+		sender := txAddress(tx.Sender)
+		recipient := txAddress(tx.Recipient)
+		receipt := &database.Receipt{
+			TxHash:      TransactionHash(tx),
+			BlockHeight: int64(block.Index),
+			Index:       i,
+			Status:      database.ReceiptSuccess,
 		}
-		if len(recipient) > 42 && recipient[:2] != "0x" {
-			pubKeyBytes, _ := hex.DecodeString(recipient)
-			recipient = wallet.PublicKeyToAddress(pubKeyBytes)
+		sm.batch.receipts = append(sm.batch.receipts, receipt)
+
+		// Spending from a multisig account needs its co-signers' signatures
+		if err := sm.checkMultisigSenderUnlocked(tx); err != nil {
+			log.Printf("❌ updateState: %v", err)
+			return err
+		}
+
+		// Multisig registration is a transfer funding the new account
+		var multisig *MultisigAccount
+		if tx.Type == transaction.TxTypeMultisig {
+			if sender == "network" {
+				log.Printf("❌ updateState: Network cannot register a multisig account")
+				return fmt.Errorf("network cannot register a multisig account")
+			}
+			account, err := sm.newMultisigAccount(tx, int64(block.Index))
+			if err != nil {
+				log.Printf("❌ Multisig registration rejected: %v", err)
+				failReceipt(receipt, err)
+				continue
+			}
+			multisig = account
 		}
 
 		// Only apply to regular transfers
-		if tx.Type == transaction.TxTypeRegular {
+		if tx.Type == transaction.TxTypeRegular || multisig != nil {
 			if sender != "network" {
 				senderAcct := sm.getAccountUnlocked(sender)
 				// Check for sufficient funds (amount + fee)
@@ -217,6 +317,11 @@ func (sm *StateManager) updateState(block *block.Block) error {
 				sm.setAccountUnlocked(validatorAcct)
 				log.Printf("💎 updateState: Credited fee %d to validator %s", tx.Fee, shortAddr(block.Validator))
 			}
+			if multisig != nil {
+				sm.batch.multisigs[multisigKey(multisig.Address)] = multisig
+				log.Printf("🔐 Multisig account %s registered by %s (%d of %d)", 
+					shortAddr(multisig.Address), shortAddr(sender), multisig.Threshold, len(multisig.PublicKeys))
+			}
 			continue
 		}
 
@@ -226,31 +331,34 @@ func (sm *StateManager) updateState(block *block.Block) error {
 			var jsonContract vm.JSONContract
 			if err := json.Unmarshal([]byte(tx.Data), &jsonContract); err != nil {
 				log.Printf("❌ Failed to parse JSON contract: %v", err)
+				failReceipt(receipt, err)
 				continue
 			}
 			// Deploy contract
 			contract, err := vm.DeployJSONContract(sender, &jsonContract, true) // Default to upgradable for now
 			if err != nil {
 				log.Printf("❌ Failed to deploy contract: %v", err)
+				failReceipt(receipt, err)
 				continue
 			}
-			sm.SetContract(contract.Address, contract)
+			contract.CodeHistory[0].BlockHeight = int64(block.Index)
+			receipt.ContractAddress = contract.Address
+			sm.setContractUnlocked(contract.Address, contract)
 			log.Printf("🚀 Contract '%s' deployed at %s by %s", contract.Name, shortAddr(contract.Address), shortAddr(sender))
 			continue
 		} else if tx.Type == transaction.TxTypeCall {
-			// Parse call data from tx.Data (JSON: {"function":..., "args":...})
-			var call struct {
-				Function string        `json:"function"`
-				Args     []interface{} `json:"args"`
-			}
+			// Parse call data from tx.Data (JSON: {"function":..., "args":...} or {"function":..., "params":{...}})
+			var call contractCall
 			if err := json.Unmarshal([]byte(tx.Data), &call); err != nil {
 				log.Printf("❌ Failed to parse contract call data: %v", err)
+				failReceipt(receipt, err)
 				continue
 			}
 			// Load contract
-			contract, ok := sm.GetContract(recipient)
+			contract, ok := sm.getContractUnlocked(recipient)
 			if !ok {
 				log.Printf("❌ Contract not found at %s", shortAddr(recipient))
+				failReceipt(receipt, fmt.Errorf("%w: %s", ErrContractNotFound, recipient))
 				continue
 			}
 			// Prepare execution context
@@ -259,31 +367,72 @@ func (sm *StateManager) updateState(block *block.Block) error {
 				Value:    tx.Amount,
 				GasLimit: uint64(tx.Fee),
 			}
-			// Create VM instance
-			vmInstance := &vm.VM{
-				StateManager: sm,
-			}
-			// Initialize VM memory with contract storage
-			vmInstance.Memory = make(map[string]int64)
-			for k, v := range contract.Storage {
-				if ival, ok := toInt64Safe(v); ok {
-					vmInstance.Memory[k] = ival
-				}
+			// Create VM instance with memory initialized from contract storage
+			vmInstance := sm.newContractVM(contract, contract.Storage)
+			// Execute function, binding named parameters through the ABI when given
+			var err error
+			if call.Params != nil {
+				_, err = contract.CallNamed(call.Function, call.Params, vmInstance, execCtx)
+			} else {
+				err = contract.CallFunction(call.Function, call.Args, vmInstance, execCtx)
 			}
-			// Execute function
-			if err := contract.CallFunction(call.Function, call.Args, vmInstance, execCtx); err != nil {
+			receipt.GasUsed = vmInstance.GetGasUsed()
+			if err != nil {
 				log.Printf("❌ Contract function '%s' execution failed: %v", call.Function, err)
+				failReceipt(receipt, err)
 				continue
 			}
 			// After execution, write VM memory back to contract storage
 			for k, v := range vmInstance.Memory {
 				contract.Storage[k] = v
 			}
+			if len(vmInstance.Logs) > 0 {
+				receipt.Logs, _ = json.Marshal(vmInstance.Logs)
+			}
 			contract.UpdatedAt = time.Now().Unix()
-			sm.SetContract(contract.Address, contract)
+			sm.setContractUnlocked(contract.Address, contract)
 			log.Printf("⚙️ Contract '%s' function '%s' executed at %s by %s (gas used: %d)", 
 				contract.Name, call.Function, shortAddr(recipient), shortAddr(sender), vmInstance.GetGasUsed())
 			continue
+		} else if tx.Type == transaction.TxTypeUpgrade {
+			if sender == "network" {
+				log.Printf("❌ updateState: Network cannot upgrade a contract")
+				return fmt.Errorf("network cannot upgrade a contract")
+			}
+			// The fee is charged and the nonce used even if the upgrade is
+			// rejected, so the same signed upgrade cannot be applied again
+			senderAcct := sm.getAccountUnlocked(sender)
+			if senderAcct.Balance < tx.Fee {
+				log.Printf("❌ updateState: Insufficient funds for upgrade by %s (balance: %d, required: %d)", shortAddr(sender), senderAcct.Balance, tx.Fee)
+				return fmt.Errorf("insufficient funds for upgrade by %s: balance %d, required %d", sender, senderAcct.Balance, tx.Fee)
+			}
+			senderAcct.Balance -= tx.Fee
+			senderAcct.Nonce++
+			sm.setAccountUnlocked(senderAcct)
+			// Credit fee to block proposer (validator)
+			if tx.Fee > 0 && block.Validator != "" && block.Validator != "GENESIS_VALIDATOR" {
+				validatorAcct := sm.getAccountUnlocked(block.Validator)
+				validatorAcct.Balance += tx.Fee
+				sm.setAccountUnlocked(validatorAcct)
+				log.Printf("💎 updateState: Credited fee %d to validator %s", tx.Fee, shortAddr(block.Validator))
+			}
+			// Parse upgrade data from tx.Data (JSON: {"contract":{...}, "migration":..., "proposal_id":...})
+			var req UpgradeRequest
+			if err := json.Unmarshal([]byte(tx.Data), &req); err != nil {
+				log.Printf("❌ Failed to parse contract upgrade data: %v", err)
+				failReceipt(receipt, err)
+				continue
+			}
+			upgraded, gasUsed, err := sm.applyContractUpgrade(sender, recipient, &req, uint64(tx.Fee), int64(block.Index), block.Timestamp)
+			receipt.GasUsed = gasUsed
+			if err != nil {
+				log.Printf("❌ Contract upgrade at %s rejected: %v", shortAddr(recipient), err)
+				failReceipt(receipt, err)
+				continue
+			}
+			log.Printf("⬆️ Contract '%s' upgraded to version %s at %s by %s", 
+				upgraded.Name, upgraded.Version, shortAddr(recipient), shortAddr(sender))
+			continue
 		}
 
 		// Handle governance transactions
@@ -296,11 +445,12 @@ func (sm *StateManager) updateState(block *block.Block) error {
 			}
 			if err := json.Unmarshal([]byte(tx.Data), &proposalData); err != nil {
 				log.Printf("❌ Failed to parse proposal data: %v", err)
+				failReceipt(receipt, err)
 				continue
 			}
 			startBlock := int64(block.Index)
 			endBlock := startBlock + proposalData.Duration
-			proposal := sm.SubmitProposal(sender, proposalData.Description, proposalData.Actions, startBlock, endBlock)
+			proposal := sm.submitProposalUnlocked(sender, proposalData.Description, proposalData.Actions, startBlock, endBlock)
 			proposal.State = ProposalActive
 			log.Printf("🗳️ Proposal submitted by %s: %s (ID: %s)", shortAddr(sender), proposal.Description, proposal.ID)
 			continue
@@ -313,15 +463,17 @@ func (sm *StateManager) updateState(block *block.Block) error {
 			}
 			if err := json.Unmarshal([]byte(tx.Data), &voteData); err != nil {
 				log.Printf("❌ Failed to parse vote data: %v", err)
+				failReceipt(receipt, err)
 				continue
 			}
-			if err := sm.CastVote(voteData.ProposalID, sender, voteData.Choice, voteData.Weight); err != nil {
+			if err := sm.castVoteUnlocked(voteData.ProposalID, sender, voteData.Choice, voteData.Weight); err != nil {
 				log.Printf("❌ Failed to cast vote: %v", err)
+				failReceipt(receipt, err)
 				continue
 			}
 			log.Printf("🗳️ Vote cast by %s on proposal %s: %s (%d)", shortAddr(sender), voteData.ProposalID, voteData.Choice, voteData.Weight)
 			// Tally proposal if voting period ended
-			sm.TallyProposal(voteData.ProposalID, int64(block.Index))
+			sm.tallyProposalUnlocked(voteData.ProposalID, int64(block.Index))
 			continue
 		}
 
@@ -339,6 +491,12 @@ func (sm *StateManager) updateState(block *block.Block) error {
 				log.Printf("❌ updateState: Stake amount too low: %d (min: %d)", tx.Amount, minStake)
 				return fmt.Errorf("stake amount too low: %d (min: %d)", tx.Amount, minStake)
 			}
+			if sm.consensusManager != nil {
+				if err := sm.consensusManager.CheckStake(uint64(tx.Amount)); err != nil {
+					log.Printf("❌ updateState: ConsensusManager rejected stake: %v", err)
+					return fmt.Errorf("consensus manager staking failed: %v", err)
+				}
+			}
 			senderAcct := sm.getAccountUnlocked(sender)
 			if senderAcct.Balance < tx.Amount+tx.Fee {
 				log.Printf("❌ updateState: Insufficient funds for staking by %s (balance: %d, required: %d)", shortAddr(sender), senderAcct.Balance, tx.Amount+tx.Fee)
@@ -346,16 +504,12 @@ func (sm *StateManager) updateState(block *block.Block) error {
 			}
 			senderAcct.Balance -= tx.Amount + tx.Fee
 			senderAcct.Nonce++
+			senderAcct.StakedAmount += tx.Amount
+			senderAcct.IsValidator = true
 			sm.setAccountUnlocked(senderAcct)
-			log.Printf("✅ updateState: Deducted stake and fee from %s, new balance: %d", shortAddr(sender), senderAcct.Balance)
-			// Register or update validator in consensus manager
-			if sm.consensusManager != nil {
-				err := sm.consensusManager.OnChainStake(sender, uint64(tx.Amount))
-				if err != nil {
-					log.Printf("❌ updateState: ConsensusManager.OnChainStake failed: %v", err)
-					return fmt.Errorf("consensus manager staking failed: %v", err)
-				}
-			}
+			log.Printf("✅ updateState: Deducted stake and fee from %s, new balance: %d, staked: %d", shortAddr(sender), senderAcct.Balance, senderAcct.StakedAmount)
+			// Register or update validator in consensus manager after commit
+			sm.batch.stakes = append(sm.batch.stakes, pendingStake{address: sender, amount: uint64(tx.Amount)})
 			// Credit fee to block proposer (validator)
 			if tx.Fee > 0 && block.Validator != "" && block.Validator != "GENESIS_VALIDATOR" {
 				validatorAcct := sm.getAccountUnlocked(block.Validator)
@@ -366,21 +520,6 @@ func (sm *StateManager) updateState(block *block.Block) error {
 			continue
 		}
 	}
-
-	log.Printf("📸 updateState: Checking if snapshot should be created...")
-	// Check if we should create a new snapshot
-	if time.Since(sm.lastSnapshot) >= time.Hour {
-		log.Printf("📸 updateState: Creating new snapshot...")
-		if err := sm.createSnapshot(int64(block.Index)); err != nil {
-			log.Printf("❌ updateState: Failed to create state snapshot: %v", err)
-		} else {
-			log.Printf("✅ updateState: Snapshot created successfully")
-		}
-	} else {
-		log.Printf("⏰ updateState: No snapshot needed yet (last: %v ago)", time.Since(sm.lastSnapshot))
-	}
-
-	log.Printf("✅ updateState: State update completed successfully for block %d", block.Index)
 	return nil
 }
 
@@ -718,16 +857,40 @@ func (sm *StateManager) GetAccount(address string) *database.Account {
 
 // getAccountUnlocked returns the Account for a given address without acquiring locks
 // Use this when you already have the lock (e.g., from updateState)
+// While a block is applied the account is a staged copy that setAccountUnlocked
+// keeps in the batch
 func (sm *StateManager) getAccountUnlocked(address string) *database.Account {
 	log.Printf("🔍 getAccountUnlocked: Getting account for %s", shortAddr(address))
+	if sm.batch != nil {
+		if acct, ok := sm.batch.accounts[address]; ok {
+			log.Printf("✅ getAccountUnlocked: Found staged account for %s with balance %d", shortAddr(address), acct.Balance)
+			return acct
+		}
+	}
 	acct, exists := sm.accounts[address]
+	if !exists && sm.db != nil {
+		dbAccount, err := sm.db.GetAccount(address)
+		if err != nil {
+			log.Printf("⚠️  Failed to get account from database: %v", err)
+		} else if dbAccount != nil {
+			acct, exists = dbAccount, true
+			sm.accounts[address] = acct
+		}
+	}
 	if !exists {
 		log.Printf("🆕 getAccountUnlocked: Creating new account for %s", shortAddr(address))
 		acct = &database.Account{Address: address, Balance: 0, Nonce: 0}
+		if sm.batch != nil {
+			return acct
+		}
 		sm.accounts[address] = acct
 	} else {
 		log.Printf("✅ getAccountUnlocked: Found existing account for %s with balance %d", shortAddr(address), acct.Balance)
 	}
+	if sm.batch != nil {
+		staged := *acct
+		return &staged
+	}
 	return acct
 }
 
@@ -735,7 +898,7 @@ func (sm *StateManager) getAccountUnlocked(address string) *database.Account {
 func (sm *StateManager) SetAccount(acct *database.Account) {
 	// Try database first if available
 	if sm.db != nil {
-		if err := sm.db.SetAccount(acct); err != nil {
+		if err := sm.saveAccount(acct); err != nil {
 			log.Printf("⚠️  Failed to set account in database: %v", err)
 		} else {
 			log.Printf("💾 SetAccount: Saved account to database for %s with balance %d", shortAddr(acct.Address), acct.Balance)
@@ -750,33 +913,235 @@ func (sm *StateManager) SetAccount(acct *database.Account) {
 	sm.accounts[acct.Address] = acct
 }
 
+// saveAccount stores an account changed outside of a block, recording the
+// change in the state history of the next height, the first whose state
+// includes it. The state of committed heights is never rewritten.
+func (sm *StateManager) saveAccount(acct *database.Account) error {
+	sm.mu.RLock()
+	height := sm.height + 1
+	sm.mu.RUnlock()
+
+	batch, err := sm.db.NewBatch()
+	if err != nil {
+		return err
+	}
+	defer batch.Rollback()
+	if err := batch.SetAccount(acct); err != nil {
+		return err
+	}
+	if err := batch.SaveStateDiff(&database.StateDiff{Height: height, Accounts: []*database.Account{acct}}); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
+
 // setAccountUnlocked sets the Account for a given address without acquiring locks
 // Use this when you already have the lock (e.g., from updateState)
 func (sm *StateManager) setAccountUnlocked(acct *database.Account) {
 	log.Printf("💾 setAccountUnlocked: Setting account for %s with balance %d", shortAddr(acct.Address), acct.Balance)
+	if sm.batch != nil {
+		sm.batch.accounts[acct.Address] = acct
+		return
+	}
 	sm.accounts[acct.Address] = acct
 }
 
+// contractCode is the serialized form of a contract kept in the contracts.code column
+type contractCode struct {
+	Name         string                  `json:"name"`
+	Version      string                  `json:"version"`
+	Upgradable   bool                    `json:"upgradable"`
+	ContractType vm.ContractType         `json:"contract_type"`
+	Functions    map[string]*vm.Function `json:"functions"`
+	CreatedAt    int64                   `json:"created_at"`
+	UpdatedAt    int64                   `json:"updated_at"`
+	CodeHistory  []vm.CodeVersion        `json:"code_history,omitempty"`
+}
+
 // GetContract retrieves a contract by address
 func (sm *StateManager) GetContract(address string) (*vm.Contract, bool) {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.getContractUnlocked(address)
+}
+
+// getContractUnlocked retrieves a contract without acquiring locks, falling back
+// to the database for contracts deployed before a restart. While a block is
+// applied the contract is a staged copy with its own storage.
+func (sm *StateManager) getContractUnlocked(address string) (*vm.Contract, bool) {
+	if sm.batch != nil {
+		if c, ok := sm.batch.contracts[address]; ok {
+			return c, true
+		}
+		c, ok := sm.loadContractUnlocked(address)
+		if !ok {
+			return nil, false
+		}
+		staged := *c
+		staged.Storage = copyStorage(c.Storage)
+		return &staged, true
+	}
+	return sm.loadContractUnlocked(address)
+}
+
+// loadContractUnlocked retrieves a committed contract from memory or the database
+func (sm *StateManager) loadContractUnlocked(address string) (*vm.Contract, bool) {
 	c, ok := sm.contracts[address]
-	return c, ok
+	if ok || sm.db == nil {
+		return c, ok
+	}
+
+	record, err := sm.db.GetContract(address)
+	if err != nil {
+		log.Printf("⚠️  Failed to get contract from database: %v", err)
+		return nil, false
+	}
+	if record == nil {
+		return nil, false
+	}
+	c, err = contractFromRecord(record)
+	if err != nil {
+		log.Printf("⚠️  Failed to decode contract %s: %v", shortAddr(address), err)
+		return nil, false
+	}
+	sm.contracts[address] = c
+	return c, true
 }
 
 // SetContract stores or updates a contract
 func (sm *StateManager) SetContract(address string, contract *vm.Contract) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	sm.setContractUnlocked(address, contract)
+}
+
+// setContractUnlocked stores a contract in memory and the database without acquiring locks
+// Use this when you already have the lock (e.g., from updateState)
+func (sm *StateManager) setContractUnlocked(address string, contract *vm.Contract) {
+	if sm.batch != nil {
+		sm.batch.contracts[address] = contract
+		return
+	}
 	sm.contracts[address] = contract
+	if sm.db == nil {
+		return
+	}
+	record, err := contractToRecord(contract)
+	if err != nil {
+		log.Printf("⚠️  Failed to encode contract %s: %v", shortAddr(address), err)
+		return
+	}
+	if err := sm.saveContract(record); err != nil {
+		log.Printf("⚠️  Failed to set contract in database: %v", err)
+	}
+}
+
+// saveContract stores a contract changed outside of a block, recording its
+// storage in the state history of the next height, like saveAccount
+// Caller must hold sm.mu
+func (sm *StateManager) saveContract(record *database.Contract) error {
+	batch, err := sm.db.NewBatch()
+	if err != nil {
+		return err
+	}
+	defer batch.Rollback()
+	if err := batch.SetContract(record); err != nil {
+		return err
+	}
+	diff := &database.StateDiff{
+		Height:    sm.height + 1,
+		Contracts: []*database.StorageVersion{{Address: record.Address, Storage: record.Storage}},
+	}
+	if err := batch.SaveStateDiff(diff); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
+
+// contractToRecord converts a VM contract into its database representation
+func contractToRecord(contract *vm.Contract) (*database.Contract, error) {
+	code, err := json.Marshal(contractCode{
+		Name:         contract.Name,
+		Version:      contract.Version,
+		Upgradable:   contract.Upgradable,
+		ContractType: contract.ContractType,
+		Functions:    contract.Functions,
+		CreatedAt:    contract.CreatedAt,
+		UpdatedAt:    contract.UpdatedAt,
+		CodeHistory:  contract.CodeHistory,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal contract code: %v", err)
+	}
+	storage, err := json.Marshal(contract.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal contract storage: %v", err)
+	}
+	abi, err := vm.MarshalABI(contract.ABI)
+	if err != nil {
+		return nil, err
+	}
+	return &database.Contract{
+		Address: contract.Address,
+		Code:    string(code),
+		Storage: string(storage),
+		Owner:   contract.Owner,
+		ABI:     abi,
+	}, nil
+}
+
+// contractFromRecord rebuilds a VM contract from its database representation
+func contractFromRecord(record *database.Contract) (*vm.Contract, error) {
+	var code contractCode
+	if err := json.Unmarshal([]byte(record.Code), &code); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal contract code: %v", err)
+	}
+	storage := make(map[string]interface{})
+	if record.Storage != "" {
+		if err := json.Unmarshal([]byte(record.Storage), &storage); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal contract storage: %v", err)
+		}
+	}
+	abi, err := vm.UnmarshalABI(record.ABI)
+	if err != nil {
+		return nil, err
+	}
+	if len(abi.Functions) == 0 {
+		abi = vm.BuildABI(code.Functions, abi.Events)
+	}
+	return &vm.Contract{
+		Address:      record.Address,
+		Name:         code.Name,
+		Version:      code.Version,
+		Functions:    code.Functions,
+		Storage:      storage,
+		Owner:        record.Owner,
+		Upgradable:   code.Upgradable,
+		CreatedAt:    code.CreatedAt,
+		UpdatedAt:    code.UpdatedAt,
+		ContractType: code.ContractType,
+		ABI:          abi,
+		CodeHistory:  code.CodeHistory,
+	}, nil
 }
 
 // SubmitProposal adds a new proposal
 func (sm *StateManager) SubmitProposal(proposer, description, actions string, startBlock, endBlock int64) *Proposal {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	id := fmt.Sprintf("proposal_%d", len(sm.proposals)+1)
+	return sm.submitProposalUnlocked(proposer, description, actions, startBlock, endBlock)
+}
+
+func (sm *StateManager) submitProposalUnlocked(proposer, description, actions string, startBlock, endBlock int64) *Proposal {
+	count := len(sm.proposals)
+	if sm.batch != nil {
+		for id := range sm.batch.proposals {
+			if _, exists := sm.proposals[id]; !exists {
+				count++
+			}
+		}
+	}
+	id := fmt.Sprintf("proposal_%d", count+1)
 	proposal := &Proposal{
 		ID:          id,
 		Proposer:    proposer,
@@ -789,7 +1154,7 @@ func (sm *StateManager) SubmitProposal(proposer, description, actions string, st
 		EndBlock:    endBlock,
 		Voters:      make(map[string]bool),
 	}
-	sm.proposals[id] = proposal
+	sm.setProposalUnlocked(proposal)
 	return proposal
 }
 
@@ -797,7 +1162,11 @@ func (sm *StateManager) SubmitProposal(proposer, description, actions string, st
 func (sm *StateManager) CastVote(proposalID, voter, choice string, weight int64) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	proposal, ok := sm.proposals[proposalID]
+	return sm.castVoteUnlocked(proposalID, voter, choice, weight)
+}
+
+func (sm *StateManager) castVoteUnlocked(proposalID, voter, choice string, weight int64) error {
+	proposal, ok := sm.getProposalUnlocked(proposalID)
 	if !ok {
 		return fmt.Errorf("proposal not found")
 	}
@@ -821,7 +1190,12 @@ func (sm *StateManager) CastVote(proposalID, voter, choice string, weight int64)
 		return fmt.Errorf("invalid vote choice")
 	}
 	proposal.Voters[voter] = true
-	sm.votes[proposalID] = append(sm.votes[proposalID], vote)
+	sm.setProposalUnlocked(proposal)
+	if sm.batch != nil {
+		sm.batch.votes = append(sm.batch.votes, vote)
+	} else {
+		sm.votes[proposalID] = append(sm.votes[proposalID], vote)
+	}
 	return nil
 }
 
@@ -829,7 +1203,11 @@ func (sm *StateManager) CastVote(proposalID, voter, choice string, weight int64)
 func (sm *StateManager) TallyProposal(proposalID string, currentBlock int64) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	proposal, ok := sm.proposals[proposalID]
+	sm.tallyProposalUnlocked(proposalID, currentBlock)
+}
+
+func (sm *StateManager) tallyProposalUnlocked(proposalID string, currentBlock int64) {
+	proposal, ok := sm.getProposalUnlocked(proposalID)
 	if !ok {
 		return
 	}
@@ -839,9 +1217,39 @@ func (sm *StateManager) TallyProposal(proposalID string, currentBlock int64) {
 		} else {
 			proposal.State = ProposalFailed
 		}
+		sm.setProposalUnlocked(proposal)
 	}
 }
 
+// getProposalUnlocked retrieves a proposal without acquiring locks. While a
+// block is applied the proposal is a staged copy.
+func (sm *StateManager) getProposalUnlocked(id string) (*Proposal, bool) {
+	if sm.batch != nil {
+		if p, ok := sm.batch.proposals[id]; ok {
+			return p, true
+		}
+	}
+	p, ok := sm.proposals[id]
+	if !ok || sm.batch == nil {
+		return p, ok
+	}
+	staged := *p
+	staged.Voters = make(map[string]bool, len(p.Voters))
+	for voter, voted := range p.Voters {
+		staged.Voters[voter] = voted
+	}
+	return &staged, true
+}
+
+// setProposalUnlocked stores a proposal, staging it while a block is applied
+func (sm *StateManager) setProposalUnlocked(proposal *Proposal) {
+	if sm.batch != nil {
+		sm.batch.proposals[proposal.ID] = proposal
+		return
+	}
+	sm.proposals[proposal.ID] = proposal
+}
+
 // SetOracleData sets the value for a given oracle key
 func (sm *StateManager) SetOracleData(key, value, source string, timestamp int64) {
 	sm.mu.Lock()
@@ -904,6 +1312,12 @@ func (sm *StateManager) MigrateToDatabase() error {
 	return nil
 }
 
+// HasDatabase reports whether state is persisted in a database rather than
+// only in JSON snapshots
+func (sm *StateManager) HasDatabase() bool {
+	return sm.db != nil
+}
+
 // CloseDatabase closes the database connection
 func (sm *StateManager) CloseDatabase() error {
 	if sm.db != nil {
@@ -924,6 +1338,29 @@ func (sm *StateManager) StartBackupSystem() {
 	}
 }
 
+// ConfigureBackups applies the backup settings of the configuration: with a
+// backup password, backups are encrypted and signed with nodeKey, and each
+// backup destination receives a copy of every new backup
+func (sm *StateManager) ConfigureBackups(nodeKey ed25519.PrivateKey) error {
+	if sm.backupManager == nil || sm.config == nil {
+		return nil
+	}
+	if sm.config.BackupPassword != "" {
+		keys := &database.ArchiveKeys{Password: sm.config.BackupPassword, NodeKey: nodeKey}
+		sm.backupManager.SetArchiveKeys(keys)
+		sm.recoveryManager.SetArchiveKeys(keys)
+		log.Printf("🔐 Backups are encrypted and signed with the node key")
+	}
+	for _, raw := range sm.config.BackupDestinations {
+		dest, retention, err := destination.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("invalid backup destination %q: %v", raw, err)
+		}
+		sm.backupManager.AddDestination(dest, retention)
+	}
+	return nil
+}
+
 // StopBackupSystem stops the automated backup system
 func (sm *StateManager) StopBackupSystem() {
 	if sm.backupManager != nil {
@@ -951,7 +1388,8 @@ func (sm *StateManager) GetBackupList() []*database.BackupInfo {
 	return []*database.BackupInfo{}
 }
 
-// CreateManualBackup creates a manual backup
+// CreateManualBackup creates a backup now, incremental when a recent full
+// backup exists
 func (sm *StateManager) CreateManualBackup() error {
 	if sm.backupManager != nil {
 		return sm.backupManager.CreateBackup()
@@ -959,6 +1397,14 @@ func (sm *StateManager) CreateManualBackup() error {
 	return fmt.Errorf("backup system not available")
 }
 
+// CreateFullBackup creates a full backup outside the schedule
+func (sm *StateManager) CreateFullBackup() error {
+	if sm.backupManager != nil {
+		return sm.backupManager.CreateFullBackup()
+	}
+	return fmt.Errorf("backup system not available")
+}
+
 // PerformAutomaticRecovery performs automatic recovery if corruption is detected
 func (sm *StateManager) PerformAutomaticRecovery() error {
 	if sm.recoveryManager != nil {
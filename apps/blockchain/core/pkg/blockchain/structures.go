@@ -20,15 +20,9 @@ func createNewBlock(transactions []transaction.Transaction, prevBlock *block.Blo
 		if err := tx.Validate(); err != nil {
 			return nil, fmt.Errorf("transaction validation failed: %v", err)
 		}
-		if tx.Sender != "network" {
-			valid, err := wallet.VerifyTransactionSignature(tx)
-			if err != nil {
-				return nil, fmt.Errorf("signature verification error: %v", err)
-			}
-			if !valid {
-				return nil, fmt.Errorf("invalid signature for transaction from %s", tx.Sender)
-			}
-		}
+	}
+	if err := block.VerifyTransactions(transactions, wallet.VerifiedSignatures); err != nil {
+		return nil, fmt.Errorf("signature verification error: %v", err)
 	}
 
 	validatorPubKeyHex := validatorWallet.PublicKeyStr()
@@ -49,18 +43,3 @@ func createNewBlock(transactions []transaction.Transaction, prevBlock *block.Blo
 	newBlock.Hash = block.CalculateHash(*newBlock)
 	return newBlock, nil
 }
-
-// createGenesisBlock creates the first block in the chain.
-// The genesis block has no transactions and a special previous hash.
-func createGenesisBlock() *block.Block {
-	genesisBlock := &block.Block{
-		Index:        0,
-		Timestamp:    1640995200, // Fixed timestamp: January 1, 2022 00:00:00 UTC
-		Transactions: []transaction.Transaction{},
-		PrevHash:     "0",
-		Validator:    "GENESIS_VALIDATOR",
-		Signature:    "GENESIS_SIGNATURE",
-	}
-	genesisBlock.Hash = block.CalculateHash(*genesisBlock)
-	return genesisBlock
-}
@@ -2,6 +2,7 @@ package blockchain
 
 import (
 	"container/heap"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"sync"
@@ -35,6 +36,8 @@ type TransactionManager struct {
 	historicalSuccessRate map[string]float64 // Tracks historical success rate
 
 	dynamicFeeMultiplier float64 // Dynamic fee multiplier based on congestion
+
+	onTransactionAdded []func(transaction.Transaction) // Callbacks called after a transaction enters the pool
 }
 
 // NewTransactionManager creates a new transaction manager
@@ -53,8 +56,31 @@ func NewTransactionManager(config *config.BlockchainConfig, stateManager *StateM
 	return tm
 }
 
+// checkChainID rejects a transaction that was not signed for chainID
+func checkChainID(tx transaction.Transaction, chainID string) error {
+	if tx.ChainID == "" {
+		return fmt.Errorf("transaction has no chain ID (this node is on %q)", chainID)
+	}
+	if tx.ChainID != chainID {
+		return fmt.Errorf("transaction is for chain %q, not %q", tx.ChainID, chainID)
+	}
+	return nil
+}
+
 // AddTransaction adds a transaction to the pool with priority calculation
 func (tm *TransactionManager) AddTransaction(tx transaction.Transaction) error {
+	// Verified before taking the lock; the shared cache spares block import
+	// from verifying the transaction again
+	if tx.Sender != "network" {
+		valid, err := wallet.VerifiedSignatures.Verify(tx)
+		if err != nil {
+			return fmt.Errorf("signature verification error: %v", err)
+		}
+		if !valid {
+			return fmt.Errorf("invalid signature for transaction from %s", tx.Sender)
+		}
+	}
+
 	fmt.Printf("[DEBUG] AddTransaction: Attempting to acquire lock...\n")
 	tm.mu.Lock()
 	fmt.Printf("[DEBUG] AddTransaction: Lock acquired.\n")
@@ -67,6 +93,20 @@ func (tm *TransactionManager) AddTransaction(tx transaction.Transaction) error {
 	tm.UpdateDynamicFeeMultiplier()
 	fmt.Printf("[DEBUG] AddTransaction: UpdateDynamicFeeMultiplier complete.\n")
 
+	// Transactions signed for another network could be replayed here
+	if tx.Sender != "network" {
+		if err := checkChainID(tx, tm.config.ChainID); err != nil {
+			return err
+		}
+	}
+
+	// Multisig accounts can only be spent from with their co-signers' signatures
+	if tm.stateManager != nil {
+		if err := tm.stateManager.CheckMultisigSender(tx); err != nil {
+			return err
+		}
+	}
+
 	// Nonce validation: check that the transaction nonce matches the sender's account nonce
 	if tx.Sender != "network" && tm.stateManager != nil {
 		expectedNonce := tm.stateManager.GetNonce(tx.Sender)
@@ -128,9 +168,22 @@ func (tm *TransactionManager) AddTransaction(tx transaction.Transaction) error {
 	tm.byHash[txHash] = tp
 	fmt.Printf("[DEBUG] AddTransaction: Transaction added successfully.\n")
 
+	for _, callback := range tm.onTransactionAdded {
+		callback(tx)
+	}
+
 	return nil
 }
 
+// SetOnTransactionAddedCallback registers a callback to be called after a
+// transaction enters the pool. Callbacks run with the pool locked, so they
+// must not block or call back into the TransactionManager.
+func (tm *TransactionManager) SetOnTransactionAddedCallback(callback func(transaction.Transaction)) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.onTransactionAdded = append(tm.onTransactionAdded, callback)
+}
+
 // GetTransactionsForBlock returns the highest priority transactions for a new block
 func (tm *TransactionManager) GetTransactionsForBlock() []transaction.Transaction {
 	tm.mu.Lock()
@@ -257,10 +310,15 @@ func (tm *TransactionManager) GetPoolSize() int {
 	return len(tm.pool)
 }
 
-// GetTransactionByHash retrieves a transaction from the pool by its hash
+// GetTransactionByHash retrieves a transaction from the pool by its hash,
+// hex encoded as TransactionHash returns it
 func (tm *TransactionManager) GetTransactionByHash(hash string) *transaction.Transaction {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
+	// The pool is keyed by the raw hash
+	if raw, err := hex.DecodeString(hash); err == nil {
+		hash = string(raw)
+	}
 	if tp, exists := tm.byHash[hash]; exists {
 		return &tp.Transaction
 	}
@@ -0,0 +1,242 @@
+// Package chainfile reads and writes portable chain archives.
+//
+// An archive is a gzip stream holding:
+//
+//	magic    "ATLASCHN"
+//	version  uint16, big endian
+//	header   uint32 length + JSON Header
+//	blocks   uint32 length + JSON block, repeated
+//	end      uint32 zero
+//	checksum SHA-256 of the header and block records
+//
+// Blocks are written in height order, so archives can be streamed in both
+// directions without holding the chain in memory.
+package chainfile
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"atlas-blockchain/pkg/block"
+)
+
+// Version is the archive format version written by this package
+const Version = 1
+
+const magic = "ATLASCHN"
+
+// maxRecordSize bounds a single record so a corrupt length cannot exhaust
+// memory
+const maxRecordSize = 64 << 20
+
+// Header describes the contents of an archive
+type Header struct {
+	GenesisHash string    `json:"genesis_hash"` // Chain the blocks belong to
+	From        int64     `json:"from"`         // First block height
+	To          int64     `json:"to"`           // Last block height
+	Created     time.Time `json:"created"`
+}
+
+// Count is the number of blocks the archive holds
+func (h Header) Count() int64 {
+	return h.To - h.From + 1
+}
+
+// Writer writes blocks to an archive
+type Writer struct {
+	gz     *gzip.Writer
+	sum    hash.Hash
+	header Header
+	next   int64
+	closed bool
+}
+
+// NewWriter writes the archive preamble for header to w
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	if header.From < 0 || header.To < header.From {
+		return nil, fmt.Errorf("invalid block range %d-%d", header.From, header.To)
+	}
+	if header.Created.IsZero() {
+		header.Created = time.Now().UTC()
+	}
+	gz := gzip.NewWriter(w)
+	cw := &Writer{gz: gz, sum: sha256.New(), header: header, next: header.From}
+
+	if _, err := io.WriteString(gz, magic); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(gz, binary.BigEndian, uint16(Version)); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if err := cw.writeRecord(data); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (w *Writer) writeRecord(data []byte) error {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	out := io.MultiWriter(w.gz, w.sum)
+	if _, err := out.Write(length[:]); err != nil {
+		return err
+	}
+	_, err := out.Write(data)
+	return err
+}
+
+// WriteBlock appends the next block of the range
+func (w *Writer) WriteBlock(blk *block.Block) error {
+	if int64(blk.Index) != w.next || w.next > w.header.To {
+		return fmt.Errorf("expected block %d, got %d", w.next, blk.Index)
+	}
+	data, err := json.Marshal(blk)
+	if err != nil {
+		return fmt.Errorf("failed to marshal block %d: %v", blk.Index, err)
+	}
+	if err := w.writeRecord(data); err != nil {
+		return fmt.Errorf("failed to write block %d: %v", blk.Index, err)
+	}
+	w.next++
+	return nil
+}
+
+// Close writes the end marker and checksum. It fails if blocks of the
+// range are missing. The underlying writer is not closed.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.next != w.header.To+1 {
+		w.gz.Close()
+		return fmt.Errorf("archive incomplete: wrote blocks %d-%d of %d-%d", w.header.From, w.next-1, w.header.From, w.header.To)
+	}
+	if _, err := w.gz.Write(make([]byte, 4)); err != nil {
+		return err
+	}
+	if _, err := w.gz.Write(w.sum.Sum(nil)); err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+// Reader reads blocks from an archive
+type Reader struct {
+	gz     *gzip.Reader
+	r      *bufio.Reader
+	sum    hash.Hash
+	header Header
+	next   int64
+	done   bool
+}
+
+// NewReader reads the archive preamble from r
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a chain archive: %v", err)
+	}
+	cr := &Reader{gz: gz, r: bufio.NewReader(gz), sum: sha256.New()}
+
+	prefix := make([]byte, len(magic))
+	if _, err := io.ReadFull(cr.r, prefix); err != nil || string(prefix) != magic {
+		return nil, errors.New("not a chain archive")
+	}
+	var version uint16
+	if err := binary.Read(cr.r, binary.BigEndian, &version); err != nil {
+		return nil, fmt.Errorf("failed to read archive version: %v", err)
+	}
+	if version != Version {
+		return nil, fmt.Errorf("unsupported chain archive version %d (supported: %d)", version, Version)
+	}
+	data, err := cr.readRecord()
+	if err != nil || data == nil {
+		return nil, fmt.Errorf("failed to read archive header: %v", err)
+	}
+	if err := json.Unmarshal(data, &cr.header); err != nil {
+		return nil, fmt.Errorf("invalid archive header: %v", err)
+	}
+	cr.next = cr.header.From
+	return cr, nil
+}
+
+// Header returns the archive header
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// readRecord returns the next record, or nil at the end marker
+func (r *Reader) readRecord() ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r.r, length[:]); err != nil {
+		return nil, fmt.Errorf("archive truncated: %v", err)
+	}
+	n := binary.BigEndian.Uint32(length[:])
+	if n == 0 {
+		return nil, nil
+	}
+	if n > maxRecordSize {
+		return nil, fmt.Errorf("record of %d bytes exceeds the limit", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, fmt.Errorf("archive truncated: %v", err)
+	}
+	r.sum.Write(length[:])
+	r.sum.Write(data)
+	return data, nil
+}
+
+// Next returns the next block. After the last block it verifies the
+// checksum and returns io.EOF.
+func (r *Reader) Next() (*block.Block, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	data, err := r.readRecord()
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		r.done = true
+		if r.next != r.header.To+1 {
+			return nil, fmt.Errorf("archive ends at block %d, header promises %d", r.next-1, r.header.To)
+		}
+		checksum := make([]byte, sha256.Size)
+		if _, err := io.ReadFull(r.r, checksum); err != nil {
+			return nil, fmt.Errorf("archive truncated: %v", err)
+		}
+		if string(checksum) != string(r.sum.Sum(nil)) {
+			return nil, errors.New("archive checksum mismatch")
+		}
+		return nil, io.EOF
+	}
+
+	var blk block.Block
+	if err := json.Unmarshal(data, &blk); err != nil {
+		return nil, fmt.Errorf("invalid block record after height %d: %v", r.next-1, err)
+	}
+	if int64(blk.Index) != r.next {
+		return nil, fmt.Errorf("expected block %d, got %d", r.next, blk.Index)
+	}
+	r.next++
+	return &blk, nil
+}
+
+// Close releases the decompressor. The underlying reader is not closed.
+func (r *Reader) Close() error {
+	return r.gz.Close()
+}
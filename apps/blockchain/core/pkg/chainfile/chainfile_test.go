@@ -0,0 +1,161 @@
+package chainfile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"atlas-blockchain/pkg/block"
+	"atlas-blockchain/pkg/transaction"
+)
+
+func testBlocks(from, to int) []*block.Block {
+	var blocks []*block.Block
+	prev := "0"
+	for i := from; i <= to; i++ {
+		blk := &block.Block{Index: i, Timestamp: int64(1000 + i), PrevHash: prev, Validator: "v",
+			Transactions: []transaction.Transaction{{Sender: "alice", Recipient: "bob", Amount: int64(i)}}}
+		blk.Hash = block.CalculateHash(*blk)
+		prev = blk.Hash
+		blocks = append(blocks, blk)
+	}
+	return blocks
+}
+
+func writeArchive(t *testing.T, header Header, blocks []*block.Block) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, header)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	for _, blk := range blocks {
+		if err := w.WriteBlock(blk); err != nil {
+			t.Fatalf("WriteBlock failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func readArchive(data []byte) ([]*block.Block, error) {
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var blocks []*block.Block
+	for {
+		blk, err := r.Next()
+		if err == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			return blocks, err
+		}
+		blocks = append(blocks, blk)
+	}
+}
+
+// rewrite decompresses an archive, edits it and compresses it again, so the
+// damage is only visible to the archive's own checks
+func rewrite(t *testing.T, data []byte, edit func([]byte) []byte) []byte {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := io.ReadAll(gz)
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(edit(raw))
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestArchive(t *testing.T) {
+	blocks := testBlocks(5, 9)
+	header := Header{GenesisHash: "genesis", From: 5, To: 9}
+	data := writeArchive(t, header, blocks)
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		r, err := NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("NewReader failed: %v", err)
+		}
+		if h := r.Header(); h.GenesisHash != "genesis" || h.From != 5 || h.To != 9 || h.Count() != 5 || h.Created.IsZero() {
+			t.Errorf("Unexpected header %+v", h)
+		}
+		got, err := readArchive(data)
+		if err != nil {
+			t.Fatalf("Reading failed: %v", err)
+		}
+		if len(got) != len(blocks) {
+			t.Fatalf("Expected %d blocks, got %d", len(blocks), len(got))
+		}
+		for i, blk := range got {
+			if blk.Hash != blocks[i].Hash || blk.Transactions[0].Amount != blocks[i].Transactions[0].Amount {
+				t.Errorf("Block %d differs after the round trip", blk.Index)
+			}
+		}
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		tampered := rewrite(t, data, func(raw []byte) []byte {
+			return bytes.Replace(raw, []byte(`"Amount":7`), []byte(`"Amount":8`), 1)
+		})
+		if _, err := readArchive(tampered); err == nil || !strings.Contains(err.Error(), "checksum") {
+			t.Errorf("Expected a checksum mismatch, got %v", err)
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		truncated := rewrite(t, data, func(raw []byte) []byte { return raw[:len(raw)/2] })
+		if _, err := readArchive(truncated); err == nil || !strings.Contains(err.Error(), "truncated") {
+			t.Errorf("Expected a truncation error, got %v", err)
+		}
+	})
+
+	t.Run("UnsupportedVersion", func(t *testing.T) {
+		future := rewrite(t, data, func(raw []byte) []byte {
+			raw[len(magic)+1] = Version + 1
+			return raw
+		})
+		if _, err := NewReader(bytes.NewReader(future)); err == nil || !strings.Contains(err.Error(), "version") {
+			t.Errorf("Expected an unsupported version error, got %v", err)
+		}
+	})
+
+	t.Run("NotAnArchive", func(t *testing.T) {
+		if _, err := NewReader(strings.NewReader("plain text")); err == nil {
+			t.Errorf("Expected an error for a file that is not an archive")
+		}
+	})
+}
+
+func TestWriterChecks(t *testing.T) {
+	blocks := testBlocks(0, 2)
+
+	t.Run("OutOfOrder", func(t *testing.T) {
+		w, _ := NewWriter(io.Discard, Header{From: 0, To: 2})
+		if err := w.WriteBlock(blocks[1]); err == nil {
+			t.Errorf("Expected an error for a block out of order")
+		}
+	})
+
+	t.Run("Incomplete", func(t *testing.T) {
+		w, _ := NewWriter(io.Discard, Header{From: 0, To: 2})
+		w.WriteBlock(blocks[0])
+		if err := w.Close(); err == nil {
+			t.Errorf("Expected an error when closing an incomplete archive")
+		}
+	})
+
+	t.Run("InvalidRange", func(t *testing.T) {
+		if _, err := NewWriter(io.Discard, Header{From: 3, To: 1}); err == nil {
+			t.Errorf("Expected an error for an empty range")
+		}
+	})
+}
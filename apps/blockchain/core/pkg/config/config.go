@@ -8,6 +8,7 @@ import (
 // BlockchainConfig holds the configuration parameters for the blockchain.
 type BlockchainConfig struct {
 	// Network parameters
+	ChainID            string        // Network the node belongs to; transactions must be signed for it
 	MaxPeers           int           // Maximum number of peers a node can connect to
 	PeerDiscoveryPort int           // Port for peer discovery
 	BlockTime         time.Duration // Target time between blocks
@@ -25,11 +26,29 @@ type BlockchainConfig struct {
 	// Security parameters
 	MaxValidators     int // Maximum number of validators in the pool
 	SlashingPenalty   int // Amount to slash for malicious behavior
+
+	// Contract execution parameters
+	CallGasCap        uint64 // Maximum gas for read-only calls and gas estimation
+
+	// Storage parameters
+	StorageBackend    string // "sqlite" (requires CGO) or "kv" (embedded, pure Go)
+	DatabasePath      string // Path of the database file
+
+	// Pruning parameters
+	PruningMode       string        // "archive", "full" or "pruned"
+	StateRetention    int64         // Recent heights whose state is kept in full and pruned modes
+	BlockRetention    int64         // Recent blocks kept in pruned mode
+	PruneInterval     time.Duration // Time between database garbage collection runs
+
+	// Backup parameters
+	BackupDestinations []string // Extra backup copies: directories, sftp:// or s3:// URLs
+	BackupPassword     string   // Encrypts backups, which are then signed with the node key
 }
 
 // DefaultConfig returns the default configuration for the blockchain.
 func DefaultConfig() *BlockchainConfig {
 	return &BlockchainConfig{
+		ChainID:            "atlas-devnet",
 		MaxPeers:           10,
 		PeerDiscoveryPort: 8000,
 		BlockTime:          time.Second * 30,
@@ -41,11 +60,21 @@ func DefaultConfig() *BlockchainConfig {
 		ValidatorRotation:  100,
 		MaxValidators:      100,
 		SlashingPenalty:    50,
+		CallGasCap:         10000000,
+		StorageBackend:     "sqlite",
+		DatabasePath:       "blockchain.db",
+		PruningMode:        "full",
+		StateRetention:     128,
+		BlockRetention:     1000,
+		PruneInterval:      time.Minute * 10,
 	}
 }
 
 // Validate checks if the configuration is valid.
 func (c *BlockchainConfig) Validate() error {
+	if c.ChainID == "" {
+		return errors.New("ChainID must be set")
+	}
 	if c.MaxPeers <= 0 {
 		return errors.New("MaxPeers must be positive")
 	}
@@ -79,5 +108,26 @@ func (c *BlockchainConfig) Validate() error {
 	if c.SlashingPenalty <= 0 {
 		return errors.New("SlashingPenalty must be positive")
 	}
+	if c.CallGasCap == 0 {
+		return errors.New("CallGasCap must be positive")
+	}
+	if c.StorageBackend != "" && c.StorageBackend != "sqlite" && c.StorageBackend != "kv" {
+		return errors.New("StorageBackend must be \"sqlite\" or \"kv\"")
+	}
+	switch c.PruningMode {
+	case "", "archive":
+	case "full", "pruned":
+		if c.StateRetention <= 0 {
+			return errors.New("StateRetention must be positive")
+		}
+		if c.PruningMode == "pruned" && c.BlockRetention < c.StateRetention {
+			return errors.New("BlockRetention must be at least StateRetention")
+		}
+	default:
+		return errors.New("PruningMode must be \"archive\", \"full\" or \"pruned\"")
+	}
+	if c.PruneInterval <= 0 {
+		return errors.New("PruneInterval must be positive")
+	}
 	return nil
 } 
\ No newline at end of file
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"atlas-blockchain/pkg/crypto"
+)
+
+// ArchiveKeys encrypt and sign backup archives
+type ArchiveKeys struct {
+	Password string             // Encryption key is derived from it with a per-archive salt
+	NodeKey  ed25519.PrivateKey // Signs archives; restores accept only its signatures
+}
+
+// SealedArchive is an encrypted backup signed by the node that wrote it
+type SealedArchive struct {
+	Version   int                   `json:"version"`
+	Salt      string                `json:"salt"` // Hex salt for crypto.DeriveKeyFromPassword
+	Encrypted *crypto.EncryptedData `json:"encrypted"`
+	Signer    string                `json:"signer"`    // Hex Ed25519 public key
+	Signature string                `json:"signature"` // Hex signature of signedPayload
+}
+
+const sealedArchiveVersion = 1
+
+// signedPayload is the part of an archive covered by its signature
+func (a *SealedArchive) signedPayload() ([]byte, error) {
+	return json.Marshal(struct {
+		Version   int                   `json:"version"`
+		Salt      string                `json:"salt"`
+		Encrypted *crypto.EncryptedData `json:"encrypted"`
+	}{a.Version, a.Salt, a.Encrypted})
+}
+
+// SealArchive encrypts data with a key derived from the password and signs
+// the result with the node key
+func SealArchive(data []byte, keys *ArchiveKeys) ([]byte, error) {
+	if keys == nil || keys.Password == "" || len(keys.NodeKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("backup encryption requires a password and an Ed25519 node key")
+	}
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	key, err := crypto.DeriveKeyFromPassword(keys.Password, salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive backup key: %v", err)
+	}
+	encrypted, err := crypto.EncryptData(data, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt backup: %v", err)
+	}
+
+	archive := &SealedArchive{
+		Version:   sealedArchiveVersion,
+		Salt:      hex.EncodeToString(salt),
+		Encrypted: encrypted,
+		Signer:    hex.EncodeToString(keys.NodeKey.Public().(ed25519.PublicKey)),
+	}
+	payload, err := archive.signedPayload()
+	if err != nil {
+		return nil, err
+	}
+	archive.Signature = hex.EncodeToString(ed25519.Sign(keys.NodeKey, payload))
+	return json.Marshal(archive)
+}
+
+// OpenArchive checks that a sealed archive was signed by the node key and
+// decrypts it
+func OpenArchive(sealed []byte, keys *ArchiveKeys) ([]byte, error) {
+	if keys == nil || keys.Password == "" || len(keys.NodeKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("backup is encrypted but no backup keys are configured")
+	}
+	var archive SealedArchive
+	if err := json.Unmarshal(sealed, &archive); err != nil {
+		return nil, fmt.Errorf("failed to decode sealed backup: %v", err)
+	}
+	if archive.Version != sealedArchiveVersion || archive.Encrypted == nil {
+		return nil, fmt.Errorf("unsupported sealed backup version %d", archive.Version)
+	}
+
+	public := keys.NodeKey.Public().(ed25519.PublicKey)
+	if archive.Signer != hex.EncodeToString(public) {
+		return nil, fmt.Errorf("backup was signed by %s, not by this node", shortChecksum(archive.Signer))
+	}
+	signature, err := hex.DecodeString(archive.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup signature: %v", err)
+	}
+	payload, err := archive.signedPayload()
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(public, payload, signature) {
+		return nil, fmt.Errorf("backup signature does not match its contents")
+	}
+
+	salt, err := hex.DecodeString(archive.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup salt: %v", err)
+	}
+	key, err := crypto.DeriveKeyFromPassword(keys.Password, salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive backup key: %v", err)
+	}
+	data, err := crypto.DecryptData(archive.Encrypted, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt backup (wrong password?): %v", err)
+	}
+	return data, nil
+}
+
+// sealFile replaces the file at path with a sealed copy written to sealedPath
+func sealFile(path, sealedPath string, keys *ArchiveKeys) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sealed, err := SealArchive(data, keys)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(sealedPath, sealed, 0600); err != nil {
+		return fmt.Errorf("failed to write sealed backup: %v", err)
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,67 @@
+package database_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+
+	"atlas-blockchain/pkg/database"
+)
+
+func archiveKeys(seed byte, password string) *database.ArchiveKeys {
+	return &database.ArchiveKeys{
+		Password: password,
+		NodeKey:  ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize)),
+	}
+}
+
+func TestSealedArchive(t *testing.T) {
+	keys := archiveKeys(1, "correct horse")
+	data := []byte("backup contents")
+	sealed, err := database.SealArchive(data, keys)
+	if err != nil {
+		t.Fatalf("SealArchive failed: %v", err)
+	}
+	if bytes.Contains(sealed, data) {
+		t.Errorf("Expected the sealed archive not to contain the plain data")
+	}
+
+	t.Run("Open", func(t *testing.T) {
+		opened, err := database.OpenArchive(sealed, keys)
+		if err != nil || !bytes.Equal(opened, data) {
+			t.Errorf("Expected the original data back, got %q (%v)", opened, err)
+		}
+	})
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		if _, err := database.OpenArchive(sealed, archiveKeys(1, "wrong")); err == nil {
+			t.Errorf("Expected a wrong password to fail")
+		}
+	})
+
+	t.Run("OtherNode", func(t *testing.T) {
+		if _, err := database.OpenArchive(sealed, archiveKeys(2, "correct horse")); err == nil {
+			t.Errorf("Expected an archive signed by another node to be rejected")
+		}
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		var archive database.SealedArchive
+		json.Unmarshal(sealed, &archive)
+		archive.Salt = "00" + archive.Salt[2:]
+		tampered, _ := json.Marshal(archive)
+		if _, err := database.OpenArchive(tampered, keys); err == nil {
+			t.Errorf("Expected a tampered archive to fail signature verification")
+		}
+	})
+
+	t.Run("NoKeys", func(t *testing.T) {
+		if _, err := database.SealArchive(data, &database.ArchiveKeys{Password: "x"}); err == nil {
+			t.Errorf("Expected sealing without a node key to fail")
+		}
+		if _, err := database.OpenArchive(sealed, nil); err == nil {
+			t.Errorf("Expected opening without keys to fail")
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Backup types
+const (
+	BackupFull        = "full"        // Complete copy of the store
+	BackupIncremental = "incremental" // Changes since the parent backup
+)
+
+// IncrementalBackup holds the changes between two backed up heights
+type IncrementalBackup struct {
+	BaseHeight int64              `json:"base_height"` // Height of the parent backup
+	Commit     StateCommit        `json:"commit"`      // State commit the backup ends at
+	Blocks     []*Block           `json:"blocks"`
+	Receipts   []*Receipt         `json:"receipts"`
+	Diffs      []*StateDiff       `json:"diffs"`
+	Contracts  []*Contract        `json:"contracts"` // Contracts changed since the parent, with their storage at Commit
+	Proposals  []*Proposal        `json:"proposals"`
+	Votes      map[string][]*Vote `json:"votes"` // Keyed by proposal ID
+	Multisigs  []*MultisigAccount `json:"multisigs,omitempty"`
+}
+
+// collectIncremental reads the changes made to db after baseHeight, up to
+// and including the state commit at to
+func collectIncremental(db Store, baseHeight int64, to *StateCommit) (*IncrementalBackup, error) {
+	inc := &IncrementalBackup{BaseHeight: baseHeight, Commit: *to, Votes: make(map[string][]*Vote)}
+
+	blocks, err := db.GetBlockRange(baseHeight+1, to.Height)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(blocks)) != to.Height-baseHeight {
+		return nil, fmt.Errorf("blocks %d to %d are no longer all stored", baseHeight+1, to.Height)
+	}
+	inc.Blocks = blocks
+	for _, block := range blocks {
+		receipts, err := db.GetBlockReceipts(block.Height)
+		if err != nil {
+			return nil, err
+		}
+		inc.Receipts = append(inc.Receipts, receipts...)
+	}
+
+	if inc.Diffs, err = db.GetStateDiffs(baseHeight+1, to.Height); err != nil {
+		return nil, err
+	}
+	// Contract records only change in their storage, so the latest storage
+	// version up to the commit is combined with the stored code and owner
+	storage := make(map[string]string)
+	for _, diff := range inc.Diffs {
+		for _, version := range diff.Contracts {
+			storage[version.Address] = version.Storage
+		}
+	}
+	for address, data := range storage {
+		contract, err := db.GetContract(address)
+		if err != nil {
+			return nil, err
+		}
+		if contract == nil {
+			return nil, fmt.Errorf("contract %s has storage history but no record", address)
+		}
+		contract.Storage = data
+		inc.Contracts = append(inc.Contracts, contract)
+	}
+	sort.Slice(inc.Contracts, func(i, j int) bool { return inc.Contracts[i].Address < inc.Contracts[j].Address })
+
+	if inc.Proposals, err = db.GetAllProposals(); err != nil {
+		return nil, err
+	}
+	for _, proposal := range inc.Proposals {
+		votes, err := db.GetVotesForProposal(proposal.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(votes) > 0 {
+			inc.Votes[proposal.ID] = votes
+		}
+	}
+	if inc.Multisigs, err = db.GetAllMultisigs(); err != nil {
+		return nil, err
+	}
+	return inc, nil
+}
+
+// applyIncremental writes an incremental backup on top of a store restored
+// to its base height, in a single batch
+func applyIncremental(db Store, inc *IncrementalBackup) error {
+	commit, err := db.GetStateCommit()
+	if err != nil {
+		return err
+	}
+	if commit == nil || commit.Height != inc.BaseHeight {
+		return fmt.Errorf("incremental backup starts at height %d but the store is at %v", inc.BaseHeight, commit)
+	}
+
+	batch, err := db.NewBatch()
+	if err != nil {
+		return err
+	}
+	defer batch.Rollback()
+
+	receipts := make(map[int64][]*Receipt)
+	for _, receipt := range inc.Receipts {
+		receipts[receipt.BlockHeight] = append(receipts[receipt.BlockHeight], receipt)
+	}
+	for _, block := range inc.Blocks {
+		txHashes := make([]string, len(receipts[block.Height]))
+		for i, receipt := range receipts[block.Height] {
+			txHashes[i] = receipt.TxHash
+		}
+		if err := batch.SaveBlock(block, txHashes); err != nil {
+			return err
+		}
+		if err := batch.SaveReceipts(receipts[block.Height]); err != nil {
+			return err
+		}
+	}
+
+	// Diffs are in height order, so the last version of each account wins
+	latest := make(map[string]*Account)
+	for _, diff := range inc.Diffs {
+		if err := batch.SaveStateDiff(diff); err != nil {
+			return err
+		}
+		for _, account := range diff.Accounts {
+			latest[account.Address] = account
+		}
+	}
+	for _, account := range latest {
+		if err := batch.SetAccount(account); err != nil {
+			return err
+		}
+	}
+	for _, contract := range inc.Contracts {
+		if err := batch.SetContract(contract); err != nil {
+			return err
+		}
+	}
+
+	for _, proposal := range inc.Proposals {
+		if err := batch.SetProposal(proposal); err != nil {
+			return err
+		}
+		existing, err := db.GetVotesForProposal(proposal.ID)
+		if err != nil {
+			return err
+		}
+		voted := make(map[string]bool)
+		for _, vote := range existing {
+			voted[vote.Voter] = true
+		}
+		for _, vote := range inc.Votes[proposal.ID] {
+			if voted[vote.Voter] {
+				continue
+			}
+			if err := batch.AddVote(vote); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, account := range inc.Multisigs {
+		if err := batch.SetMultisig(account); err != nil {
+			return err
+		}
+	}
+
+	if err := batch.SetStateCommit(&inc.Commit); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
+
+// writeIncremental stores an incremental backup as gzipped JSON
+func writeIncremental(path string, inc *IncrementalBackup) error {
+	err := writeGzip(path, func(w io.Writer) error { return json.NewEncoder(w).Encode(inc) })
+	if err != nil {
+		return fmt.Errorf("failed to write incremental backup: %v", err)
+	}
+	return nil
+}
+
+// readIncremental loads an incremental backup written by writeIncremental
+func readIncremental(r io.Reader) (*IncrementalBackup, error) {
+	var inc IncrementalBackup
+	if err := json.NewDecoder(r).Decode(&inc); err != nil {
+		return nil, fmt.Errorf("failed to decode incremental backup: %v", err)
+	}
+	return &inc, nil
+}
+
+// StateChecksum hashes the state held by a store: the state commit, every
+// account and every contract's code and storage. Two stores with the same
+// checksum hold the same state.
+func StateChecksum(db Store) (string, error) {
+	commit, err := db.GetStateCommit()
+	if err != nil {
+		return "", err
+	}
+	accounts, err := db.GetAllAccounts()
+	if err != nil {
+		return "", err
+	}
+	contracts, err := db.GetAllContracts()
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Address < accounts[j].Address })
+	sort.Slice(contracts, func(i, j int) bool { return contracts[i].Address < contracts[j].Address })
+
+	hash := sha256.New()
+	if commit != nil {
+		fmt.Fprintf(hash, "commit:%d:%s\n", commit.Height, commit.BlockHash)
+	}
+	for _, account := range accounts {
+		fmt.Fprintf(hash, "account:%s:%d:%d:%t:%d\n", account.Address, account.Balance,
+			account.Nonce, account.IsValidator, account.StakedAmount)
+	}
+	for _, contract := range contracts {
+		fmt.Fprintf(hash, "contract:%s:%s:%q:%q\n", contract.Address, contract.Owner, contract.Code, contract.Storage)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
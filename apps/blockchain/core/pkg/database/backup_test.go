@@ -0,0 +1,270 @@
+package database_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"atlas-blockchain/pkg/database"
+	"atlas-blockchain/pkg/database/destination"
+	"atlas-blockchain/pkg/database/destination/s3test"
+)
+
+// applyBlock commits a block the way the state manager does: the block, its
+// receipt, the changed accounts with their history and the state commit
+func applyBlock(t *testing.T, s database.Store, height int64, accounts ...*database.Account) {
+	batch, err := s.NewBatch()
+	if err != nil {
+		t.Fatalf("NewBatch failed: %v", err)
+	}
+	defer batch.Rollback()
+
+	hash := fmt.Sprintf("hash%d", height)
+	txHash := fmt.Sprintf("tx%d", height)
+	storage := fmt.Sprintf(`{"height":%d}`, height)
+	steps := []error{
+		batch.SaveBlock(&database.Block{Height: height, Hash: hash, PrevHash: fmt.Sprintf("hash%d", height-1),
+			TxCount: 1, Data: fmt.Sprintf(`{"index":%d}`, height)}, []string{txHash}),
+		batch.SaveReceipts([]*database.Receipt{{TxHash: txHash, BlockHeight: height, Status: database.ReceiptSuccess}}),
+		batch.SetContract(&database.Contract{Address: "0xc1", Code: `{}`, Storage: storage, Owner: "alice"}),
+		batch.SaveStateDiff(&database.StateDiff{Height: height, Accounts: accounts,
+			Contracts: []*database.StorageVersion{{Address: "0xc1", Storage: storage}}}),
+		batch.SetStateCommit(&database.StateCommit{Height: height, BlockHash: hash}),
+	}
+	for _, account := range accounts {
+		steps = append(steps, batch.SetAccount(account))
+	}
+	for _, err := range steps {
+		if err != nil {
+			t.Fatalf("Applying block %d failed: %v", height, err)
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+}
+
+func openKV(t *testing.T) database.Store {
+	s, err := database.NewKVStore(filepath.Join(t.TempDir(), "node.kv"))
+	if err != nil {
+		t.Fatalf("NewKVStore failed: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func checksum(t *testing.T, s database.Store) string {
+	sum, err := database.StateChecksum(s)
+	if err != nil {
+		t.Fatalf("StateChecksum failed: %v", err)
+	}
+	return sum
+}
+
+func TestBackups(t *testing.T) {
+	s := openKV(t)
+	dir := t.TempDir()
+	bm := database.NewBackupManager(s, dir)
+
+	for h := int64(0); h <= 3; h++ {
+		applyBlock(t, s, h, &database.Account{Address: "alice", Balance: 100 - h, Nonce: uint64(h)})
+	}
+	if err := bm.CreateFullBackup(); err != nil {
+		t.Fatalf("CreateFullBackup failed: %v", err)
+	}
+	atFull := checksum(t, s)
+
+	applyBlock(t, s, 4, &database.Account{Address: "bob", Balance: 7, StakedAmount: 5, IsValidator: true})
+	applyBlock(t, s, 5, &database.Account{Address: "alice", Balance: 90, Nonce: 5})
+	if err := bm.CreateBackup(); err != nil {
+		t.Fatalf("CreateBackup failed: %v", err)
+	}
+	atIncremental := checksum(t, s)
+
+	list := bm.GetBackupList()
+	if len(list) != 2 {
+		t.Fatalf("Expected a full and an incremental backup, got %d", len(list))
+	}
+	inc, full := list[0], list[1]
+
+	t.Run("Metadata", func(t *testing.T) {
+		if full.Type != database.BackupFull || full.BlockHeight != 3 || full.BlockHash != "hash3" || full.StateChecksum != atFull {
+			t.Errorf("Unexpected full backup metadata: %+v", full)
+		}
+		if inc.Type != database.BackupIncremental || inc.Parent != full.ID || inc.BlockHeight != 5 ||
+			inc.StateChecksum != atIncremental {
+			t.Errorf("Unexpected incremental backup metadata: %+v", inc)
+		}
+		if full.Status != "verified" || inc.Status != "verified" {
+			t.Errorf("Expected new backups to be verified, got %s and %s", full.Status, inc.Status)
+		}
+
+		reloaded := database.NewBackupManager(s, dir).GetBackupList()
+		if len(reloaded) != 2 || reloaded[0].Parent != full.ID || reloaded[1].StateChecksum != atFull {
+			t.Errorf("Expected backup metadata to survive a restart, got %v", reloaded)
+		}
+	})
+
+	t.Run("NothingNew", func(t *testing.T) {
+		if err := bm.CreateBackup(); err != nil {
+			t.Fatalf("CreateBackup failed: %v", err)
+		}
+		if n := len(bm.GetBackupList()); n != 2 {
+			t.Errorf("Expected no backup without new blocks, got %d backups", n)
+		}
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		if err := bm.VerifyBackup(inc.ID); err != nil {
+			t.Errorf("VerifyBackup failed: %v", err)
+		}
+	})
+
+	t.Run("RestoreToBlockHeight", func(t *testing.T) {
+		applyBlock(t, s, 6, &database.Account{Address: "carol", Balance: 1})
+		rm := database.NewRecoveryManager(s, dir)
+
+		if err := rm.RestoreToBlockHeight(4); err != nil {
+			t.Fatalf("RestoreToBlockHeight failed: %v", err)
+		}
+		if got := checksum(t, s); got != atFull {
+			t.Errorf("Expected the state of the full backup at height 3, got %s", got)
+		}
+		if b, _ := s.GetBlockByHeight(4); b != nil {
+			t.Errorf("Expected no block above the restored height, got %v", b)
+		}
+
+		if err := rm.RestoreFromBackup(inc.ID); err != nil {
+			t.Fatalf("RestoreFromBackup failed: %v", err)
+		}
+		if got := checksum(t, s); got != atIncremental {
+			t.Errorf("Expected the state of the incremental backup, got %s", got)
+		}
+		bob, _ := s.GetAccount("bob")
+		if bob == nil || bob.StakedAmount != 5 || !bob.IsValidator {
+			t.Errorf("Expected bob's stake from the incremental backup, got %v", bob)
+		}
+		if receipt, _ := s.GetReceipt("tx5"); receipt == nil || receipt.BlockHeight != 5 {
+			t.Errorf("Expected the receipt of block 5, got %v", receipt)
+		}
+		if acct, _ := s.GetAccountAt("alice", 4); acct == nil || acct.Balance != 97 {
+			t.Errorf("Expected alice's history to be restored, got %v", acct)
+		}
+		if c, _ := s.GetContract("0xc1"); c == nil || c.Storage != `{"height":5}` {
+			t.Errorf("Expected contract storage at height 5, got %v", c)
+		}
+	})
+
+	t.Run("Corrupted", func(t *testing.T) {
+		path := filepath.Join(dir, full.ID+".db.gz")
+		if err := os.WriteFile(path, []byte("not a backup"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := bm.VerifyBackup(inc.ID); err == nil {
+			t.Errorf("Expected verification to fail when the full backup is damaged")
+		}
+		if err := database.NewRecoveryManager(s, dir).RestoreFromBackup(inc.ID); err == nil {
+			t.Errorf("Expected restoring a damaged chain to fail")
+		}
+		if got := checksum(t, s); got != atIncremental {
+			t.Errorf("Expected a failed restore to leave the database untouched")
+		}
+	})
+}
+
+func TestSealedBackups(t *testing.T) {
+	s := openKV(t)
+	dir := t.TempDir()
+	keys := archiveKeys(1, "correct horse")
+	server := s3test.NewServer(t, "atlas", "minio")
+	remote := &destination.S3{Endpoint: server.URL, Bucket: "atlas", Prefix: "node1",
+		Region: "us-east-1", AccessKey: "minio", SecretKey: "minio123"}
+
+	bm := database.NewBackupManager(s, dir)
+	bm.SetArchiveKeys(keys)
+	bm.AddDestination(remote, destination.Retention{KeepChains: 1})
+
+	applyBlock(t, s, 0, &database.Account{Address: "alice", Balance: 100})
+	if err := bm.CreateFullBackup(); err != nil {
+		t.Fatalf("CreateFullBackup failed: %v", err)
+	}
+	applyBlock(t, s, 1, &database.Account{Address: "alice", Balance: 90, Nonce: 1})
+	if err := bm.CreateBackup(); err != nil {
+		t.Fatalf("CreateBackup failed: %v", err)
+	}
+	sealedState := checksum(t, s)
+	list := bm.GetBackupList()
+	if len(list) != 2 || !list[0].Encrypted || !list[1].Encrypted || list[0].Status != "verified" {
+		t.Fatalf("Expected two verified sealed backups, got %v", list)
+	}
+	inc, full := list[0], list[1]
+
+	t.Run("Sealed", func(t *testing.T) {
+		sealed, err := os.ReadFile(filepath.Join(dir, full.ID+".db.gz.sealed"))
+		if err != nil {
+			t.Fatalf("Expected a sealed backup file: %v", err)
+		}
+		if _, err := database.OpenArchive(sealed, keys); err != nil {
+			t.Errorf("Expected the backup to open with the node's keys: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, full.ID+".db.gz")); !os.IsNotExist(err) {
+			t.Errorf("Expected no plain copy of a sealed backup")
+		}
+	})
+
+	t.Run("Uploaded", func(t *testing.T) {
+		want := []string{
+			"node1/" + full.ID + "/" + full.ID + ".db.gz.sealed",
+			"node1/" + full.ID + "/" + full.ID + ".json",
+			"node1/" + full.ID + "/" + inc.ID + ".inc.gz.sealed",
+			"node1/" + full.ID + "/" + inc.ID + ".json",
+		}
+		sort.Strings(want)
+		if got := strings.Join(server.Keys(), ","); got != strings.Join(want, ",") {
+			t.Errorf("Unexpected remote objects\n got: %s\nwant: %s", got, strings.Join(want, ","))
+		}
+	})
+
+	t.Run("Retention", func(t *testing.T) {
+		applyBlock(t, s, 2, &database.Account{Address: "bob", Balance: 1})
+		if err := bm.CreateFullBackup(); err != nil {
+			t.Fatalf("CreateFullBackup failed: %v", err)
+		}
+		for _, key := range server.Keys() {
+			if strings.HasPrefix(key, "node1/"+full.ID+"/") {
+				t.Errorf("Expected the older chain to be removed remotely, found %s", key)
+			}
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		rm := database.NewRecoveryManager(s, dir)
+		if err := rm.RestoreFromBackup(inc.ID); err == nil {
+			t.Fatalf("Expected restoring a sealed backup without keys to fail")
+		}
+		rm.SetArchiveKeys(archiveKeys(2, "correct horse"))
+		if err := rm.RestoreFromBackup(inc.ID); err == nil {
+			t.Fatalf("Expected a backup signed by another node to be rejected")
+		}
+		rm.SetArchiveKeys(keys)
+		if err := rm.RestoreFromBackup(inc.ID); err != nil {
+			t.Fatalf("RestoreFromBackup failed: %v", err)
+		}
+		if got := checksum(t, s); got != sealedState {
+			t.Errorf("Expected the state of the sealed incremental backup, got %s", got)
+		}
+	})
+}
+
+func TestBackupWithoutDatabase(t *testing.T) {
+	bm := database.NewBackupManager(nil, t.TempDir())
+	if err := bm.CreateBackup(); err == nil {
+		t.Errorf("Expected an error without a database")
+	}
+	if n := len(bm.GetBackupList()); n != 0 {
+		t.Errorf("Expected no backups, got %d", n)
+	}
+}
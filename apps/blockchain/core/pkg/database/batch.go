@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// StateCommit records the last block whose state changes were committed
+type StateCommit struct {
+	Height    int64  `json:"height"`
+	BlockHash string `json:"block_hash"`
+}
+
+// sqliteBatch is a Batch backed by a SQL transaction
+type sqliteBatch struct {
+	tx *sql.Tx
+}
+
+// NewBatch starts a write batch
+func (d *SQLiteStore) NewBatch() (Batch, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin batch: %v", err)
+	}
+	return &sqliteBatch{tx: tx}, nil
+}
+
+func (b *sqliteBatch) SetAccount(account *Account) error {
+	return setAccount(b.tx, account)
+}
+
+func (b *sqliteBatch) SetContract(contract *Contract) error {
+	return setContract(b.tx, contract)
+}
+
+func (b *sqliteBatch) SetProposal(proposal *Proposal) error {
+	return setProposal(b.tx, proposal)
+}
+
+func (b *sqliteBatch) AddVote(vote *Vote) error {
+	return addVote(b.tx, vote)
+}
+
+func (b *sqliteBatch) SetMultisig(account *MultisigAccount) error {
+	return setMultisig(b.tx, account)
+}
+
+// SaveBlock stores a block and indexes its transaction hashes
+func (b *sqliteBatch) SaveBlock(block *Block, txHashes []string) error {
+	return saveBlockTx(b.tx, block, txHashes)
+}
+
+func (b *sqliteBatch) SaveReceipts(receipts []*Receipt) error {
+	for _, receipt := range receipts {
+		if err := saveReceipt(b.tx, receipt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *sqliteBatch) SaveStateDiff(diff *StateDiff) error {
+	return saveStateDiff(b.tx, diff)
+}
+
+// SetStateCommit marks the state as applied up to and including a block
+func (b *sqliteBatch) SetStateCommit(commit *StateCommit) error {
+	query := `INSERT OR REPLACE INTO state_commit (id, height, block_hash, updated_at)
+			  VALUES (1, ?, ?, CURRENT_TIMESTAMP)`
+	if _, err := b.tx.Exec(query, commit.Height, commit.BlockHash); err != nil {
+		return fmt.Errorf("failed to set state commit: %v", err)
+	}
+	return nil
+}
+
+func (b *sqliteBatch) Commit() error {
+	if err := b.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit batch: %v", err)
+	}
+	return nil
+}
+
+func (b *sqliteBatch) Rollback() error {
+	if err := b.tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		return fmt.Errorf("failed to roll back batch: %v", err)
+	}
+	return nil
+}
+
+// GetStateCommit returns the last committed block state, or nil if no block
+// has been applied through a batch yet
+func (d *SQLiteStore) GetStateCommit() (*StateCommit, error) {
+	var commit StateCommit
+	err := d.db.QueryRow(`SELECT height, block_hash FROM state_commit WHERE id = 1`).
+		Scan(&commit.Height, &commit.BlockHash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state commit: %v", err)
+	}
+	return &commit, nil
+}
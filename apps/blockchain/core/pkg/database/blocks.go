@@ -0,0 +1,163 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Block represents a stored block
+type Block struct {
+	Height    int64  `json:"height"`
+	Hash      string `json:"hash"`
+	PrevHash  string `json:"prev_hash"`
+	Validator string `json:"validator"`
+	Timestamp int64  `json:"timestamp"`
+	TxCount   int    `json:"tx_count"`
+	Data      string `json:"data"` // JSON encoded block
+}
+
+// TxLocation is the position of a transaction in the chain
+type TxLocation struct {
+	Hash        string `json:"hash"`
+	BlockHeight int64  `json:"block_height"`
+	Index       int    `json:"index"`
+}
+
+// Block operations
+const blockColumns = `height, hash, prev_hash, validator, timestamp, tx_count, data`
+
+// SaveBlock stores a block and indexes its transaction hashes in a single
+// database transaction
+func (d *SQLiteStore) SaveBlock(block *Block, txHashes []string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin block transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := saveBlockTx(tx, block, txHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit block %d: %v", block.Height, err)
+	}
+	return nil
+}
+
+// saveBlockTx writes a block and its transaction index within an open transaction
+func saveBlockTx(tx *sql.Tx, block *Block, txHashes []string) error {
+	_, err := tx.Exec(`INSERT OR REPLACE INTO blocks (`+blockColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		block.Height, block.Hash, block.PrevHash, block.Validator, block.Timestamp, block.TxCount, block.Data)
+	if err != nil {
+		return fmt.Errorf("failed to save block %d: %v", block.Height, err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM transactions WHERE block_height = ?`, block.Height); err != nil {
+		return fmt.Errorf("failed to clear transaction index for block %d: %v", block.Height, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM receipts WHERE block_height = ?`, block.Height); err != nil {
+		return fmt.Errorf("failed to clear receipts for block %d: %v", block.Height, err)
+	}
+	for i, hash := range txHashes {
+		_, err := tx.Exec(`INSERT INTO transactions (block_height, tx_index, hash) VALUES (?, ?, ?)`,
+			block.Height, i, hash)
+		if err != nil {
+			return fmt.Errorf("failed to index transaction %s: %v", hash, err)
+		}
+	}
+	return nil
+}
+
+// DeleteBlocksAbove removes the blocks higher than height with their transaction
+// index, receipts and state history
+func (d *SQLiteStore) DeleteBlocksAbove(height int64) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin block transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM transactions WHERE block_height > ?`, height); err != nil {
+		return 0, fmt.Errorf("failed to clear transaction index above %d: %v", height, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM receipts WHERE block_height > ?`, height); err != nil {
+		return 0, fmt.Errorf("failed to clear receipts above %d: %v", height, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM account_history WHERE block_height > ?`, height); err != nil {
+		return 0, fmt.Errorf("failed to clear account history above %d: %v", height, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM contract_history WHERE block_height > ?`, height); err != nil {
+		return 0, fmt.Errorf("failed to clear contract history above %d: %v", height, err)
+	}
+	result, err := tx.Exec(`DELETE FROM blocks WHERE height > ?`, height)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete blocks above %d: %v", height, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit block deletion: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+func (d *SQLiteStore) GetBlockByHeight(height int64) (*Block, error) {
+	return d.queryBlock(`SELECT `+blockColumns+` FROM blocks WHERE height = ?`, height)
+}
+
+func (d *SQLiteStore) GetBlockByHash(hash string) (*Block, error) {
+	return d.queryBlock(`SELECT `+blockColumns+` FROM blocks WHERE hash = ?`, hash)
+}
+
+// GetLatestBlock returns the block with the greatest height, or nil for an empty chain
+func (d *SQLiteStore) GetLatestBlock() (*Block, error) {
+	return d.queryBlock(`SELECT ` + blockColumns + ` FROM blocks ORDER BY height DESC LIMIT 1`)
+}
+
+// GetBlockRange returns the blocks with from <= height <= to, in height order
+func (d *SQLiteStore) GetBlockRange(from, to int64) ([]*Block, error) {
+	rows, err := d.db.Query(`SELECT `+blockColumns+` FROM blocks WHERE height >= ? AND height <= ? ORDER BY height`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocks: %v", err)
+	}
+	defer rows.Close()
+
+	var blocks []*Block
+	for rows.Next() {
+		var block Block
+		if err := rows.Scan(&block.Height, &block.Hash, &block.PrevHash, &block.Validator,
+			&block.Timestamp, &block.TxCount, &block.Data); err != nil {
+			return nil, fmt.Errorf("failed to scan block: %v", err)
+		}
+		blocks = append(blocks, &block)
+	}
+	return blocks, rows.Err()
+}
+
+// GetTxLocation finds the most recent block containing a transaction hash
+func (d *SQLiteStore) GetTxLocation(hash string) (*TxLocation, error) {
+	query := `SELECT hash, block_height, tx_index FROM transactions
+			  WHERE hash = ? ORDER BY block_height DESC LIMIT 1`
+
+	var loc TxLocation
+	err := d.db.QueryRow(query, hash).Scan(&loc.Hash, &loc.BlockHeight, &loc.Index)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction location: %v", err)
+	}
+	return &loc, nil
+}
+
+func (d *SQLiteStore) queryBlock(query string, args ...interface{}) (*Block, error) {
+	var block Block
+	err := d.db.QueryRow(query, args...).Scan(&block.Height, &block.Hash, &block.PrevHash,
+		&block.Validator, &block.Timestamp, &block.TxCount, &block.Data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block: %v", err)
+	}
+	return &block, nil
+}
@@ -13,9 +13,16 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// Database represents the blockchain database
-type Database struct {
-	db *sql.DB
+// SQLiteStore is the Store backed by SQLite. It requires CGO.
+type SQLiteStore struct {
+	db   *sql.DB
+	path string
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx, so writes can run
+// directly or as part of a Batch
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
 }
 
 // Account represents a blockchain account
@@ -35,6 +42,7 @@ type Contract struct {
 	Code        string    `json:"code"`
 	Storage     string    `json:"storage"` // JSON encoded
 	Owner       string    `json:"owner"`
+	ABI         string    `json:"abi"`     // JSON encoded
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -74,8 +82,8 @@ type OracleData struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
-// NewDatabase creates a new database connection
-func NewDatabase(dbPath string) (*Database, error) {
+// NewSQLiteStore opens or creates a SQLite database
+func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
 	// Ensure directory exists
 	dir := filepath.Dir(dbPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -92,96 +100,39 @@ func NewDatabase(dbPath string) (*Database, error) {
 		return nil, fmt.Errorf("failed to ping database: %v", err)
 	}
 
-	database := &Database{db: db}
+	database := &SQLiteStore{db: db, path: dbPath}
 
-	// Initialize schema
-	if err := database.initSchema(); err != nil {
-		return nil, fmt.Errorf("failed to initialize schema: %v", err)
+	// Bring the schema up to date
+	if _, err := Migrate(db, MigrateOptions{}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %v", err)
 	}
 
 	return database, nil
 }
 
-// initSchema creates the database tables
-func (d *Database) initSchema() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS accounts (
-			address TEXT PRIMARY KEY,
-			balance INTEGER NOT NULL DEFAULT 0,
-			nonce INTEGER NOT NULL DEFAULT 0,
-			is_validator BOOLEAN NOT NULL DEFAULT FALSE,
-			staked_amount INTEGER NOT NULL DEFAULT 0,
-			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS contracts (
-			address TEXT PRIMARY KEY,
-			code TEXT NOT NULL,
-			storage TEXT NOT NULL DEFAULT '{}',
-			owner TEXT NOT NULL,
-			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS proposals (
-			id TEXT PRIMARY KEY,
-			proposer TEXT NOT NULL,
-			description TEXT NOT NULL,
-			actions TEXT NOT NULL,
-			state TEXT NOT NULL DEFAULT 'pending',
-			votes_for INTEGER NOT NULL DEFAULT 0,
-			votes_against INTEGER NOT NULL DEFAULT 0,
-			start_block INTEGER NOT NULL,
-			end_block INTEGER NOT NULL,
-			voters TEXT NOT NULL DEFAULT '{}',
-			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS votes (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			proposal_id TEXT NOT NULL,
-			voter TEXT NOT NULL,
-			choice TEXT NOT NULL,
-			weight INTEGER NOT NULL,
-			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (proposal_id) REFERENCES proposals(id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS oracle_data (
-			key TEXT PRIMARY KEY,
-			value TEXT NOT NULL,
-			timestamp INTEGER NOT NULL,
-			source TEXT NOT NULL,
-			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS state_snapshots (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			block_height INTEGER NOT NULL,
-			checksum TEXT NOT NULL,
-			data TEXT NOT NULL,
-			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_accounts_validator ON accounts(is_validator)`,
-		`CREATE INDEX IF NOT EXISTS idx_proposals_state ON proposals(state)`,
-		`CREATE INDEX IF NOT EXISTS idx_votes_proposal ON votes(proposal_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_snapshots_height ON state_snapshots(block_height)`,
-	}
-
-	for _, query := range queries {
-		if _, err := d.db.Exec(query); err != nil {
-			return fmt.Errorf("failed to execute query: %v", err)
-		}
-	}
-
-	log.Printf("✅ Database schema initialized successfully")
-	return nil
-}
-
 // Close closes the database connection
-func (d *Database) Close() error {
+func (d *SQLiteStore) Close() error {
 	return d.db.Close()
 }
 
+// CheckIntegrity runs SQLite's integrity check
+func (d *SQLiteStore) CheckIntegrity() error {
+	var result string
+	if err := d.db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("failed to run integrity check: %v", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	if _, err := d.db.Exec("SELECT COUNT(*) FROM accounts LIMIT 1"); err != nil {
+		return fmt.Errorf("cannot read accounts: %v", err)
+	}
+	return nil
+}
+
 // Account operations
-func (d *Database) GetAccount(address string) (*Account, error) {
+func (d *SQLiteStore) GetAccount(address string) (*Account, error) {
 	query := `SELECT address, balance, nonce, is_validator, staked_amount, created_at, updated_at 
 			  FROM accounts WHERE address = ?`
 	
@@ -202,12 +153,16 @@ func (d *Database) GetAccount(address string) (*Account, error) {
 	return &account, nil
 }
 
-func (d *Database) SetAccount(account *Account) error {
+func (d *SQLiteStore) SetAccount(account *Account) error {
+	return setAccount(d.db, account)
+}
+
+func setAccount(ex execer, account *Account) error {
 	query := `INSERT OR REPLACE INTO accounts 
 			  (address, balance, nonce, is_validator, staked_amount, updated_at) 
 			  VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
 	
-	_, err := d.db.Exec(query, account.Address, account.Balance, account.Nonce,
+	_, err := ex.Exec(query, account.Address, account.Balance, account.Nonce,
 		account.IsValidator, account.StakedAmount)
 	
 	if err != nil {
@@ -217,7 +172,7 @@ func (d *Database) SetAccount(account *Account) error {
 	return nil
 }
 
-func (d *Database) GetAllAccounts() ([]*Account, error) {
+func (d *SQLiteStore) GetAllAccounts() ([]*Account, error) {
 	query := `SELECT address, balance, nonce, is_validator, staked_amount, created_at, updated_at 
 			  FROM accounts ORDER BY address`
 	
@@ -242,7 +197,7 @@ func (d *Database) GetAllAccounts() ([]*Account, error) {
 	return accounts, nil
 }
 
-func (d *Database) GetValidators() ([]*Account, error) {
+func (d *SQLiteStore) GetValidators() ([]*Account, error) {
 	query := `SELECT address, balance, nonce, is_validator, staked_amount, created_at, updated_at 
 			  FROM accounts WHERE is_validator = TRUE ORDER BY staked_amount DESC`
 	
@@ -268,14 +223,14 @@ func (d *Database) GetValidators() ([]*Account, error) {
 }
 
 // Contract operations
-func (d *Database) GetContract(address string) (*Contract, error) {
-	query := `SELECT address, code, storage, owner, created_at, updated_at 
+func (d *SQLiteStore) GetContract(address string) (*Contract, error) {
+	query := `SELECT address, code, storage, owner, abi, created_at, updated_at 
 			  FROM contracts WHERE address = ?`
 	
 	var contract Contract
 	err := d.db.QueryRow(query, address).Scan(
 		&contract.Address, &contract.Code, &contract.Storage,
-		&contract.Owner, &contract.CreatedAt, &contract.UpdatedAt,
+		&contract.Owner, &contract.ABI, &contract.CreatedAt, &contract.UpdatedAt,
 	)
 	
 	if err == sql.ErrNoRows {
@@ -288,13 +243,44 @@ func (d *Database) GetContract(address string) (*Contract, error) {
 	return &contract, nil
 }
 
-func (d *Database) SetContract(contract *Contract) error {
+// GetAllContracts returns every deployed contract ordered by address
+func (d *SQLiteStore) GetAllContracts() ([]*Contract, error) {
+	query := `SELECT address, code, storage, owner, abi, created_at, updated_at
+			  FROM contracts ORDER BY address`
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contracts: %v", err)
+	}
+	defer rows.Close()
+
+	var contracts []*Contract
+	for rows.Next() {
+		var contract Contract
+		if err := rows.Scan(&contract.Address, &contract.Code, &contract.Storage,
+			&contract.Owner, &contract.ABI, &contract.CreatedAt, &contract.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan contract: %v", err)
+		}
+		contracts = append(contracts, &contract)
+	}
+	return contracts, rows.Err()
+}
+
+func (d *SQLiteStore) SetContract(contract *Contract) error {
+	return setContract(d.db, contract)
+}
+
+func setContract(ex execer, contract *Contract) error {
+	abi := contract.ABI
+	if abi == "" {
+		abi = "{}"
+	}
 	query := `INSERT OR REPLACE INTO contracts 
-			  (address, code, storage, owner, updated_at) 
-			  VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)`
+			  (address, code, storage, owner, abi, updated_at) 
+			  VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
 	
-	_, err := d.db.Exec(query, contract.Address, contract.Code,
-		contract.Storage, contract.Owner)
+	_, err := ex.Exec(query, contract.Address, contract.Code,
+		contract.Storage, contract.Owner, abi)
 	
 	if err != nil {
 		return fmt.Errorf("failed to set contract: %v", err)
@@ -304,7 +290,7 @@ func (d *Database) SetContract(contract *Contract) error {
 }
 
 // Proposal operations
-func (d *Database) GetProposal(id string) (*Proposal, error) {
+func (d *SQLiteStore) GetProposal(id string) (*Proposal, error) {
 	query := `SELECT id, proposer, description, actions, state, votes_for, votes_against,
 			  start_block, end_block, voters, created_at, updated_at 
 			  FROM proposals WHERE id = ?`
@@ -327,13 +313,17 @@ func (d *Database) GetProposal(id string) (*Proposal, error) {
 	return &proposal, nil
 }
 
-func (d *Database) SetProposal(proposal *Proposal) error {
+func (d *SQLiteStore) SetProposal(proposal *Proposal) error {
+	return setProposal(d.db, proposal)
+}
+
+func setProposal(ex execer, proposal *Proposal) error {
 	query := `INSERT OR REPLACE INTO proposals 
 			  (id, proposer, description, actions, state, votes_for, votes_against,
 			   start_block, end_block, voters, updated_at) 
 			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
 	
-	_, err := d.db.Exec(query, proposal.ID, proposal.Proposer, proposal.Description,
+	_, err := ex.Exec(query, proposal.ID, proposal.Proposer, proposal.Description,
 		proposal.Actions, proposal.State, proposal.VotesFor, proposal.VotesAgainst,
 		proposal.StartBlock, proposal.EndBlock, proposal.Voters)
 	
@@ -344,7 +334,7 @@ func (d *Database) SetProposal(proposal *Proposal) error {
 	return nil
 }
 
-func (d *Database) GetAllProposals() ([]*Proposal, error) {
+func (d *SQLiteStore) GetAllProposals() ([]*Proposal, error) {
 	query := `SELECT id, proposer, description, actions, state, votes_for, votes_against,
 			  start_block, end_block, voters, created_at, updated_at 
 			  FROM proposals ORDER BY created_at DESC`
@@ -372,10 +362,14 @@ func (d *Database) GetAllProposals() ([]*Proposal, error) {
 }
 
 // Vote operations
-func (d *Database) AddVote(vote *Vote) error {
+func (d *SQLiteStore) AddVote(vote *Vote) error {
+	return addVote(d.db, vote)
+}
+
+func addVote(ex execer, vote *Vote) error {
 	query := `INSERT INTO votes (proposal_id, voter, choice, weight) VALUES (?, ?, ?, ?)`
 	
-	_, err := d.db.Exec(query, vote.ProposalID, vote.Voter, vote.Choice, vote.Weight)
+	_, err := ex.Exec(query, vote.ProposalID, vote.Voter, vote.Choice, vote.Weight)
 	if err != nil {
 		return fmt.Errorf("failed to add vote: %v", err)
 	}
@@ -383,7 +377,7 @@ func (d *Database) AddVote(vote *Vote) error {
 	return nil
 }
 
-func (d *Database) GetVotesForProposal(proposalID string) ([]*Vote, error) {
+func (d *SQLiteStore) GetVotesForProposal(proposalID string) ([]*Vote, error) {
 	query := `SELECT id, proposal_id, voter, choice, weight, created_at 
 			  FROM votes WHERE proposal_id = ? ORDER BY created_at`
 	
@@ -408,7 +402,7 @@ func (d *Database) GetVotesForProposal(proposalID string) ([]*Vote, error) {
 }
 
 // Oracle operations
-func (d *Database) SetOracleData(data *OracleData) error {
+func (d *SQLiteStore) SetOracleData(data *OracleData) error {
 	query := `INSERT OR REPLACE INTO oracle_data (key, value, timestamp, source) 
 			  VALUES (?, ?, ?, ?)`
 	
@@ -420,7 +414,7 @@ func (d *Database) SetOracleData(data *OracleData) error {
 	return nil
 }
 
-func (d *Database) GetOracleData(key string) (*OracleData, error) {
+func (d *SQLiteStore) GetOracleData(key string) (*OracleData, error) {
 	query := `SELECT key, value, timestamp, source, created_at 
 			  FROM oracle_data WHERE key = ?`
 	
@@ -440,7 +434,7 @@ func (d *Database) GetOracleData(key string) (*OracleData, error) {
 }
 
 // State snapshot operations
-func (d *Database) SaveSnapshot(blockHeight int64, checksum string, data map[string]interface{}) error {
+func (d *SQLiteStore) SaveSnapshot(blockHeight int64, checksum string, data map[string]interface{}) error {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("failed to marshal snapshot data: %v", err)
@@ -456,7 +450,7 @@ func (d *Database) SaveSnapshot(blockHeight int64, checksum string, data map[str
 	return nil
 }
 
-func (d *Database) GetLatestSnapshot() (int64, string, map[string]interface{}, error) {
+func (d *SQLiteStore) GetLatestSnapshot() (int64, string, map[string]interface{}, error) {
 	query := `SELECT block_height, checksum, data FROM state_snapshots 
 			  ORDER BY block_height DESC LIMIT 1`
 	
@@ -480,14 +474,14 @@ func (d *Database) GetLatestSnapshot() (int64, string, map[string]interface{}, e
 }
 
 // Backup and recovery
-func (d *Database) Backup(backupPath string) error {
+func (d *SQLiteStore) Backup(backupPath string) error {
 	// TODO: Implement proper SQLite backup using CGO or file copy
 	log.Printf("📝 Backup method not yet implemented")
 	return fmt.Errorf("backup method not yet implemented")
 }
 
 // BackupToWriter creates a backup of the database to a writer (for compression)
-func (d *Database) BackupToWriter(writer io.Writer) error {
+func (d *SQLiteStore) BackupToWriter(writer io.Writer) error {
 	// Use SQLite's backup API to create a backup
 	backup, err := d.db.Query("SELECT * FROM sqlite_master")
 	if err != nil {
@@ -497,18 +491,24 @@ func (d *Database) BackupToWriter(writer io.Writer) error {
 	
 	// For now, we'll use a simple approach
 	// In a production system, you'd use SQLite's backup API properly
-	_, err = d.db.Exec("VACUUM INTO ?", "backup_temp.db")
+	tempDir, err := os.MkdirTemp("", "backup_*")
+	if err != nil {
+		return fmt.Errorf("failed to create backup directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir) // Clean up temp file
+	tempPath := filepath.Join(tempDir, "backup.db")
+	
+	_, err = d.db.Exec("VACUUM INTO ?", tempPath)
 	if err != nil {
 		return fmt.Errorf("failed to create backup: %v", err)
 	}
 	
 	// Read the backup file and write to the provided writer
-	backupFile, err := os.Open("backup_temp.db")
+	backupFile, err := os.Open(tempPath)
 	if err != nil {
 		return fmt.Errorf("failed to open backup file: %v", err)
 	}
 	defer backupFile.Close()
-	defer os.Remove("backup_temp.db") // Clean up temp file
 	
 	_, err = io.Copy(writer, backupFile)
 	if err != nil {
@@ -519,7 +519,7 @@ func (d *Database) BackupToWriter(writer io.Writer) error {
 }
 
 // Restore restores the database from a reader
-func (d *Database) Restore(reader io.Reader) error {
+func (d *SQLiteStore) Restore(reader io.Reader) error {
 	// Create a temporary file for the backup
 	tempFile, err := os.CreateTemp("", "restore_*.db")
 	if err != nil {
@@ -543,7 +543,7 @@ func (d *Database) Restore(reader io.Reader) error {
 	}
 	
 	// Remove the current database file
-	currentDBPath := d.db.Driver().(interface{ Path() string }).Path()
+	currentDBPath := d.path
 	if err := os.Remove(currentDBPath); err != nil {
 		return fmt.Errorf("failed to remove current database: %v", err)
 	}
@@ -578,7 +578,7 @@ func (d *Database) Restore(reader io.Reader) error {
 }
 
 // Migration helper
-func (d *Database) MigrateFromJSONSnapshots(snapshotDir string) error {
+func (d *SQLiteStore) MigrateFromJSONSnapshots(snapshotDir string) error {
 	log.Printf("🔄 Starting migration from JSON snapshots...")
 	
 	// This would read existing JSON snapshots and migrate them to the database
@@ -0,0 +1,166 @@
+// Package destination stores backup archives outside the node: in a local
+// directory, on an SFTP server or in an S3-compatible bucket.
+package destination
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Object is a stored backup file
+type Object struct {
+	Name     string    `json:"name"` // Slash separated, relative to the destination root
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Destination is a place backup archives are copied to
+type Destination interface {
+	// Name describes the destination in logs
+	Name() string
+	Put(name string, data io.Reader) error
+	Get(name string) (io.ReadCloser, error)
+	// List returns every object below the root, in name order
+	List() ([]Object, error)
+	Delete(name string) error
+}
+
+// Retention limits how many backups a destination keeps. Backups are kept
+// or removed per chain: the objects sharing the first path segment of their
+// name, i.e. a full backup and the incremental backups built on it.
+type Retention struct {
+	KeepChains int           // Newest chains kept, 0 keeps all
+	MaxAge     time.Duration // Chains not written to for longer are removed, 0 keeps all
+}
+
+// chain is the set of objects under one first path segment
+type chain struct {
+	root    string
+	objects []Object
+	newest  time.Time
+}
+
+// ApplyRetention removes the chains that fall outside r and returns the
+// number of objects deleted. The newest chain is always kept.
+func ApplyRetention(d Destination, r Retention) (int, error) {
+	if r.KeepChains <= 0 && r.MaxAge <= 0 {
+		return 0, nil
+	}
+	objects, err := d.List()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list %s: %v", d.Name(), err)
+	}
+
+	byRoot := make(map[string]*chain)
+	for _, object := range objects {
+		root := object.Name
+		if i := strings.Index(root, "/"); i >= 0 {
+			root = root[:i]
+		}
+		c := byRoot[root]
+		if c == nil {
+			c = &chain{root: root}
+			byRoot[root] = c
+		}
+		c.objects = append(c.objects, object)
+		if object.Modified.After(c.newest) {
+			c.newest = object.Modified
+		}
+	}
+	chains := make([]*chain, 0, len(byRoot))
+	for _, c := range byRoot {
+		chains = append(chains, c)
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		if !chains[i].newest.Equal(chains[j].newest) {
+			return chains[i].newest.After(chains[j].newest)
+		}
+		return chains[i].root > chains[j].root
+	})
+
+	deleted := 0
+	for i, c := range chains {
+		expired := r.MaxAge > 0 && time.Since(c.newest) > r.MaxAge
+		if i == 0 || (r.KeepChains <= 0 || i < r.KeepChains) && !expired {
+			continue
+		}
+		for _, object := range c.objects {
+			if err := d.Delete(object.Name); err != nil {
+				return deleted, fmt.Errorf("failed to delete %s from %s: %v", object.Name, d.Name(), err)
+			}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Parse creates a destination from a URL and reads its retention from the
+// query parameters keep (chains) and max-age (a duration):
+//
+//	/var/backups or file:///var/backups
+//	sftp://user@host:22/backups?identity=/home/node/.ssh/id_ed25519
+//	s3://bucket/prefix?endpoint=http://localhost:9000&region=us-east-1
+//
+// S3 credentials are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+func Parse(raw string) (Destination, Retention, error) {
+	var retention Retention
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, retention, fmt.Errorf("invalid backup destination %q: %v", raw, err)
+	}
+	query := u.Query()
+	if keep := query.Get("keep"); keep != "" {
+		if retention.KeepChains, err = strconv.Atoi(keep); err != nil || retention.KeepChains < 0 {
+			return nil, retention, fmt.Errorf("invalid keep %q in backup destination", keep)
+		}
+	}
+	if maxAge := query.Get("max-age"); maxAge != "" {
+		if retention.MaxAge, err = time.ParseDuration(maxAge); err != nil || retention.MaxAge < 0 {
+			return nil, retention, fmt.Errorf("invalid max-age %q in backup destination", maxAge)
+		}
+	}
+
+	switch u.Scheme {
+	case "", "file":
+		return NewLocal(u.Path), retention, nil
+	case "sftp":
+		port := 22
+		if p := u.Port(); p != "" {
+			if port, err = strconv.Atoi(p); err != nil {
+				return nil, retention, fmt.Errorf("invalid port in backup destination %q", raw)
+			}
+		}
+		return &SFTP{
+			User:         u.User.Username(),
+			Host:         u.Hostname(),
+			Port:         port,
+			Dir:          u.Path,
+			IdentityFile: query.Get("identity"),
+		}, retention, nil
+	case "s3":
+		endpoint := query.Get("endpoint")
+		if endpoint == "" {
+			endpoint = "https://s3.amazonaws.com"
+		}
+		region := query.Get("region")
+		if region == "" {
+			region = "us-east-1"
+		}
+		return &S3{
+			Endpoint:  endpoint,
+			Bucket:    u.Host,
+			Prefix:    strings.Trim(u.Path, "/"),
+			Region:    region,
+			AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		}, retention, nil
+	default:
+		return nil, retention, fmt.Errorf("unknown backup destination scheme '%s'", u.Scheme)
+	}
+}
@@ -0,0 +1,90 @@
+package destination
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Local stores backups in a directory, e.g. a mounted network share
+type Local struct {
+	Dir string
+}
+
+// NewLocal creates a destination storing backups under dir
+func NewLocal(dir string) *Local {
+	return &Local{Dir: dir}
+}
+
+func (l *Local) Name() string {
+	return "local:" + l.Dir
+}
+
+func (l *Local) path(name string) string {
+	return filepath.Join(l.Dir, filepath.FromSlash(name))
+}
+
+// Put writes the object through a temporary file so readers never see a
+// partial backup
+func (l *Local) Put(name string, data io.Reader) error {
+	path := l.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", name, err)
+	}
+	if _, err := io.Copy(file, data); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func (l *Local) Get(name string) (io.ReadCloser, error) {
+	return os.Open(l.path(name))
+}
+
+func (l *Local) List() ([]Object, error) {
+	var objects []Object
+	err := filepath.Walk(l.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == l.Dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() || filepath.Ext(path) == ".tmp" {
+			return nil
+		}
+		rel, err := filepath.Rel(l.Dir, path)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Name: filepath.ToSlash(rel), Size: info.Size(), Modified: info.ModTime()})
+		return nil
+	})
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, err
+}
+
+// Delete removes an object and its directory once empty
+func (l *Local) Delete(name string) error {
+	path := l.path(name)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != filepath.Clean(l.Dir) {
+		os.Remove(dir) // Fails while other objects remain
+	}
+	return nil
+}
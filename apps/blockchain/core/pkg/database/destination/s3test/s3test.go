@@ -0,0 +1,144 @@
+// Package s3test provides a minimal in-memory S3-compatible server for tests
+package s3test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Server is a MinIO-compatible stand-in holding one bucket in memory. It
+// checks that requests carry a SigV4 authorization for its access key and a
+// matching payload hash, and lists at most two keys per page to exercise
+// continuation tokens.
+type Server struct {
+	*httptest.Server
+	mu        sync.Mutex
+	bucket    string
+	accessKey string
+	objects   map[string][]byte
+	modified  map[string]time.Time
+}
+
+// NewServer starts a server for bucket that is closed when the test ends
+func NewServer(t *testing.T, bucket, accessKey string) *Server {
+	s := &Server{
+		bucket:    bucket,
+		accessKey: accessKey,
+		objects:   make(map[string][]byte),
+		modified:  make(map[string]time.Time),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Keys returns the stored object keys in order
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Object returns the contents of a stored object
+func (s *Server) Object(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	return data, ok
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/") {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if parts[0] != s.bucket {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	if len(parts) == 1 {
+		s.list(w, r)
+		return
+	}
+	key := parts[1]
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[key] = body
+		s.modified[key] = time.Now().UTC()
+	case http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(s.objects, key)
+		delete(s.modified, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type listEntry struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+type listResult struct {
+	XMLName               xml.Name    `xml:"ListBucketResult"`
+	Contents              []listEntry `xml:"Contents"`
+	IsTruncated           bool        `xml:"IsTruncated"`
+	NextContinuationToken string      `xml:"NextContinuationToken,omitempty"`
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	after := r.URL.Query().Get("continuation-token")
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var result listResult
+	if len(keys) > 2 {
+		keys = keys[:2]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[1]
+	}
+	for _, key := range keys {
+		result.Contents = append(result.Contents, listEntry{key, int64(len(s.objects[key])), s.modified[key]})
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,228 @@
+package destination
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SFTP stores backups on an SFTP server through the OpenSSH sftp client in
+// batch mode. Authentication uses IdentityFile or the ssh agent; the host
+// key must already be known.
+type SFTP struct {
+	User         string
+	Host         string
+	Port         int
+	Dir          string // Remote directory backups are stored under
+	IdentityFile string
+	Command      string // sftp binary, "sftp" if empty
+}
+
+func (s *SFTP) Name() string {
+	return fmt.Sprintf("sftp://%s@%s:%d%s", s.User, s.Host, s.Port, s.Dir)
+}
+
+func (s *SFTP) remote(name string) string {
+	return path.Join(s.Dir, name)
+}
+
+// run executes sftp batch commands and returns their output
+func (s *SFTP) run(commands ...string) (string, error) {
+	command := s.Command
+	if command == "" {
+		command = "sftp"
+	}
+	args := []string{"-b", "-", "-o", "BatchMode=yes"}
+	if s.Port != 0 {
+		args = append(args, "-P", strconv.Itoa(s.Port))
+	}
+	if s.IdentityFile != "" {
+		args = append(args, "-i", s.IdentityFile)
+	}
+	target := s.Host
+	if s.User != "" {
+		target = s.User + "@" + s.Host
+	}
+	args = append(args, target)
+
+	cmd := exec.Command(command, args...)
+	cmd.Stdin = strings.NewReader(strings.Join(commands, "\n") + "\n")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("sftp failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// quote escapes a path for the sftp batch syntax
+func quote(p string) string {
+	return `"` + strings.ReplaceAll(p, `"`, `\"`) + `"`
+}
+
+func (s *SFTP) Put(name string, data io.Reader) error {
+	tmp, err := os.CreateTemp("", "sftp_put_*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, data); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+
+	// Directories are created one level at a time; "-" ignores existing ones
+	var commands []string
+	dir := path.Dir(s.remote(name))
+	for _, d := range parents(dir) {
+		commands = append(commands, "-mkdir "+quote(d))
+	}
+	remote := s.remote(name)
+	commands = append(commands,
+		"put "+quote(tmp.Name())+" "+quote(remote+".tmp"),
+		"-rm "+quote(remote),
+		"rename "+quote(remote+".tmp")+" "+quote(remote))
+	_, err = s.run(commands...)
+	return err
+}
+
+// parents lists dir and its ancestors, outermost first
+func parents(dir string) []string {
+	var dirs []string
+	for d := dir; d != "/" && d != "." && d != ""; d = path.Dir(d) {
+		dirs = append([]string{d}, dirs...)
+	}
+	return dirs
+}
+
+// Get downloads an object to a temporary file that is removed on Close
+func (s *SFTP) Get(name string) (io.ReadCloser, error) {
+	tmp, err := os.CreateTemp("", "sftp_get_*")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	if _, err := s.run("get " + quote(s.remote(name)) + " " + quote(tmp.Name())); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	file, err := os.Open(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &tempFile{File: file}, nil
+}
+
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+// List reads the backup directory and the chain directories below it
+func (s *SFTP) List() ([]Object, error) {
+	out, err := s.run("-ls -ln " + quote(s.Dir))
+	if err != nil {
+		return nil, err
+	}
+	entries := parseListing(out, time.Now())
+
+	var objects []Object
+	var dirs []string
+	for _, entry := range entries {
+		if entry.dir {
+			dirs = append(dirs, entry.Name)
+			continue
+		}
+		objects = append(objects, entry.Object)
+	}
+	for _, dir := range dirs {
+		out, err := s.run("ls -ln " + quote(s.remote(dir)))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range parseListing(out, time.Now()) {
+			if !entry.dir {
+				entry.Name = dir + "/" + entry.Name
+				objects = append(objects, entry.Object)
+			}
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, nil
+}
+
+func (s *SFTP) Delete(name string) error {
+	remote := s.remote(name)
+	commands := []string{"rm " + quote(remote)}
+	if dir := path.Dir(remote); path.Clean(dir) != path.Clean(s.Dir) {
+		commands = append(commands, "-rmdir "+quote(dir)) // Fails while other objects remain
+	}
+	_, err := s.run(commands...)
+	return err
+}
+
+type listEntry struct {
+	Object
+	dir bool
+}
+
+// parseListing parses the long listing printed by the sftp ls -l command:
+//
+//	drwxr-xr-x    2 1000     1000         4096 Oct 19 08:00 backup_1760860800_ab
+//	-rw-r--r--    1 1000     1000       123456 Mar  2  2025 old.db.gz
+//
+// Dates without a year are in the year before now when they would otherwise
+// lie in the future.
+func parseListing(out string, now time.Time) []listEntry {
+	var entries []listEntry
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 9 || len(fields[0]) != 10 {
+			continue // Echoed command or summary line
+		}
+		name := strings.Join(fields[8:], " ")
+		if name == "." || name == ".." {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			continue
+		}
+		stamp := fields[5] + " " + fields[6] + " " + fields[7]
+		var modified time.Time
+		if strings.Contains(fields[7], ":") {
+			modified, err = time.ParseInLocation("Jan 2 15:04", stamp, time.Local)
+			modified = modified.AddDate(now.Year(), 0, 0)
+			if modified.After(now.Add(24 * time.Hour)) {
+				modified = modified.AddDate(-1, 0, 0)
+			}
+		} else {
+			modified, err = time.ParseInLocation("Jan 2 2006", stamp, time.Local)
+		}
+		if err != nil {
+			continue
+		}
+		entries = append(entries, listEntry{
+			Object: Object{Name: path.Base(name), Size: size, Modified: modified},
+			dir:    fields[0][0] == 'd',
+		})
+	}
+	return entries
+}
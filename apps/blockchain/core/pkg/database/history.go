@@ -0,0 +1,245 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// StateDiff holds the values that the accounts and contract storage changed
+// at a block height were left with
+type StateDiff struct {
+	Height    int64
+	Accounts  []*Account
+	Contracts []*StorageVersion
+}
+
+// StorageVersion is the storage of a contract as of a block height
+type StorageVersion struct {
+	Address string `json:"address"`
+	Height  int64  `json:"height"`
+	Storage string `json:"storage"` // JSON encoded
+}
+
+// PruneState records how far history has been pruned. Zero heights mean
+// nothing has been pruned.
+type PruneState struct {
+	StateHeight int64 `json:"state_height"` // Oldest height whose state can be read
+	BlockHeight int64 `json:"block_height"` // Oldest block still stored
+}
+
+// saveStateDiff records the account and contract storage versions of a block height
+func saveStateDiff(ex execer, diff *StateDiff) error {
+	query := `INSERT OR REPLACE INTO account_history
+			  (address, block_height, balance, nonce, is_validator, staked_amount)
+			  VALUES (?, ?, ?, ?, ?, ?)`
+	for _, account := range diff.Accounts {
+		if _, err := ex.Exec(query, account.Address, diff.Height, account.Balance, account.Nonce,
+			account.IsValidator, account.StakedAmount); err != nil {
+			return fmt.Errorf("failed to save account history: %v", err)
+		}
+	}
+	query = `INSERT OR REPLACE INTO contract_history (address, block_height, storage) VALUES (?, ?, ?)`
+	for _, version := range diff.Contracts {
+		if _, err := ex.Exec(query, version.Address, diff.Height, version.Storage); err != nil {
+			return fmt.Errorf("failed to save contract history: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetAccountAt returns an account as it was after the block at height was
+// applied, or nil if no change to it was recorded up to that height
+func (d *SQLiteStore) GetAccountAt(address string, height int64) (*Account, error) {
+	query := `SELECT address, balance, nonce, is_validator, staked_amount
+			  FROM account_history WHERE address = ? AND block_height <= ?
+			  ORDER BY block_height DESC LIMIT 1`
+
+	var account Account
+	err := d.db.QueryRow(query, address, height).Scan(
+		&account.Address, &account.Balance, &account.Nonce,
+		&account.IsValidator, &account.StakedAmount,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account history: %v", err)
+	}
+	return &account, nil
+}
+
+// GetContractStorageAt returns the storage of a contract as of height, or nil
+// if no storage was recorded for it up to that height
+func (d *SQLiteStore) GetContractStorageAt(address string, height int64) (*StorageVersion, error) {
+	query := `SELECT address, block_height, storage FROM contract_history
+			  WHERE address = ? AND block_height <= ?
+			  ORDER BY block_height DESC LIMIT 1`
+
+	var version StorageVersion
+	err := d.db.QueryRow(query, address, height).Scan(&version.Address, &version.Height, &version.Storage)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contract history: %v", err)
+	}
+	return &version, nil
+}
+
+// GetStateDiffs returns the recorded state changes of heights from to to,
+// inclusive, ordered by height
+func (d *SQLiteStore) GetStateDiffs(from, to int64) ([]*StateDiff, error) {
+	diffs := make(map[int64]*StateDiff)
+	diffAt := func(height int64) *StateDiff {
+		if diffs[height] == nil {
+			diffs[height] = &StateDiff{Height: height}
+		}
+		return diffs[height]
+	}
+
+	rows, err := d.db.Query(`SELECT address, block_height, balance, nonce, is_validator, staked_amount
+		FROM account_history WHERE block_height BETWEEN ? AND ? ORDER BY block_height, address`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account history: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var account Account
+		var height int64
+		if err := rows.Scan(&account.Address, &height, &account.Balance, &account.Nonce,
+			&account.IsValidator, &account.StakedAmount); err != nil {
+			return nil, fmt.Errorf("failed to scan account history: %v", err)
+		}
+		diff := diffAt(height)
+		diff.Accounts = append(diff.Accounts, &account)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = d.db.Query(`SELECT address, block_height, storage
+		FROM contract_history WHERE block_height BETWEEN ? AND ? ORDER BY block_height, address`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contract history: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version StorageVersion
+		if err := rows.Scan(&version.Address, &version.Height, &version.Storage); err != nil {
+			return nil, fmt.Errorf("failed to scan contract history: %v", err)
+		}
+		diff := diffAt(version.Height)
+		diff.Contracts = append(diff.Contracts, &version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sortedDiffs(diffs), nil
+}
+
+// sortedDiffs returns the diffs of a height map in height order
+func sortedDiffs(diffs map[int64]*StateDiff) []*StateDiff {
+	result := make([]*StateDiff, 0, len(diffs))
+	for _, diff := range diffs {
+		result = append(result, diff)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Height < result[j].Height })
+	return result
+}
+
+// PruneStateHistory removes the account and storage versions that are not
+// needed to read state at height below or later. The newest version older
+// than below is kept for every account and contract. Returns the number of
+// versions removed.
+func (d *SQLiteStore) PruneStateHistory(below int64) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin prune transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var removed int64
+	for _, table := range []string{"account_history", "contract_history"} {
+		result, err := tx.Exec(fmt.Sprintf(`DELETE FROM %[1]s
+			WHERE block_height < (SELECT MAX(h.block_height) FROM %[1]s h
+				WHERE h.address = %[1]s.address AND h.block_height < ?)`, table), below)
+		if err != nil {
+			return 0, fmt.Errorf("failed to prune %s below %d: %v", table, below, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		removed += n
+	}
+	if err := setPruneState(tx, `state_height`, below); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit prune: %v", err)
+	}
+	return removed, nil
+}
+
+// DeleteBlocksBelow removes the blocks lower than height with their
+// transaction index and receipts
+func (d *SQLiteStore) DeleteBlocksBelow(height int64) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin block transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM transactions WHERE block_height < ?`, height); err != nil {
+		return 0, fmt.Errorf("failed to clear transaction index below %d: %v", height, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM receipts WHERE block_height < ?`, height); err != nil {
+		return 0, fmt.Errorf("failed to clear receipts below %d: %v", height, err)
+	}
+	result, err := tx.Exec(`DELETE FROM blocks WHERE height < ?`, height)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete blocks below %d: %v", height, err)
+	}
+	if err := setPruneState(tx, `block_height`, height); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit block deletion: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// setPruneState raises one of the prune_state heights to at least height
+func setPruneState(ex execer, column string, height int64) error {
+	if _, err := ex.Exec(`INSERT OR IGNORE INTO prune_state (id, state_height, block_height) VALUES (1, 0, 0)`); err != nil {
+		return fmt.Errorf("failed to init prune state: %v", err)
+	}
+	query := fmt.Sprintf(`UPDATE prune_state SET %s = MAX(%s, ?), updated_at = CURRENT_TIMESTAMP WHERE id = 1`, column, column)
+	if _, err := ex.Exec(query, height); err != nil {
+		return fmt.Errorf("failed to update prune state: %v", err)
+	}
+	return nil
+}
+
+// GetPruneState returns how far history has been pruned
+func (d *SQLiteStore) GetPruneState() (*PruneState, error) {
+	var state PruneState
+	err := d.db.QueryRow(`SELECT state_height, block_height FROM prune_state WHERE id = 1`).
+		Scan(&state.StateHeight, &state.BlockHeight)
+	if err == sql.ErrNoRows {
+		return &PruneState{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prune state: %v", err)
+	}
+	return &state, nil
+}
+
+// Compact rebuilds the database file to release the space of deleted rows
+func (d *SQLiteStore) Compact() error {
+	if _, err := d.db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("failed to vacuum database: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package kv
+
+import "encoding/binary"
+
+// Batch collects writes that DB.Write applies atomically
+type Batch struct {
+	ops  []op
+	size int
+}
+
+type op struct {
+	kind  byte
+	key   string
+	value []byte
+}
+
+// Put stages a write of value under key. The value is copied.
+func (b *Batch) Put(key string, value []byte) {
+	v := make([]byte, len(value))
+	copy(v, value)
+	b.ops = append(b.ops, op{kind: opPut, key: key, value: v})
+	b.size += len(key) + len(v)
+}
+
+// Delete stages the removal of key
+func (b *Batch) Delete(key string) {
+	b.ops = append(b.ops, op{kind: opDelete, key: key})
+	b.size += len(key)
+}
+
+// Len returns the number of staged operations
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Reset discards every staged operation
+func (b *Batch) Reset() {
+	b.ops = b.ops[:0]
+	b.size = 0
+}
+
+// encode serializes the operations as a record payload
+func (b *Batch) encode() []byte {
+	buf := make([]byte, 0, b.size+len(b.ops)*(1+2*binary.MaxVarintLen64))
+	for _, o := range b.ops {
+		buf = append(buf, o.kind)
+		buf = binary.AppendUvarint(buf, uint64(len(o.key)))
+		buf = append(buf, o.key...)
+		if o.kind == opPut {
+			buf = binary.AppendUvarint(buf, uint64(len(o.value)))
+			buf = append(buf, o.value...)
+		}
+	}
+	return buf
+}
@@ -0,0 +1,500 @@
+// Package kv is an embedded, pure-Go key-value store.
+//
+// Writes are appended to a single log file as checksummed batches. A batch is
+// either applied completely or, if the process dies while writing it, dropped
+// when the log is opened again. Keys are kept in a sorted in-memory index and
+// values are read from the file on demand. Overwritten and deleted entries are
+// reclaimed by rewriting the log once they make up most of it.
+package kv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	ErrNotFound = errors.New("kv: key not found")
+	ErrClosed   = errors.New("kv: database is closed")
+	ErrCorrupt  = errors.New("kv: log is corrupt")
+
+	// errIncomplete is a record that extends past the end of the log
+	errIncomplete = errors.New("kv: incomplete record")
+	errChecksum   = fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+)
+
+const (
+	magic      = "ATLASKV1"
+	headerSize = 8 // payload length and CRC-32, both uint32
+
+	opPut    byte = 1
+	opDelete byte = 2
+
+	// Logs smaller than this are never compacted automatically
+	compactMinSize = 8 << 20
+	// Records written by compaction and backups are split at this size
+	chunkSize = 1 << 20
+)
+
+// DB is an open key-value store. It is safe for concurrent use.
+type DB struct {
+	mu     sync.RWMutex
+	path   string
+	file   *os.File
+	size   int64 // Bytes in the log
+	stale  int64 // Bytes of overwritten or deleted entries
+	index  *skiplist
+	closed bool
+}
+
+// Open opens the store at path, creating it if it does not exist
+func Open(path string) (*DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %v", err)
+	}
+	db := &DB{path: path}
+	if err := db.load(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// load opens the log file and rebuilds the index from it, discarding an
+// incomplete batch at the end. A damaged record before the last one is an
+// error: truncating there would drop every batch committed after it.
+func (db *DB) load() error {
+	f, err := os.OpenFile(db.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", db.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat %s: %v", db.path, err)
+	}
+
+	size := info.Size()
+	if size == 0 {
+		if _, err := f.WriteAt([]byte(magic), 0); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to initialize %s: %v", db.path, err)
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to sync %s: %v", db.path, err)
+		}
+		size = int64(len(magic))
+	} else {
+		header := make([]byte, len(magic))
+		if _, err := f.ReadAt(header, 0); err != nil || string(header) != magic {
+			f.Close()
+			return fmt.Errorf("%w: %s is not a kv database", ErrCorrupt, db.path)
+		}
+	}
+
+	db.index = newSkiplist()
+	db.stale = 0
+	offset := int64(len(magic))
+	r := bufio.NewReader(io.NewSectionReader(f, offset, size-offset))
+	for offset < size {
+		payload, err := readRecord(r, size-offset)
+		end := offset + headerSize + int64(len(payload))
+		if errors.Is(err, errIncomplete) || (errors.Is(err, errChecksum) && end == size) {
+			// The last append did not finish
+			break
+		}
+		if err == nil {
+			err = db.apply(payload, offset+headerSize)
+		}
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("%w: bad record at offset %d of %s: %v", ErrCorrupt, offset, db.path, err)
+		}
+		offset = end
+	}
+
+	if offset < size {
+		log.Printf("⚠️  kv: discarding %d bytes of incomplete writes at the end of %s", size-offset, db.path)
+		if err := f.Truncate(offset); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to truncate %s: %v", db.path, err)
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to sync %s: %v", db.path, err)
+		}
+	}
+
+	db.file = f
+	db.size = offset
+	return nil
+}
+
+// readRecord reads one record of the remaining bytes of the log and verifies
+// its checksum. A record cut off by the end of the log is errIncomplete, and
+// one with a wrong checksum is returned with errChecksum. remaining bounds the
+// payload length so a damaged header cannot trigger a huge allocation.
+func readRecord(r io.Reader, remaining int64) ([]byte, error) {
+	var header [headerSize]byte
+	if remaining < headerSize {
+		return nil, errIncomplete
+	}
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := int64(binary.LittleEndian.Uint32(header[0:4]))
+	sum := binary.LittleEndian.Uint32(header[4:8])
+	if length > remaining-headerSize {
+		return nil, errIncomplete
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != sum {
+		return payload, errChecksum
+	}
+	return payload, nil
+}
+
+// apply updates the index with the operations of a record whose payload
+// starts at base in the file
+func (db *DB) apply(payload []byte, base int64) error {
+	for pos := 0; pos < len(payload); {
+		kind := payload[pos]
+		pos++
+		key, n, err := readBytes(payload, pos)
+		if err != nil {
+			return err
+		}
+		pos = n
+
+		switch kind {
+		case opPut:
+			length, n := binary.Uvarint(payload[pos:])
+			if n <= 0 || uint64(len(payload)-pos-n) < length {
+				return ErrCorrupt
+			}
+			pos += n
+			if old, replaced := db.index.set(string(key), valuePos{offset: base + int64(pos), length: int(length)}); replaced {
+				db.stale += int64(len(key) + old.length)
+			}
+			pos += int(length)
+		case opDelete:
+			if old, removed := db.index.remove(string(key)); removed {
+				db.stale += int64(len(key) + old.length)
+			}
+			db.stale += int64(len(key))
+		default:
+			return ErrCorrupt
+		}
+	}
+	return nil
+}
+
+func readBytes(buf []byte, pos int) ([]byte, int, error) {
+	length, n := binary.Uvarint(buf[pos:])
+	if n <= 0 || uint64(len(buf)-pos-n) < length {
+		return nil, 0, ErrCorrupt
+	}
+	start := pos + n
+	return buf[start : start+int(length)], start + int(length), nil
+}
+
+// Get returns the value stored under key, or ErrNotFound
+func (db *DB) Get(key string) ([]byte, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return nil, ErrClosed
+	}
+	pos, ok := db.index.get(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return db.read(pos)
+}
+
+// Has reports whether key is present
+func (db *DB) Has(key string) (bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return false, ErrClosed
+	}
+	_, ok := db.index.get(key)
+	return ok, nil
+}
+
+func (db *DB) read(pos valuePos) ([]byte, error) {
+	value := make([]byte, pos.length)
+	if _, err := db.file.ReadAt(value, pos.offset); err != nil {
+		return nil, fmt.Errorf("failed to read value: %v", err)
+	}
+	return value, nil
+}
+
+// Len returns the number of keys in the store
+func (db *DB) Len() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.index.len
+}
+
+// Put stores a single key
+func (db *DB) Put(key string, value []byte) error {
+	b := new(Batch)
+	b.Put(key, value)
+	return db.Write(b)
+}
+
+// Delete removes a single key
+func (db *DB) Delete(key string) error {
+	b := new(Batch)
+	b.Delete(key)
+	return db.Write(b)
+}
+
+// Write applies every operation of a batch atomically and durably
+func (db *DB) Write(b *Batch) error {
+	if len(b.ops) == 0 {
+		return nil
+	}
+	payload := b.encode()
+	if len(payload) > 1<<32-1 {
+		return fmt.Errorf("kv: batch of %d bytes is too large", len(payload))
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return ErrClosed
+	}
+
+	if _, err := db.file.WriteAt(encodeRecord(payload), db.size); err != nil {
+		db.file.Truncate(db.size)
+		return fmt.Errorf("failed to write batch: %v", err)
+	}
+	if err := db.file.Sync(); err != nil {
+		db.file.Truncate(db.size)
+		return fmt.Errorf("failed to sync batch: %v", err)
+	}
+	if err := db.apply(payload, db.size+headerSize); err != nil {
+		return err
+	}
+	db.size += headerSize + int64(len(payload))
+
+	if db.size > compactMinSize && db.stale > db.size/2 {
+		if err := db.compact(); err != nil {
+			log.Printf("⚠️  kv: compaction of %s failed: %v", db.path, err)
+		}
+	}
+	return nil
+}
+
+func encodeRecord(payload []byte) []byte {
+	record := make([]byte, headerSize+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[headerSize:], payload)
+	return record
+}
+
+// Range calls fn for every key in [start, end) in ascending order until fn
+// returns false. An empty end means no upper bound. fn must not write to the
+// store.
+func (db *DB) Range(start, end string, fn func(key string, value []byte) bool) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+	for n := db.index.seek(start); n != nil && (end == "" || n.key < end); n = n.next[0] {
+		value, err := db.read(n.pos)
+		if err != nil {
+			return err
+		}
+		if !fn(n.key, value) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// ReverseRange is like Range but visits the keys in descending order
+func (db *DB) ReverseRange(start, end string, fn func(key string, value []byte) bool) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+	var n *node
+	if end == "" {
+		n = db.index.last()
+	} else {
+		n = db.index.seekBefore(end)
+	}
+	for ; n != nil && n.key >= start; n = db.index.seekBefore(n.key) {
+		value, err := db.read(n.pos)
+		if err != nil {
+			return err
+		}
+		if !fn(n.key, value) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Scan calls fn for every key with the given prefix in ascending order
+func (db *DB) Scan(prefix string, fn func(key string, value []byte) bool) error {
+	return db.Range(prefix, PrefixEnd(prefix), fn)
+}
+
+// ReverseScan calls fn for every key with the given prefix in descending order
+func (db *DB) ReverseScan(prefix string, fn func(key string, value []byte) bool) error {
+	return db.ReverseRange(prefix, PrefixEnd(prefix), fn)
+}
+
+// PrefixEnd returns the smallest key greater than every key with the given
+// prefix, or "" if there is none
+func PrefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
+
+// Compact rewrites the log so it only holds live entries
+func (db *DB) Compact() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return ErrClosed
+	}
+	return db.compact()
+}
+
+func (db *DB) compact() error {
+	tmpPath := db.path + ".compact"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", tmpPath, err)
+	}
+	defer os.Remove(tmpPath)
+
+	w := bufio.NewWriter(tmp)
+	if err := db.writeLive(w); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", tmpPath, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s: %v", tmpPath, err)
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmpPath, db.path); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", db.path, err)
+	}
+	if dir, err := os.Open(filepath.Dir(db.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	before := db.size
+	db.file.Close()
+	if err := db.load(); err != nil {
+		db.closed = true
+		return err
+	}
+	log.Printf("🧹 kv: compacted %s from %d to %d bytes", db.path, before, db.size)
+	return nil
+}
+
+// writeLive writes a complete log holding only the live entries
+func (db *DB) writeLive(w io.Writer) error {
+	if _, err := io.WriteString(w, magic); err != nil {
+		return fmt.Errorf("failed to write log header: %v", err)
+	}
+	b := new(Batch)
+	flush := func() error {
+		if len(b.ops) == 0 {
+			return nil
+		}
+		if _, err := w.Write(encodeRecord(b.encode())); err != nil {
+			return fmt.Errorf("failed to write log record: %v", err)
+		}
+		b.Reset()
+		return nil
+	}
+	for n := db.index.seek(""); n != nil; n = n.next[0] {
+		value, err := db.read(n.pos)
+		if err != nil {
+			return err
+		}
+		b.Put(n.key, value)
+		if b.size >= chunkSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// Backup writes a consistent, compacted copy of the store to w. The copy is a
+// valid log file that Open accepts.
+func (db *DB) Backup(w io.Writer) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+	return db.writeLive(w)
+}
+
+// Verify re-reads the whole log and checks every record
+func (db *DB) Verify() error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+	offset := int64(len(magic))
+	r := bufio.NewReader(io.NewSectionReader(db.file, offset, db.size-offset))
+	for offset < db.size {
+		payload, err := readRecord(r, db.size-offset)
+		if err != nil {
+			return fmt.Errorf("%w: bad record at offset %d: %v", ErrCorrupt, offset, err)
+		}
+		offset += headerSize + int64(len(payload))
+	}
+	return nil
+}
+
+// Close releases the log file
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return nil
+	}
+	db.closed = true
+	return db.file.Close()
+}
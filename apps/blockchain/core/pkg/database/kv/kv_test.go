@@ -0,0 +1,223 @@
+package kv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTemp(t *testing.T) (*DB, string) {
+	path := filepath.Join(t.TempDir(), "test.kv")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	return db, path
+}
+
+func TestKV(t *testing.T) {
+	t.Run("PutGetDelete", func(t *testing.T) {
+		db, _ := openTemp(t)
+		defer db.Close()
+
+		if err := db.Put("a", []byte("1")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if v, err := db.Get("a"); err != nil || string(v) != "1" {
+			t.Errorf("Expected 1, got %q (%v)", v, err)
+		}
+		if err := db.Put("a", []byte("2")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if v, _ := db.Get("a"); string(v) != "2" {
+			t.Errorf("Expected overwritten value 2, got %q", v)
+		}
+		if err := db.Delete("a"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if _, err := db.Get("a"); err != ErrNotFound {
+			t.Errorf("Expected ErrNotFound after delete, got %v", err)
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		db, path := openTemp(t)
+		b := new(Batch)
+		b.Put("x", []byte("10"))
+		b.Put("y", []byte("20"))
+		b.Delete("x")
+		if err := db.Write(b); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		db.Close()
+
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Reopen failed: %v", err)
+		}
+		defer db.Close()
+		if _, err := db.Get("x"); err != ErrNotFound {
+			t.Errorf("Expected x to stay deleted, got %v", err)
+		}
+		if v, _ := db.Get("y"); string(v) != "20" {
+			t.Errorf("Expected y=20 after reopen, got %q", v)
+		}
+	})
+
+	t.Run("TornBatchIsDropped", func(t *testing.T) {
+		db, path := openTemp(t)
+		db.Put("kept", []byte("yes"))
+		b := new(Batch)
+		b.Put("lost1", []byte("a"))
+		b.Put("lost2", []byte("b"))
+		db.Write(b)
+		db.Close()
+
+		// Simulate a crash halfway through writing the last batch
+		info, _ := os.Stat(path)
+		if err := os.Truncate(path, info.Size()-3); err != nil {
+			t.Fatalf("Truncate failed: %v", err)
+		}
+
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Reopen failed: %v", err)
+		}
+		defer db.Close()
+		if v, _ := db.Get("kept"); string(v) != "yes" {
+			t.Errorf("Expected earlier batch to survive, got %q", v)
+		}
+		for _, key := range []string{"lost1", "lost2"} {
+			if ok, _ := db.Has(key); ok {
+				t.Errorf("Expected %s from the torn batch to be dropped", key)
+			}
+		}
+		if err := db.Put("after", []byte("ok")); err != nil {
+			t.Fatalf("Put after recovery failed: %v", err)
+		}
+		if err := db.Verify(); err != nil {
+			t.Errorf("Verify failed after recovery: %v", err)
+		}
+	})
+
+	// flipByte corrupts the byte at offset of a closed log
+	flipByte := func(t *testing.T, path string, offset int64) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if offset < 0 {
+			offset += int64(len(data))
+		}
+		data[offset] ^= 0xff
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	t.Run("TornChecksumIsDropped", func(t *testing.T) {
+		db, path := openTemp(t)
+		db.Put("kept", []byte("yes"))
+		db.Put("lost", []byte("no"))
+		db.Close()
+
+		// The last batch reached its full length but not all its bytes
+		flipByte(t, path, -1)
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Reopen failed: %v", err)
+		}
+		defer db.Close()
+		if v, _ := db.Get("kept"); string(v) != "yes" {
+			t.Errorf("Expected earlier batch to survive, got %q", v)
+		}
+		if ok, _ := db.Has("lost"); ok {
+			t.Errorf("Expected the torn batch to be dropped")
+		}
+	})
+
+	t.Run("CorruptionBeforeTheEndIsAnError", func(t *testing.T) {
+		db, path := openTemp(t)
+		for i := 0; i < 3; i++ {
+			db.Put(fmt.Sprintf("k%d", i), []byte("value"))
+		}
+		db.Close()
+		before, _ := os.Stat(path)
+
+		// A damaged first batch must not cost the two committed after it
+		flipByte(t, path, int64(len(magic)+headerSize+1))
+		if _, err := Open(path); !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("Expected ErrCorrupt, got %v", err)
+		}
+		if after, _ := os.Stat(path); after.Size() != before.Size() {
+			t.Errorf("Expected the log to be left as it was, size %d became %d", before.Size(), after.Size())
+		}
+	})
+
+	t.Run("Ranges", func(t *testing.T) {
+		db, _ := openTemp(t)
+		defer db.Close()
+		for _, k := range []string{"b/3", "a/1", "b/1", "c/1", "b/2"} {
+			db.Put(k, []byte(k))
+		}
+
+		var got []string
+		db.Scan("b/", func(key string, value []byte) bool {
+			got = append(got, key)
+			return true
+		})
+		if fmt.Sprint(got) != "[b/1 b/2 b/3]" {
+			t.Errorf("Unexpected scan order: %v", got)
+		}
+
+		got = nil
+		db.ReverseScan("b/", func(key string, value []byte) bool {
+			got = append(got, key)
+			return len(got) < 2
+		})
+		if fmt.Sprint(got) != "[b/3 b/2]" {
+			t.Errorf("Unexpected reverse scan: %v", got)
+		}
+
+		got = nil
+		db.Range("a/", "", func(key string, value []byte) bool {
+			got = append(got, key)
+			return true
+		})
+		if len(got) != 5 {
+			t.Errorf("Expected unbounded range to visit 5 keys, got %v", got)
+		}
+	})
+
+	t.Run("Compact", func(t *testing.T) {
+		db, path := openTemp(t)
+		value := bytes.Repeat([]byte("v"), 100)
+		for i := 0; i < 100; i++ {
+			db.Put("key", value)
+		}
+		db.Put("other", []byte("o"))
+		before := db.size
+		if err := db.Compact(); err != nil {
+			t.Fatalf("Compact failed: %v", err)
+		}
+		if db.size >= before {
+			t.Errorf("Expected compaction to shrink the log (%d -> %d)", before, db.size)
+		}
+		db.Close()
+
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Reopen failed: %v", err)
+		}
+		defer db.Close()
+		if v, _ := db.Get("key"); !bytes.Equal(v, value) {
+			t.Errorf("Value lost in compaction")
+		}
+		if db.Len() != 2 {
+			t.Errorf("Expected 2 keys after compaction, got %d", db.Len())
+		}
+	})
+}
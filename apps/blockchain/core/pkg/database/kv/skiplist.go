@@ -0,0 +1,142 @@
+package kv
+
+import "math/rand"
+
+const maxLevel = 24
+
+// valuePos locates a value in the log file
+type valuePos struct {
+	offset int64
+	length int
+}
+
+type node struct {
+	key  string
+	pos  valuePos
+	next []*node
+}
+
+// skiplist is the ordered in-memory index of live keys
+type skiplist struct {
+	head  *node
+	level int
+	len   int
+	rnd   *rand.Rand
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		head:  &node{next: make([]*node, maxLevel)},
+		level: 1,
+		rnd:   rand.New(rand.NewSource(1)),
+	}
+}
+
+func (s *skiplist) randomLevel() int {
+	level := 1
+	for level < maxLevel && s.rnd.Intn(4) == 0 {
+		level++
+	}
+	return level
+}
+
+// findPrev fills prev with the last node before key on every level
+func (s *skiplist) findPrev(key string, prev []*node) *node {
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].key < key {
+			x = x.next[i]
+		}
+		if prev != nil {
+			prev[i] = x
+		}
+	}
+	return x.next[0]
+}
+
+func (s *skiplist) get(key string) (valuePos, bool) {
+	n := s.findPrev(key, nil)
+	if n != nil && n.key == key {
+		return n.pos, true
+	}
+	return valuePos{}, false
+}
+
+// set inserts or replaces key, returning the previous position if there was one
+func (s *skiplist) set(key string, pos valuePos) (valuePos, bool) {
+	prev := make([]*node, maxLevel)
+	n := s.findPrev(key, prev)
+	if n != nil && n.key == key {
+		old := n.pos
+		n.pos = pos
+		return old, true
+	}
+
+	level := s.randomLevel()
+	if level > s.level {
+		for i := s.level; i < level; i++ {
+			prev[i] = s.head
+		}
+		s.level = level
+	}
+	n = &node{key: key, pos: pos, next: make([]*node, level)}
+	for i := 0; i < level; i++ {
+		n.next[i] = prev[i].next[i]
+		prev[i].next[i] = n
+	}
+	s.len++
+	return valuePos{}, false
+}
+
+// remove deletes key, returning its position if it was present
+func (s *skiplist) remove(key string) (valuePos, bool) {
+	prev := make([]*node, maxLevel)
+	n := s.findPrev(key, prev)
+	if n == nil || n.key != key {
+		return valuePos{}, false
+	}
+	for i := 0; i < s.level; i++ {
+		if prev[i].next[i] != n {
+			break
+		}
+		prev[i].next[i] = n.next[i]
+	}
+	for s.level > 1 && s.head.next[s.level-1] == nil {
+		s.level--
+	}
+	s.len--
+	return n.pos, true
+}
+
+// seek returns the first node with a key >= key
+func (s *skiplist) seek(key string) *node {
+	return s.findPrev(key, nil)
+}
+
+// seekBefore returns the last node with a key < key, or nil
+func (s *skiplist) seekBefore(key string) *node {
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].key < key {
+			x = x.next[i]
+		}
+	}
+	if x == s.head {
+		return nil
+	}
+	return x
+}
+
+// last returns the node with the greatest key, or nil
+func (s *skiplist) last() *node {
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i] != nil {
+			x = x.next[i]
+		}
+	}
+	if x == s.head {
+		return nil
+	}
+	return x
+}
@@ -0,0 +1,991 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"atlas-blockchain/pkg/database/kv"
+)
+
+// Key layout of the kv backend. Heights and indexes are zero padded so keys
+// sort in numeric order.
+const (
+	kvAccountPrefix     = "account/"   // account/<address>
+	kvContractPrefix    = "contract/"  // contract/<address>
+	kvProposalPrefix    = "proposal/"  // proposal/<id>
+	kvVotePrefix        = "vote/"      // vote/<proposal id>/<seq>
+	kvMultisigPrefix    = "multisig/"  // multisig/<address>
+	kvOraclePrefix      = "oracle/"    // oracle/<key>
+	kvSnapshotPrefix    = "snapshot/"  // snapshot/<height>/<seq>
+	kvBlockPrefix       = "block/"     // block/<height>
+	kvBlockHashPrefix   = "blockhash/" // blockhash/<hash> -> height
+	kvTxPrefix          = "tx/"        // tx/<height>/<index> -> hash
+	kvTxHashPrefix      = "txhash/"    // txhash/<hash>/<height>/<index>
+	kvReceiptPrefix     = "receipt/"   // receipt/<height>/<index>
+	kvHistoryPrefix     = "accthist/"  // accthist/<address>/<height>
+	kvStorageHistPrefix = "storehist/" // storehist/<address>/<height>
+
+	kvStateCommitKey = "meta/state_commit"
+	kvVoteSeqKey     = "meta/vote_seq"
+	kvSnapshotSeqKey = "meta/snapshot_seq"
+	kvPruneStateKey  = "meta/prune_state"
+)
+
+// KVStore is the Store backed by the embedded pure-Go kv engine. It needs no
+// CGO, so nodes using it can be cross-compiled freely. Records are kept as JSON.
+type KVStore struct {
+	mu          sync.RWMutex // Guards db, which Restore replaces
+	db          *kv.DB
+	path        string
+	voteSeq     int64
+	snapshotSeq int64
+}
+
+// kvSnapshot is the stored form of a state snapshot
+type kvSnapshot struct {
+	BlockHeight int64                  `json:"block_height"`
+	Checksum    string                 `json:"checksum"`
+	Data        map[string]interface{} `json:"data"`
+}
+
+// NewKVStore opens or creates a kv database
+func NewKVStore(path string) (*KVStore, error) {
+	db, err := kv.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open kv store: %v", err)
+	}
+	s := &KVStore{db: db, path: path}
+	if err := s.loadSequences(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *KVStore) loadSequences() error {
+	for key, seq := range map[string]*int64{kvVoteSeqKey: &s.voteSeq, kvSnapshotSeqKey: &s.snapshotSeq} {
+		value, err := s.db.Get(key)
+		if err == kv.ErrNotFound {
+			*seq = 0
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if *seq, err = strconv.ParseInt(string(value), 10, 64); err != nil {
+			return fmt.Errorf("invalid sequence %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+func (s *KVStore) kv() *kv.DB {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db
+}
+
+func heightKey(height int64) string {
+	return fmt.Sprintf("%020d", height)
+}
+
+func indexKey(index int) string {
+	return fmt.Sprintf("%010d", index)
+}
+
+// getJSON decodes the value under key into v, reporting whether it exists
+func (s *KVStore) getJSON(key string, v interface{}) (bool, error) {
+	data, err := s.kv().Get(key)
+	if err == kv.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to decode %s: %v", key, err)
+	}
+	return true, nil
+}
+
+// scanJSON decodes every value under prefix, in key order, with decode
+func (s *KVStore) scanJSON(prefix string, decode func(data []byte) error) error {
+	var decodeErr error
+	err := s.kv().Scan(prefix, func(key string, value []byte) bool {
+		decodeErr = decode(value)
+		return decodeErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return decodeErr
+}
+
+// write applies a single-record change through a batch
+func (s *KVStore) write(fn func(b *kvBatch) error) error {
+	b := s.newBatch()
+	if err := fn(b); err != nil {
+		return err
+	}
+	return b.Commit()
+}
+
+// Account operations
+func (s *KVStore) GetAccount(address string) (*Account, error) {
+	var account Account
+	found, err := s.getJSON(kvAccountPrefix+address, &account)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (s *KVStore) SetAccount(account *Account) error {
+	return s.write(func(b *kvBatch) error { return b.SetAccount(account) })
+}
+
+func (s *KVStore) GetAllAccounts() ([]*Account, error) {
+	var accounts []*Account
+	err := s.scanJSON(kvAccountPrefix, func(data []byte) error {
+		var account Account
+		if err := json.Unmarshal(data, &account); err != nil {
+			return fmt.Errorf("failed to decode account: %v", err)
+		}
+		accounts = append(accounts, &account)
+		return nil
+	})
+	return accounts, err
+}
+
+func (s *KVStore) GetValidators() ([]*Account, error) {
+	accounts, err := s.GetAllAccounts()
+	if err != nil {
+		return nil, err
+	}
+	var validators []*Account
+	for _, account := range accounts {
+		if account.IsValidator {
+			validators = append(validators, account)
+		}
+	}
+	sort.SliceStable(validators, func(i, j int) bool {
+		return validators[i].StakedAmount > validators[j].StakedAmount
+	})
+	return validators, nil
+}
+
+// Contract operations
+func (s *KVStore) GetContract(address string) (*Contract, error) {
+	var contract Contract
+	found, err := s.getJSON(kvContractPrefix+address, &contract)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &contract, nil
+}
+
+// GetAllContracts returns every deployed contract ordered by address
+func (s *KVStore) GetAllContracts() ([]*Contract, error) {
+	var contracts []*Contract
+	err := s.scanJSON(kvContractPrefix, func(data []byte) error {
+		var contract Contract
+		if err := json.Unmarshal(data, &contract); err != nil {
+			return fmt.Errorf("failed to decode contract: %v", err)
+		}
+		contracts = append(contracts, &contract)
+		return nil
+	})
+	return contracts, err
+}
+
+func (s *KVStore) SetContract(contract *Contract) error {
+	return s.write(func(b *kvBatch) error { return b.SetContract(contract) })
+}
+
+// Proposal operations
+func (s *KVStore) GetProposal(id string) (*Proposal, error) {
+	var proposal Proposal
+	found, err := s.getJSON(kvProposalPrefix+id, &proposal)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+func (s *KVStore) SetProposal(proposal *Proposal) error {
+	return s.write(func(b *kvBatch) error { return b.SetProposal(proposal) })
+}
+
+func (s *KVStore) GetAllProposals() ([]*Proposal, error) {
+	var proposals []*Proposal
+	err := s.scanJSON(kvProposalPrefix, func(data []byte) error {
+		var proposal Proposal
+		if err := json.Unmarshal(data, &proposal); err != nil {
+			return fmt.Errorf("failed to decode proposal: %v", err)
+		}
+		proposals = append(proposals, &proposal)
+		return nil
+	})
+	sort.SliceStable(proposals, func(i, j int) bool {
+		return proposals[i].CreatedAt.After(proposals[j].CreatedAt)
+	})
+	return proposals, err
+}
+
+// Vote operations
+func (s *KVStore) AddVote(vote *Vote) error {
+	return s.write(func(b *kvBatch) error { return b.AddVote(vote) })
+}
+
+func (s *KVStore) GetVotesForProposal(proposalID string) ([]*Vote, error) {
+	var votes []*Vote
+	err := s.scanJSON(kvVotePrefix+proposalID+"/", func(data []byte) error {
+		var vote Vote
+		if err := json.Unmarshal(data, &vote); err != nil {
+			return fmt.Errorf("failed to decode vote: %v", err)
+		}
+		votes = append(votes, &vote)
+		return nil
+	})
+	return votes, err
+}
+
+// Multisig operations
+func (s *KVStore) GetMultisig(address string) (*MultisigAccount, error) {
+	var account MultisigAccount
+	found, err := s.getJSON(kvMultisigPrefix+address, &account)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (s *KVStore) SetMultisig(account *MultisigAccount) error {
+	return s.write(func(b *kvBatch) error { return b.SetMultisig(account) })
+}
+
+// GetAllMultisigs returns every registered multisig account ordered by address
+func (s *KVStore) GetAllMultisigs() ([]*MultisigAccount, error) {
+	var accounts []*MultisigAccount
+	err := s.scanJSON(kvMultisigPrefix, func(data []byte) error {
+		var account MultisigAccount
+		if err := json.Unmarshal(data, &account); err != nil {
+			return fmt.Errorf("failed to decode multisig account: %v", err)
+		}
+		accounts = append(accounts, &account)
+		return nil
+	})
+	return accounts, err
+}
+
+// Oracle operations
+func (s *KVStore) SetOracleData(data *OracleData) error {
+	stored := *data
+	stored.CreatedAt = time.Now()
+	value, err := json.Marshal(&stored)
+	if err != nil {
+		return fmt.Errorf("failed to set oracle data: %v", err)
+	}
+	return s.kv().Put(kvOraclePrefix+data.Key, value)
+}
+
+func (s *KVStore) GetOracleData(key string) (*OracleData, error) {
+	var data OracleData
+	found, err := s.getJSON(kvOraclePrefix+key, &data)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// State snapshot operations
+func (s *KVStore) SaveSnapshot(blockHeight int64, checksum string, data map[string]interface{}) error {
+	value, err := json.Marshal(kvSnapshot{BlockHeight: blockHeight, Checksum: checksum, Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot data: %v", err)
+	}
+	seq := atomic.AddInt64(&s.snapshotSeq, 1)
+	b := new(kv.Batch)
+	b.Put(kvSnapshotPrefix+heightKey(blockHeight)+"/"+heightKey(seq), value)
+	b.Put(kvSnapshotSeqKey, []byte(strconv.FormatInt(seq, 10)))
+	if err := s.kv().Write(b); err != nil {
+		return fmt.Errorf("failed to save snapshot: %v", err)
+	}
+	return nil
+}
+
+func (s *KVStore) GetLatestSnapshot() (int64, string, map[string]interface{}, error) {
+	var snapshot *kvSnapshot
+	var decodeErr error
+	err := s.kv().ReverseScan(kvSnapshotPrefix, func(key string, value []byte) bool {
+		snapshot = new(kvSnapshot)
+		decodeErr = json.Unmarshal(value, snapshot)
+		return false
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	if err != nil {
+		return 0, "", nil, fmt.Errorf("failed to get latest snapshot: %v", err)
+	}
+	if snapshot == nil {
+		return 0, "", nil, nil
+	}
+	return snapshot.BlockHeight, snapshot.Checksum, snapshot.Data, nil
+}
+
+// Block operations
+func (s *KVStore) SaveBlock(block *Block, txHashes []string) error {
+	return s.write(func(b *kvBatch) error { return b.SaveBlock(block, txHashes) })
+}
+
+func (s *KVStore) GetBlockByHeight(height int64) (*Block, error) {
+	var block Block
+	found, err := s.getJSON(kvBlockPrefix+heightKey(height), &block)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &block, nil
+}
+
+func (s *KVStore) GetBlockByHash(hash string) (*Block, error) {
+	value, err := s.kv().Get(kvBlockHashPrefix + hash)
+	if err == kv.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block: %v", err)
+	}
+	height, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid height for block %s: %v", hash, err)
+	}
+	return s.GetBlockByHeight(height)
+}
+
+// GetLatestBlock returns the block with the greatest height, or nil for an empty chain
+func (s *KVStore) GetLatestBlock() (*Block, error) {
+	var block *Block
+	var decodeErr error
+	err := s.kv().ReverseScan(kvBlockPrefix, func(key string, value []byte) bool {
+		block = new(Block)
+		decodeErr = json.Unmarshal(value, block)
+		return false
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block: %v", err)
+	}
+	return block, nil
+}
+
+// GetBlockRange returns the blocks with from <= height <= to, in height order
+func (s *KVStore) GetBlockRange(from, to int64) ([]*Block, error) {
+	if from < 0 {
+		from = 0
+	}
+	if to < from {
+		return nil, nil
+	}
+	var blocks []*Block
+	var decodeErr error
+	err := s.kv().Range(kvBlockPrefix+heightKey(from), kvBlockPrefix+heightKey(to+1), func(key string, value []byte) bool {
+		var block Block
+		if decodeErr = json.Unmarshal(value, &block); decodeErr != nil {
+			return false
+		}
+		blocks = append(blocks, &block)
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocks: %v", err)
+	}
+	return blocks, nil
+}
+
+// GetTxLocation finds the most recent block containing a transaction hash
+func (s *KVStore) GetTxLocation(hash string) (*TxLocation, error) {
+	prefix := kvTxHashPrefix + hash + "/"
+	var loc *TxLocation
+	var parseErr error
+	err := s.kv().ReverseScan(prefix, func(key string, value []byte) bool {
+		loc, parseErr = parseTxHashKey(hash, strings.TrimPrefix(key, prefix))
+		return false
+	})
+	if err == nil {
+		err = parseErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction location: %v", err)
+	}
+	return loc, nil
+}
+
+func parseTxHashKey(hash, suffix string) (*TxLocation, error) {
+	parts := strings.Split(suffix, "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("malformed transaction index key for %s", hash)
+	}
+	height, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	index, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	return &TxLocation{Hash: hash, BlockHeight: height, Index: index}, nil
+}
+
+// DeleteBlocksAbove removes the blocks higher than height with their transaction
+// index, receipts and state history
+func (s *KVStore) DeleteBlocksAbove(height int64) (int64, error) {
+	db := s.kv()
+	b := new(kv.Batch)
+	var removed int64
+	var decodeErr error
+	err := db.Range(kvBlockPrefix+heightKey(height+1), kv.PrefixEnd(kvBlockPrefix), func(key string, value []byte) bool {
+		var block Block
+		if decodeErr = json.Unmarshal(value, &block); decodeErr != nil {
+			return false
+		}
+		b.Delete(key)
+		b.Delete(kvBlockHashPrefix + block.Hash)
+		removed++
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete blocks above %d: %v", height, err)
+	}
+	if err := s.deleteBlockIndexes(b, height+1, -1); err != nil {
+		return 0, err
+	}
+	for _, prefix := range []string{kvHistoryPrefix, kvStorageHistPrefix} {
+		err = db.Scan(prefix, func(key string, value []byte) bool {
+			if h, _ := historyKeyHeight(key); h > height {
+				b.Delete(key)
+			}
+			return true
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to clear state history: %v", err)
+		}
+	}
+	if err := db.Write(b); err != nil {
+		return 0, fmt.Errorf("failed to delete blocks above %d: %v", height, err)
+	}
+	return removed, nil
+}
+
+// deleteBlockIndexes stages the removal of the transaction index and receipts
+// of blocks from height from up to and including to, or without bound if to < 0
+func (s *KVStore) deleteBlockIndexes(b *kv.Batch, from, to int64) error {
+	db := s.kv()
+	txStart, receiptStart := kvTxPrefix+heightKey(from), kvReceiptPrefix+heightKey(from)
+	txEnd, receiptEnd := kv.PrefixEnd(kvTxPrefix), kv.PrefixEnd(kvReceiptPrefix)
+	if to >= 0 {
+		txEnd, receiptEnd = kvTxPrefix+heightKey(to+1), kvReceiptPrefix+heightKey(to+1)
+	}
+
+	err := db.Range(txStart, txEnd, func(key string, value []byte) bool {
+		b.Delete(key)
+		b.Delete(kvTxHashPrefix + string(value) + "/" + strings.TrimPrefix(key, kvTxPrefix))
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clear transaction index: %v", err)
+	}
+	err = db.Range(receiptStart, receiptEnd, func(key string, value []byte) bool {
+		b.Delete(key)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clear receipts: %v", err)
+	}
+	return nil
+}
+
+// Receipt operations
+
+// GetReceipt returns the receipt of the most recent transaction with the given hash
+func (s *KVStore) GetReceipt(txHash string) (*Receipt, error) {
+	loc, err := s.GetTxLocation(txHash)
+	if err != nil || loc == nil {
+		return nil, err
+	}
+	var receipt Receipt
+	found, err := s.getJSON(kvReceiptPrefix+heightKey(loc.BlockHeight)+"/"+indexKey(loc.Index), &receipt)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// GetBlockReceipts returns the receipts of a block in transaction order
+func (s *KVStore) GetBlockReceipts(height int64) ([]*Receipt, error) {
+	var receipts []*Receipt
+	err := s.scanJSON(kvReceiptPrefix+heightKey(height)+"/", func(data []byte) error {
+		var receipt Receipt
+		if err := json.Unmarshal(data, &receipt); err != nil {
+			return fmt.Errorf("failed to decode receipt: %v", err)
+		}
+		receipts = append(receipts, &receipt)
+		return nil
+	})
+	return receipts, err
+}
+
+// State history and pruning
+
+func historyKey(prefix, address string, height int64) string {
+	return prefix + address + "/" + heightKey(height)
+}
+
+// historyKeyHeight returns the height and address prefix of an account history key
+func historyKeyHeight(key string) (int64, string) {
+	i := strings.LastIndex(key, "/")
+	height, _ := strconv.ParseInt(key[i+1:], 10, 64)
+	return height, key[:i+1]
+}
+
+// GetAccountAt returns an account as it was after the block at height was
+// applied, or nil if no change to it was recorded up to that height
+func (s *KVStore) GetAccountAt(address string, height int64) (*Account, error) {
+	var account *Account
+	found, err := s.getVersionAt(kvHistoryPrefix, address, height, func(key string, value []byte) error {
+		account = new(Account)
+		return json.Unmarshal(value, account)
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return account, nil
+}
+
+// GetContractStorageAt returns the storage of a contract as of height, or nil
+// if no storage was recorded for it up to that height
+func (s *KVStore) GetContractStorageAt(address string, height int64) (*StorageVersion, error) {
+	var version *StorageVersion
+	found, err := s.getVersionAt(kvStorageHistPrefix, address, height, func(key string, value []byte) error {
+		h, _ := historyKeyHeight(key)
+		version = &StorageVersion{Address: address, Height: h, Storage: string(value)}
+		return nil
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return version, nil
+}
+
+// GetStateDiffs returns the recorded state changes of heights from to to,
+// inclusive, ordered by height
+func (s *KVStore) GetStateDiffs(from, to int64) ([]*StateDiff, error) {
+	diffs := make(map[int64]*StateDiff)
+	diffAt := func(height int64) *StateDiff {
+		if diffs[height] == nil {
+			diffs[height] = &StateDiff{Height: height}
+		}
+		return diffs[height]
+	}
+
+	var decodeErr error
+	err := s.kv().Scan(kvHistoryPrefix, func(key string, value []byte) bool {
+		height, _ := historyKeyHeight(key)
+		if height < from || height > to {
+			return true
+		}
+		var account Account
+		if decodeErr = json.Unmarshal(value, &account); decodeErr != nil {
+			return false
+		}
+		diff := diffAt(height)
+		diff.Accounts = append(diff.Accounts, &account)
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state history: %v", err)
+	}
+	err = s.kv().Scan(kvStorageHistPrefix, func(key string, value []byte) bool {
+		height, prefix := historyKeyHeight(key)
+		if height >= from && height <= to {
+			address := strings.TrimSuffix(strings.TrimPrefix(prefix, kvStorageHistPrefix), "/")
+			diff := diffAt(height)
+			diff.Contracts = append(diff.Contracts, &StorageVersion{Address: address, Height: height, Storage: string(value)})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage history: %v", err)
+	}
+	return sortedDiffs(diffs), nil
+}
+
+// getVersionAt decodes the newest history entry of address at or below height
+func (s *KVStore) getVersionAt(prefix, address string, height int64, decode func(key string, value []byte) error) (bool, error) {
+	found := false
+	var decodeErr error
+	start := prefix + address + "/"
+	err := s.kv().ReverseRange(start, historyKey(prefix, address, height+1), func(key string, value []byte) bool {
+		found = true
+		decodeErr = decode(key, value)
+		return false
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get state history: %v", err)
+	}
+	return found, nil
+}
+
+// PruneStateHistory removes the account and storage versions that are not
+// needed to read state at height below or later. The newest version older
+// than below is kept for every account and contract. Returns the number of
+// versions removed.
+func (s *KVStore) PruneStateHistory(below int64) (int64, error) {
+	db := s.kv()
+	b := new(kv.Batch)
+	var removed int64
+	for _, historyPrefix := range []string{kvHistoryPrefix, kvStorageHistPrefix} {
+		var keep, keepPrefix string
+		err := db.Scan(historyPrefix, func(key string, value []byte) bool {
+			height, prefix := historyKeyHeight(key)
+			if height >= below {
+				return true
+			}
+			// Keys of one address sort by height, so a previous version of
+			// the same address older than below has been superseded
+			if prefix == keepPrefix {
+				b.Delete(keep)
+				removed++
+			}
+			keep, keepPrefix = key, prefix
+			return true
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to prune state history below %d: %v", below, err)
+		}
+	}
+	if err := s.stagePruneState(b, func(state *PruneState) {
+		if below > state.StateHeight {
+			state.StateHeight = below
+		}
+	}); err != nil {
+		return 0, err
+	}
+	if err := db.Write(b); err != nil {
+		return 0, fmt.Errorf("failed to prune state history below %d: %v", below, err)
+	}
+	return removed, nil
+}
+
+// DeleteBlocksBelow removes the blocks lower than height with their
+// transaction index and receipts
+func (s *KVStore) DeleteBlocksBelow(height int64) (int64, error) {
+	if height <= 0 {
+		return 0, nil
+	}
+	db := s.kv()
+	b := new(kv.Batch)
+	var removed int64
+	var decodeErr error
+	err := db.Range(kvBlockPrefix, kvBlockPrefix+heightKey(height), func(key string, value []byte) bool {
+		var block Block
+		if decodeErr = json.Unmarshal(value, &block); decodeErr != nil {
+			return false
+		}
+		b.Delete(key)
+		b.Delete(kvBlockHashPrefix + block.Hash)
+		removed++
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete blocks below %d: %v", height, err)
+	}
+	if err := s.deleteBlockIndexes(b, 0, height-1); err != nil {
+		return 0, err
+	}
+	if err := s.stagePruneState(b, func(state *PruneState) {
+		if height > state.BlockHeight {
+			state.BlockHeight = height
+		}
+	}); err != nil {
+		return 0, err
+	}
+	if err := db.Write(b); err != nil {
+		return 0, fmt.Errorf("failed to delete blocks below %d: %v", height, err)
+	}
+	return removed, nil
+}
+
+// stagePruneState stages an update of the prune state in b
+func (s *KVStore) stagePruneState(b *kv.Batch, update func(state *PruneState)) error {
+	state, err := s.GetPruneState()
+	if err != nil {
+		return err
+	}
+	update(state)
+	value, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode prune state: %v", err)
+	}
+	b.Put(kvPruneStateKey, value)
+	return nil
+}
+
+// GetPruneState returns how far history has been pruned
+func (s *KVStore) GetPruneState() (*PruneState, error) {
+	var state PruneState
+	if _, err := s.getJSON(kvPruneStateKey, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Batch operations
+func (s *KVStore) NewBatch() (Batch, error) {
+	return s.newBatch(), nil
+}
+
+func (s *KVStore) newBatch() *kvBatch {
+	return &kvBatch{store: s, batch: new(kv.Batch), now: time.Now()}
+}
+
+// GetStateCommit returns the last committed block state, or nil if no block
+// has been applied through a batch yet
+func (s *KVStore) GetStateCommit() (*StateCommit, error) {
+	var commit StateCommit
+	found, err := s.getJSON(kvStateCommitKey, &commit)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &commit, nil
+}
+
+// Maintenance
+
+// CheckIntegrity verifies the checksum of every record in the log
+func (s *KVStore) CheckIntegrity() error {
+	return s.kv().Verify()
+}
+
+// Compact rewrites the log without deleted and overwritten records
+func (s *KVStore) Compact() error {
+	return s.kv().Compact()
+}
+
+// BackupToWriter writes a compacted copy of the store
+func (s *KVStore) BackupToWriter(writer io.Writer) error {
+	return s.kv().Backup(writer)
+}
+
+// Restore replaces the store's contents with a backup written by BackupToWriter
+func (s *KVStore) Restore(reader io.Reader) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmpPath := s.path + ".restore"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpPath)
+	if _, err := io.Copy(tmp, reader); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write backup to temp file: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temp file: %v", err)
+	}
+	tmp.Close()
+
+	// Make sure the backup is a readable store before replacing anything
+	check, err := kv.Open(tmpPath)
+	if err != nil {
+		return fmt.Errorf("invalid backup: %v", err)
+	}
+	check.Close()
+
+	s.db.Close()
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace database: %v", err)
+	}
+	if s.db, err = kv.Open(s.path); err != nil {
+		return fmt.Errorf("failed to reopen database: %v", err)
+	}
+	return s.loadSequences()
+}
+
+func (s *KVStore) Close() error {
+	return s.kv().Close()
+}
+
+// kvBatch is a Batch that stages writes in a kv.Batch
+type kvBatch struct {
+	store *KVStore
+	batch *kv.Batch
+	now   time.Time
+	done  bool
+}
+
+func (b *kvBatch) put(key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", key, err)
+	}
+	b.batch.Put(key, value)
+	return nil
+}
+
+func (b *kvBatch) SetAccount(account *Account) error {
+	stored := *account
+	stored.CreatedAt, stored.UpdatedAt = b.now, b.now
+	if existing, err := b.store.GetAccount(account.Address); err != nil {
+		return fmt.Errorf("failed to set account: %v", err)
+	} else if existing != nil {
+		stored.CreatedAt = existing.CreatedAt
+	}
+	return b.put(kvAccountPrefix+account.Address, &stored)
+}
+
+func (b *kvBatch) SetContract(contract *Contract) error {
+	stored := *contract
+	if stored.ABI == "" {
+		stored.ABI = "{}"
+	}
+	stored.CreatedAt, stored.UpdatedAt = b.now, b.now
+	if existing, err := b.store.GetContract(contract.Address); err != nil {
+		return fmt.Errorf("failed to set contract: %v", err)
+	} else if existing != nil {
+		stored.CreatedAt = existing.CreatedAt
+	}
+	return b.put(kvContractPrefix+contract.Address, &stored)
+}
+
+func (b *kvBatch) SetProposal(proposal *Proposal) error {
+	stored := *proposal
+	stored.CreatedAt, stored.UpdatedAt = b.now, b.now
+	if existing, err := b.store.GetProposal(proposal.ID); err != nil {
+		return fmt.Errorf("failed to set proposal: %v", err)
+	} else if existing != nil {
+		stored.CreatedAt = existing.CreatedAt
+	}
+	return b.put(kvProposalPrefix+proposal.ID, &stored)
+}
+
+func (b *kvBatch) AddVote(vote *Vote) error {
+	stored := *vote
+	stored.ID = atomic.AddInt64(&b.store.voteSeq, 1)
+	stored.CreatedAt = b.now
+	b.batch.Put(kvVoteSeqKey, []byte(strconv.FormatInt(stored.ID, 10)))
+	return b.put(kvVotePrefix+vote.ProposalID+"/"+heightKey(stored.ID), &stored)
+}
+
+func (b *kvBatch) SetMultisig(account *MultisigAccount) error {
+	stored := *account
+	stored.CreatedAt = b.now
+	if existing, err := b.store.GetMultisig(account.Address); err != nil {
+		return fmt.Errorf("failed to set multisig account: %v", err)
+	} else if existing != nil {
+		stored.CreatedAt = existing.CreatedAt
+	}
+	return b.put(kvMultisigPrefix+account.Address, &stored)
+}
+
+func (b *kvBatch) SaveBlock(block *Block, txHashes []string) error {
+	// Replacing a block drops the index entries of the old one
+	existing, err := b.store.GetBlockByHeight(block.Height)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		b.batch.Delete(kvBlockHashPrefix + existing.Hash)
+		if err := b.store.deleteBlockIndexes(b.batch, block.Height, block.Height); err != nil {
+			return err
+		}
+	}
+
+	if err := b.put(kvBlockPrefix+heightKey(block.Height), block); err != nil {
+		return err
+	}
+	b.batch.Put(kvBlockHashPrefix+block.Hash, []byte(strconv.FormatInt(block.Height, 10)))
+	for i, hash := range txHashes {
+		suffix := heightKey(block.Height) + "/" + indexKey(i)
+		b.batch.Put(kvTxPrefix+suffix, []byte(hash))
+		b.batch.Put(kvTxHashPrefix+hash+"/"+suffix, nil)
+	}
+	return nil
+}
+
+func (b *kvBatch) SaveReceipts(receipts []*Receipt) error {
+	for _, receipt := range receipts {
+		if err := b.put(kvReceiptPrefix+heightKey(receipt.BlockHeight)+"/"+indexKey(receipt.Index), receipt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *kvBatch) SaveStateDiff(diff *StateDiff) error {
+	for _, account := range diff.Accounts {
+		version := Account{
+			Address:      account.Address,
+			Balance:      account.Balance,
+			Nonce:        account.Nonce,
+			IsValidator:  account.IsValidator,
+			StakedAmount: account.StakedAmount,
+		}
+		if err := b.put(historyKey(kvHistoryPrefix, account.Address, diff.Height), &version); err != nil {
+			return err
+		}
+	}
+	for _, version := range diff.Contracts {
+		b.batch.Put(historyKey(kvStorageHistPrefix, version.Address, diff.Height), []byte(version.Storage))
+	}
+	return nil
+}
+
+func (b *kvBatch) SetStateCommit(commit *StateCommit) error {
+	return b.put(kvStateCommitKey, commit)
+}
+
+func (b *kvBatch) Commit() error {
+	if b.done {
+		return fmt.Errorf("batch already committed or rolled back")
+	}
+	b.done = true
+	if err := b.store.kv().Write(b.batch); err != nil {
+		return fmt.Errorf("failed to commit batch: %v", err)
+	}
+	return nil
+}
+
+func (b *kvBatch) Rollback() error {
+	b.done = true
+	b.batch.Reset()
+	return nil
+}
@@ -0,0 +1,358 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Migration is a forward-only change to the SQLite schema. Applied
+// migrations are recorded in the schema_version table.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *sql.Tx) error
+}
+
+// MigrateOptions control a migration run
+type MigrateOptions struct {
+	DryRun bool // Apply pending migrations in a transaction that is rolled back
+	Target int  // Last version to apply; 0 means the latest
+}
+
+// migrations is the schema history in order. Released migrations must never
+// change; schema changes are made by appending a new one.
+//
+// Databases created before schema_version existed report version 0 and
+// already contain some of the objects below, so migrations 1 to 7 only
+// create what is missing.
+var migrations = []Migration{
+	{1, "accounts, contracts, governance, oracle and snapshot tables", execAll(
+		`CREATE TABLE IF NOT EXISTS accounts (
+			address TEXT PRIMARY KEY,
+			balance INTEGER NOT NULL DEFAULT 0,
+			nonce INTEGER NOT NULL DEFAULT 0,
+			is_validator BOOLEAN NOT NULL DEFAULT FALSE,
+			staked_amount INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS contracts (
+			address TEXT PRIMARY KEY,
+			code TEXT NOT NULL,
+			storage TEXT NOT NULL DEFAULT '{}',
+			owner TEXT NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS proposals (
+			id TEXT PRIMARY KEY,
+			proposer TEXT NOT NULL,
+			description TEXT NOT NULL,
+			actions TEXT NOT NULL,
+			state TEXT NOT NULL DEFAULT 'pending',
+			votes_for INTEGER NOT NULL DEFAULT 0,
+			votes_against INTEGER NOT NULL DEFAULT 0,
+			start_block INTEGER NOT NULL,
+			end_block INTEGER NOT NULL,
+			voters TEXT NOT NULL DEFAULT '{}',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS votes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			proposal_id TEXT NOT NULL,
+			voter TEXT NOT NULL,
+			choice TEXT NOT NULL,
+			weight INTEGER NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (proposal_id) REFERENCES proposals(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS oracle_data (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL,
+			timestamp INTEGER NOT NULL,
+			source TEXT NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS state_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			block_height INTEGER NOT NULL,
+			checksum TEXT NOT NULL,
+			data TEXT NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_accounts_validator ON accounts(is_validator)`,
+		`CREATE INDEX IF NOT EXISTS idx_proposals_state ON proposals(state)`,
+		`CREATE INDEX IF NOT EXISTS idx_votes_proposal ON votes(proposal_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_snapshots_height ON state_snapshots(block_height)`,
+	)},
+	{2, "contract ABI", func(tx *sql.Tx) error {
+		return addColumnIfMissing(tx, "contracts", "abi", "TEXT NOT NULL DEFAULT '{}'")
+	}},
+	{3, "blocks and transaction index", execAll(
+		`CREATE TABLE IF NOT EXISTS blocks (
+			height INTEGER PRIMARY KEY,
+			hash TEXT UNIQUE NOT NULL,
+			prev_hash TEXT NOT NULL,
+			validator TEXT NOT NULL,
+			timestamp INTEGER NOT NULL,
+			tx_count INTEGER NOT NULL DEFAULT 0,
+			data TEXT NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS transactions (
+			block_height INTEGER NOT NULL,
+			tx_index INTEGER NOT NULL,
+			hash TEXT NOT NULL,
+			PRIMARY KEY (block_height, tx_index),
+			FOREIGN KEY (block_height) REFERENCES blocks(height)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_hash ON transactions(hash)`,
+	)},
+	{4, "state commit", execAll(
+		`CREATE TABLE IF NOT EXISTS state_commit (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			height INTEGER NOT NULL,
+			block_hash TEXT NOT NULL,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+	)},
+	{5, "transaction receipts", execAll(
+		`CREATE TABLE IF NOT EXISTS receipts (
+			block_height INTEGER NOT NULL,
+			tx_index INTEGER NOT NULL,
+			tx_hash TEXT NOT NULL,
+			status INTEGER NOT NULL,
+			gas_used INTEGER NOT NULL DEFAULT 0,
+			contract_address TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (block_height, tx_index),
+			FOREIGN KEY (block_height) REFERENCES blocks(height)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_receipts_hash ON receipts(tx_hash)`,
+	)},
+	{6, "account history and pruning state", execAll(
+		`CREATE TABLE IF NOT EXISTS account_history (
+			address TEXT NOT NULL,
+			block_height INTEGER NOT NULL,
+			balance INTEGER NOT NULL,
+			nonce INTEGER NOT NULL,
+			is_validator BOOLEAN NOT NULL,
+			staked_amount INTEGER NOT NULL,
+			PRIMARY KEY (address, block_height)
+		)`,
+		`CREATE TABLE IF NOT EXISTS prune_state (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			state_height INTEGER NOT NULL,
+			block_height INTEGER NOT NULL,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_account_history_height ON account_history(block_height)`,
+	)},
+	{7, "contract storage history", execAll(
+		`CREATE TABLE IF NOT EXISTS contract_history (
+			address TEXT NOT NULL,
+			block_height INTEGER NOT NULL,
+			storage TEXT NOT NULL,
+			PRIMARY KEY (address, block_height)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_contract_history_height ON contract_history(block_height)`,
+	)},
+	{8, "multisig accounts", execAll(
+		`CREATE TABLE IF NOT EXISTS multisig_accounts (
+			address TEXT PRIMARY KEY,
+			threshold INTEGER NOT NULL,
+			public_keys TEXT NOT NULL,
+			block_height INTEGER NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+	)},
+	{9, "receipt logs", func(tx *sql.Tx) error {
+		return addColumnIfMissing(tx, "receipts", "logs", "TEXT NOT NULL DEFAULT ''")
+	}},
+}
+
+// execAll returns a migration step running statements in order
+func execAll(statements ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				return fmt.Errorf("failed to execute query: %v", err)
+			}
+		}
+		return nil
+	}
+}
+
+// addColumnIfMissing adds a column to an existing table if it is not present
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read table info for %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan table info for %s: %v", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %v", table, column, err)
+	}
+	return nil
+}
+
+// Migrations returns the schema history in order
+func Migrations() []Migration {
+	return append([]Migration(nil), migrations...)
+}
+
+// LatestSchemaVersion is the schema version this build creates
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// SchemaVersion returns the highest migration applied to db, 0 for a new
+// database or one created before migrations were tracked
+func SchemaVersion(db *sql.DB) (int, error) {
+	return schemaVersion(db)
+}
+
+func schemaVersion(q querier) (int, error) {
+	var tables int
+	err := q.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`).Scan(&tables)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %v", err)
+	}
+	if tables == 0 {
+		return 0, nil
+	}
+	var version int
+	if err := q.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %v", err)
+	}
+	return version, nil
+}
+
+// Migrate brings the schema of db up to date and returns the migrations it
+// applied, or would apply in a dry run. Each migration runs in its own
+// transaction together with its schema_version record, so a failed
+// migration leaves the database at the previous version.
+func Migrate(db *sql.DB, opts MigrateOptions) ([]Migration, error) {
+	current, err := schemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	latest := LatestSchemaVersion()
+	if current > latest {
+		return nil, fmt.Errorf("database schema version %d is newer than this node supports (%d)", current, latest)
+	}
+	target := opts.Target
+	if target == 0 {
+		target = latest
+	}
+	if target < current || target > latest {
+		return nil, fmt.Errorf("cannot migrate from version %d to %d (latest is %d)", current, target, latest)
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > current && m.Version <= target {
+			pending = append(pending, m)
+		}
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	if opts.DryRun {
+		// SQLite DDL is transactional, so the whole plan is tried and undone
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, fmt.Errorf("failed to begin transaction: %v", err)
+		}
+		defer tx.Rollback()
+		for _, m := range pending {
+			if err := applyMigration(tx, m); err != nil {
+				return nil, err
+			}
+		}
+		return pending, nil
+	}
+
+	for i, m := range pending {
+		tx, err := db.Begin()
+		if err != nil {
+			return pending[:i], fmt.Errorf("failed to begin transaction: %v", err)
+		}
+		if err := applyMigration(tx, m); err != nil {
+			tx.Rollback()
+			return pending[:i], err
+		}
+		if err := tx.Commit(); err != nil {
+			return pending[:i], fmt.Errorf("failed to commit migration %d: %v", m.Version, err)
+		}
+		log.Printf("🗄️ Applied schema migration %d: %s", m.Version, m.Description)
+	}
+	return pending, nil
+}
+
+// applyMigration runs one migration and records it in schema_version
+func applyMigration(tx *sql.Tx, m Migration) error {
+	_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_version table: %v", err)
+	}
+	if err := m.Up(tx); err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %v", m.Version, m.Description, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, description) VALUES (?, ?)`, m.Version, m.Description); err != nil {
+		return fmt.Errorf("failed to record migration %d: %v", m.Version, err)
+	}
+	return nil
+}
+
+// MigrateFile migrates the SQLite database at path and returns its version
+// before the run together with the migrations applied, or pending in a dry
+// run
+func MigrateFile(path string, opts MigrateOptions) (int, []Migration, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, nil, fmt.Errorf("database %s not found: %v", path, err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		return 0, nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	from, err := schemaVersion(db)
+	if err != nil {
+		return 0, nil, err
+	}
+	applied, err := Migrate(db, opts)
+	return from, applied, err
+}
@@ -0,0 +1,200 @@
+package database_test
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"atlas-blockchain/pkg/database"
+)
+
+// openSQL opens a raw SQLite database, skipping the test in builds without
+// the SQLite driver
+func openSQL(t *testing.T, path string) *sql.DB {
+	db, err := sql.Open("sqlite3", path)
+	if err == nil {
+		err = db.Ping()
+	}
+	if err != nil && strings.Contains(err.Error(), "cgo") {
+		t.Skipf("SQLite backend unavailable in this build: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	return db
+}
+
+// schemaOf describes the tables, columns and indexes of a database. Columns
+// are sorted because ALTER TABLE appends them.
+func schemaOf(t *testing.T, db *sql.DB) string {
+	rows, err := db.Query(`SELECT type, name FROM sqlite_master
+		WHERE name NOT LIKE 'sqlite_%' AND type IN ('table', 'index') ORDER BY type, name`)
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	var objects [][2]string
+	for rows.Next() {
+		var kind, name string
+		rows.Scan(&kind, &name)
+		objects = append(objects, [2]string{kind, name})
+	}
+	rows.Close()
+
+	var lines []string
+	for _, object := range objects {
+		if object[0] == "index" {
+			lines = append(lines, "index "+object[1])
+			continue
+		}
+		cols, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", object[1]))
+		if err != nil {
+			t.Fatalf("Failed to read columns of %s: %v", object[1], err)
+		}
+		var columns []string
+		for cols.Next() {
+			var cid, notNull, pk int
+			var name, colType string
+			var defaultValue sql.NullString
+			cols.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk)
+			columns = append(columns, fmt.Sprintf("%s %s notnull=%d default=%s pk=%d", name, colType, notNull, defaultValue.String, pk))
+		}
+		cols.Close()
+		sort.Strings(columns)
+		lines = append(lines, "table "+object[1]+" ("+strings.Join(columns, ", ")+")")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fixtureAt creates a database at schema version, with rows in the tables
+// that exist at that version. Untracked fixtures look like databases created
+// before migrations were recorded.
+func fixtureAt(t *testing.T, version int, tracked bool) string {
+	path := filepath.Join(t.TempDir(), fmt.Sprintf("v%d.db", version))
+	db := openSQL(t, path)
+	defer db.Close()
+	if version == 0 {
+		return path
+	}
+	if _, err := database.Migrate(db, database.MigrateOptions{Target: version}); err != nil {
+		t.Fatalf("Migrating the fixture to version %d failed: %v", version, err)
+	}
+	statements := []string{
+		`INSERT INTO accounts (address, balance, nonce) VALUES ('alice', 100, 3)`,
+		`INSERT INTO contracts (address, code, owner) VALUES ('0xc1', '{}', 'alice')`,
+	}
+	if version >= 3 {
+		statements = append(statements,
+			`INSERT INTO blocks (height, hash, prev_hash, validator, timestamp, tx_count, data) VALUES (1, 'hash1', 'hash0', 'v', 0, 1, '{}')`,
+			`INSERT INTO transactions (block_height, tx_index, hash) VALUES (1, 0, 'tx1')`)
+	}
+	if !tracked {
+		statements = append(statements, `DROP TABLE schema_version`)
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Preparing the version %d fixture failed: %v", version, err)
+		}
+	}
+	return path
+}
+
+func TestMigrations(t *testing.T) {
+	latest := database.LatestSchemaVersion()
+	fresh := fixtureAt(t, latest, true)
+	freshDB := openSQL(t, fresh)
+	want := schemaOf(t, freshDB)
+	freshDB.Close()
+
+	// An untracked database at the latest version runs every migration
+	// again, which each must tolerate
+	for version := 0; version <= latest; version++ {
+		for _, tracked := range []bool{true, false} {
+			if (version == 0 && !tracked) || (version == latest && tracked) {
+				continue
+			}
+			name := fmt.Sprintf("FromVersion%d", version)
+			if !tracked {
+				name += "Untracked"
+			}
+			t.Run(name, func(t *testing.T) {
+				path := fixtureAt(t, version, tracked)
+				s, err := database.NewSQLiteStore(path)
+				if err != nil {
+					t.Fatalf("NewSQLiteStore failed: %v", err)
+				}
+				if version > 0 {
+					if acct, _ := s.GetAccount("alice"); acct == nil || acct.Balance != 100 || acct.Nonce != 3 {
+						t.Errorf("Expected alice's account to survive the migration, got %v", acct)
+					}
+					if c, _ := s.GetContract("0xc1"); c == nil || c.ABI != "{}" {
+						t.Errorf("Expected the contract with a default ABI, got %v", c)
+					}
+				}
+				if version >= 3 {
+					if loc, _ := s.GetTxLocation("tx1"); loc == nil || loc.BlockHeight != 1 {
+						t.Errorf("Expected the transaction index to survive, got %v", loc)
+					}
+				}
+				s.Close()
+
+				db := openSQL(t, path)
+				defer db.Close()
+				if got, _ := database.SchemaVersion(db); got != latest {
+					t.Errorf("Expected schema version %d, got %d", latest, got)
+				}
+				if got := schemaOf(t, db); got != want {
+					t.Errorf("Migrated schema differs from a new database\n got:\n%s\nwant:\n%s", got, want)
+				}
+			})
+		}
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		path := fixtureAt(t, 3, true)
+		from, pending, err := database.MigrateFile(path, database.MigrateOptions{DryRun: true})
+		if err != nil {
+			t.Fatalf("Dry run failed: %v", err)
+		}
+		if from != 3 || len(pending) != latest-3 || pending[0].Version != 4 {
+			t.Errorf("Expected migrations 4 to %d pending from version 3, got %d and %v", latest, from, pending)
+		}
+		db := openSQL(t, path)
+		defer db.Close()
+		if got, _ := database.SchemaVersion(db); got != 3 {
+			t.Errorf("Expected a dry run to leave version 3, got %d", got)
+		}
+		if _, err := db.Exec(`SELECT COUNT(*) FROM state_commit`); err == nil {
+			t.Errorf("Expected a dry run not to create tables")
+		}
+	})
+
+	t.Run("Target", func(t *testing.T) {
+		path := fixtureAt(t, 2, true)
+		from, applied, err := database.MigrateFile(path, database.MigrateOptions{Target: 4})
+		if err != nil || from != 2 || len(applied) != 2 {
+			t.Fatalf("Expected migrations 3 and 4 applied, got %d %v (%v)", from, applied, err)
+		}
+		if _, _, err := database.MigrateFile(path, database.MigrateOptions{Target: 3}); err == nil {
+			t.Errorf("Expected migrating backwards to fail")
+		}
+	})
+
+	t.Run("NewerDatabase", func(t *testing.T) {
+		path := fixtureAt(t, latest, true)
+		db := openSQL(t, path)
+		db.Exec(`INSERT INTO schema_version (version, description) VALUES (?, 'from the future')`, latest+1)
+		db.Close()
+		if _, err := database.NewSQLiteStore(path); err == nil || !strings.Contains(err.Error(), "newer") {
+			t.Errorf("Expected a newer schema to be refused, got %v", err)
+		}
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		if _, _, err := database.MigrateFile(filepath.Join(t.TempDir(), "none.db"), database.MigrateOptions{}); err == nil {
+			t.Errorf("Expected an error for a missing database")
+		}
+	})
+}
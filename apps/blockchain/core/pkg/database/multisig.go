@@ -0,0 +1,67 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MultisigAccount is a registered M-of-N account
+type MultisigAccount struct {
+	Address     string    `json:"address"`
+	Threshold   int       `json:"threshold"`
+	PublicKeys  string    `json:"public_keys"`  // JSON encoded list
+	BlockHeight int64     `json:"block_height"` // Height of the registering block
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Multisig operations
+const multisigColumns = `address, threshold, public_keys, block_height, created_at`
+
+func setMultisig(ex execer, account *MultisigAccount) error {
+	query := `INSERT OR REPLACE INTO multisig_accounts (address, threshold, public_keys, block_height)
+			  VALUES (?, ?, ?, ?)`
+	_, err := ex.Exec(query, account.Address, account.Threshold, account.PublicKeys, account.BlockHeight)
+	if err != nil {
+		return fmt.Errorf("failed to set multisig account: %v", err)
+	}
+	return nil
+}
+
+func (d *SQLiteStore) SetMultisig(account *MultisigAccount) error {
+	return setMultisig(d.db, account)
+}
+
+// GetMultisig returns the multisig account at address, or nil if none is registered
+func (d *SQLiteStore) GetMultisig(address string) (*MultisigAccount, error) {
+	var account MultisigAccount
+	err := d.db.QueryRow(`SELECT `+multisigColumns+` FROM multisig_accounts WHERE address = ?`, address).Scan(
+		&account.Address, &account.Threshold, &account.PublicKeys, &account.BlockHeight, &account.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get multisig account: %v", err)
+	}
+	return &account, nil
+}
+
+// GetAllMultisigs returns every registered multisig account ordered by address
+func (d *SQLiteStore) GetAllMultisigs() ([]*MultisigAccount, error) {
+	rows, err := d.db.Query(`SELECT ` + multisigColumns + ` FROM multisig_accounts ORDER BY address`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query multisig accounts: %v", err)
+	}
+	defer rows.Close()
+
+	var accounts []*MultisigAccount
+	for rows.Next() {
+		var account MultisigAccount
+		if err := rows.Scan(&account.Address, &account.Threshold, &account.PublicKeys,
+			&account.BlockHeight, &account.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan multisig account: %v", err)
+		}
+		accounts = append(accounts, &account)
+	}
+	return accounts, rows.Err()
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// Receipt operations
+const receiptColumns = `tx_hash, block_height, tx_index, status, gas_used, contract_address, error, logs`
+
+func saveReceipt(ex execer, receipt *Receipt) error {
+	_, err := ex.Exec(`INSERT OR REPLACE INTO receipts (`+receiptColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		receipt.TxHash, receipt.BlockHeight, receipt.Index, receipt.Status, receipt.GasUsed,
+		receipt.ContractAddress, receipt.Error, string(receipt.Logs))
+	if err != nil {
+		return fmt.Errorf("failed to save receipt for %s: %v", receipt.TxHash, err)
+	}
+	return nil
+}
+
+// GetReceipt returns the receipt of the most recent transaction with the given hash
+func (d *SQLiteStore) GetReceipt(txHash string) (*Receipt, error) {
+	query := `SELECT ` + receiptColumns + ` FROM receipts
+			  WHERE tx_hash = ? ORDER BY block_height DESC, tx_index DESC LIMIT 1`
+
+	var receipt Receipt
+	var logs string
+	err := d.db.QueryRow(query, txHash).Scan(&receipt.TxHash, &receipt.BlockHeight, &receipt.Index,
+		&receipt.Status, &receipt.GasUsed, &receipt.ContractAddress, &receipt.Error, &logs)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipt: %v", err)
+	}
+	if logs != "" {
+		receipt.Logs = json.RawMessage(logs)
+	}
+	return &receipt, nil
+}
+
+// GetBlockReceipts returns the receipts of a block in transaction order
+func (d *SQLiteStore) GetBlockReceipts(height int64) ([]*Receipt, error) {
+	rows, err := d.db.Query(`SELECT `+receiptColumns+` FROM receipts WHERE block_height = ? ORDER BY tx_index`, height)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query receipts: %v", err)
+	}
+	defer rows.Close()
+
+	var receipts []*Receipt
+	for rows.Next() {
+		var receipt Receipt
+		var logs string
+		if err := rows.Scan(&receipt.TxHash, &receipt.BlockHeight, &receipt.Index,
+			&receipt.Status, &receipt.GasUsed, &receipt.ContractAddress, &receipt.Error, &logs); err != nil {
+			return nil, fmt.Errorf("failed to scan receipt: %v", err)
+		}
+		if logs != "" {
+			receipt.Logs = json.RawMessage(logs)
+		}
+		receipts = append(receipts, &receipt)
+	}
+	return receipts, rows.Err()
+}
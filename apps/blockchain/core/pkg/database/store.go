@@ -0,0 +1,127 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Storage backends selectable by configuration
+const (
+	BackendSQLite = "sqlite" // SQLite through CGO
+	BackendKV     = "kv"     // Embedded pure-Go key-value store
+)
+
+// Store is a storage backend for chain and state data
+type Store interface {
+	// Accounts
+	GetAccount(address string) (*Account, error)
+	SetAccount(account *Account) error
+	GetAllAccounts() ([]*Account, error)
+	GetValidators() ([]*Account, error)
+
+	// Contracts
+	GetContract(address string) (*Contract, error)
+	SetContract(contract *Contract) error
+	GetAllContracts() ([]*Contract, error)
+
+	// Governance
+	GetProposal(id string) (*Proposal, error)
+	SetProposal(proposal *Proposal) error
+	GetAllProposals() ([]*Proposal, error)
+	AddVote(vote *Vote) error
+	GetVotesForProposal(proposalID string) ([]*Vote, error)
+
+	// Multisig accounts
+	GetMultisig(address string) (*MultisigAccount, error)
+	SetMultisig(account *MultisigAccount) error
+	GetAllMultisigs() ([]*MultisigAccount, error)
+
+	// Oracle data
+	SetOracleData(data *OracleData) error
+	GetOracleData(key string) (*OracleData, error)
+
+	// Blocks and the transaction index
+	SaveBlock(block *Block, txHashes []string) error
+	GetBlockByHeight(height int64) (*Block, error)
+	GetBlockByHash(hash string) (*Block, error)
+	GetLatestBlock() (*Block, error)
+	GetBlockRange(from, to int64) ([]*Block, error)
+	GetTxLocation(hash string) (*TxLocation, error)
+	DeleteBlocksAbove(height int64) (int64, error)
+
+	// Receipts
+	GetReceipt(txHash string) (*Receipt, error)
+	GetBlockReceipts(height int64) ([]*Receipt, error)
+
+	// State history and pruning
+	GetAccountAt(address string, height int64) (*Account, error)
+	GetContractStorageAt(address string, height int64) (*StorageVersion, error)
+	GetStateDiffs(from, to int64) ([]*StateDiff, error)
+	PruneStateHistory(below int64) (int64, error)
+	DeleteBlocksBelow(height int64) (int64, error)
+	GetPruneState() (*PruneState, error)
+
+	// State snapshots
+	SaveSnapshot(blockHeight int64, checksum string, data map[string]interface{}) error
+	GetLatestSnapshot() (int64, string, map[string]interface{}, error)
+
+	// Batches
+	NewBatch() (Batch, error)
+	GetStateCommit() (*StateCommit, error)
+
+	// Maintenance
+	CheckIntegrity() error
+	Compact() error
+	BackupToWriter(writer io.Writer) error
+	Restore(reader io.Reader) error
+	Close() error
+}
+
+// Batch groups state writes that are committed or rolled back as a unit
+type Batch interface {
+	SetAccount(account *Account) error
+	SetContract(contract *Contract) error
+	SetProposal(proposal *Proposal) error
+	AddVote(vote *Vote) error
+	SetMultisig(account *MultisigAccount) error
+	SaveBlock(block *Block, txHashes []string) error
+	SaveReceipts(receipts []*Receipt) error
+	SaveStateDiff(diff *StateDiff) error
+	SetStateCommit(commit *StateCommit) error
+
+	// Commit applies every write in the batch
+	Commit() error
+	// Rollback discards every write in the batch. Calling it after Commit is a no-op.
+	Rollback() error
+}
+
+// Receipt statuses
+const (
+	ReceiptFailed  = 0
+	ReceiptSuccess = 1
+)
+
+// Receipt is the outcome of a transaction included in a block
+type Receipt struct {
+	TxHash          string          `json:"tx_hash"`
+	BlockHeight     int64           `json:"block_height"`
+	Index           int             `json:"index"`
+	Status          int             `json:"status"`
+	GasUsed         uint64          `json:"gas_used"`
+	ContractAddress string          `json:"contract_address,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	Logs            json.RawMessage `json:"logs,omitempty"` // JSON encoded events emitted by a successful contract call
+}
+
+// Open opens the store for the given backend at path
+func Open(backend, path string) (Store, error) {
+	switch backend {
+	case BackendSQLite, "":
+		return NewSQLiteStore(path)
+	case BackendKV:
+		return NewKVStore(path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend '%s'", backend)
+	}
+}
@@ -0,0 +1,54 @@
+package database_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"atlas-blockchain/pkg/database"
+	"atlas-blockchain/pkg/database/storetest"
+)
+
+func TestKVStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) database.Store {
+		s, err := database.NewKVStore(filepath.Join(t.TempDir(), "test.kv"))
+		if err != nil {
+			t.Fatalf("NewKVStore failed: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestSQLiteStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) database.Store {
+		s, err := database.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil && strings.Contains(err.Error(), "cgo") {
+			t.Skipf("SQLite backend unavailable in this build: %v", err)
+		}
+		if err != nil {
+			t.Fatalf("NewSQLiteStore failed: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestOpen(t *testing.T) {
+	t.Run("KV", func(t *testing.T) {
+		s, err := database.Open(database.BackendKV, filepath.Join(t.TempDir(), "node.kv"))
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer s.Close()
+		if _, ok := s.(*database.KVStore); !ok {
+			t.Errorf("Expected a KVStore, got %T", s)
+		}
+	})
+
+	t.Run("UnknownBackend", func(t *testing.T) {
+		if _, err := database.Open("leveldb", filepath.Join(t.TempDir(), "x")); err == nil {
+			t.Errorf("Expected an error for an unknown backend")
+		}
+	})
+}
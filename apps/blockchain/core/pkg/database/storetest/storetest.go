@@ -0,0 +1,675 @@
+// Package storetest is the conformance suite every database.Store backend must pass
+package storetest
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"atlas-blockchain/pkg/database"
+)
+
+// Opener returns a new, empty store that is closed when the test ends
+type Opener func(t *testing.T) database.Store
+
+// Run runs the conformance suite against the stores returned by open
+func Run(t *testing.T, open Opener) {
+	t.Run("Accounts", func(t *testing.T) { testAccounts(t, open(t)) })
+	t.Run("Contracts", func(t *testing.T) { testContracts(t, open(t)) })
+	t.Run("Governance", func(t *testing.T) { testGovernance(t, open(t)) })
+	t.Run("Multisigs", func(t *testing.T) { testMultisigs(t, open(t)) })
+	t.Run("OracleData", func(t *testing.T) { testOracleData(t, open(t)) })
+	t.Run("Blocks", func(t *testing.T) { testBlocks(t, open(t)) })
+	t.Run("DeleteBlocksAbove", func(t *testing.T) { testDeleteBlocksAbove(t, open(t)) })
+	t.Run("Receipts", func(t *testing.T) { testReceipts(t, open(t)) })
+	t.Run("Snapshots", func(t *testing.T) { testSnapshots(t, open(t)) })
+	t.Run("BatchCommit", func(t *testing.T) { testBatchCommit(t, open(t)) })
+	t.Run("BatchRollback", func(t *testing.T) { testBatchRollback(t, open(t)) })
+	t.Run("StateHistory", func(t *testing.T) { testStateHistory(t, open(t)) })
+	t.Run("StorageHistory", func(t *testing.T) { testStorageHistory(t, open(t)) })
+	t.Run("DeleteBlocksBelow", func(t *testing.T) { testDeleteBlocksBelow(t, open(t)) })
+	t.Run("BackupRestore", func(t *testing.T) { testBackupRestore(t, open(t)) })
+}
+
+func testAccounts(t *testing.T, s database.Store) {
+	if acct, err := s.GetAccount("missing"); err != nil || acct != nil {
+		t.Errorf("Expected nil for a missing account, got %v (%v)", acct, err)
+	}
+
+	accounts := []*database.Account{
+		{Address: "carol", Balance: 30, Nonce: 1, IsValidator: true, StakedAmount: 500},
+		{Address: "alice", Balance: 10, Nonce: 2},
+		{Address: "bob", Balance: 20, IsValidator: true, StakedAmount: 900},
+	}
+	for _, acct := range accounts {
+		if err := s.SetAccount(acct); err != nil {
+			t.Fatalf("SetAccount failed: %v", err)
+		}
+	}
+	if err := s.SetAccount(&database.Account{Address: "alice", Balance: 15, Nonce: 3}); err != nil {
+		t.Fatalf("SetAccount overwrite failed: %v", err)
+	}
+
+	acct, err := s.GetAccount("alice")
+	if err != nil || acct == nil {
+		t.Fatalf("GetAccount failed: %v", err)
+	}
+	if acct.Balance != 15 || acct.Nonce != 3 {
+		t.Errorf("Expected overwritten balance 15 and nonce 3, got %d and %d", acct.Balance, acct.Nonce)
+	}
+
+	all, err := s.GetAllAccounts()
+	if err != nil {
+		t.Fatalf("GetAllAccounts failed: %v", err)
+	}
+	if got := addresses(all); got != "[alice bob carol]" {
+		t.Errorf("Expected accounts ordered by address, got %s", got)
+	}
+
+	validators, err := s.GetValidators()
+	if err != nil {
+		t.Fatalf("GetValidators failed: %v", err)
+	}
+	if got := addresses(validators); got != "[bob carol]" {
+		t.Errorf("Expected validators ordered by stake, got %s", got)
+	}
+}
+
+func addresses(accounts []*database.Account) string {
+	list := make([]string, len(accounts))
+	for i, acct := range accounts {
+		list[i] = acct.Address
+	}
+	return fmt.Sprint(list)
+}
+
+func testContracts(t *testing.T, s database.Store) {
+	if c, err := s.GetContract("0xmissing"); err != nil || c != nil {
+		t.Errorf("Expected nil for a missing contract, got %v (%v)", c, err)
+	}
+	contract := &database.Contract{Address: "0xc1", Code: `{"name":"c"}`, Storage: `{"x":1}`, Owner: "alice"}
+	if err := s.SetContract(contract); err != nil {
+		t.Fatalf("SetContract failed: %v", err)
+	}
+	got, err := s.GetContract("0xc1")
+	if err != nil || got == nil {
+		t.Fatalf("GetContract failed: %v", err)
+	}
+	if got.Code != contract.Code || got.Storage != contract.Storage || got.Owner != "alice" {
+		t.Errorf("Contract did not round trip: %+v", got)
+	}
+	if got.ABI != "{}" {
+		t.Errorf("Expected empty ABI to be stored as {}, got %q", got.ABI)
+	}
+
+	s.SetContract(&database.Contract{Address: "0xa0", Code: `{}`, Storage: `{}`, Owner: "bob"})
+	all, err := s.GetAllContracts()
+	if err != nil {
+		t.Fatalf("GetAllContracts failed: %v", err)
+	}
+	if len(all) != 2 || all[0].Address != "0xa0" || all[1].Address != "0xc1" {
+		t.Errorf("Expected contracts 0xa0 and 0xc1 in address order, got %v", all)
+	}
+}
+
+func testGovernance(t *testing.T, s database.Store) {
+	proposal := &database.Proposal{ID: "proposal_1", Proposer: "alice", Description: "d", Actions: "[]",
+		State: "active", StartBlock: 1, EndBlock: 10, Voters: "{}"}
+	if err := s.SetProposal(proposal); err != nil {
+		t.Fatalf("SetProposal failed: %v", err)
+	}
+	proposal.VotesFor = 7
+	proposal.Voters = `{"bob":true}`
+	if err := s.SetProposal(proposal); err != nil {
+		t.Fatalf("SetProposal update failed: %v", err)
+	}
+	got, err := s.GetProposal("proposal_1")
+	if err != nil || got == nil {
+		t.Fatalf("GetProposal failed: %v", err)
+	}
+	if got.VotesFor != 7 || got.Voters != `{"bob":true}` || got.EndBlock != 10 {
+		t.Errorf("Proposal did not round trip: %+v", got)
+	}
+	if missing, err := s.GetProposal("proposal_2"); err != nil || missing != nil {
+		t.Errorf("Expected nil for a missing proposal, got %v (%v)", missing, err)
+	}
+	if all, err := s.GetAllProposals(); err != nil || len(all) != 1 {
+		t.Errorf("Expected 1 proposal, got %d (%v)", len(all), err)
+	}
+
+	for _, voter := range []string{"bob", "carol", "dave"} {
+		if err := s.AddVote(&database.Vote{ProposalID: "proposal_1", Voter: voter, Choice: "for", Weight: 1}); err != nil {
+			t.Fatalf("AddVote failed: %v", err)
+		}
+	}
+	s.AddVote(&database.Vote{ProposalID: "proposal_9", Voter: "erin", Choice: "against", Weight: 1})
+
+	votes, err := s.GetVotesForProposal("proposal_1")
+	if err != nil {
+		t.Fatalf("GetVotesForProposal failed: %v", err)
+	}
+	if len(votes) != 3 {
+		t.Fatalf("Expected 3 votes, got %d", len(votes))
+	}
+	for i, voter := range []string{"bob", "carol", "dave"} {
+		if votes[i].Voter != voter {
+			t.Errorf("Expected vote %d by %s, got %s", i, voter, votes[i].Voter)
+		}
+		if votes[i].ID == 0 {
+			t.Errorf("Expected vote %d to be assigned an ID", i)
+		}
+	}
+}
+
+func testMultisigs(t *testing.T, s database.Store) {
+	if m, err := s.GetMultisig("0xm1"); err != nil || m != nil {
+		t.Errorf("Expected nil for a missing multisig account, got %v (%v)", m, err)
+	}
+	for _, address := range []string{"0xm2", "0xm1"} {
+		account := &database.MultisigAccount{Address: address, Threshold: 2, PublicKeys: `["aa","bb","cc"]`, BlockHeight: 4}
+		if err := s.SetMultisig(account); err != nil {
+			t.Fatalf("SetMultisig failed: %v", err)
+		}
+	}
+	got, err := s.GetMultisig("0xm1")
+	if err != nil || got == nil {
+		t.Fatalf("GetMultisig failed: %v", err)
+	}
+	if got.Threshold != 2 || got.PublicKeys != `["aa","bb","cc"]` || got.BlockHeight != 4 {
+		t.Errorf("Multisig account did not round trip: %+v", got)
+	}
+	all, err := s.GetAllMultisigs()
+	if err != nil || len(all) != 2 || all[0].Address != "0xm1" || all[1].Address != "0xm2" {
+		t.Errorf("Expected multisig accounts 0xm1 and 0xm2 in address order, got %v (%v)", all, err)
+	}
+}
+
+func testOracleData(t *testing.T, s database.Store) {
+	if d, err := s.GetOracleData("price"); err != nil || d != nil {
+		t.Errorf("Expected nil for missing oracle data, got %v (%v)", d, err)
+	}
+	s.SetOracleData(&database.OracleData{Key: "price", Value: "1", Timestamp: 100, Source: "a"})
+	if err := s.SetOracleData(&database.OracleData{Key: "price", Value: "2", Timestamp: 200, Source: "b"}); err != nil {
+		t.Fatalf("SetOracleData failed: %v", err)
+	}
+	d, err := s.GetOracleData("price")
+	if err != nil || d == nil {
+		t.Fatalf("GetOracleData failed: %v", err)
+	}
+	if d.Value != "2" || d.Timestamp != 200 || d.Source != "b" {
+		t.Errorf("Expected the latest oracle value, got %+v", d)
+	}
+}
+
+func block(height int64) *database.Block {
+	return &database.Block{
+		Height:    height,
+		Hash:      fmt.Sprintf("hash%d", height),
+		PrevHash:  fmt.Sprintf("hash%d", height-1),
+		Validator: "v",
+		Timestamp: 1000 + height,
+		TxCount:   2,
+		Data:      fmt.Sprintf(`{"index":%d}`, height),
+	}
+}
+
+func saveChain(t *testing.T, s database.Store, n int64) {
+	for h := int64(0); h < n; h++ {
+		if err := s.SaveBlock(block(h), []string{fmt.Sprintf("tx%d", h), "reward"}); err != nil {
+			t.Fatalf("SaveBlock %d failed: %v", h, err)
+		}
+	}
+}
+
+func testBlocks(t *testing.T, s database.Store) {
+	if latest, err := s.GetLatestBlock(); err != nil || latest != nil {
+		t.Errorf("Expected no latest block in an empty store, got %v (%v)", latest, err)
+	}
+	saveChain(t, s, 12)
+
+	latest, err := s.GetLatestBlock()
+	if err != nil || latest == nil || latest.Height != 11 {
+		t.Fatalf("Expected latest block 11, got %v (%v)", latest, err)
+	}
+	if *latest != *block(11) {
+		t.Errorf("Block did not round trip: %+v", latest)
+	}
+	if b, err := s.GetBlockByHeight(3); err != nil || b == nil || b.Hash != "hash3" {
+		t.Errorf("GetBlockByHeight(3) returned %v (%v)", b, err)
+	}
+	if b, err := s.GetBlockByHash("hash7"); err != nil || b == nil || b.Height != 7 {
+		t.Errorf("GetBlockByHash(hash7) returned %v (%v)", b, err)
+	}
+	if b, err := s.GetBlockByHeight(99); err != nil || b != nil {
+		t.Errorf("Expected nil for a missing height, got %v (%v)", b, err)
+	}
+
+	blocks, err := s.GetBlockRange(8, 10)
+	if err != nil {
+		t.Fatalf("GetBlockRange failed: %v", err)
+	}
+	if len(blocks) != 3 || blocks[0].Height != 8 || blocks[2].Height != 10 {
+		t.Errorf("Expected blocks 8..10, got %d blocks", len(blocks))
+	}
+
+	loc, err := s.GetTxLocation("tx5")
+	if err != nil || loc == nil || loc.BlockHeight != 5 || loc.Index != 0 {
+		t.Errorf("Expected tx5 at 5/0, got %v (%v)", loc, err)
+	}
+	// A hash included in several blocks resolves to the most recent one
+	loc, err = s.GetTxLocation("reward")
+	if err != nil || loc == nil || loc.BlockHeight != 11 || loc.Index != 1 {
+		t.Errorf("Expected reward at 11/1, got %v (%v)", loc, err)
+	}
+	if loc, err := s.GetTxLocation("unknown"); err != nil || loc != nil {
+		t.Errorf("Expected nil location for an unknown hash, got %v (%v)", loc, err)
+	}
+
+	// Replacing a block replaces its transaction index
+	replacement := block(11)
+	replacement.Hash = "hash11b"
+	if err := s.SaveBlock(replacement, []string{"tx11b"}); err != nil {
+		t.Fatalf("SaveBlock replacement failed: %v", err)
+	}
+	if loc, _ := s.GetTxLocation("tx11"); loc != nil {
+		t.Errorf("Expected the replaced block's transactions to be unindexed, got %v", loc)
+	}
+	if b, _ := s.GetBlockByHash("hash11"); b != nil {
+		t.Errorf("Expected the replaced block's hash to be gone")
+	}
+	if loc, _ := s.GetTxLocation("tx11b"); loc == nil || loc.BlockHeight != 11 {
+		t.Errorf("Expected tx11b at height 11, got %v", loc)
+	}
+}
+
+func testDeleteBlocksAbove(t *testing.T, s database.Store) {
+	saveChain(t, s, 6)
+	batch, err := s.NewBatch()
+	if err != nil {
+		t.Fatalf("NewBatch failed: %v", err)
+	}
+	batch.SaveReceipts([]*database.Receipt{{TxHash: "tx5", BlockHeight: 5, Status: database.ReceiptSuccess}})
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	removed, err := s.DeleteBlocksAbove(3)
+	if err != nil {
+		t.Fatalf("DeleteBlocksAbove failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 blocks removed, got %d", removed)
+	}
+	if latest, _ := s.GetLatestBlock(); latest == nil || latest.Height != 3 {
+		t.Errorf("Expected new tip 3, got %v", latest)
+	}
+	if b, _ := s.GetBlockByHash("hash5"); b != nil {
+		t.Errorf("Expected hash5 to be removed")
+	}
+	if loc, _ := s.GetTxLocation("tx4"); loc != nil {
+		t.Errorf("Expected tx4 to be unindexed, got %v", loc)
+	}
+	if loc, _ := s.GetTxLocation("reward"); loc == nil || loc.BlockHeight != 3 {
+		t.Errorf("Expected reward to resolve to height 3, got %v", loc)
+	}
+	if r, _ := s.GetReceipt("tx5"); r != nil {
+		t.Errorf("Expected receipt of a removed block to be gone, got %v", r)
+	}
+}
+
+func testReceipts(t *testing.T, s database.Store) {
+	batch, err := s.NewBatch()
+	if err != nil {
+		t.Fatalf("NewBatch failed: %v", err)
+	}
+	batch.SaveBlock(block(1), []string{"deploy", "call"})
+	err = batch.SaveReceipts([]*database.Receipt{
+		{TxHash: "deploy", BlockHeight: 1, Index: 0, Status: database.ReceiptSuccess, ContractAddress: "0xc1"},
+		{TxHash: "call", BlockHeight: 1, Index: 1, Status: database.ReceiptFailed, GasUsed: 42, Error: "out of gas"},
+	})
+	if err != nil {
+		t.Fatalf("SaveReceipts failed: %v", err)
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	r, err := s.GetReceipt("call")
+	if err != nil || r == nil {
+		t.Fatalf("GetReceipt failed: %v", err)
+	}
+	if r.Status != database.ReceiptFailed || r.GasUsed != 42 || r.Error != "out of gas" || r.Index != 1 {
+		t.Errorf("Receipt did not round trip: %+v", r)
+	}
+	receipts, err := s.GetBlockReceipts(1)
+	if err != nil || len(receipts) != 2 {
+		t.Fatalf("Expected 2 block receipts, got %d (%v)", len(receipts), err)
+	}
+	if receipts[0].ContractAddress != "0xc1" || receipts[1].TxHash != "call" {
+		t.Errorf("Expected receipts in transaction order, got %+v %+v", receipts[0], receipts[1])
+	}
+	if r, err := s.GetReceipt("unknown"); err != nil || r != nil {
+		t.Errorf("Expected nil for an unknown receipt, got %v (%v)", r, err)
+	}
+}
+
+func testSnapshots(t *testing.T, s database.Store) {
+	height, checksum, data, err := s.GetLatestSnapshot()
+	if err != nil || height != 0 || checksum != "" || data != nil {
+		t.Errorf("Expected no snapshot, got %d %q %v (%v)", height, checksum, data, err)
+	}
+	s.SaveSnapshot(5, "c5", map[string]interface{}{"alice": float64(1)})
+	s.SaveSnapshot(20, "c20", map[string]interface{}{"alice": float64(2)})
+	if err := s.SaveSnapshot(10, "c10", map[string]interface{}{"alice": float64(3)}); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+	height, checksum, data, err = s.GetLatestSnapshot()
+	if err != nil {
+		t.Fatalf("GetLatestSnapshot failed: %v", err)
+	}
+	if height != 20 || checksum != "c20" || data["alice"] != float64(2) {
+		t.Errorf("Expected the snapshot at height 20, got %d %q %v", height, checksum, data)
+	}
+}
+
+func testBatchCommit(t *testing.T, s database.Store) {
+	if commit, err := s.GetStateCommit(); err != nil || commit != nil {
+		t.Errorf("Expected no state commit, got %v (%v)", commit, err)
+	}
+
+	batch, err := s.NewBatch()
+	if err != nil {
+		t.Fatalf("NewBatch failed: %v", err)
+	}
+	steps := []error{
+		batch.SetAccount(&database.Account{Address: "alice", Balance: 5}),
+		batch.SetContract(&database.Contract{Address: "0xc1", Code: "{}", Storage: "{}", Owner: "alice"}),
+		batch.SetProposal(&database.Proposal{ID: "proposal_1", Proposer: "alice", Description: "d", Actions: "[]", State: "active", Voters: "{}"}),
+		batch.AddVote(&database.Vote{ProposalID: "proposal_1", Voter: "bob", Choice: "for", Weight: 2}),
+		batch.SetMultisig(&database.MultisigAccount{Address: "0xm1", Threshold: 1, PublicKeys: `["aa"]`, BlockHeight: 1}),
+		batch.SaveBlock(block(1), []string{"tx1"}),
+		batch.SetStateCommit(&database.StateCommit{Height: 1, BlockHash: "hash1"}),
+	}
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("Batch write %d failed: %v", i, err)
+		}
+	}
+
+	// Nothing is visible before the commit
+	if acct, _ := s.GetAccount("alice"); acct != nil && acct.Balance == 5 {
+		t.Errorf("Expected uncommitted account to be invisible")
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := batch.Rollback(); err != nil {
+		t.Errorf("Rollback after Commit should be a no-op, got %v", err)
+	}
+
+	if acct, _ := s.GetAccount("alice"); acct == nil || acct.Balance != 5 {
+		t.Errorf("Expected committed account balance 5, got %v", acct)
+	}
+	if c, _ := s.GetContract("0xc1"); c == nil {
+		t.Errorf("Expected committed contract")
+	}
+	if p, _ := s.GetProposal("proposal_1"); p == nil {
+		t.Errorf("Expected committed proposal")
+	}
+	if votes, _ := s.GetVotesForProposal("proposal_1"); len(votes) != 1 {
+		t.Errorf("Expected 1 committed vote, got %d", len(votes))
+	}
+	if m, _ := s.GetMultisig("0xm1"); m == nil {
+		t.Errorf("Expected committed multisig account")
+	}
+	if b, _ := s.GetBlockByHeight(1); b == nil {
+		t.Errorf("Expected committed block")
+	}
+	commit, err := s.GetStateCommit()
+	if err != nil || commit == nil || commit.Height != 1 || commit.BlockHash != "hash1" {
+		t.Errorf("Expected state commit at 1/hash1, got %v (%v)", commit, err)
+	}
+}
+
+func testBatchRollback(t *testing.T, s database.Store) {
+	s.SetAccount(&database.Account{Address: "alice", Balance: 100})
+
+	batch, err := s.NewBatch()
+	if err != nil {
+		t.Fatalf("NewBatch failed: %v", err)
+	}
+	batch.SetAccount(&database.Account{Address: "alice", Balance: 1})
+	batch.SetAccount(&database.Account{Address: "bob", Balance: 99})
+	batch.SaveBlock(block(1), []string{"tx1"})
+	batch.SetStateCommit(&database.StateCommit{Height: 1, BlockHash: "hash1"})
+	if err := batch.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	if acct, _ := s.GetAccount("alice"); acct == nil || acct.Balance != 100 {
+		t.Errorf("Expected alice to keep balance 100, got %v", acct)
+	}
+	if acct, _ := s.GetAccount("bob"); acct != nil {
+		t.Errorf("Expected bob not to exist, got %v", acct)
+	}
+	if b, _ := s.GetBlockByHeight(1); b != nil {
+		t.Errorf("Expected rolled back block to be absent")
+	}
+	if commit, _ := s.GetStateCommit(); commit != nil {
+		t.Errorf("Expected no state commit after rollback, got %v", commit)
+	}
+}
+
+func testBackupRestore(t *testing.T, s database.Store) {
+	s.SetAccount(&database.Account{Address: "alice", Balance: 7})
+	saveChain(t, s, 3)
+	if err := s.CheckIntegrity(); err != nil {
+		t.Fatalf("CheckIntegrity failed: %v", err)
+	}
+
+	var backup bytes.Buffer
+	if err := s.BackupToWriter(&backup); err != nil {
+		t.Fatalf("BackupToWriter failed: %v", err)
+	}
+
+	s.SetAccount(&database.Account{Address: "alice", Balance: 1000})
+	s.SaveBlock(block(3), []string{"tx3"})
+
+	if err := s.Restore(&backup); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if acct, _ := s.GetAccount("alice"); acct == nil || acct.Balance != 7 {
+		t.Errorf("Expected restored balance 7, got %v", acct)
+	}
+	if latest, _ := s.GetLatestBlock(); latest == nil || latest.Height != 2 {
+		t.Errorf("Expected restored tip 2, got %v", latest)
+	}
+	if err := s.CheckIntegrity(); err != nil {
+		t.Errorf("CheckIntegrity after restore failed: %v", err)
+	}
+	// The store stays writable after a restore
+	if err := s.SetAccount(&database.Account{Address: "bob", Balance: 1}); err != nil {
+		t.Errorf("SetAccount after restore failed: %v", err)
+	}
+}
+
+func saveDiff(t *testing.T, s database.Store, height int64, accounts ...*database.Account) {
+	batch, err := s.NewBatch()
+	if err != nil {
+		t.Fatalf("NewBatch failed: %v", err)
+	}
+	if err := batch.SaveStateDiff(&database.StateDiff{Height: height, Accounts: accounts}); err != nil {
+		t.Fatalf("SaveStateDiff failed: %v", err)
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+}
+
+func balanceAt(t *testing.T, s database.Store, address string, height int64) int64 {
+	acct, err := s.GetAccountAt(address, height)
+	if err != nil {
+		t.Fatalf("GetAccountAt(%s, %d) failed: %v", address, height, err)
+	}
+	if acct == nil {
+		return -1
+	}
+	return acct.Balance
+}
+
+func testStateHistory(t *testing.T, s database.Store) {
+	saveDiff(t, s, 1, &database.Account{Address: "alice", Balance: 10, Nonce: 1})
+	saveDiff(t, s, 3, &database.Account{Address: "alice", Balance: 30, Nonce: 2},
+		&database.Account{Address: "bob", Balance: 5, StakedAmount: 100, IsValidator: true})
+	saveDiff(t, s, 5, &database.Account{Address: "alice", Balance: 50, Nonce: 3})
+	saveDiff(t, s, 6, &database.Account{Address: "alice2", Balance: 1})
+
+	for height, want := range map[int64]int64{0: -1, 1: 10, 2: 10, 3: 30, 4: 30, 5: 50, 9: 50} {
+		if got := balanceAt(t, s, "alice", height); got != want {
+			t.Errorf("Expected alice balance %d at height %d, got %d", want, height, got)
+		}
+	}
+	bob, _ := s.GetAccountAt("bob", 4)
+	if bob == nil || bob.StakedAmount != 100 || !bob.IsValidator {
+		t.Errorf("Expected bob's stake at height 4, got %v", bob)
+	}
+
+	diffs, err := s.GetStateDiffs(2, 5)
+	if err != nil {
+		t.Fatalf("GetStateDiffs failed: %v", err)
+	}
+	if len(diffs) != 2 || diffs[0].Height != 3 || diffs[1].Height != 5 {
+		t.Fatalf("Expected diffs at heights 3 and 5, got %v", diffs)
+	}
+	if addresses(diffs[0].Accounts) != "[alice bob]" || diffs[0].Accounts[1].StakedAmount != 100 {
+		t.Errorf("Expected alice and bob changed at height 3, got %s", addresses(diffs[0].Accounts))
+	}
+
+	state, err := s.GetPruneState()
+	if err != nil || state.StateHeight != 0 || state.BlockHeight != 0 {
+		t.Errorf("Expected nothing pruned, got %+v (%v)", state, err)
+	}
+
+	removed, err := s.PruneStateHistory(4)
+	if err != nil {
+		t.Fatalf("PruneStateHistory failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 superseded version removed, got %d", removed)
+	}
+	// Every height from the prune point on still resolves
+	for height, want := range map[int64]int64{4: 30, 5: 50} {
+		if got := balanceAt(t, s, "alice", height); got != want {
+			t.Errorf("Expected alice balance %d at height %d after pruning, got %d", want, height, got)
+		}
+	}
+	if got := balanceAt(t, s, "bob", 4); got != 5 {
+		t.Errorf("Expected bob's only version to survive pruning, got %d", got)
+	}
+	if got := balanceAt(t, s, "alice2", 6); got != 1 {
+		t.Errorf("Expected alice2 balance 1, got %d", got)
+	}
+
+	s.PruneStateHistory(2)
+	state, err = s.GetPruneState()
+	if err != nil || state.StateHeight != 4 {
+		t.Errorf("Expected prune state to stay at 4, got %+v (%v)", state, err)
+	}
+	if err := s.Compact(); err != nil {
+		t.Errorf("Compact failed: %v", err)
+	}
+	if got := balanceAt(t, s, "alice", 9); got != 50 {
+		t.Errorf("Expected alice balance 50 after compaction, got %d", got)
+	}
+}
+
+func testStorageHistory(t *testing.T, s database.Store) {
+	diff := func(height int64, storage string) {
+		batch, err := s.NewBatch()
+		if err != nil {
+			t.Fatalf("NewBatch failed: %v", err)
+		}
+		batch.SaveStateDiff(&database.StateDiff{Height: height,
+			Contracts: []*database.StorageVersion{{Address: "0xc1", Storage: storage}}})
+		if err := batch.Commit(); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+	}
+	diff(2, `{"n":1}`)
+	diff(4, `{"n":2}`)
+	diff(7, `{"n":3}`)
+
+	if v, err := s.GetContractStorageAt("0xc1", 1); err != nil || v != nil {
+		t.Errorf("Expected no storage before deployment, got %v (%v)", v, err)
+	}
+	v, err := s.GetContractStorageAt("0xc1", 5)
+	if err != nil || v == nil {
+		t.Fatalf("GetContractStorageAt failed: %v", err)
+	}
+	if v.Storage != `{"n":2}` || v.Height != 4 || v.Address != "0xc1" {
+		t.Errorf("Expected storage written at 4, got %+v", v)
+	}
+	diffs, err := s.GetStateDiffs(3, 7)
+	if err != nil {
+		t.Fatalf("GetStateDiffs failed: %v", err)
+	}
+	if len(diffs) != 2 || len(diffs[1].Contracts) != 1 || diffs[1].Contracts[0].Address != "0xc1" ||
+		diffs[1].Contracts[0].Storage != `{"n":3}` || diffs[1].Height != 7 {
+		t.Errorf("Expected storage diffs at heights 4 and 7, got %v", diffs)
+	}
+
+	if _, err := s.PruneStateHistory(6); err != nil {
+		t.Fatalf("PruneStateHistory failed: %v", err)
+	}
+	if v, _ := s.GetContractStorageAt("0xc1", 6); v == nil || v.Storage != `{"n":2}` {
+		t.Errorf("Expected storage at 6 to survive pruning, got %v", v)
+	}
+	if v, _ := s.GetContractStorageAt("0xc1", 2); v != nil {
+		t.Errorf("Expected superseded storage to be pruned, got %v", v)
+	}
+	if v, _ := s.GetContractStorageAt("0xc1", 100); v == nil || v.Storage != `{"n":3}` {
+		t.Errorf("Expected latest storage, got %v", v)
+	}
+}
+
+func testDeleteBlocksBelow(t *testing.T, s database.Store) {
+	saveChain(t, s, 6)
+	batch, err := s.NewBatch()
+	if err != nil {
+		t.Fatalf("NewBatch failed: %v", err)
+	}
+	batch.SaveReceipts([]*database.Receipt{{TxHash: "tx1", BlockHeight: 1, Status: database.ReceiptSuccess}})
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	removed, err := s.DeleteBlocksBelow(3)
+	if err != nil {
+		t.Fatalf("DeleteBlocksBelow failed: %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("Expected 3 blocks removed, got %d", removed)
+	}
+	if b, _ := s.GetBlockByHeight(2); b != nil {
+		t.Errorf("Expected block 2 to be removed")
+	}
+	if b, _ := s.GetBlockByHash("hash1"); b != nil {
+		t.Errorf("Expected hash1 to be removed")
+	}
+	if b, _ := s.GetBlockByHeight(3); b == nil {
+		t.Errorf("Expected block 3 to remain")
+	}
+	if loc, _ := s.GetTxLocation("tx1"); loc != nil {
+		t.Errorf("Expected tx1 to be unindexed, got %v", loc)
+	}
+	if r, _ := s.GetReceipt("tx1"); r != nil {
+		t.Errorf("Expected receipt of a removed block to be gone, got %v", r)
+	}
+	if latest, _ := s.GetLatestBlock(); latest == nil || latest.Height != 5 {
+		t.Errorf("Expected tip 5, got %v", latest)
+	}
+	state, err := s.GetPruneState()
+	if err != nil || state.BlockHeight != 3 {
+		t.Errorf("Expected blocks pruned below 3, got %+v (%v)", state, err)
+	}
+}
@@ -0,0 +1,330 @@
+// Package genesis loads the genesis file that defines a network: its chain
+// ID, genesis time, initial balances, initial validator set, system
+// contracts and consensus parameters.
+//
+// Every node of a network must start from the same file. The genesis block
+// commits to the file's hash, so nodes started from different files have
+// different genesis blocks and refuse each other during the peer handshake.
+package genesis
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"atlas-blockchain/pkg/block"
+	"atlas-blockchain/pkg/config"
+	"atlas-blockchain/pkg/database"
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/vm"
+)
+
+// DefaultChainID is the chain ID of the built-in development network, and
+// the ChainID of config.DefaultConfig
+const DefaultChainID = "atlas-devnet"
+
+// defaultTime is the genesis time of the built-in development network
+var defaultTime = time.Unix(1640995200, 0).UTC() // January 1, 2022 00:00:00 UTC
+
+// Genesis is the initial state of a network
+type Genesis struct {
+	ChainID     string           `json:"chain_id"`
+	GenesisTime time.Time        `json:"genesis_time"`
+	Alloc       []Allocation     `json:"alloc,omitempty"`
+	Validators  []Validator      `json:"validators,omitempty"`
+	Contracts   []Contract       `json:"contracts,omitempty"`
+	Consensus   *ConsensusParams `json:"consensus,omitempty"`
+}
+
+// Allocation is the initial balance of an account
+type Allocation struct {
+	Address string `json:"address"`
+	Balance int64  `json:"balance"`
+}
+
+// Validator is a member of the initial validator set
+type Validator struct {
+	Address string `json:"address"`
+	Stake   int64  `json:"stake"`
+}
+
+// Contract is a contract deployed at a fixed address in the genesis state
+type Contract struct {
+	Address    string          `json:"address"`
+	Owner      string          `json:"owner"`
+	Upgradable bool            `json:"upgradable,omitempty"`
+	Code       vm.JSONContract `json:"code"`
+}
+
+// ConsensusParams override the matching fields of config.DefaultConfig.
+// Zero values keep the configured value.
+type ConsensusParams struct {
+	BlockTime         Duration `json:"block_time,omitempty"`
+	MaxBlockSize      int      `json:"max_block_size,omitempty"`
+	MaxTxPoolSize     int      `json:"max_tx_pool_size,omitempty"`
+	MinStake          int      `json:"min_stake,omitempty"`
+	BlockReward       int      `json:"block_reward,omitempty"`
+	ValidatorRotation int      `json:"validator_rotation,omitempty"`
+	MaxValidators     int      `json:"max_validators,omitempty"`
+	SlashingPenalty   int      `json:"slashing_penalty,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "30s"
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %v", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Default returns the built-in development network genesis. Its block is the
+// genesis block of chains created before genesis files existed.
+func Default() *Genesis {
+	return &Genesis{ChainID: DefaultChainID, GenesisTime: defaultTime}
+}
+
+// Load reads a genesis file. Files ending in .yaml or .yml are read as YAML,
+// anything else as JSON.
+func Load(path string) (*Genesis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genesis file: %v", err)
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("invalid genesis file %s: %v", path, err)
+		}
+	}
+	g, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid genesis file %s: %v", path, err)
+	}
+	return g, nil
+}
+
+// Parse decodes and validates a JSON genesis document
+func Parse(data []byte) (*Genesis, error) {
+	var g Genesis
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&g); err != nil {
+		return nil, err
+	}
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// Validate checks that the genesis is complete and free of duplicates
+func (g *Genesis) Validate() error {
+	if g.ChainID == "" {
+		return errors.New("chain_id is required")
+	}
+	if g.GenesisTime.IsZero() {
+		return errors.New("genesis_time is required")
+	}
+	seen := make(map[string]bool)
+	for i, alloc := range g.Alloc {
+		if alloc.Address == "" {
+			return fmt.Errorf("alloc %d has no address", i)
+		}
+		if alloc.Balance < 0 {
+			return fmt.Errorf("alloc %s has a negative balance", alloc.Address)
+		}
+		if seen[alloc.Address] {
+			return fmt.Errorf("alloc %s appears twice", alloc.Address)
+		}
+		seen[alloc.Address] = true
+	}
+	seen = make(map[string]bool)
+	for i, v := range g.Validators {
+		if v.Address == "" {
+			return fmt.Errorf("validator %d has no address", i)
+		}
+		if v.Stake <= 0 {
+			return fmt.Errorf("validator %s needs a positive stake", v.Address)
+		}
+		if seen[v.Address] {
+			return fmt.Errorf("validator %s appears twice", v.Address)
+		}
+		seen[v.Address] = true
+	}
+	seen = make(map[string]bool)
+	for i, c := range g.Contracts {
+		if c.Address == "" {
+			return fmt.Errorf("contract %d has no address", i)
+		}
+		if c.Owner == "" {
+			return fmt.Errorf("contract %s has no owner", c.Address)
+		}
+		if seen[c.Address] {
+			return fmt.Errorf("contract %s appears twice", c.Address)
+		}
+		seen[c.Address] = true
+	}
+	if _, err := g.BuildContracts(); err != nil {
+		return err
+	}
+	if c := g.Consensus; c != nil {
+		if c.BlockTime < 0 || c.MaxBlockSize < 0 || c.MaxTxPoolSize < 0 || c.MinStake < 0 || c.BlockReward < 0 ||
+			c.ValidatorRotation < 0 || c.MaxValidators < 0 || c.SlashingPenalty < 0 {
+			return errors.New("consensus parameters cannot be negative")
+		}
+	}
+	return nil
+}
+
+// ApplyConfig sets the chain ID of cfg, overrides it with the consensus
+// parameters of the genesis and checks every genesis validator against the
+// resulting minimum stake
+func (g *Genesis) ApplyConfig(cfg *config.BlockchainConfig) error {
+	cfg.ChainID = g.ChainID
+	if c := g.Consensus; c != nil {
+		if c.BlockTime > 0 {
+			cfg.BlockTime = time.Duration(c.BlockTime)
+		}
+		if c.MaxBlockSize > 0 {
+			cfg.MaxBlockSize = c.MaxBlockSize
+		}
+		if c.MaxTxPoolSize > 0 {
+			cfg.MaxTxPoolSize = c.MaxTxPoolSize
+		}
+		if c.MinStake > 0 {
+			cfg.MinStake = c.MinStake
+		}
+		if c.BlockReward > 0 {
+			cfg.BlockReward = c.BlockReward
+		}
+		if c.ValidatorRotation > 0 {
+			cfg.ValidatorRotation = c.ValidatorRotation
+		}
+		if c.MaxValidators > 0 {
+			cfg.MaxValidators = c.MaxValidators
+		}
+		if c.SlashingPenalty > 0 {
+			cfg.SlashingPenalty = c.SlashingPenalty
+		}
+	}
+	if len(g.Validators) > cfg.MaxValidators {
+		return fmt.Errorf("genesis has %d validators, the limit is %d", len(g.Validators), cfg.MaxValidators)
+	}
+	for _, v := range g.Validators {
+		if v.Stake < int64(cfg.MinStake) {
+			return fmt.Errorf("validator %s stakes %d, below the minimum stake %d", v.Address, v.Stake, cfg.MinStake)
+		}
+	}
+	return nil
+}
+
+// Hash is the SHA-256 of the canonical JSON encoding of the genesis
+func (g *Genesis) Hash() string {
+	data, err := json.Marshal(g)
+	if err != nil {
+		// Every field of a validated genesis is encodable
+		panic(fmt.Sprintf("genesis: failed to encode genesis: %v", err))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// isDefault reports whether g is the built-in development network genesis
+func (g *Genesis) isDefault() bool {
+	return g.ChainID == DefaultChainID && g.GenesisTime.Equal(defaultTime) &&
+		len(g.Alloc) == 0 && len(g.Validators) == 0 && len(g.Contracts) == 0 && g.Consensus == nil
+}
+
+// Block returns the genesis block. Its previous hash is the genesis hash, so
+// the block identifies the whole genesis file. The built-in development
+// network keeps "0" so that existing chains stay valid.
+func (g *Genesis) Block() *block.Block {
+	prevHash := "0"
+	if !g.isDefault() {
+		prevHash = g.Hash()
+	}
+	genesisBlock := &block.Block{
+		Index:        0,
+		Timestamp:    g.GenesisTime.Unix(),
+		Transactions: []transaction.Transaction{},
+		PrevHash:     prevHash,
+		Validator:    "GENESIS_VALIDATOR",
+		Signature:    "GENESIS_SIGNATURE",
+	}
+	genesisBlock.Hash = block.CalculateHash(*genesisBlock)
+	return genesisBlock
+}
+
+// Accounts returns the genesis accounts: the allocations, with the stake of
+// genesis validators recorded on their accounts
+func (g *Genesis) Accounts() []*database.Account {
+	accounts := make(map[string]*database.Account)
+	var order []string
+	account := func(address string) *database.Account {
+		acct, ok := accounts[address]
+		if !ok {
+			acct = &database.Account{Address: address, CreatedAt: g.GenesisTime, UpdatedAt: g.GenesisTime}
+			accounts[address] = acct
+			order = append(order, address)
+		}
+		return acct
+	}
+	for _, alloc := range g.Alloc {
+		account(alloc.Address).Balance = alloc.Balance
+	}
+	for _, v := range g.Validators {
+		acct := account(v.Address)
+		acct.IsValidator = true
+		acct.StakedAmount = v.Stake
+	}
+
+	result := make([]*database.Account, 0, len(order))
+	for _, address := range order {
+		result = append(result, accounts[address])
+	}
+	return result
+}
+
+// BuildContracts deploys the genesis contracts at their addresses
+func (g *Genesis) BuildContracts() ([]*vm.Contract, error) {
+	contracts := make([]*vm.Contract, 0, len(g.Contracts))
+	for _, c := range g.Contracts {
+		code := c.Code
+		code.Storage = make(map[string]interface{}, len(c.Code.Storage))
+		for key, value := range c.Code.Storage {
+			code.Storage[key] = value
+		}
+		contract, err := vm.DeployJSONContract(c.Owner, &code, c.Upgradable)
+		if err != nil {
+			return nil, fmt.Errorf("contract %s: %v", c.Address, err)
+		}
+		contract.Address = c.Address
+		contract.CreatedAt = g.GenesisTime.Unix()
+		contract.UpdatedAt = contract.CreatedAt
+		for i := range contract.CodeHistory {
+			contract.CodeHistory[i].UpgradedAt = contract.CreatedAt
+		}
+		contracts = append(contracts, contract)
+	}
+	return contracts, nil
+}
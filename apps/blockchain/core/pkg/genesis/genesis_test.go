@@ -0,0 +1,261 @@
+package genesis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"atlas-blockchain/pkg/block"
+	"atlas-blockchain/pkg/config"
+)
+
+const testJSON = `{
+  "chain_id": "atlas-testnet",
+  "genesis_time": "2024-06-01T00:00:00Z",
+  "alloc": [
+    {"address": "alice", "balance": 1000000},
+    {"address": "bob", "balance": 250}
+  ],
+  "validators": [
+    {"address": "alice", "stake": 500},
+    {"address": "carol", "stake": 200}
+  ],
+  "contracts": [
+    {
+      "address": "0xc0ffee",
+      "owner": "alice",
+      "code": {
+        "name": "Registry",
+        "version": "1.0",
+        "contract_type": "system",
+        "storage": {"admin": "alice"},
+        "functions": {
+          "get": {"params": [], "code": [{"op": "LOAD", "key": "admin"}, {"op": "RETURN"}]}
+        }
+      }
+    }
+  ],
+  "consensus": {"block_time": "5s", "min_stake": 150, "block_reward": 20}
+}`
+
+// testYAML is testJSON written as YAML
+const testYAML = `# Atlas test network
+chain_id: atlas-testnet
+genesis_time: "2024-06-01T00:00:00Z"
+
+alloc:
+  - address: alice
+    balance: 1000000
+  - address: 'bob'
+    balance: 250   # spending money
+
+validators:
+- address: alice
+  stake: 500
+- address: carol
+  stake: 200
+
+contracts:
+  - address: "0xc0ffee"
+    owner: alice
+    code:
+      name: Registry
+      version: "1.0"
+      contract_type: system
+      storage:
+        admin: alice
+      functions:
+        get:
+          params: []
+          code:
+            - op: LOAD
+              key: admin
+            - {"op": "RETURN"}
+
+consensus:
+  block_time: 5s
+  min_stake: 150
+  block_reward: 20
+`
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	fromJSON, err := Load(writeFile(t, "genesis.json", testJSON))
+	if err != nil {
+		t.Fatalf("Loading JSON failed: %v", err)
+	}
+
+	t.Run("Fields", func(t *testing.T) {
+		if fromJSON.ChainID != "atlas-testnet" || !fromJSON.GenesisTime.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Unexpected chain %q at %v", fromJSON.ChainID, fromJSON.GenesisTime)
+		}
+		if len(fromJSON.Alloc) != 2 || fromJSON.Alloc[0].Balance != 1000000 || len(fromJSON.Validators) != 2 {
+			t.Errorf("Unexpected allocations %v and validators %v", fromJSON.Alloc, fromJSON.Validators)
+		}
+		if c := fromJSON.Consensus; c == nil || time.Duration(c.BlockTime) != 5*time.Second || c.MinStake != 150 {
+			t.Errorf("Unexpected consensus parameters %+v", c)
+		}
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		fromYAML, err := Load(writeFile(t, "genesis.yaml", testYAML))
+		if err != nil {
+			t.Fatalf("Loading YAML failed: %v", err)
+		}
+		if fromYAML.Hash() != fromJSON.Hash() {
+			t.Errorf("Expected the YAML and JSON files to describe the same genesis")
+		}
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		blk := fromJSON.Block()
+		if blk.Index != 0 || blk.PrevHash != fromJSON.Hash() || blk.Timestamp != fromJSON.GenesisTime.Unix() {
+			t.Errorf("Unexpected genesis block %+v", blk)
+		}
+		if blk.Hash != block.CalculateHash(*blk) {
+			t.Errorf("Genesis block hash does not verify")
+		}
+		other, _ := Parse([]byte(strings.Replace(testJSON, `"balance": 250`, `"balance": 251`, 1)))
+		if other == nil || other.Block().Hash == blk.Hash {
+			t.Errorf("Expected a different allocation to change the genesis block")
+		}
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		if got, want := Default().Block().Hash, block.CreateGenesisBlock().Hash; got != want {
+			t.Errorf("Expected the devnet genesis block %s, got %s", want, got)
+		}
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		if _, err := Load(filepath.Join(t.TempDir(), "none.json")); err == nil {
+			t.Errorf("Expected an error for a missing file")
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(string) string
+		wantErr string
+	}{
+		{"MissingChainID", func(s string) string { return strings.Replace(s, `"atlas-testnet"`, `""`, 1) }, "chain_id"},
+		{"MissingTime", func(s string) string { return strings.Replace(s, `"genesis_time": "2024-06-01T00:00:00Z",`, "", 1) }, "genesis_time"},
+		{"DuplicateAlloc", func(s string) string { return strings.Replace(s, `"address": "bob"`, `"address": "alice"`, 1) }, "twice"},
+		{"NegativeBalance", func(s string) string { return strings.Replace(s, `"balance": 250`, `"balance": -1`, 1) }, "negative"},
+		{"ZeroStake", func(s string) string { return strings.Replace(s, `"stake": 200`, `"stake": 0`, 1) }, "positive stake"},
+		{"UnknownField", func(s string) string { return strings.Replace(s, `"alloc"`, `"allocs"`, 1) }, "unknown field"},
+		{"BadContract", func(s string) string {
+			return strings.Replace(s, `"params": []`, `"inputs": [{"name": "x", "type": "bogus"}]`, 1)
+		}, "0xc0ffee"},
+		{"BadDuration", func(s string) string { return strings.Replace(s, `"5s"`, `"soon"`, 1) }, "duration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.edit(testJSON)))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	g, err := Parse([]byte(testJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Overrides", func(t *testing.T) {
+		cfg := config.DefaultConfig()
+		if err := g.ApplyConfig(cfg); err != nil {
+			t.Fatalf("ApplyConfig failed: %v", err)
+		}
+		defaults := config.DefaultConfig()
+		if cfg.ChainID != "atlas-testnet" || cfg.BlockTime != 5*time.Second || cfg.MinStake != 150 || cfg.BlockReward != 20 {
+			t.Errorf("Expected the genesis parameters, got %+v", cfg)
+		}
+		if cfg.MaxBlockSize != defaults.MaxBlockSize || cfg.SlashingPenalty != defaults.SlashingPenalty {
+			t.Errorf("Expected parameters missing from the genesis to keep their defaults")
+		}
+	})
+
+	t.Run("StakeBelowMinimum", func(t *testing.T) {
+		cfg := config.DefaultConfig()
+		strict := *g
+		strict.Consensus = &ConsensusParams{MinStake: 300}
+		if err := strict.ApplyConfig(cfg); err == nil || !strings.Contains(err.Error(), "carol") {
+			t.Errorf("Expected carol's stake to be refused, got %v", err)
+		}
+	})
+}
+
+func TestState(t *testing.T) {
+	g, err := Parse([]byte(testJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Accounts", func(t *testing.T) {
+		accounts := g.Accounts()
+		if len(accounts) != 3 {
+			t.Fatalf("Expected 3 accounts, got %d", len(accounts))
+		}
+		alice, bob, carol := accounts[0], accounts[1], accounts[2]
+		if alice.Address != "alice" || alice.Balance != 1000000 || !alice.IsValidator || alice.StakedAmount != 500 {
+			t.Errorf("Unexpected account %+v", alice)
+		}
+		if bob.Balance != 250 || bob.IsValidator {
+			t.Errorf("Unexpected account %+v", bob)
+		}
+		if carol.Balance != 0 || !carol.IsValidator || carol.StakedAmount != 200 {
+			t.Errorf("Unexpected account %+v", carol)
+		}
+	})
+
+	t.Run("Contracts", func(t *testing.T) {
+		contracts, err := g.BuildContracts()
+		if err != nil || len(contracts) != 1 {
+			t.Fatalf("Expected one contract, got %d (%v)", len(contracts), err)
+		}
+		c := contracts[0]
+		if c.Address != "0xc0ffee" || c.Owner != "alice" || c.Functions["get"] == nil || c.CreatedAt != g.GenesisTime.Unix() {
+			t.Errorf("Unexpected contract %+v", c)
+		}
+		c.Storage["admin"] = "mallory"
+		if again, _ := g.BuildContracts(); again[0].Storage["admin"] != "alice" {
+			t.Errorf("Expected every build to start from the genesis storage")
+		}
+	})
+}
+
+func TestYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{"Tabs", "chain_id: x\n\tgenesis_time: y\n"},
+		{"Indentation", "chain_id: x\n  genesis_time: y\n"},
+		{"DuplicateKey", "chain_id: x\nchain_id: y\n"},
+		{"NotAMapping", "chain_id x\n"},
+		{"BadFlow", "alloc: [{address: alice}]\n"},
+		{"Empty", "# nothing\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := yamlToJSON([]byte(tt.yaml)); err == nil {
+				t.Errorf("Expected an error for %q", tt.yaml)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package genesis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The YAML reader handles the subset of YAML that genesis files need: block
+// mappings and sequences nested by indentation, plain, quoted and JSON flow
+// scalars, and comments. Anchors, tags, multi-line strings and multiple
+// documents are not supported.
+
+var yamlNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+type yamlLine struct {
+	number int // 1-based line number in the file
+	indent int
+	text   string
+}
+
+// yamlToJSON converts a YAML document into the equivalent JSON
+func yamlToJSON(data []byte) ([]byte, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, " \r")
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs cannot be used for indentation", i+1)
+		}
+		if text = stripComment(text); text == "" || text == "---" {
+			continue
+		}
+		lines = append(lines, yamlLine{number: i + 1, indent: len(raw) - len(strings.TrimLeft(raw, " ")), text: text})
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("empty document")
+	}
+
+	p := &yamlParser{lines: lines}
+	value, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].number)
+	}
+	return json.Marshal(value)
+}
+
+// stripComment removes a trailing comment outside of quotes
+func stripComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' '):
+			return strings.TrimRight(text[:i], " ")
+		}
+	}
+	return text
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// block parses the mapping or sequence starting at the current line
+func (p *yamlParser) block(indent int) (interface{}, error) {
+	if isSequenceItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		// A list at the indentation of its key ends at the next key
+		if line.indent < indent || (line.indent == indent && !isSequenceItem(line.text)) {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.number)
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if rest == "" {
+			p.pos++
+			item, err := p.nested(indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		if _, _, ok := splitKey(rest); ok || isSequenceItem(rest) {
+			// "- key: value" starts a mapping indented past the dash
+			p.lines[p.pos] = yamlLine{number: line.number, indent: line.indent + len(line.text) - len(rest), text: rest}
+			item, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		item, err := scalar(rest, line.number)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		p.pos++
+	}
+	return items, nil
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	values := make(map[string]interface{})
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.number)
+		}
+		key, rest, ok := splitKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.number)
+		}
+		if _, exists := values[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.number, key)
+		}
+		p.pos++
+		if rest != "" {
+			value, err := scalar(rest, line.number)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+			continue
+		}
+		// A list may sit at the same indentation as its key
+		if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].text) {
+			value, err := p.sequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+			continue
+		}
+		value, err := p.nested(indent)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// nested parses the block indented under the line before the current one,
+// or returns null when there is none
+func (p *yamlParser) nested(indent int) (interface{}, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+		return nil, nil
+	}
+	return p.block(p.lines[p.pos].indent)
+}
+
+// splitKey splits "key: value" into its parts
+func splitKey(text string) (string, string, bool) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 {
+			return "", "", false
+		}
+		key, after := text[1:end+1], text[end+2:]
+		if !strings.HasPrefix(after, ":") || (len(after) > 1 && after[1] != ' ') {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(after[1:]), true
+	}
+	if text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i == len(text)-1 || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), i > 0
+		}
+	}
+	return "", "", false
+}
+
+// scalar converts a value written on one line
+func scalar(text string, number int) (interface{}, error) {
+	switch {
+	case text[0] == '"':
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", number, text)
+		}
+		return s, nil
+	case text[0] == '\'':
+		if len(text) < 2 || text[len(text)-1] != '\'' {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", number, text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case text[0] == '[' || text[0] == '{':
+		// Flow collections are read as JSON
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader([]byte(text)))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("line %d: flow values must be valid JSON: %v", number, err)
+		}
+		return value, nil
+	case text == "null" || text == "~":
+		return nil, nil
+	case text == "true":
+		return true, nil
+	case text == "false":
+		return false, nil
+	case yamlNumber.MatchString(text):
+		return json.Number(text), nil
+	}
+	return text, nil
+}
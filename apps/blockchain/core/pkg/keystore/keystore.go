@@ -0,0 +1,361 @@
+// Package keystore keeps validator and node private keys on disk encrypted
+// with a password.
+//
+// Each key is a JSON file named after its address. The private key is
+// encrypted with AES-128-CTR under a key derived from the password with
+// scrypt, and an HMAC-SHA256 over the address, key type, signature scheme
+// and ciphertext
+// detects a wrong password or a modified file before the key is used.
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"atlas-blockchain/pkg/transaction"
+	"atlas-blockchain/pkg/wallet"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Version is the version of the key file format
+const Version = 1
+
+// Key types
+const (
+	TypeValidator = "validator" // wallet key, encoded as wallet.ImportWalletWithScheme expects
+	TypeNode      = "node"      // libp2p identity key, encoded as in nodekey.priv
+)
+
+var (
+	ErrWrongPassword = errors.New("wrong password or corrupted key file")
+	ErrKeyNotFound   = errors.New("key not found in keystore")
+	ErrKeyExists     = errors.New("key already exists in keystore")
+)
+
+// ScryptParams are the cost parameters of the password key derivation
+type ScryptParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+var (
+	// StandardScrypt takes about a second and 256 MB per derivation
+	StandardScrypt = ScryptParams{N: 1 << 18, R: 8, P: 1}
+	// LightScrypt is for tests and constrained devices
+	LightScrypt = ScryptParams{N: 1 << 12, R: 8, P: 6}
+)
+
+// Key is a decrypted private key
+type Key struct {
+	Address    string
+	Type       string
+	Scheme     string // Signature scheme of a validator key, empty for P-256
+	PrivateKey []byte
+}
+
+// NewValidatorKey returns the key of a wallet
+func NewValidatorKey(w *wallet.Wallet) (*Key, error) {
+	privateKey, err := w.PrivateKeyBytes()
+	if err != nil {
+		return nil, err
+	}
+	key := &Key{Address: wallet.PublicKeyToAddress(w.PublicKey), Type: TypeValidator, PrivateKey: privateKey}
+	if w.SchemeName() != transaction.SchemeP256 {
+		key.Scheme = w.SchemeName()
+	}
+	return key, nil
+}
+
+// NewNodeKey returns the key of a libp2p identity, as stored in nodekey.priv.
+// Its address is the peer ID.
+func NewNodeKey(data []byte) (*Key, error) {
+	privKey, err := crypto.UnmarshalPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node key: %v", err)
+	}
+	id, err := peer.IDFromPrivateKey(privKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node key: %v", err)
+	}
+	return &Key{Address: id.String(), Type: TypeNode, PrivateKey: data}, nil
+}
+
+// Wallet returns the wallet of a validator key
+func (k *Key) Wallet() (*wallet.Wallet, error) {
+	if k.Type != TypeValidator {
+		return nil, fmt.Errorf("%s is a %s key, not a validator key", k.Address, k.Type)
+	}
+	return wallet.ImportWalletWithScheme(k.Scheme, hex.EncodeToString(k.PrivateKey))
+}
+
+// NodeKey returns the libp2p identity of a node key
+func (k *Key) NodeKey() (crypto.PrivKey, error) {
+	if k.Type != TypeNode {
+		return nil, fmt.Errorf("%s is a %s key, not a node key", k.Address, k.Type)
+	}
+	return crypto.UnmarshalPrivateKey(k.PrivateKey)
+}
+
+type keyFile struct {
+	Version int        `json:"version"`
+	Address string     `json:"address"`
+	Type    string     `json:"type"`
+	Scheme  string     `json:"scheme,omitempty"`
+	Crypto  cryptoJSON `json:"crypto"`
+}
+
+type cryptoJSON struct {
+	Cipher       string       `json:"cipher"`
+	CipherText   string       `json:"ciphertext"`
+	CipherParams cipherParams `json:"cipherparams"`
+	KDF          string       `json:"kdf"`
+	KDFParams    kdfParams    `json:"kdfparams"`
+	MAC          string       `json:"mac"`
+}
+
+type cipherParams struct {
+	IV string `json:"iv"`
+}
+
+type kdfParams struct {
+	ScryptParams
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+// Encrypt encodes a key as an encrypted key file
+func Encrypt(key *Key, password string, params ScryptParams) ([]byte, error) {
+	salt := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("failed to generate IV: %v", err)
+	}
+	derived, err := scrypt.Key([]byte(password), salt, params.N, params.R, params.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %v", err)
+	}
+	ciphertext, err := aesCTR(derived[:16], iv, key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	file := keyFile{
+		Version: Version,
+		Address: key.Address,
+		Type:    key.Type,
+		Scheme:  key.Scheme,
+		Crypto: cryptoJSON{
+			Cipher:       "aes-128-ctr",
+			CipherText:   hex.EncodeToString(ciphertext),
+			CipherParams: cipherParams{IV: hex.EncodeToString(iv)},
+			KDF:          "scrypt",
+			KDFParams:    kdfParams{ScryptParams: params, DKLen: 32, Salt: hex.EncodeToString(salt)},
+			MAC:          hex.EncodeToString(keyMAC(derived, key.Address, key.Type, key.Scheme, ciphertext)),
+		},
+	}
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// Decrypt decodes an encrypted key file
+func Decrypt(data []byte, password string) (*Key, error) {
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid key file: %v", err)
+	}
+	if file.Version != Version {
+		return nil, fmt.Errorf("unsupported key file version %d", file.Version)
+	}
+	c := file.Crypto
+	if c.Cipher != "aes-128-ctr" || c.KDF != "scrypt" || c.KDFParams.DKLen != 32 {
+		return nil, fmt.Errorf("unsupported key encryption %s/%s", c.KDF, c.Cipher)
+	}
+	salt, err1 := hex.DecodeString(c.KDFParams.Salt)
+	iv, err2 := hex.DecodeString(c.CipherParams.IV)
+	ciphertext, err3 := hex.DecodeString(c.CipherText)
+	mac, err4 := hex.DecodeString(c.MAC)
+	if err := errors.Join(err1, err2, err3, err4); err != nil {
+		return nil, fmt.Errorf("invalid key file: %v", err)
+	}
+
+	p := c.KDFParams.ScryptParams
+	derived, err := scrypt.Key([]byte(password), salt, p.N, p.R, p.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %v", err)
+	}
+	if !hmac.Equal(mac, keyMAC(derived, file.Address, file.Type, file.Scheme, ciphertext)) {
+		return nil, ErrWrongPassword
+	}
+	plaintext, err := aesCTR(derived[:16], iv, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{Address: file.Address, Type: file.Type, Scheme: file.Scheme, PrivateKey: plaintext}, nil
+}
+
+// keyMAC authenticates the address, type, scheme and ciphertext with the
+// second half of the derived key. P-256 keys have no scheme, which keeps the
+// MAC of files written before schemes existed.
+func keyMAC(derived []byte, address, keyType, scheme string, ciphertext []byte) []byte {
+	mac := hmac.New(sha256.New, derived[16:32])
+	mac.Write([]byte(address + "\x00" + keyType + "\x00"))
+	if scheme != "" {
+		mac.Write([]byte(scheme + "\x00"))
+	}
+	mac.Write(ciphertext)
+	return mac.Sum(nil)
+}
+
+func aesCTR(key, iv, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid IV length %d", len(iv))
+	}
+	out := make([]byte, len(data))
+	cipher.NewCTR(block, iv).XORKeyStream(out, data)
+	return out, nil
+}
+
+// Keystore is a directory of encrypted key files
+type Keystore struct {
+	dir string
+	// Scrypt is the cost of keys written from now on; existing keys keep
+	// the parameters they were written with
+	Scrypt ScryptParams
+}
+
+// Entry describes a stored key without decrypting it
+type Entry struct {
+	Address string `json:"address"`
+	Type    string `json:"type"`
+	Scheme  string `json:"scheme,omitempty"`
+	Path    string `json:"path"`
+}
+
+// Open opens the keystore in dir, creating the directory if needed
+func Open(dir string) (*Keystore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create keystore directory: %v", err)
+	}
+	return &Keystore{dir: dir, Scrypt: StandardScrypt}, nil
+}
+
+// Dir returns the keystore directory
+func (ks *Keystore) Dir() string {
+	return ks.dir
+}
+
+// path is the file of an address
+func (ks *Keystore) path(address string) (string, error) {
+	if address == "" || strings.ContainsAny(address, `/\`) || address == "." || address == ".." {
+		return "", fmt.Errorf("invalid address %q", address)
+	}
+	return filepath.Join(ks.dir, address+".json"), nil
+}
+
+// Store encrypts a new key with password
+func (ks *Keystore) Store(key *Key, password string) (string, error) {
+	path, err := ks.path(key.Address)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%w: %s", ErrKeyExists, key.Address)
+	}
+	if err := ks.write(path, key, password); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// write replaces the file at path atomically
+func (ks *Keystore) write(path string, key *Key, password string) error {
+	data, err := Encrypt(key, password, ks.Scrypt)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write key file: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write key file: %v", err)
+	}
+	return nil
+}
+
+// Load decrypts the key of an address
+func (ks *Keystore) Load(address, password string) (*Key, error) {
+	path, err := ks.path(address)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	key, err := Decrypt(data, password)
+	if err != nil {
+		return nil, err
+	}
+	if key.Address != address {
+		return nil, fmt.Errorf("key file %s holds the key of %s", path, key.Address)
+	}
+	return key, nil
+}
+
+// ChangePassword re-encrypts the key of an address with a new password
+func (ks *Keystore) ChangePassword(address, oldPassword, newPassword string) error {
+	key, err := ks.Load(address, oldPassword)
+	if err != nil {
+		return err
+	}
+	path, _ := ks.path(address)
+	return ks.write(path, key, newPassword)
+}
+
+// List returns the stored keys sorted by address
+func (ks *Keystore) List() ([]Entry, error) {
+	paths, err := filepath.Glob(filepath.Join(ks.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	entries := []Entry{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %v", err)
+		}
+		var file keyFile
+		if err := json.Unmarshal(data, &file); err != nil || file.Address == "" {
+			continue // not a key file
+		}
+		entries = append(entries, Entry{Address: file.Address, Type: file.Type, Scheme: file.Scheme, Path: path})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Address < entries[j].Address })
+	return entries, nil
+}
@@ -0,0 +1,184 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"atlas-blockchain/pkg/wallet"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func newTestKeystore(t *testing.T) *Keystore {
+	ks, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	ks.Scrypt = LightScrypt
+	return ks
+}
+
+func TestKeystore(t *testing.T) {
+	ks := newTestKeystore(t)
+	w, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewValidatorKey(w)
+	if err != nil {
+		t.Fatalf("NewValidatorKey failed: %v", err)
+	}
+	path, err := ks.Store(key, "correct horse")
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	t.Run("Encrypted", func(t *testing.T) {
+		data, _ := os.ReadFile(path)
+		if bytes.Contains(data, key.PrivateKey) || strings.Contains(string(data), hex.EncodeToString(key.PrivateKey)) {
+			t.Errorf("Expected the key file not to contain the private key")
+		}
+		if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+			t.Errorf("Expected the key file to be private, got %v", info.Mode().Perm())
+		}
+	})
+
+	t.Run("Unlock", func(t *testing.T) {
+		loaded, err := ks.Load(key.Address, "correct horse")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		unlocked, err := loaded.Wallet()
+		if err != nil || !bytes.Equal(unlocked.PublicKey, w.PublicKey) {
+			t.Errorf("Expected the stored wallet back, got %v", err)
+		}
+	})
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		if _, err := ks.Load(key.Address, "battery staple"); !errors.Is(err, ErrWrongPassword) {
+			t.Errorf("Expected ErrWrongPassword, got %v", err)
+		}
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		data, _ := os.ReadFile(path)
+		tampered := strings.Replace(string(data), `"type": "validator"`, `"type": "node"`, 1)
+		if _, err := Decrypt([]byte(tampered), "correct horse"); !errors.Is(err, ErrWrongPassword) {
+			t.Errorf("Expected a changed key type to be detected, got %v", err)
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		if _, err := ks.Store(key, "other"); !errors.Is(err, ErrKeyExists) {
+			t.Errorf("Expected ErrKeyExists, got %v", err)
+		}
+	})
+
+	t.Run("ChangePassword", func(t *testing.T) {
+		if err := ks.ChangePassword(key.Address, "wrong", "new"); !errors.Is(err, ErrWrongPassword) {
+			t.Errorf("Expected the old password to be checked, got %v", err)
+		}
+		if err := ks.ChangePassword(key.Address, "correct horse", "new"); err != nil {
+			t.Fatalf("ChangePassword failed: %v", err)
+		}
+		if _, err := ks.Load(key.Address, "correct horse"); !errors.Is(err, ErrWrongPassword) {
+			t.Errorf("Expected the old password to stop working")
+		}
+		if _, err := ks.Load(key.Address, "new"); err != nil {
+			t.Errorf("Expected the new password to work, got %v", err)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		if _, err := ks.Load("0x00", "new"); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Expected ErrKeyNotFound, got %v", err)
+		}
+		if _, err := ks.Load("../etc", "new"); err == nil {
+			t.Errorf("Expected an address with a path to be refused")
+		}
+	})
+}
+
+func TestSchemeKeys(t *testing.T) {
+	ks := newTestKeystore(t)
+	for _, scheme := range []string{"secp256k1", "ed25519"} {
+		t.Run(scheme, func(t *testing.T) {
+			w, err := wallet.NewWalletWithScheme(scheme)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, err := NewValidatorKey(w)
+			if err != nil || key.Scheme != scheme {
+				t.Fatalf("Expected a %s validator key, got %+v (%v)", scheme, key, err)
+			}
+			path, err := ks.Store(key, "pw")
+			if err != nil {
+				t.Fatalf("Store failed: %v", err)
+			}
+			loaded, err := ks.Load(key.Address, "pw")
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			unlocked, err := loaded.Wallet()
+			if err != nil || unlocked.Scheme != scheme || !bytes.Equal(unlocked.PublicKey, w.PublicKey) {
+				t.Errorf("Expected the stored %s wallet back, got %v", scheme, err)
+			}
+
+			data, _ := os.ReadFile(path)
+			tampered := strings.Replace(string(data), `"scheme": "`+scheme+`"`, `"scheme": "p256"`, 1)
+			if _, err := Decrypt([]byte(tampered), "pw"); !errors.Is(err, ErrWrongPassword) {
+				t.Errorf("Expected a changed scheme to be detected, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNodeKey(t *testing.T) {
+	ks := newTestKeystore(t)
+	privKey, _, err := crypto.GenerateKeyPair(crypto.Ed25519, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := crypto.MarshalPrivateKey(privKey)
+	key, err := NewNodeKey(data)
+	if err != nil {
+		t.Fatalf("NewNodeKey failed: %v", err)
+	}
+	if _, err := ks.Store(key, "pw"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	t.Run("Unlock", func(t *testing.T) {
+		loaded, err := ks.Load(key.Address, "pw")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		unlocked, err := loaded.NodeKey()
+		if err != nil || !unlocked.Equals(privKey) {
+			t.Errorf("Expected the stored node key back, got %v", err)
+		}
+		if _, err := loaded.Wallet(); err == nil {
+			t.Errorf("Expected a node key not to open as a wallet")
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		w, _ := wallet.NewWallet()
+		validator, _ := NewValidatorKey(w)
+		ks.Store(validator, "pw")
+		os.WriteFile(ks.Dir()+"/notes.json", []byte(`{"hello": 1}`), 0600)
+
+		entries, err := ks.List()
+		if err != nil || len(entries) != 2 {
+			t.Fatalf("Expected 2 keys, got %v (%v)", entries, err)
+		}
+		types := map[string]string{entries[0].Address: entries[0].Type, entries[1].Address: entries[1].Type}
+		if types[key.Address] != TypeNode || types[validator.Address] != TypeValidator {
+			t.Errorf("Unexpected entries %+v", entries)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// HandshakeProtocolID is the protocol peers use to compare networks before
+// exchanging messages
+const HandshakeProtocolID = "/blockchain/handshake/1.0.0"
+
+const handshakeTimeout = 10 * time.Second
+
+// Handshake identifies the network a node belongs to
+type Handshake struct {
+	ChainID     string `json:"chain_id"`
+	GenesisHash string `json:"genesis_hash"`
+}
+
+// Check returns why a peer announcing remote is not on the same network
+func (h Handshake) Check(remote Handshake) error {
+	if remote.ChainID != h.ChainID {
+		return fmt.Errorf("peer is on chain %q, not %q", remote.ChainID, h.ChainID)
+	}
+	if remote.GenesisHash != h.GenesisHash {
+		return fmt.Errorf("peer has genesis block %s, not %s", remote.GenesisHash, h.GenesisHash)
+	}
+	return nil
+}
+
+// SetHandshake makes the node exchange h with every peer it connects to.
+// Peers on another network are disconnected, and no messages are sent to or
+// accepted from a peer until its handshake has succeeded.
+func (node *P2PNode) SetHandshake(h Handshake) {
+	node.peersMu.Lock()
+	node.handshake = &h
+	node.verified = make(map[peer.ID]bool)
+	node.peersMu.Unlock()
+
+	node.Host.SetStreamHandler(HandshakeProtocolID, node.handleHandshake)
+	node.Host.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(_ network.Network, conn network.Conn) {
+			go node.VerifyPeer(context.Background(), conn.RemotePeer())
+		},
+		DisconnectedF: func(n network.Network, conn network.Conn) {
+			if n.Connectedness(conn.RemotePeer()) != network.Connected {
+				node.peersMu.Lock()
+				delete(node.verified, conn.RemotePeer())
+				node.peersMu.Unlock()
+			}
+		},
+	})
+}
+
+// VerifyPeer runs the handshake with a peer unless it already succeeded. A
+// peer on another network is disconnected.
+func (node *P2PNode) VerifyPeer(ctx context.Context, peerID peer.ID) error {
+	node.peersMu.Lock()
+	h, verified := node.handshake, node.verified[peerID]
+	node.peersMu.Unlock()
+	if h == nil || verified {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	defer cancel()
+	s, err := node.Host.NewStream(ctx, peerID, HandshakeProtocolID)
+	if err != nil {
+		return node.rejectPeer(peerID, fmt.Errorf("handshake failed: %v", err))
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	var remote Handshake
+	if err := json.NewEncoder(s).Encode(h); err != nil {
+		return node.rejectPeer(peerID, fmt.Errorf("handshake failed: %v", err))
+	}
+	if err := json.NewDecoder(s).Decode(&remote); err != nil {
+		return node.rejectPeer(peerID, fmt.Errorf("handshake failed: %v", err))
+	}
+	return node.acceptPeer(peerID, remote)
+}
+
+// handleHandshake answers a handshake started by a peer
+func (node *P2PNode) handleHandshake(s network.Stream) {
+	defer s.Close()
+	peerID := s.Conn().RemotePeer()
+	s.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	var remote Handshake
+	if err := json.NewDecoder(s).Decode(&remote); err != nil {
+		node.rejectPeer(peerID, fmt.Errorf("handshake failed: %v", err))
+		return
+	}
+	node.peersMu.Lock()
+	h := *node.handshake
+	node.peersMu.Unlock()
+	// Always answer, so the peer can tell why it was refused
+	if err := json.NewEncoder(s).Encode(h); err != nil {
+		node.rejectPeer(peerID, fmt.Errorf("handshake failed: %v", err))
+		return
+	}
+	node.acceptPeer(peerID, remote)
+}
+
+func (node *P2PNode) acceptPeer(peerID peer.ID, remote Handshake) error {
+	node.peersMu.Lock()
+	err := node.handshake.Check(remote)
+	if err == nil {
+		node.verified[peerID] = true
+	}
+	node.peersMu.Unlock()
+	if err != nil {
+		return node.rejectPeer(peerID, err)
+	}
+	log.Printf("[P2P] 🤝 Handshake with peer %s succeeded", peerID.String())
+	return nil
+}
+
+func (node *P2PNode) rejectPeer(peerID peer.ID, err error) error {
+	node.peersMu.Lock()
+	delete(node.verified, peerID)
+	node.peersMu.Unlock()
+	log.Printf("[P2P] ⛔ Refusing peer %s: %v", peerID.String(), err)
+	node.Host.Network().ClosePeer(peerID)
+	return err
+}
@@ -243,14 +243,9 @@ func ValidateChain(chain []*block.Block) bool {
 		}
 
 		// Verify all transactions in the block
-		for _, tx := range currentBlock.Transactions {
-			if tx.Sender != "network" { // Skip network reward transactions
-				valid, err := wallet.VerifyTransactionSignature(tx)
-				if err != nil || !valid {
-					fmt.Printf("Chain validation failed: Invalid transaction in block %d\n", i)
-					return false
-				}
-			}
+		if err := block.VerifyTransactions(currentBlock.Transactions, wallet.VerifiedSignatures); err != nil {
+			fmt.Printf("Chain validation failed: Invalid transaction in block %d: %v\n", i, err)
+			return false
 		}
 	}
 	return true
@@ -2,6 +2,7 @@ package network
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/host"
@@ -13,6 +14,7 @@ import (
 	"io/ioutil"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"runtime/debug"
+	"sync"
 )
 
 const ProtocolID = "/blockchain/1.0.0"
@@ -25,6 +27,11 @@ const ProtocolID = "/blockchain/1.0.0"
 	OnBlockReceived func(block BlockMessage)
 	OnTransactionReceived func(tx TransactionMessage)
 	OnValidatorRegistrationReceived func(reg ValidatorRegistrationMessage) // New callback
+
+	// Network identity checked with every peer, see SetHandshake
+	peersMu   sync.Mutex
+	handshake *Handshake
+	verified  map[peer.ID]bool
 }
 
 // loadOrCreatePrivKey loads a private key from file or generates and saves a new one
@@ -56,6 +63,32 @@ func loadOrCreatePrivKey(path string) (crypto.PrivKey, error) {
 	return privKey, nil
 }
 
+// NodeSigningKey loads or creates the node key at path as an Ed25519 key for
+// signing data outside the p2p protocol, such as backups
+func NodeSigningKey(path string) (ed25519.PrivateKey, error) {
+	privKey, err := loadOrCreatePrivKey(path)
+	if err != nil {
+		return nil, err
+	}
+	signingKey, err := SigningKey(privKey)
+	if err != nil {
+		return nil, fmt.Errorf("node key %s: %v", path, err)
+	}
+	return signingKey, nil
+}
+
+// SigningKey returns a node key as an Ed25519 signing key
+func SigningKey(privKey crypto.PrivKey) (ed25519.PrivateKey, error) {
+	if privKey.Type() != crypto.Ed25519 {
+		return nil, fmt.Errorf("node key is not an Ed25519 key")
+	}
+	raw, err := privKey.Raw()
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.PrivateKey(raw), nil
+}
+
 // NewP2PNode creates and starts a new libp2p node listening on the given port, using a persistent private key
 func NewP2PNode(ctx context.Context, listenPort int, keyPath ...string) (*P2PNode, error) {
 	log.Printf("[DEBUG] NewP2PNode called with listenPort=%d, keyPath=%v", listenPort, keyPath)
@@ -74,6 +107,13 @@ func NewP2PNode(ctx context.Context, listenPort int, keyPath ...string) (*P2PNod
 			return nil, err
 		}
 	}
+	return NewP2PNodeWithKey(ctx, listenPort, privKey)
+}
+
+// NewP2PNodeWithKey creates and starts a libp2p node with the given identity,
+// such as a node key unlocked from the keystore. A nil key gives a random
+// identity.
+func NewP2PNodeWithKey(ctx context.Context, listenPort int, privKey crypto.PrivKey) (*P2PNode, error) {
 	opts := []libp2p.Option{
 		libp2p.ListenAddrStrings(fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", listenPort)),
 	}
@@ -100,6 +140,10 @@ func (node *P2PNode) RegisterStreamHandler() {
         remotePeer := s.Conn().RemotePeer()
         log.Printf("[P2P] Stream handler triggered from peer: %s", remotePeer.String())
         defer s.Close()
+        if err := node.VerifyPeer(context.Background(), remotePeer); err != nil {
+            log.Printf("[P2P] Dropping message from unverified peer %s", remotePeer.String())
+            return
+        }
         var msg NetworkMessage
         decoder := json.NewDecoder(s)
         if err := decoder.Decode(&msg); err != nil {
@@ -137,6 +181,9 @@ func (node *P2PNode) RegisterStreamHandler() {
 // SendMessage sends a NetworkMessage to the given peer over a new libp2p stream
 func (node *P2PNode) SendMessage(ctx context.Context, peerID peer.ID, msg NetworkMessage) error {
     log.Printf("[P2P] Attempting to send message of type: %s to peer: %s", msg.Type, peerID.String())
+    if err := node.VerifyPeer(ctx, peerID); err != nil {
+        return err
+    }
     s, err := node.Host.NewStream(ctx, peerID, ProtocolID)
     if err != nil {
         log.Printf("[P2P] Error opening stream to peer: %v", err)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"testing"
 	"time"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"encoding/json"
 )
@@ -79,4 +80,70 @@ func TestP2PBasicMessageExchange(t *testing.T) {
 // EncodePayload marshals a struct to json.RawMessage
 func EncodePayload(v interface{}) ([]byte, error) {
 	return json.Marshal(v)
-} 
\ No newline at end of file
+} 
+func TestHandshake(t *testing.T) {
+	ctx := context.Background()
+	testnet := Handshake{ChainID: "atlas-testnet", GenesisHash: "aaaa"}
+	start := func(port int, h Handshake) (*P2PNode, chan NetworkMessage) {
+		node, err := NewP2PNode(ctx, port)
+		if err != nil {
+			t.Fatalf("Failed to start node on port %d: %v", port, err)
+		}
+		t.Cleanup(func() { node.Host.Close() })
+		node.SetHandshake(h)
+		node.RegisterStreamHandler()
+		received := make(chan NetworkMessage, 1)
+		node.HandleIncomingMessage = func(msg NetworkMessage) { received <- msg }
+		return node, received
+	}
+	connect := func(from, to *P2PNode) {
+		if err := from.Host.Connect(ctx, peer.AddrInfo{ID: to.Host.ID(), Addrs: to.Host.Addrs()}); err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+	}
+	msg := NetworkMessage{Type: MsgTypePeerInfo, Payload: []byte(`{}`)}
+
+	nodeA, receivedA := start(9011, testnet)
+
+	t.Run("SameNetwork", func(t *testing.T) {
+		nodeB, _ := start(9012, testnet)
+		connect(nodeB, nodeA)
+		if err := nodeB.SendMessage(ctx, nodeA.Host.ID(), msg); err != nil {
+			t.Fatalf("Expected the message to be sent, got %v", err)
+		}
+		select {
+		case <-receivedA:
+		case <-time.After(3 * time.Second):
+			t.Fatal("Timed out waiting for message receipt")
+		}
+	})
+
+	for _, tt := range []struct {
+		name string
+		port int
+		h    Handshake
+	}{
+		{"OtherChainID", 9013, Handshake{ChainID: "atlas-mainnet", GenesisHash: "aaaa"}},
+		{"OtherGenesis", 9014, Handshake{ChainID: "atlas-testnet", GenesisHash: "bbbb"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeC, _ := start(tt.port, tt.h)
+			connect(nodeC, nodeA)
+			if err := nodeC.SendMessage(ctx, nodeA.Host.ID(), msg); err == nil {
+				t.Errorf("Expected the handshake to refuse the peer")
+			}
+			select {
+			case <-receivedA:
+				t.Errorf("Expected no message from a peer on another network")
+			case <-time.After(500 * time.Millisecond):
+			}
+			deadline := time.Now().Add(3 * time.Second)
+			for nodeA.Host.Network().Connectedness(nodeC.Host.ID()) == network.Connected && time.Now().Before(deadline) {
+				time.Sleep(50 * time.Millisecond)
+			}
+			if nodeA.Host.Network().Connectedness(nodeC.Host.ID()) == network.Connected {
+				t.Errorf("Expected the peer to be disconnected")
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+// Package ratelimit limits how much of the API each client may use.
+//
+// Routes belong to a Class with its own token bucket, optional quota and
+// maximum body size. Clients are identified by API key when they send a
+// valid one and by remote address otherwise, so every client has its own
+// budget per class. Rejected requests get a Retry-After header and are
+// counted per class and reason.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Class is the budget of a group of routes, given to each client
+type Class struct {
+	Name        string
+	Rate        float64       // Requests per second in the long run
+	Burst       int           // Requests a client may make at once
+	Quota       int           // Requests per QuotaWindow, 0 for no quota
+	QuotaWindow time.Duration // Window of the quota, which restarts with the first request after it
+	MaxBody     int64         // Largest request body in bytes, 0 for no limit
+}
+
+// Reasons of rejections
+const (
+	ReasonRate  = "rate"  // Token bucket empty
+	ReasonQuota = "quota" // Quota of the window used up
+	ReasonBody  = "body"  // Request body too large
+)
+
+// Rejection errors
+var (
+	ErrRateLimited   = errors.New("rate limit exceeded")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+// DefaultKeyFactor scales the rate and burst of clients with an API key
+const DefaultKeyFactor = 10
+
+// sweepInterval is how often buckets of idle clients are dropped
+const sweepInterval = time.Minute
+
+// bucket is the state of one client in one class
+type bucket struct {
+	tokens      float64
+	last        time.Time
+	count       int // Requests in the quota window
+	windowStart time.Time
+}
+
+type bucketKey struct {
+	class  string
+	client string
+}
+
+// Limiter enforces the budgets of its classes per client
+type Limiter struct {
+	// KeyFactor multiplies the rate and burst of API key clients; quotas
+	// are the same for every client
+	KeyFactor float64
+	// APIKey returns the ID of the valid API key a request carries, "" for
+	// none. Without it every client is identified by address.
+	APIKey func(*http.Request) string
+	// OnReject is called after each rejection with the number of
+	// rejections of the class and reason so far
+	OnReject func(class, reason string, count uint64)
+
+	mu        sync.Mutex
+	classes   map[string]Class
+	buckets   map[bucketKey]*bucket
+	rejected  map[string]map[string]uint64
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLimiter creates a limiter with the given classes
+func NewLimiter(classes ...Class) *Limiter {
+	l := &Limiter{
+		KeyFactor: DefaultKeyFactor,
+		classes:   make(map[string]Class),
+		buckets:   make(map[bucketKey]*bucket),
+		rejected:  make(map[string]map[string]uint64),
+		now:       time.Now,
+	}
+	for _, c := range classes {
+		l.classes[c.Name] = c
+	}
+	l.lastSweep = l.now()
+	return l
+}
+
+// Class returns the class of a name
+func (l *Limiter) Class(name string) (Class, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.classes[name]
+	return c, ok
+}
+
+// Allow takes one request of client from the budget of class. When the
+// budget is used up it returns how long the client should wait. Requests
+// of unknown classes are always allowed.
+func (l *Limiter) Allow(class, client string) (time.Duration, error) {
+	wait, err := l.take(class, client)
+	if err != nil {
+		reason := ReasonRate
+		if errors.Is(err, ErrQuotaExceeded) {
+			reason = ReasonQuota
+		}
+		l.reject(class, reason)
+	}
+	return wait, err
+}
+
+func (l *Limiter) take(class, client string) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.classes[class]
+	if !ok {
+		return 0, nil
+	}
+	now := l.now()
+	l.sweep(now)
+
+	rate, burst := l.budget(c, client)
+	key := bucketKey{class, client}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now, windowStart: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens < 1 {
+		if rate <= 0 {
+			return time.Hour, ErrRateLimited
+		}
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second)), ErrRateLimited
+	}
+	if c.Quota > 0 {
+		if now.Sub(b.windowStart) >= c.QuotaWindow {
+			b.count, b.windowStart = 0, now
+		}
+		if b.count >= c.Quota {
+			return b.windowStart.Add(c.QuotaWindow).Sub(now), ErrQuotaExceeded
+		}
+		b.count++
+	}
+	b.tokens--
+	return 0, nil
+}
+
+// budget returns the rate and burst of a client in a class
+func (l *Limiter) budget(c Class, client string) (rate, burst float64) {
+	rate, burst = c.Rate, float64(c.Burst)
+	if strings.HasPrefix(client, "key:") && l.KeyFactor > 0 {
+		rate, burst = rate*l.KeyFactor, burst*l.KeyFactor
+	}
+	return rate, burst
+}
+
+// sweep drops buckets that are as good as new: full, with no quota used in
+// a current window
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		c := l.classes[key.class]
+		rate, burst := l.budget(c, key.client)
+		full := b.tokens+now.Sub(b.last).Seconds()*rate >= burst
+		if full && (c.Quota == 0 || now.Sub(b.windowStart) >= c.QuotaWindow) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// reject counts a rejection and reports it
+func (l *Limiter) reject(class, reason string) {
+	l.mu.Lock()
+	if l.rejected[class] == nil {
+		l.rejected[class] = make(map[string]uint64)
+	}
+	l.rejected[class][reason]++
+	count := l.rejected[class][reason]
+	l.mu.Unlock()
+	if l.OnReject != nil {
+		l.OnReject(class, reason, count)
+	}
+}
+
+// Rejected returns the number of rejections by class and reason
+func (l *Limiter) Rejected() map[string]map[string]uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make(map[string]map[string]uint64, len(l.rejected))
+	for class, reasons := range l.rejected {
+		stats[class] = make(map[string]uint64, len(reasons))
+		for reason, count := range reasons {
+			stats[class][reason] = count
+		}
+	}
+	return stats
+}
+
+// Client identifies the client of a request: "key:<id>" for a valid API
+// key, otherwise "ip:<address>", with IPv6 addresses grouped by /64
+func (l *Limiter) Client(r *http.Request) string {
+	if l.APIKey != nil {
+		if id := l.APIKey(r); id != "" {
+			return "key:" + id
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "ip:" + host
+	}
+	if ip.To4() == nil {
+		return "ip:" + ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+	}
+	return "ip:" + ip.String()
+}
+
+// Handler wraps a handler with the budget and body limit of class. The
+// client of an allowed request is in its context.
+func (l *Limiter) Handler(class string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := l.Client(r)
+		if c, ok := l.Class(class); ok && c.MaxBody > 0 && r.Body != nil {
+			if r.ContentLength > c.MaxBody {
+				l.reject(class, ReasonBody)
+				http.Error(w, fmt.Sprintf("Request body larger than %d bytes", c.MaxBody), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, c.MaxBody)
+		}
+		if wait, err := l.Allow(class, client); err != nil {
+			w.Header().Set("Retry-After", strconv.Itoa(RetryAfter(wait)))
+			http.Error(w, "Too many requests: "+err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		next(w, r.WithContext(WithClient(r.Context(), client)))
+	}
+}
+
+// RetryAfter rounds a wait up to the whole seconds of a Retry-After header
+func RetryAfter(wait time.Duration) int {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+type clientKey struct{}
+
+// WithClient returns a context carrying the client of a request
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext returns the client of a request, "" outside a limited
+// handler
+func ClientFromContext(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
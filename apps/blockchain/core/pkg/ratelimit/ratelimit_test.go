@@ -0,0 +1,226 @@
+package ratelimit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestLimiter creates a limiter with a clock the test moves
+func newTestLimiter(classes ...Class) (*Limiter, func(time.Duration)) {
+	l := NewLimiter(classes...)
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLimiter(t *testing.T) {
+	read := Class{Name: "read", Rate: 2, Burst: 4}
+	faucet := Class{Name: "faucet", Rate: 1, Burst: 1, Quota: 2, QuotaWindow: time.Hour}
+
+	t.Run("Burst", func(t *testing.T) {
+		l, _ := newTestLimiter(read)
+		for i := 0; i < 4; i++ {
+			if _, err := l.Allow("read", "ip:10.0.0.1"); err != nil {
+				t.Fatalf("Expected request %d of the burst to be allowed, got %v", i, err)
+			}
+		}
+		wait, err := l.Allow("read", "ip:10.0.0.1")
+		if !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("Expected the request after the burst to be limited, got %v", err)
+		}
+		if wait != 500*time.Millisecond {
+			t.Errorf("Expected to wait 500ms for the next token, got %v", wait)
+		}
+		if _, err := l.Allow("read", "ip:10.0.0.2"); err != nil {
+			t.Errorf("Expected another client to have its own budget, got %v", err)
+		}
+	})
+
+	t.Run("Refill", func(t *testing.T) {
+		l, advance := newTestLimiter(read)
+		for i := 0; i < 4; i++ {
+			l.Allow("read", "ip:10.0.0.1")
+		}
+		advance(time.Second)
+		for i := 0; i < 2; i++ {
+			if _, err := l.Allow("read", "ip:10.0.0.1"); err != nil {
+				t.Fatalf("Expected a refilled token, got %v", err)
+			}
+		}
+		if _, err := l.Allow("read", "ip:10.0.0.1"); err == nil {
+			t.Errorf("Expected the bucket to refill at 2 per second only")
+		}
+	})
+
+	t.Run("KeyFactor", func(t *testing.T) {
+		l, _ := newTestLimiter(read)
+		for i := 0; i < 4*DefaultKeyFactor; i++ {
+			if _, err := l.Allow("read", "key:ops"); err != nil {
+				t.Fatalf("Expected request %d of the key's burst to be allowed, got %v", i, err)
+			}
+		}
+		if _, err := l.Allow("read", "key:ops"); err == nil {
+			t.Errorf("Expected the key's burst to be limited too")
+		}
+	})
+
+	t.Run("Quota", func(t *testing.T) {
+		l, advance := newTestLimiter(faucet)
+		for i := 0; i < 2; i++ {
+			if _, err := l.Allow("faucet", "key:ops"); err != nil {
+				t.Fatalf("Expected request %d within the quota, got %v", i, err)
+			}
+			advance(time.Minute)
+		}
+		wait, err := l.Allow("faucet", "key:ops")
+		if !errors.Is(err, ErrQuotaExceeded) {
+			t.Fatalf("Expected the quota to be used up, got %v", err)
+		}
+		if wait != 58*time.Minute {
+			t.Errorf("Expected to wait for the end of the window, got %v", wait)
+		}
+		advance(wait)
+		if _, err := l.Allow("faucet", "key:ops"); err != nil {
+			t.Errorf("Expected a new window to restart the quota, got %v", err)
+		}
+	})
+
+	t.Run("UnknownClass", func(t *testing.T) {
+		l, _ := newTestLimiter()
+		for i := 0; i < 100; i++ {
+			if _, err := l.Allow("other", "ip:10.0.0.1"); err != nil {
+				t.Fatalf("Expected requests of unknown classes to be allowed, got %v", err)
+			}
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		l, _ := newTestLimiter(faucet)
+		var reported uint64
+		l.OnReject = func(class, reason string, count uint64) { reported = count }
+		l.Allow("faucet", "ip:10.0.0.1")
+		l.Allow("faucet", "ip:10.0.0.1")
+		l.Allow("faucet", "ip:10.0.0.1")
+		if got := l.Rejected()["faucet"][ReasonRate]; got != 2 || reported != 2 {
+			t.Errorf("Expected 2 rate rejections, got %d (reported %d)", got, reported)
+		}
+	})
+
+	t.Run("Sweep", func(t *testing.T) {
+		l, advance := newTestLimiter(read, faucet)
+		l.Allow("read", "ip:10.0.0.1")
+		l.Allow("faucet", "ip:10.0.0.1")
+		advance(sweepInterval)
+		l.Allow("read", "ip:10.0.0.2")
+		if len(l.buckets) != 2 {
+			t.Fatalf("Expected the full read bucket to be dropped and the faucet one kept, got %d buckets", len(l.buckets))
+		}
+		advance(time.Hour)
+		l.Allow("read", "ip:10.0.0.2")
+		if len(l.buckets) != 1 {
+			t.Errorf("Expected the faucet bucket to be dropped after its window, got %d buckets", len(l.buckets))
+		}
+	})
+}
+
+func TestClient(t *testing.T) {
+	l := NewLimiter()
+	l.APIKey = func(r *http.Request) string { return r.Header.Get("X-Key") }
+
+	tests := []struct {
+		name   string
+		remote string
+		key    string
+		want   string
+	}{
+		{"IPv4", "192.0.2.7:51234", "", "ip:192.0.2.7"},
+		{"IPv6", "[2001:db8:1:2:3:4:5:6]:51234", "", "ip:2001:db8:1:2::/64"},
+		{"IPv6SamePrefix", "[2001:db8:1:2:ffff::1]:443", "", "ip:2001:db8:1:2::/64"},
+		{"NoPort", "192.0.2.7", "", "ip:192.0.2.7"},
+		{"APIKey", "192.0.2.7:51234", "ops", "key:ops"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.key != "" {
+				req.Header.Set("X-Key", tt.key)
+			}
+			if got := l.Client(req); got != tt.want {
+				t.Errorf("Expected client %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	l, _ := newTestLimiter(Class{Name: "tx", Rate: 0.5, Burst: 1, MaxBody: 16})
+	handler := l.Handler("tx", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, ClientFromContext(r.Context()))
+	})
+	serve := func(body io.Reader, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/submit", body)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	t.Run("TooLarge", func(t *testing.T) {
+		rec := serve(strings.NewReader(strings.Repeat("x", 17)), "192.0.2.1:1000")
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected 413, got %d", rec.Code)
+		}
+		if l.Rejected()["tx"][ReasonBody] != 1 {
+			t.Errorf("Expected the rejection to be counted")
+		}
+	})
+
+	t.Run("TooLargeUnknownLength", func(t *testing.T) {
+		rec := serve(io.MultiReader(strings.NewReader(strings.Repeat("x", 17))), "192.0.2.2:1000")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected the body to be cut off, got %d", rec.Code)
+		}
+	})
+
+	t.Run("Allowed", func(t *testing.T) {
+		rec := serve(strings.NewReader("{}"), "192.0.2.3:1000")
+		if rec.Code != http.StatusOK || rec.Body.String() != "ip:192.0.2.3" {
+			t.Errorf("Expected the client in the context, got %d %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("TooMany", func(t *testing.T) {
+		rec := serve(strings.NewReader("{}"), "192.0.2.3:1000")
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("Expected 429, got %d", rec.Code)
+		}
+		if got := rec.Header().Get("Retry-After"); got != "2" {
+			t.Errorf("Expected Retry-After 2, got %q", got)
+		}
+	})
+}
+
+func TestRetryAfter(t *testing.T) {
+	for wait, want := range map[time.Duration]int{
+		0:                       1,
+		100 * time.Millisecond:  1,
+		time.Second:             1,
+		1500 * time.Millisecond: 2,
+		time.Hour:               3600,
+	} {
+		if got := RetryAfter(wait); got != want {
+			t.Errorf("Expected Retry-After %d for %v, got %d", want, wait, got)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// Package rpc is a JSON-RPC 2.0 server with typed methods.
+//
+// Methods are registered with Go types for their parameters and result,
+// which gives both the decoding of parameters and a machine-readable
+// OpenRPC schema of the API. Requests may be sent one at a time or in
+// batches, and notifications (requests without an id) get no response.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Version is the JSON-RPC protocol version of every request and response
+const Version = "2.0"
+
+const (
+	// MaxBatchSize bounds the requests of one batch
+	MaxBatchSize = 100
+	// MaxRequestSize bounds the body of one HTTP request
+	MaxRequestSize = 1 << 20
+)
+
+// Error codes. The range -32768 to -32000 is reserved by the JSON-RPC
+// specification; -32099 to -32000 is left to servers for their own errors.
+const (
+	CodeParseError     = -32700 // Invalid JSON
+	CodeInvalidRequest = -32600 // Not a valid request object
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+
+	CodeNotFound          = -32001 // Block, transaction, account or contract not found
+	CodeHeightUnavailable = -32002 // State at a height that is pruned or not yet reached
+	CodeTxRejected        = -32003 // Transaction refused by the mempool
+	CodeExecutionFailed   = -32004 // Contract call failed
+	CodeUnavailable       = -32005 // Service not available on this node
+	CodeLimitExceeded     = -32006 // Per-connection or per-client limit reached
+)
+
+// errorMessages are the messages of the codes, as listed in the schema
+var errorMessages = map[int]string{
+	CodeParseError:        "Parse error",
+	CodeInvalidRequest:    "Invalid request",
+	CodeMethodNotFound:    "Method not found",
+	CodeInvalidParams:     "Invalid params",
+	CodeInternalError:     "Internal error",
+	CodeNotFound:          "Not found",
+	CodeHeightUnavailable: "Height unavailable",
+	CodeTxRejected:        "Transaction rejected",
+	CodeExecutionFailed:   "Execution failed",
+	CodeUnavailable:       "Unavailable",
+	CodeLimitExceeded:     "Limit exceeded",
+}
+
+// Error is a JSON-RPC error object. Methods return one to choose the code
+// of a failure; any other error is reported as an internal error.
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// Errorf returns an error with a code and a formatted message
+func Errorf(code int, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Request is a JSON-RPC request. ID is nil for notifications.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// Response is a JSON-RPC response, with either a result or an error
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// method is a registered method
+type method struct {
+	name    string
+	summary string
+	params  reflect.Type // Struct whose fields are the named parameters
+	result  reflect.Type
+	call    func(ctx context.Context, params json.RawMessage) (interface{}, error)
+}
+
+// Server dispatches JSON-RPC requests to registered methods
+type Server struct {
+	title   string
+	version string // Version of the API, not of the protocol
+	methods map[string]*method
+}
+
+// NewServer creates a server without methods. version is the version of the
+// API published in its schema.
+func NewServer(title, version string) *Server {
+	s := &Server{title: title, version: version, methods: make(map[string]*method)}
+	Register(s, "rpc_discover", "Returns the OpenRPC schema of this API", func(ctx context.Context, _ struct{}) (*Schema, error) {
+		return s.Schema(), nil
+	})
+	return s
+}
+
+// Register adds a method. Its parameters are the fields of P, given by name
+// in an object or by position in an array; fields tagged omitempty are
+// optional. Registering a name twice replaces the method.
+func Register[P, R any](s *Server, name, summary string, fn func(ctx context.Context, params P) (R, error)) {
+	paramsType := reflect.TypeOf((*P)(nil)).Elem()
+	if paramsType.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("rpc: parameters of %s must be a struct, not %s", name, paramsType))
+	}
+	s.methods[name] = &method{
+		name:    name,
+		summary: summary,
+		params:  paramsType,
+		result:  reflect.TypeOf((*R)(nil)).Elem(),
+		call: func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+			var params P
+			if err := decodeParams(raw, paramsType, &params); err != nil {
+				return nil, err
+			}
+			return fn(ctx, params)
+		},
+	}
+}
+
+// Methods returns the names of the registered methods, sorted
+func (s *Server) Methods() []string {
+	names := make([]string, 0, len(s.methods))
+	for name := range s.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// decodeParams decodes an object or array of parameters into params
+func decodeParams(raw json.RawMessage, paramsType reflect.Type, params interface{}) error {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		raw = []byte("{}")
+	}
+	if raw[0] == '[' {
+		var positional []json.RawMessage
+		if err := json.Unmarshal(raw, &positional); err != nil {
+			return Errorf(CodeInvalidParams, "invalid params: %v", err)
+		}
+		fields := paramFields(paramsType)
+		if len(positional) > len(fields) {
+			return Errorf(CodeInvalidParams, "too many params: expected at most %d", len(fields))
+		}
+		named := make(map[string]json.RawMessage, len(positional))
+		for i, value := range positional {
+			named[fields[i].name] = value
+		}
+		raw, _ = json.Marshal(named)
+	}
+	if raw[0] != '{' {
+		return Errorf(CodeInvalidParams, "params must be an object or an array")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(params); err != nil {
+		return Errorf(CodeInvalidParams, "invalid params: %v", err)
+	}
+	for _, f := range paramFields(paramsType) {
+		if f.required && !hasKey(raw, f.name) {
+			return Errorf(CodeInvalidParams, "missing param %q", f.name)
+		}
+	}
+	return nil
+}
+
+// hasKey reports whether a JSON object has a key
+func hasKey(raw json.RawMessage, key string) bool {
+	var object map[string]json.RawMessage
+	if json.Unmarshal(raw, &object) != nil {
+		return false
+	}
+	_, ok := object[key]
+	return ok
+}
+
+// Handle processes one request or a batch and returns the encoded
+// response, or nil when there is nothing to answer: a notification or a
+// batch of notifications.
+func (s *Server) Handle(ctx context.Context, body []byte) []byte {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return encode(errorResponse(nil, Errorf(CodeParseError, "parse error: %v", err)))
+		}
+		if len(batch) == 0 {
+			return encode(errorResponse(nil, Errorf(CodeInvalidRequest, "empty batch")))
+		}
+		if len(batch) > MaxBatchSize {
+			return encode(errorResponse(nil, Errorf(CodeInvalidRequest, "batch of %d requests exceeds %d", len(batch), MaxBatchSize)))
+		}
+		responses := []*Response{}
+		for _, raw := range batch {
+			if resp := s.handleOne(ctx, raw); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return encode(responses)
+	}
+
+	if !json.Valid(body) {
+		return encode(errorResponse(nil, Errorf(CodeParseError, "parse error: invalid JSON")))
+	}
+	if resp := s.handleOne(ctx, body); resp != nil {
+		return encode(resp)
+	}
+	return nil
+}
+
+// handleOne processes a single request; it returns nil for notifications
+func (s *Server) handleOne(ctx context.Context, raw json.RawMessage) *Response {
+	var req Request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, Errorf(CodeInvalidRequest, "invalid request: %v", err))
+	}
+	if !validID(req.ID) {
+		return errorResponse(nil, Errorf(CodeInvalidRequest, "id must be a string, a number or null"))
+	}
+	if req.JSONRPC != Version || req.Method == "" {
+		return errorResponse(req.ID, Errorf(CodeInvalidRequest, `invalid request: expected "jsonrpc": "2.0" and a method`))
+	}
+
+	result, err := s.call(ctx, req)
+	if req.ID == nil {
+		return nil // Notification
+	}
+	if err != nil {
+		return errorResponse(req.ID, err)
+	}
+	return &Response{JSONRPC: Version, Result: result, ID: req.ID}
+}
+
+// call runs a method, turning panics into internal errors
+func (s *Server) call(ctx context.Context, req Request) (result interface{}, err error) {
+	m, ok := s.methods[req.Method]
+	if !ok {
+		return nil, Errorf(CodeMethodNotFound, "method %q not found", req.Method)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("❌ RPC method %s panicked: %v", req.Method, r)
+			result, err = nil, Errorf(CodeInternalError, "internal error")
+		}
+	}()
+	result, err = m.call(ctx, req.Params)
+	if err == nil && result == nil {
+		result = json.RawMessage("null")
+	}
+	return result, err
+}
+
+// validID reports whether an id is absent, null, a string or a number
+func validID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	switch c := bytes.TrimSpace(id)[0]; {
+	case c == '"', c == 'n', c == '-', c >= '0' && c <= '9':
+		return true
+	}
+	return false
+}
+
+// errorResponse wraps an error in a response. Errors that are not *Error
+// become internal errors.
+func errorResponse(id json.RawMessage, err error) *Response {
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) {
+		rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+	}
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &Response{JSONRPC: Version, Error: rpcErr, ID: id}
+}
+
+func encode(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(errorResponse(nil, Errorf(CodeInternalError, "failed to encode response: %v", err)))
+	}
+	return data
+}
+
+// ServeHTTP answers JSON-RPC requests POSTed as the body and the schema on GET
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Schema())
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestSize))
+	if err != nil {
+		http.Error(w, "Request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	resp := s.Handle(r.Context(), body)
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
@@ -0,0 +1,250 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type addParams struct {
+	A     int64  `json:"a"`
+	B     int64  `json:"b"`
+	Label string `json:"label,omitempty"`
+}
+
+type sum struct {
+	Sum   int64  `json:"sum"`
+	Label string `json:"label,omitempty"`
+}
+
+func newTestServer() *Server {
+	s := NewServer("Test API", "1.0.0")
+	Register(s, "math_add", "Adds two numbers", func(ctx context.Context, p addParams) (*sum, error) {
+		return &sum{Sum: p.A + p.B, Label: p.Label}, nil
+	})
+	Register(s, "math_fail", "Always fails", func(ctx context.Context, _ struct{}) (*sum, error) {
+		return nil, Errorf(CodeNotFound, "nothing here")
+	})
+	Register(s, "math_broken", "Fails without a code", func(ctx context.Context, _ struct{}) (*sum, error) {
+		return nil, errors.New("boom")
+	})
+	Register(s, "math_panic", "Panics", func(ctx context.Context, _ struct{}) (*sum, error) {
+		panic("bad state")
+	})
+	return s
+}
+
+// decode parses a single response
+func decode(t *testing.T, data []byte) Response {
+	t.Helper()
+	var resp Response
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("Invalid response %s: %v", data, err)
+	}
+	if resp.JSONRPC != Version {
+		t.Errorf("Expected jsonrpc %q, got %q", Version, resp.JSONRPC)
+	}
+	return resp
+}
+
+func TestServer(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+
+	t.Run("NamedParams", func(t *testing.T) {
+		resp := decode(t, s.Handle(ctx, []byte(`{"jsonrpc":"2.0","method":"math_add","params":{"a":2,"b":3,"label":"x"},"id":1}`)))
+		if resp.Error != nil {
+			t.Fatalf("Unexpected error: %v", resp.Error)
+		}
+		result := resp.Result.(map[string]interface{})
+		if result["sum"] != float64(5) || result["label"] != "x" {
+			t.Errorf("Expected sum 5 labelled x, got %v", result)
+		}
+		if string(resp.ID) != "1" {
+			t.Errorf("Expected id 1, got %s", resp.ID)
+		}
+	})
+
+	t.Run("PositionalParams", func(t *testing.T) {
+		resp := decode(t, s.Handle(ctx, []byte(`{"jsonrpc":"2.0","method":"math_add","params":[4,5],"id":"a"}`)))
+		if resp.Error != nil {
+			t.Fatalf("Unexpected error: %v", resp.Error)
+		}
+		if resp.Result.(map[string]interface{})["sum"] != float64(9) {
+			t.Errorf("Expected sum 9, got %v", resp.Result)
+		}
+		if string(resp.ID) != `"a"` {
+			t.Errorf("Expected id \"a\", got %s", resp.ID)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			name string
+			body string
+			code int
+		}{
+			{"ParseError", `{"jsonrpc":"2.0",`, CodeParseError},
+			{"WrongVersion", `{"jsonrpc":"1.0","method":"math_add","id":1}`, CodeInvalidRequest},
+			{"NoMethod", `{"jsonrpc":"2.0","id":1}`, CodeInvalidRequest},
+			{"ObjectID", `{"jsonrpc":"2.0","method":"math_add","id":{}}`, CodeInvalidRequest},
+			{"EmptyBatch", `[]`, CodeInvalidRequest},
+			{"MethodNotFound", `{"jsonrpc":"2.0","method":"math_sub","id":1}`, CodeMethodNotFound},
+			{"MissingParam", `{"jsonrpc":"2.0","method":"math_add","params":{"a":1},"id":1}`, CodeInvalidParams},
+			{"UnknownParam", `{"jsonrpc":"2.0","method":"math_add","params":{"a":1,"b":2,"c":3},"id":1}`, CodeInvalidParams},
+			{"WrongType", `{"jsonrpc":"2.0","method":"math_add","params":{"a":"1","b":2},"id":1}`, CodeInvalidParams},
+			{"TooManyParams", `{"jsonrpc":"2.0","method":"math_add","params":[1,2,"x",4],"id":1}`, CodeInvalidParams},
+			{"MethodError", `{"jsonrpc":"2.0","method":"math_fail","id":1}`, CodeNotFound},
+			{"PlainError", `{"jsonrpc":"2.0","method":"math_broken","id":1}`, CodeInternalError},
+			{"Panic", `{"jsonrpc":"2.0","method":"math_panic","id":1}`, CodeInternalError},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp := decode(t, s.Handle(ctx, []byte(tt.body)))
+				if resp.Error == nil {
+					t.Fatalf("Expected error %d, got result %v", tt.code, resp.Result)
+				}
+				if resp.Error.Code != tt.code {
+					t.Errorf("Expected error %d, got %d (%s)", tt.code, resp.Error.Code, resp.Error.Message)
+				}
+				if resp.Result != nil {
+					t.Errorf("Expected no result alongside an error, got %v", resp.Result)
+				}
+			})
+		}
+	})
+
+	t.Run("Notification", func(t *testing.T) {
+		if out := s.Handle(ctx, []byte(`{"jsonrpc":"2.0","method":"math_add","params":[1,2]}`)); out != nil {
+			t.Errorf("Expected no response to a notification, got %s", out)
+		}
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		out := s.Handle(ctx, []byte(`[
+			{"jsonrpc":"2.0","method":"math_add","params":[1,1],"id":1},
+			{"jsonrpc":"2.0","method":"math_add","params":[2,2]},
+			{"jsonrpc":"2.0","method":"math_fail","id":2},
+			1
+		]`))
+		var responses []Response
+		if err := json.Unmarshal(out, &responses); err != nil {
+			t.Fatalf("Expected an array of responses, got %s", out)
+		}
+		if len(responses) != 3 {
+			t.Fatalf("Expected 3 responses without the notification, got %d", len(responses))
+		}
+		if responses[0].Error != nil || responses[0].Result.(map[string]interface{})["sum"] != float64(2) {
+			t.Errorf("Expected sum 2 for the first request, got %+v", responses[0])
+		}
+		if responses[1].Error == nil || responses[1].Error.Code != CodeNotFound {
+			t.Errorf("Expected the method error for the second request, got %+v", responses[1])
+		}
+		if responses[2].Error == nil || responses[2].Error.Code != CodeInvalidRequest {
+			t.Errorf("Expected an invalid request for a non-object, got %+v", responses[2])
+		}
+	})
+
+	t.Run("BatchOfNotifications", func(t *testing.T) {
+		if out := s.Handle(ctx, []byte(`[{"jsonrpc":"2.0","method":"math_add","params":[1,2]}]`)); out != nil {
+			t.Errorf("Expected no response to a batch of notifications, got %s", out)
+		}
+	})
+
+	t.Run("BatchTooLarge", func(t *testing.T) {
+		batch := "[" + strings.Repeat(`{"jsonrpc":"2.0","method":"math_add","params":[1,2],"id":1},`, MaxBatchSize) + "1]"
+		resp := decode(t, s.Handle(ctx, []byte(batch)))
+		if resp.Error == nil || resp.Error.Code != CodeInvalidRequest {
+			t.Errorf("Expected an oversized batch to be rejected, got %+v", resp)
+		}
+	})
+}
+
+func TestSchema(t *testing.T) {
+	s := newTestServer()
+	schema := s.Schema()
+
+	if schema.OpenRPC != OpenRPCVersion || schema.Info.Version != "1.0.0" {
+		t.Errorf("Unexpected schema header %s %+v", schema.OpenRPC, schema.Info)
+	}
+	var add *MethodSchema
+	for i, m := range schema.Methods {
+		if i > 0 && schema.Methods[i-1].Name > m.Name {
+			t.Errorf("Expected methods sorted by name, got %s before %s", schema.Methods[i-1].Name, m.Name)
+		}
+		if m.Name == "math_add" {
+			add = &schema.Methods[i]
+		}
+	}
+	if add == nil {
+		t.Fatal("Expected math_add in the schema")
+	}
+	if len(add.Params) != 3 || add.Params[0].Name != "a" || !add.Params[0].Required || add.Params[2].Required {
+		t.Errorf("Expected required a and b and optional label, got %+v", add.Params)
+	}
+	if add.Params[0].Schema["type"] != "integer" {
+		t.Errorf("Expected a to be an integer, got %v", add.Params[0].Schema)
+	}
+	props, _ := add.Result.Schema["properties"].(JSONSchema)
+	if add.Result.Schema["title"] != "sum" || props["sum"] == nil {
+		t.Errorf("Expected the result to describe sum, got %v", add.Result.Schema)
+	}
+	if schema.Components.Errors["NotFound"].Code != CodeNotFound {
+		t.Errorf("Expected the error codes in the schema, got %v", schema.Components.Errors)
+	}
+
+	t.Run("Discover", func(t *testing.T) {
+		resp := decode(t, s.Handle(context.Background(), []byte(`{"jsonrpc":"2.0","method":"rpc_discover","id":1}`)))
+		if resp.Error != nil {
+			t.Fatalf("Unexpected error: %v", resp.Error)
+		}
+		if resp.Result.(map[string]interface{})["openrpc"] != OpenRPCVersion {
+			t.Errorf("Expected the schema from rpc_discover, got %v", resp.Result)
+		}
+	})
+}
+
+func TestServeHTTP(t *testing.T) {
+	s := newTestServer()
+
+	t.Run("Post", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/rpc/v1", strings.NewReader(`{"jsonrpc":"2.0","method":"math_add","params":[1,2],"id":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || decode(t, rec.Body.Bytes()).Error != nil {
+			t.Errorf("Expected a result, got %d %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("Notification", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rpc/v1", strings.NewReader(`{"jsonrpc":"2.0","method":"math_add","params":[1,2]}`)))
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("Expected 204 for a notification, got %d", rec.Code)
+		}
+	})
+
+	t.Run("Schema", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rpc/v1", nil))
+		var schema Schema
+		if err := json.Unmarshal(rec.Body.Bytes(), &schema); err != nil || len(schema.Methods) == 0 {
+			t.Errorf("Expected the schema on GET, got %d %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("ContentType", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/rpc/v1", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "text/plain")
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("Expected 415 for a non-JSON body, got %d", rec.Code)
+		}
+	})
+}
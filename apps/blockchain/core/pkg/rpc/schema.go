@@ -0,0 +1,196 @@
+package rpc
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// OpenRPCVersion is the version of the OpenRPC specification the schema follows
+const OpenRPCVersion = "1.2.6"
+
+// Schema is an OpenRPC document describing the methods of a server, from
+// which clients can be generated
+type Schema struct {
+	OpenRPC    string           `json:"openrpc"`
+	Info       SchemaInfo       `json:"info"`
+	Methods    []MethodSchema   `json:"methods"`
+	Components SchemaComponents `json:"components"`
+}
+
+// SchemaInfo names and versions the API
+type SchemaInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// MethodSchema describes one method
+type MethodSchema struct {
+	Name           string              `json:"name"`
+	Summary        string              `json:"summary,omitempty"`
+	ParamStructure string              `json:"paramStructure"`
+	Params         []ContentDescriptor `json:"params"`
+	Result         ContentDescriptor   `json:"result"`
+}
+
+// ContentDescriptor describes a parameter or a result
+type ContentDescriptor struct {
+	Name     string     `json:"name"`
+	Required bool       `json:"required,omitempty"`
+	Schema   JSONSchema `json:"schema"`
+}
+
+// SchemaComponents lists the error codes methods may return
+type SchemaComponents struct {
+	Errors map[string]ErrorSchema `json:"errors"`
+}
+
+// ErrorSchema describes an error code
+type ErrorSchema struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSONSchema is a JSON Schema of a Go type
+type JSONSchema map[string]interface{}
+
+// Schema returns the OpenRPC document of the server's methods
+func (s *Server) Schema() *Schema {
+	schema := &Schema{
+		OpenRPC:    OpenRPCVersion,
+		Info:       SchemaInfo{Title: s.title, Version: s.version},
+		Methods:    []MethodSchema{},
+		Components: SchemaComponents{Errors: make(map[string]ErrorSchema, len(errorMessages))},
+	}
+	for _, name := range s.Methods() {
+		m := s.methods[name]
+		ms := MethodSchema{
+			Name:           m.name,
+			Summary:        m.summary,
+			ParamStructure: "either",
+			Params:         []ContentDescriptor{},
+			Result:         ContentDescriptor{Name: "result", Schema: typeSchema(m.result, nil)},
+		}
+		for _, f := range paramFields(m.params) {
+			ms.Params = append(ms.Params, ContentDescriptor{Name: f.name, Required: f.required, Schema: typeSchema(f.typ, nil)})
+		}
+		schema.Methods = append(schema.Methods, ms)
+	}
+	for code, message := range errorMessages {
+		schema.Components.Errors[errorKey(message)] = ErrorSchema{Code: code, Message: message}
+	}
+	return schema
+}
+
+// errorKey names an error code in the schema: "Not found" becomes NotFound
+func errorKey(message string) string {
+	var key strings.Builder
+	for _, word := range strings.Fields(message) {
+		key.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return key.String()
+}
+
+// field is a JSON field of a struct
+type field struct {
+	name     string
+	typ      reflect.Type
+	required bool
+}
+
+// paramFields returns the JSON fields of a struct in declaration order,
+// including those of embedded structs, as encoding/json sees them
+func paramFields(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, paramFields(ft)...)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		optional := strings.Contains(","+opts+",", ",omitempty,")
+		fields = append(fields, field{name: name, typ: ft, required: !optional})
+	}
+	return fields
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	rawType       = reflect.TypeOf(json.RawMessage{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// typeSchema returns the JSON Schema of a type. Types already being
+// described further up are left open to stop recursion.
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) JSONSchema {
+	switch {
+	case t == timeType:
+		return JSONSchema{"type": "string", "format": "date-time"}
+	case t == rawType:
+		return JSONSchema{}
+	case t.Kind() != reflect.Ptr && t.Implements(marshalerType):
+		return JSONSchema{} // Encodes itself
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), seen)
+	case reflect.Bool:
+		return JSONSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return JSONSchema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return JSONSchema{"type": "number"}
+	case reflect.String:
+		return JSONSchema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return JSONSchema{"type": "string", "contentEncoding": "base64"}
+		}
+		return JSONSchema{"type": "array", "items": typeSchema(t.Elem(), seen)}
+	case reflect.Map:
+		return JSONSchema{"type": "object", "additionalProperties": typeSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return JSONSchema{"type": "object"}
+		}
+		inner := make(map[reflect.Type]bool, len(seen)+1)
+		for k := range seen {
+			inner[k] = true
+		}
+		inner[t] = true
+		// Fields are not marked required: the server fills in what a
+		// client leaves out, and results may omit empty fields
+		properties := JSONSchema{}
+		for _, f := range paramFields(t) {
+			properties[f.name] = typeSchema(f.typ, inner)
+		}
+		schema := JSONSchema{"type": "object", "properties": properties}
+		if t.Name() != "" {
+			schema["title"] = t.Name()
+		}
+		return schema
+	}
+	return JSONSchema{} // interface{}: any value
+}
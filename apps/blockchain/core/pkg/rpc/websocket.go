@@ -0,0 +1,337 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket defaults
+const (
+	// DefaultMaxSubscriptions bounds the subscriptions of one connection
+	DefaultMaxSubscriptions = 32
+	// DefaultSendQueueSize is the number of messages waiting to be written to
+	// a connection before it counts as too slow
+	DefaultSendQueueSize = 256
+
+	writeWait    = 10 * time.Second
+	pongWait     = 60 * time.Second
+	pingInterval = pongWait * 9 / 10
+)
+
+// Filter decides whether an event is sent to a subscription and what is
+// sent: ok is false to skip the event.
+type Filter func(event interface{}) (result interface{}, ok bool)
+
+// SubscriptionKind is a stream of events clients can subscribe to. NewFilter
+// builds the filter of a subscription from its params, which are empty when
+// none were given.
+type SubscriptionKind struct {
+	Name      string
+	NewFilter func(params json.RawMessage) (Filter, error)
+}
+
+// SubscribeParams are the params of <namespace>_subscribe
+type SubscribeParams struct {
+	Type   string          `json:"type"`
+	Filter json.RawMessage `json:"filter,omitempty"`
+}
+
+// UnsubscribeParams are the params of <namespace>_unsubscribe
+type UnsubscribeParams struct {
+	Subscription string `json:"subscription"`
+}
+
+// Notification carries one event of a subscription. It is a JSON-RPC
+// request without an id.
+type Notification struct {
+	JSONRPC string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  NotificationParams `json:"params"`
+}
+
+// NotificationParams name the subscription an event belongs to
+type NotificationParams struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+// subscription is one client subscription
+type subscription struct {
+	id     string
+	kind   string
+	filter Filter
+	conn   *wsConn
+}
+
+// Hub serves JSON-RPC over WebSocket and delivers subscription events.
+// Requests on a connection are answered by the server; subscriptions are
+// managed with the <namespace>_subscribe and <namespace>_unsubscribe methods
+// and events arrive as <namespace>_subscription notifications.
+//
+// A connection whose send queue is full does not get further events: it is
+// closed, so a slow client cannot hold up the others or grow the node's
+// memory. Responses wait for room in the queue instead, which stops reading
+// requests from a client that does not read its responses.
+type Hub struct {
+	server    *Server
+	namespace string
+
+	MaxSubscriptions int // Per connection
+	SendQueueSize    int // Messages per connection
+
+	// Limit is called before each message is handled; an error is the
+	// response to the message instead
+	Limit func(ctx context.Context) error
+
+	upgrader websocket.Upgrader
+
+	mu    sync.RWMutex
+	kinds map[string]*SubscriptionKind
+	subs  map[string]map[string]*subscription // Kind to subscription ID
+}
+
+// connKey is the context key of the connection a request arrived on
+type connKey struct{}
+
+// NewHub creates a hub serving s over WebSocket and registers the
+// subscription methods of namespace on s
+func NewHub(s *Server, namespace string, kinds ...SubscriptionKind) *Hub {
+	h := &Hub{
+		server:           s,
+		namespace:        namespace,
+		MaxSubscriptions: DefaultMaxSubscriptions,
+		SendQueueSize:    DefaultSendQueueSize,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			// Same policy as the HTTP API, which allows any origin
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		kinds: make(map[string]*SubscriptionKind),
+		subs:  make(map[string]map[string]*subscription),
+	}
+	names := make([]string, 0, len(kinds))
+	for i := range kinds {
+		h.kinds[kinds[i].Name] = &kinds[i]
+		h.subs[kinds[i].Name] = make(map[string]*subscription)
+		names = append(names, kinds[i].Name)
+	}
+	sort.Strings(names)
+
+	Register(s, namespace+"_subscribe", "Subscribes to "+strings.Join(names, ", ")+" over WebSocket and returns the subscription ID", h.subscribe)
+	Register(s, namespace+"_unsubscribe", "Cancels a subscription of this connection", h.unsubscribe)
+	return h
+}
+
+func (h *Hub) subscribe(ctx context.Context, p SubscribeParams) (string, error) {
+	conn, ok := ctx.Value(connKey{}).(*wsConn)
+	if !ok {
+		return "", Errorf(CodeUnavailable, "subscriptions need a WebSocket connection")
+	}
+	kind, ok := h.kinds[p.Type]
+	if !ok {
+		return "", Errorf(CodeInvalidParams, "unknown subscription type %q", p.Type)
+	}
+	filter, err := kind.NewFilter(p.Filter)
+	if err != nil {
+		return "", Errorf(CodeInvalidParams, "invalid filter: %v", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(conn.subs) >= h.MaxSubscriptions {
+		return "", Errorf(CodeLimitExceeded, "at most %d subscriptions per connection", h.MaxSubscriptions)
+	}
+	sub := &subscription{id: newSubscriptionID(), kind: p.Type, filter: filter, conn: conn}
+	h.subs[sub.kind][sub.id] = sub
+	conn.subs[sub.id] = sub
+	return sub.id, nil
+}
+
+func (h *Hub) unsubscribe(ctx context.Context, p UnsubscribeParams) (bool, error) {
+	conn, ok := ctx.Value(connKey{}).(*wsConn)
+	if !ok {
+		return false, Errorf(CodeUnavailable, "subscriptions need a WebSocket connection")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sub, ok := conn.subs[p.Subscription]
+	if !ok {
+		return false, Errorf(CodeNotFound, "subscription %s not found", p.Subscription)
+	}
+	delete(conn.subs, sub.id)
+	delete(h.subs[sub.kind], sub.id)
+	return true, nil
+}
+
+// newSubscriptionID returns a random hex ID
+func newSubscriptionID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return "0x" + hex.EncodeToString(id)
+}
+
+// HasSubscribers reports whether any connection subscribes to kind, so that
+// publishers can skip building events nobody receives
+func (h *Hub) HasSubscribers(kind string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs[kind]) > 0
+}
+
+// Publish sends an event to every subscription of kind whose filter accepts
+// it. It never blocks on a connection.
+func (h *Hub) Publish(kind string, event interface{}) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, sub := range h.subs[kind] {
+		result, ok := sub.filter(event)
+		if !ok {
+			continue
+		}
+		data := encode(&Notification{
+			JSONRPC: Version,
+			Method:  h.namespace + "_subscription",
+			Params:  NotificationParams{Subscription: sub.id, Result: result},
+		})
+		sub.conn.notify(data)
+	}
+}
+
+// wsConn is a WebSocket connection with its send queue and subscriptions
+type wsConn struct {
+	ws        *websocket.Conn
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+	subs      map[string]*subscription // Guarded by Hub.mu
+}
+
+// notify queues an event, closing the connection if its queue is full
+func (c *wsConn) notify(data []byte) {
+	select {
+	case c.send <- data:
+	case <-c.done:
+	default:
+		log.Printf("⚠️ WebSocket client %s is too slow, disconnecting", c.ws.RemoteAddr())
+		c.close()
+	}
+}
+
+// reply queues a response, waiting for room in the queue
+func (c *wsConn) reply(data []byte) bool {
+	select {
+	case c.send <- data:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+func (c *wsConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.ws.Close()
+	})
+}
+
+// ServeHTTP upgrades the request to a WebSocket connection and serves
+// JSON-RPC requests on it until the client goes away
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // The upgrader has answered with an error
+	}
+	conn := &wsConn{
+		ws:   ws,
+		send: make(chan []byte, h.SendQueueSize),
+		done: make(chan struct{}),
+		subs: make(map[string]*subscription),
+	}
+	go h.writeLoop(conn)
+	h.readLoop(r.Context(), conn)
+
+	conn.close()
+	h.mu.Lock()
+	for id, sub := range conn.subs {
+		delete(h.subs[sub.kind], id)
+	}
+	conn.subs = nil
+	h.mu.Unlock()
+}
+
+// readLoop answers requests one at a time until the connection fails
+func (h *Hub) readLoop(ctx context.Context, conn *wsConn) {
+	ctx = context.WithValue(ctx, connKey{}, conn)
+	conn.ws.SetReadLimit(MaxRequestSize)
+	conn.ws.SetReadDeadline(time.Now().Add(pongWait))
+	conn.ws.SetPongHandler(func(string) error {
+		return conn.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, msg, err := conn.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		if h.Limit != nil {
+			if err := h.Limit(ctx); err != nil {
+				if !conn.reply(encode(errorResponse(requestID(msg), err))) {
+					return
+				}
+				continue
+			}
+		}
+		if resp := h.server.Handle(ctx, msg); resp != nil {
+			if !conn.reply(resp) {
+				return
+			}
+		}
+	}
+}
+
+// requestID returns the id of a single request, or nil for a batch or a
+// message that is not a request
+func requestID(msg []byte) json.RawMessage {
+	var req struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if json.Unmarshal(msg, &req) != nil || !validID(req.ID) {
+		return nil
+	}
+	return req.ID
+}
+
+// writeLoop writes queued messages and keeps the connection alive with pings
+func (h *Hub) writeLoop(conn *wsConn) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	defer conn.close()
+	for {
+		select {
+		case msg := <-conn.send:
+			conn.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.ws.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-conn.done:
+			conn.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			conn.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestHub serves a hub with a "numbers" subscription whose filter keeps
+// multiples of "of"
+func newTestHub(t *testing.T) (*Hub, *httptest.Server) {
+	s := newTestServer()
+	hub := NewHub(s, "test", SubscriptionKind{Name: "numbers", NewFilter: func(params json.RawMessage) (Filter, error) {
+		f := struct {
+			Of int `json:"of"`
+		}{Of: 1}
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &f); err != nil {
+				return nil, err
+			}
+		}
+		return func(event interface{}) (interface{}, bool) {
+			n := event.(int)
+			return n, n%f.Of == 0
+		}, nil
+	}})
+	srv := httptest.NewServer(hub)
+	t.Cleanup(srv.Close)
+	return hub, srv
+}
+
+func dial(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// call sends a request and reads the next message as its response
+func call(t *testing.T, ws *websocket.Conn, method, params string) Response {
+	t.Helper()
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":%q,"params":%s,"id":1}`, method, params))); err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	return decode(t, data)
+}
+
+func TestHub(t *testing.T) {
+	t.Run("Requests", func(t *testing.T) {
+		_, srv := newTestHub(t)
+		ws := dial(t, srv)
+		resp := call(t, ws, "math_add", "[2,3]")
+		if resp.Error != nil || resp.Result.(map[string]interface{})["sum"] != float64(5) {
+			t.Errorf("Expected sum 5 over WebSocket, got %+v", resp)
+		}
+	})
+
+	t.Run("Subscribe", func(t *testing.T) {
+		hub, srv := newTestHub(t)
+		ws := dial(t, srv)
+		resp := call(t, ws, "test_subscribe", `{"type":"numbers","filter":{"of":2}}`)
+		if resp.Error != nil {
+			t.Fatalf("Failed to subscribe: %v", resp.Error)
+		}
+		id := resp.Result.(string)
+		if !hub.HasSubscribers("numbers") {
+			t.Fatal("Expected the hub to have a subscriber")
+		}
+
+		for n := 1; n <= 4; n++ {
+			hub.Publish("numbers", n)
+		}
+		for _, want := range []float64{2, 4} {
+			ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+			var note struct {
+				Method string
+				Params struct {
+					Subscription string
+					Result       float64
+				}
+			}
+			if err := ws.ReadJSON(&note); err != nil {
+				t.Fatalf("Failed to read notification: %v", err)
+			}
+			if note.Method != "test_subscription" || note.Params.Subscription != id || note.Params.Result != want {
+				t.Errorf("Expected %v for subscription %s, got %+v", want, id, note)
+			}
+		}
+
+		if resp := call(t, ws, "test_unsubscribe", `[`+fmt.Sprintf("%q", id)+`]`); resp.Error != nil || resp.Result != true {
+			t.Errorf("Expected unsubscribe to succeed, got %+v", resp)
+		}
+		if hub.HasSubscribers("numbers") {
+			t.Error("Expected no subscribers after unsubscribing")
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		hub, srv := newTestHub(t)
+		hub.MaxSubscriptions = 1
+		ws := dial(t, srv)
+		if resp := call(t, ws, "test_subscribe", `{"type":"letters"}`); resp.Error == nil || resp.Error.Code != CodeInvalidParams {
+			t.Errorf("Expected an unknown type to be rejected, got %+v", resp)
+		}
+		if resp := call(t, ws, "test_subscribe", `{"type":"numbers","filter":{"of":"two"}}`); resp.Error == nil || resp.Error.Code != CodeInvalidParams {
+			t.Errorf("Expected an invalid filter to be rejected, got %+v", resp)
+		}
+		if resp := call(t, ws, "test_subscribe", `{"type":"numbers"}`); resp.Error != nil {
+			t.Fatalf("Failed to subscribe: %v", resp.Error)
+		}
+		if resp := call(t, ws, "test_subscribe", `{"type":"numbers"}`); resp.Error == nil || resp.Error.Code != CodeLimitExceeded {
+			t.Errorf("Expected the subscription limit, got %+v", resp)
+		}
+		if resp := call(t, ws, "test_unsubscribe", `["0x00"]`); resp.Error == nil || resp.Error.Code != CodeNotFound {
+			t.Errorf("Expected an unknown subscription to be rejected, got %+v", resp)
+		}
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		hub, srv := newTestHub(t)
+		budget := 2
+		hub.Limit = func(ctx context.Context) error {
+			if budget == 0 {
+				return Errorf(CodeLimitExceeded, "rate limit exceeded")
+			}
+			budget--
+			return nil
+		}
+		ws := dial(t, srv)
+		for i := 0; i < 2; i++ {
+			if resp := call(t, ws, "math_add", "[2,3]"); resp.Error != nil {
+				t.Fatalf("Expected request %d within the budget, got %+v", i, resp.Error)
+			}
+		}
+		resp := call(t, ws, "math_add", "[2,3]")
+		if resp.Error == nil || resp.Error.Code != CodeLimitExceeded || string(resp.ID) != "1" {
+			t.Errorf("Expected the request over the budget to be rejected with its id, got %+v", resp)
+		}
+	})
+
+	t.Run("WithoutWebSocket", func(t *testing.T) {
+		hub, _ := newTestHub(t)
+		resp := decode(t, hub.server.Handle(context.Background(), []byte(`{"jsonrpc":"2.0","method":"test_subscribe","params":{"type":"numbers"},"id":1}`)))
+		if resp.Error == nil || resp.Error.Code != CodeUnavailable {
+			t.Errorf("Expected subscriptions over HTTP to be unavailable, got %+v", resp)
+		}
+	})
+
+	t.Run("Disconnect", func(t *testing.T) {
+		hub, srv := newTestHub(t)
+		ws := dial(t, srv)
+		if resp := call(t, ws, "test_subscribe", `{"type":"numbers"}`); resp.Error != nil {
+			t.Fatalf("Failed to subscribe: %v", resp.Error)
+		}
+		ws.Close()
+		waitFor(t, func() bool { return !hub.HasSubscribers("numbers") })
+	})
+
+	t.Run("SlowClient", func(t *testing.T) {
+		hub := NewHub(newTestServer(), "test", SubscriptionKind{Name: "blobs", NewFilter: func(json.RawMessage) (Filter, error) {
+			return func(event interface{}) (interface{}, bool) { return event, true }, nil
+		}})
+		hub.SendQueueSize = 1
+		srv := httptest.NewServer(hub)
+		defer srv.Close()
+		ws := dial(t, srv)
+		if resp := call(t, ws, "test_subscribe", `{"type":"blobs"}`); resp.Error != nil {
+			t.Fatalf("Failed to subscribe: %v", resp.Error)
+		}
+
+		// The client stops reading; once the queue and socket buffers are
+		// full the connection is dropped instead of blocking Publish
+		blob := strings.Repeat("x", 64<<10)
+		for deadline := time.Now().Add(10 * time.Second); hub.HasSubscribers("blobs") && time.Now().Before(deadline); {
+			hub.Publish("blobs", blob)
+			time.Sleep(time.Millisecond)
+		}
+		if hub.HasSubscribers("blobs") {
+			t.Error("Expected a client that does not read to be disconnected")
+		}
+	})
+}
+
+// waitFor polls cond for up to 5 seconds
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Error("Condition not met in time")
+}
@@ -0,0 +1,59 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxMultisigKeys is the largest N of an M-of-N account
+const MaxMultisigKeys = 20
+
+// Multisig defines an M-of-N account: any Threshold of the PublicKeys
+// (hex encoded like SenderPublicKey) can spend from it
+type Multisig struct {
+	Threshold  int      `json:"threshold"`
+	PublicKeys []string `json:"public_keys"`
+}
+
+// MultisigSignature is the signature of one co-signer of a multisig transaction
+type MultisigSignature struct {
+	PublicKey string `json:"public_key"`
+	Signature string `json:"signature"`
+}
+
+// Validate checks the threshold and that the keys are distinct hex strings
+func (m *Multisig) Validate() error {
+	n := len(m.PublicKeys)
+	if n == 0 || n > MaxMultisigKeys {
+		return fmt.Errorf("multisig must have between 1 and %d public keys, got %d", MaxMultisigKeys, n)
+	}
+	if m.Threshold < 1 || m.Threshold > n {
+		return fmt.Errorf("multisig threshold must be between 1 and %d, got %d", n, m.Threshold)
+	}
+	seen := make(map[string]bool, n)
+	for _, key := range m.PublicKeys {
+		if key == "" {
+			return errors.New("multisig public key cannot be empty")
+		}
+		if _, err := hex.DecodeString(key); err != nil {
+			return fmt.Errorf("invalid multisig public key encoding: %s", key)
+		}
+		if seen[strings.ToLower(key)] {
+			return fmt.Errorf("duplicate multisig public key: %s", key)
+		}
+		seen[strings.ToLower(key)] = true
+	}
+	return nil
+}
+
+// HasKey reports whether key is one of the account's public keys
+func (m *Multisig) HasKey(key string) bool {
+	for _, k := range m.PublicKeys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -13,15 +13,26 @@ const (
 	TxTypeRegular   TransactionType = "regular"
 	TxTypeDeploy    TransactionType = "deploy_contract"
 	TxTypeCall      TransactionType = "call_contract"
+	TxTypeUpgrade   TransactionType = "upgrade_contract" // Replace a contract's code, keeping address and storage
 	TxTypeProposal  TransactionType = "proposal"
 	TxTypeVote      TransactionType = "vote"
 	TxTypeStake     TransactionType = "stake"      // Stake DUT to become validator
 	TxTypeUnstake   TransactionType = "unstake"    // Unstake DUT (future)
+	TxTypeMultisig  TransactionType = "register_multisig" // Register the M-of-N account in Data, funding it with Amount
+)
+
+// Signature schemes of transactions and validator keys. An empty scheme is P-256.
+const (
+	SchemeP256      = "p256"      // ECDSA on P-256, hex DER public keys
+	SchemeSecp256k1 = "secp256k1" // ECDSA on secp256k1, recoverable signatures
+	SchemeEd25519   = "ed25519"   // Ed25519, raw public keys
 )
 
 // Transaction represents a transfer of value or a contract operation.
 type Transaction struct {
 	Type      TransactionType // New: type of transaction
+	ChainID   string `json:"chain_id,omitempty"` // Network the transaction is valid on; part of the signed payload
+	Scheme    string `json:"scheme,omitempty"`   // Signature scheme of SenderPublicKey and Signature; empty is P-256
 	Sender    string
 	SenderPublicKey string // Added for signature verification
 	Recipient string
@@ -34,6 +45,10 @@ type Transaction struct {
 	// Privacy features
 	IsEncrypted bool   `json:"is_encrypted,omitempty"` // Whether Data field is encrypted
 	EncryptionKeyID string `json:"encryption_key_id,omitempty"` // ID of encryption key used
+	// Multisig spending: the sender's M-of-N definition and its co-signers'
+	// signatures, used instead of SenderPublicKey and Signature
+	Multisig   *Multisig           `json:"multisig,omitempty"`
+	Signatures []MultisigSignature `json:"signatures,omitempty"`
 }
 
 // Validate checks if a transaction is valid.
@@ -50,11 +65,26 @@ func (t *Transaction) Validate() error {
 	if t.Fee < 0 {
 		return errors.New("fee cannot be negative")
 	}
-	if t.Signature == "" {
-		return errors.New("signature cannot be empty")
-	}
-	if t.Sender != "network" && t.SenderPublicKey == "" {
-		return errors.New("sender public key cannot be empty")
+	if t.Multisig != nil {
+		if err := t.Multisig.Validate(); err != nil {
+			return err
+		}
+		if len(t.Signatures) == 0 {
+			return errors.New("multisig transaction has no signatures")
+		}
+	} else {
+		if t.Signature == "" {
+			return errors.New("signature cannot be empty")
+		}
+		switch t.Scheme {
+		case "", SchemeP256, SchemeSecp256k1, SchemeEd25519:
+		default:
+			return fmt.Errorf("unknown signature scheme %q", t.Scheme)
+		}
+		// secp256k1 public keys are recovered from the signature
+		if t.Sender != "network" && t.SenderPublicKey == "" && t.Scheme != SchemeSecp256k1 {
+			return errors.New("sender public key cannot be empty")
+		}
 	}
 	// Normalize addresses (remove 0x prefix if present)
 	sender := t.Sender
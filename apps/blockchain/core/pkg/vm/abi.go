@@ -0,0 +1,209 @@
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ABI parameter types understood by the VM. All values live on the stack as
+// int64, the type only controls how arguments are encoded and results decoded.
+const (
+	ABITypeInt64  = "int64"
+	ABITypeUint64 = "uint64"
+	ABITypeBool   = "bool"
+)
+
+// State mutability flags for ABI functions
+const (
+	MutabilityView     = "view"     // Reads storage only, safe to evaluate off-chain
+	MutabilityMutating = "mutating" // May write storage, must go through a transaction
+)
+
+// ABIParam describes a single typed function input, output or event field
+type ABIParam struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ABIFunction describes a callable contract function
+type ABIFunction struct {
+	Name            string     `json:"name"`
+	Inputs          []ABIParam `json:"inputs"`
+	Outputs         []ABIParam `json:"outputs"`
+	StateMutability string     `json:"stateMutability"`
+}
+
+// ABIEvent describes an event a contract may emit
+type ABIEvent struct {
+	Name   string     `json:"name"`
+	Inputs []ABIParam `json:"inputs"`
+}
+
+// ABI is the machine-readable interface of a deployed contract
+type ABI struct {
+	Functions []ABIFunction `json:"functions"`
+	Events    []ABIEvent    `json:"events,omitempty"`
+}
+
+// ReturnValue is a decoded, typed value returned from a contract function
+type ReturnValue struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// BuildABI generates the ABI for a set of contract functions and events
+func BuildABI(functions map[string]*Function, events []ABIEvent) *ABI {
+	abi := &ABI{
+		Functions: make([]ABIFunction, 0, len(functions)),
+		Events:    events,
+	}
+	for name, fn := range functions {
+		mutability := MutabilityMutating
+		if fn.View {
+			mutability = MutabilityView
+		}
+		abi.Functions = append(abi.Functions, ABIFunction{
+			Name:            name,
+			Inputs:          fn.inputParams(),
+			Outputs:         append([]ABIParam{}, fn.Outputs...),
+			StateMutability: mutability,
+		})
+	}
+	// Map iteration is random, keep the ABI deterministic
+	sort.Slice(abi.Functions, func(i, j int) bool {
+		return abi.Functions[i].Name < abi.Functions[j].Name
+	})
+	return abi
+}
+
+// GetFunction returns the ABI entry for a function by name
+func (a *ABI) GetFunction(name string) (*ABIFunction, bool) {
+	for i := range a.Functions {
+		if a.Functions[i].Name == name {
+			return &a.Functions[i], true
+		}
+	}
+	return nil, false
+}
+
+// MarshalABI encodes an ABI as JSON for storage
+func MarshalABI(abi *ABI) (string, error) {
+	if abi == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(abi)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal ABI: %v", err)
+	}
+	return string(data), nil
+}
+
+// UnmarshalABI decodes an ABI previously produced by MarshalABI
+func UnmarshalABI(data string) (*ABI, error) {
+	abi := &ABI{}
+	if data == "" {
+		return abi, nil
+	}
+	if err := json.Unmarshal([]byte(data), abi); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ABI: %v", err)
+	}
+	return abi, nil
+}
+
+// inputParams returns the typed inputs of a function, treating legacy
+// name-only parameters as int64
+func (f *Function) inputParams() []ABIParam {
+	if len(f.Inputs) > 0 {
+		return append([]ABIParam{}, f.Inputs...)
+	}
+	params := make([]ABIParam, 0, len(f.Parameters))
+	for _, name := range f.Parameters {
+		params = append(params, ABIParam{Name: name, Type: ABITypeInt64})
+	}
+	return params
+}
+
+// BindArgs orders named arguments according to the function's inputs
+func (f *Function) BindArgs(args map[string]interface{}) ([]interface{}, error) {
+	inputs := f.inputParams()
+	bound := make([]interface{}, 0, len(inputs))
+	for _, input := range inputs {
+		val, ok := args[input.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing parameter '%s' for function '%s'", input.Name, f.Name)
+		}
+		bound = append(bound, val)
+	}
+	if len(args) != len(inputs) {
+		for name := range args {
+			if !hasParam(inputs, name) {
+				return nil, fmt.Errorf("unknown parameter '%s' for function '%s'", name, f.Name)
+			}
+		}
+	}
+	return bound, nil
+}
+
+func hasParam(params []ABIParam, name string) bool {
+	for _, p := range params {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateABIType checks that a parameter type is supported by the VM
+func validateABIType(t string) error {
+	switch t {
+	case ABITypeInt64, ABITypeUint64, ABITypeBool:
+		return nil
+	default:
+		return fmt.Errorf("unsupported ABI type '%s'", t)
+	}
+}
+
+// encodeABIValue converts a typed argument into its stack representation
+func encodeABIValue(param ABIParam, val interface{}) (int64, error) {
+	switch param.Type {
+	case ABITypeBool:
+		if b, ok := val.(bool); ok {
+			if b {
+				return 1, nil
+			}
+			return 0, nil
+		}
+		if i, ok := toInt64(val); ok && (i == 0 || i == 1) {
+			return i, nil
+		}
+		return 0, fmt.Errorf("parameter '%s' must be a bool", param.Name)
+	case ABITypeUint64:
+		i, ok := toInt64(val)
+		if !ok || i < 0 {
+			return 0, fmt.Errorf("parameter '%s' must be a non-negative integer", param.Name)
+		}
+		return i, nil
+	default:
+		i, ok := toInt64(val)
+		if !ok {
+			return 0, fmt.Errorf("parameter '%s' must be an integer", param.Name)
+		}
+		return i, nil
+	}
+}
+
+// decodeABIValue converts a stack value into its typed representation
+func decodeABIValue(param ABIParam, val int64) ReturnValue {
+	rv := ReturnValue{Name: param.Name, Type: param.Type}
+	switch param.Type {
+	case ABITypeBool:
+		rv.Value = val != 0
+	case ABITypeUint64:
+		rv.Value = uint64(val)
+	default:
+		rv.Value = val
+	}
+	return rv
+}
@@ -0,0 +1,169 @@
+package vm
+
+import (
+	"testing"
+)
+
+func TestContractABI(t *testing.T) {
+	jsonContract := &JSONContract{
+		Name:    "Counter",
+		Version: "1.0",
+		Functions: map[string]*JSONFunction{
+			"add": {
+				Inputs:  []ABIParam{{Name: "a", Type: ABITypeInt64}, {Name: "b", Type: ABITypeInt64}},
+				Outputs: []ABIParam{{Name: "sum", Type: ABITypeInt64}},
+				View:    true,
+				Code:    []JSONInstruction{{Op: "ADD"}},
+			},
+			"isPositive": {
+				Inputs:  []ABIParam{{Name: "value", Type: ABITypeInt64}},
+				Outputs: []ABIParam{{Name: "positive", Type: ABITypeBool}},
+				View:    true,
+				Code: []JSONInstruction{
+					{Op: "PUSH", Value: 0},
+					{Op: "GT"},
+				},
+			},
+			"increment": {
+				Parameters: []string{"amount"},
+				Code: []JSONInstruction{
+					{Op: "LOAD", Key: "counter"},
+					{Op: "ADD"},
+					{Op: "STORE", Key: "counter"},
+				},
+			},
+		},
+		Events: []ABIEvent{
+			{Name: "Incremented", Inputs: []ABIParam{{Name: "amount", Type: ABITypeInt64}}},
+		},
+		Storage: map[string]interface{}{},
+	}
+
+	contract, err := DeployJSONContract("owner1", jsonContract, true)
+	if err != nil {
+		t.Fatalf("Failed to deploy contract: %v", err)
+	}
+
+	t.Run("ABI Generated On Deploy", func(t *testing.T) {
+		if contract.ABI == nil {
+			t.Fatalf("Expected ABI to be generated on deploy")
+		}
+		if len(contract.ABI.Functions) != 3 {
+			t.Errorf("Expected 3 ABI functions, got %d", len(contract.ABI.Functions))
+		}
+		if contract.ABI.Functions[0].Name != "add" {
+			t.Errorf("Expected ABI functions to be sorted, got %s first", contract.ABI.Functions[0].Name)
+		}
+		add, ok := contract.ABI.GetFunction("add")
+		if !ok || add.StateMutability != MutabilityView {
+			t.Errorf("Expected add to be a view function")
+		}
+		inc, ok := contract.ABI.GetFunction("increment")
+		if !ok || inc.StateMutability != MutabilityMutating {
+			t.Errorf("Expected increment to be a mutating function")
+		}
+		if len(inc.Inputs) != 1 || inc.Inputs[0].Type != ABITypeInt64 {
+			t.Errorf("Expected legacy parameters to be typed as int64, got %v", inc.Inputs)
+		}
+		if len(contract.ABI.Events) != 1 || contract.ABI.Events[0].Name != "Incremented" {
+			t.Errorf("Expected event Incremented in ABI, got %v", contract.ABI.Events)
+		}
+	})
+
+	t.Run("ABI Round Trip", func(t *testing.T) {
+		data, err := MarshalABI(contract.ABI)
+		if err != nil {
+			t.Fatalf("Failed to marshal ABI: %v", err)
+		}
+		decoded, err := UnmarshalABI(data)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal ABI: %v", err)
+		}
+		if len(decoded.Functions) != len(contract.ABI.Functions) {
+			t.Errorf("Expected %d functions after round trip, got %d", len(contract.ABI.Functions), len(decoded.Functions))
+		}
+	})
+
+	t.Run("Named Parameters And Typed Returns", func(t *testing.T) {
+		vm := NewVM()
+		vm.RegisterSystemContract(contract.Address, []string{"add", "isPositive"})
+
+		context := NewExecutionContext("caller123", 1000)
+		results, err := contract.CallNamed("add", map[string]interface{}{"b": 3, "a": 5}, vm, context)
+		if err != nil {
+			t.Fatalf("Failed to call add: %v", err)
+		}
+		if len(results) != 1 || results[0].Name != "sum" || results[0].Value != int64(8) {
+			t.Errorf("Expected sum=8, got %v", results)
+		}
+
+		context = NewExecutionContext("caller123", 1000)
+		results, err = contract.CallNamed("isPositive", map[string]interface{}{"value": float64(4)}, vm, context)
+		if err != nil {
+			t.Fatalf("Failed to call isPositive: %v", err)
+		}
+		if len(results) != 1 || results[0].Value != true {
+			t.Errorf("Expected positive=true, got %v", results)
+		}
+	})
+
+	t.Run("Arguments Are Not Return Values", func(t *testing.T) {
+		leftover, err := DeployJSONContract("owner1", &JSONContract{
+			Name: "Leftover",
+			Functions: map[string]*JSONFunction{
+				// Both leave their argument on the stack
+				"forgets": {
+					Inputs:  []ABIParam{{Name: "x", Type: ABITypeInt64}},
+					Outputs: []ABIParam{{Name: "y", Type: ABITypeInt64}},
+					Code:    []JSONInstruction{{Op: "PUSH", Value: 1}, {Op: "POP"}},
+				},
+				"seven": {
+					Inputs:  []ABIParam{{Name: "x", Type: ABITypeInt64}},
+					Outputs: []ABIParam{{Name: "y", Type: ABITypeInt64}},
+					Code:    []JSONInstruction{{Op: "PUSH", Value: 7}},
+				},
+			},
+		}, true)
+		if err != nil {
+			t.Fatalf("Failed to deploy contract: %v", err)
+		}
+		vm := NewVM()
+		vm.RegisterSystemContract(leftover.Address, []string{"forgets", "seven"})
+
+		if results, err := leftover.Call("forgets", []interface{}{5}, vm, NewExecutionContext("caller123", 1000)); err == nil {
+			t.Errorf("Expected an error for a function returning nothing, got %v", results)
+		}
+		results, err := leftover.Call("seven", []interface{}{5}, vm, NewExecutionContext("caller123", 1000))
+		if err != nil {
+			t.Fatalf("Failed to call seven: %v", err)
+		}
+		if len(results) != 1 || results[0].Value != int64(7) {
+			t.Errorf("Expected y=7, got %v", results)
+		}
+	})
+
+	t.Run("Binding Errors", func(t *testing.T) {
+		vm := NewVM()
+		vm.RegisterSystemContract(contract.Address, []string{"add"})
+		context := NewExecutionContext("caller123", 1000)
+
+		if _, err := contract.CallNamed("add", map[string]interface{}{"a": 1}, vm, context); err == nil {
+			t.Errorf("Expected error for missing parameter")
+		}
+		if _, err := contract.CallNamed("add", map[string]interface{}{"a": 1, "b": 2, "c": 3}, vm, context); err == nil {
+			t.Errorf("Expected error for unknown parameter")
+		}
+	})
+
+	t.Run("Unsupported Type Rejected", func(t *testing.T) {
+		bad := &JSONContract{
+			Name: "Bad",
+			Functions: map[string]*JSONFunction{
+				"f": {Inputs: []ABIParam{{Name: "x", Type: "string"}}},
+			},
+		}
+		if _, err := DeployJSONContract("owner1", bad, true); err == nil {
+			t.Errorf("Expected deploy to fail for unsupported ABI type")
+		}
+	})
+}
@@ -0,0 +1,403 @@
+// Package asm implements a small assembly language for ATLAS VM contracts.
+//
+// A source file declares contract metadata, constants, storage and events,
+// followed by function sections:
+//
+//	.contract Counter
+//	.version 1.0.0
+//	.const STEP 1
+//	.storage counter 0
+//	.event Incremented(amount:int64)
+//
+//	.func increment(amount:int64) -> (total:int64)
+//	    LOAD counter
+//	    ADD
+//	    DUP
+//	    STORE counter
+//	    RETURN
+//	.end
+//
+// Labels ("loop:") are local to a function and may be used as JUMP/JUMPIF
+// targets. Comments start with ';' or '#'.
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"atlas-blockchain/pkg/vm"
+)
+
+// Program is an assembled contract
+type Program struct {
+	Name         string
+	Version      string
+	ContractType vm.ContractType
+	Storage      map[string]interface{}
+	Functions    map[string]*vm.Function
+	Events       []vm.ABIEvent
+
+	lines map[string][]int // Source line of each instruction, per function
+}
+
+// JSONContract converts the program to the deployable JSON contract format
+func (p *Program) JSONContract() *vm.JSONContract {
+	contract := &vm.JSONContract{
+		Name:         p.Name,
+		Version:      p.Version,
+		Functions:    make(map[string]*vm.JSONFunction, len(p.Functions)),
+		Storage:      p.Storage,
+		ContractType: p.ContractType,
+		Events:       p.Events,
+	}
+	for name, fn := range p.Functions {
+		jsonFn := &vm.JSONFunction{
+			Parameters: fn.Parameters,
+			Inputs:     fn.Inputs,
+			Outputs:    fn.Outputs,
+			View:       fn.View,
+			Code:       make([]vm.JSONInstruction, 0, len(fn.Code)),
+		}
+		for _, instr := range fn.Code {
+			jsonInstr := vm.JSONInstruction{Op: instr.Opcode}
+			if len(instr.Operands) == 1 {
+				switch Opcodes[instr.Opcode].Operand {
+				case OperandKey, OperandFunction, OperandEvent:
+					jsonInstr.Key, _ = instr.Operands[0].(string)
+				default:
+					jsonInstr.Value = instr.Operands[0]
+				}
+			}
+			jsonFn.Code = append(jsonFn.Code, jsonInstr)
+		}
+		contract.Functions[name] = jsonFn
+	}
+	return contract
+}
+
+// Assemble parses and compiles assembly source, then runs the static checks.
+// All errors found are returned as an ErrorList.
+func Assemble(src string) (*Program, error) {
+	a := &assembler{
+		prog: &Program{
+			Version:   "1.0.0",
+			Storage:   make(map[string]interface{}),
+			Functions: make(map[string]*vm.Function),
+			lines:     make(map[string][]int),
+		},
+		consts: make(map[string]int64),
+	}
+	a.parse(src)
+	if len(a.errs) > 0 {
+		return nil, a.errs
+	}
+
+	if err := Check(a.prog.Functions, a.prog.Events...); err != nil {
+		list := err.(ErrorList)
+		for _, e := range list {
+			if lines := a.prog.lines[e.Function]; e.PC >= 0 && e.PC < len(lines) {
+				e.Line = lines[e.PC]
+			}
+		}
+		return nil, list
+	}
+	return a.prog, nil
+}
+
+type pendingTarget struct {
+	pc    int
+	label string
+	line  int
+}
+
+type assembler struct {
+	prog   *Program
+	consts map[string]int64
+	errs   ErrorList
+
+	// Current function section
+	fn      *vm.Function
+	labels  map[string]int
+	targets []pendingTarget
+}
+
+func (a *assembler) errorf(line int, format string, args ...interface{}) {
+	e := &Error{Line: line, PC: -1, Msg: fmt.Sprintf(format, args...)}
+	if a.fn != nil {
+		e.Function = a.fn.Name
+	}
+	a.errs = append(a.errs, e)
+}
+
+func (a *assembler) parse(src string) {
+	for i, raw := range strings.Split(src, "\n") {
+		line := i + 1
+		text := stripComment(raw)
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, ".") {
+			a.directive(line, text)
+			continue
+		}
+
+		if a.fn == nil {
+			a.errorf(line, "instruction outside of a .func section")
+			continue
+		}
+
+		// Leading label, optionally followed by an instruction
+		if idx := strings.Index(text, ":"); idx > 0 && isIdent(text[:idx]) {
+			label := text[:idx]
+			if _, dup := a.labels[label]; dup {
+				a.errorf(line, "duplicate label '%s'", label)
+			}
+			a.labels[label] = len(a.fn.Code)
+			text = strings.TrimSpace(text[idx+1:])
+			if text == "" {
+				continue
+			}
+		}
+		a.instruction(line, text)
+	}
+
+	if a.fn != nil {
+		a.errorf(0, "missing .end for function '%s'", a.fn.Name)
+	}
+}
+
+func (a *assembler) directive(line int, text string) {
+	name, rest := splitFirst(text)
+	if a.fn != nil && name != ".end" {
+		a.errorf(line, "directive %s not allowed inside a function", name)
+		return
+	}
+
+	switch name {
+	case ".contract":
+		a.prog.Name = rest
+	case ".version":
+		a.prog.Version = rest
+	case ".type":
+		a.prog.ContractType = vm.ContractType(rest)
+	case ".const":
+		key, value := splitFirst(rest)
+		v, err := strconv.ParseInt(value, 0, 64)
+		if !isIdent(key) || err != nil {
+			a.errorf(line, "invalid constant: expected '.const NAME <integer>'")
+			return
+		}
+		a.consts[key] = v
+	case ".storage":
+		key, value := splitFirst(rest)
+		v, ok := a.value(value)
+		if key == "" || !ok {
+			a.errorf(line, "invalid storage: expected '.storage key <integer>'")
+			return
+		}
+		a.prog.Storage[key] = v
+	case ".event":
+		evName, params, ok := a.signature(line, rest)
+		if !ok {
+			return
+		}
+		a.prog.Events = append(a.prog.Events, vm.ABIEvent{Name: evName, Inputs: params})
+	case ".func":
+		a.beginFunction(line, rest)
+	case ".end":
+		if a.fn == nil {
+			a.errorf(line, ".end without .func")
+			return
+		}
+		a.endFunction()
+	default:
+		a.errorf(line, "unknown directive %s", name)
+	}
+}
+
+// beginFunction parses "name(a:int64, b) -> (out:bool) view"
+func (a *assembler) beginFunction(line int, rest string) {
+	view := false
+	if strings.HasSuffix(rest, " view") {
+		view = true
+		rest = strings.TrimSpace(strings.TrimSuffix(rest, " view"))
+	}
+	var outputs []vm.ABIParam
+	if idx := strings.Index(rest, "->"); idx >= 0 {
+		outText := strings.TrimSpace(rest[idx+2:])
+		rest = strings.TrimSpace(rest[:idx])
+		if !strings.HasPrefix(outText, "(") || !strings.HasSuffix(outText, ")") {
+			a.errorf(line, "outputs must be parenthesized")
+			return
+		}
+		var ok bool
+		if outputs, ok = a.params(line, outText[1:len(outText)-1]); !ok {
+			return
+		}
+	}
+	name, inputs, ok := a.signature(line, rest)
+	if !ok {
+		return
+	}
+	if _, dup := a.prog.Functions[name]; dup {
+		a.errorf(line, "duplicate function '%s'", name)
+	}
+
+	params := make([]string, len(inputs))
+	for i, p := range inputs {
+		params[i] = p.Name
+	}
+	a.fn = &vm.Function{
+		Name:       name,
+		Parameters: params,
+		Inputs:     inputs,
+		Outputs:    outputs,
+		View:       view,
+		Code:       make([]vm.Instruction, 0),
+	}
+	a.labels = make(map[string]int)
+	a.targets = nil
+}
+
+func (a *assembler) endFunction() {
+	for _, t := range a.targets {
+		pc, ok := a.labels[t.label]
+		if !ok {
+			a.errorf(t.line, "undefined label '%s'", t.label)
+			continue
+		}
+		a.fn.Code[t.pc].Operands = []interface{}{int64(pc)}
+	}
+	a.prog.Functions[a.fn.Name] = a.fn
+	a.fn = nil
+}
+
+func (a *assembler) instruction(line int, text string) {
+	op, operand := splitFirst(text)
+	op = strings.ToUpper(op)
+	info, ok := Opcodes[op]
+	if !ok {
+		a.errorf(line, "unknown opcode '%s'", op)
+		return
+	}
+
+	instr := vm.Instruction{Opcode: op}
+	pc := len(a.fn.Code)
+	switch {
+	case info.Operand == OperandNone:
+		if operand != "" {
+			a.errorf(line, "%s takes no operand", op)
+			return
+		}
+	case operand == "":
+		a.errorf(line, "%s expects an operand", op)
+		return
+	case info.Operand == OperandValue:
+		v, ok := a.value(operand)
+		if !ok {
+			a.errorf(line, "%s operand must be an integer, boolean or constant, got '%s'", op, operand)
+			return
+		}
+		instr.Operands = []interface{}{v}
+	case info.Operand == OperandTarget:
+		if n, err := strconv.ParseInt(operand, 10, 64); err == nil {
+			instr.Operands = []interface{}{n}
+		} else if isIdent(operand) {
+			a.targets = append(a.targets, pendingTarget{pc: pc, label: operand, line: line})
+		} else {
+			a.errorf(line, "invalid jump target '%s'", operand)
+			return
+		}
+	default:
+		instr.Operands = []interface{}{strings.Trim(operand, `"`)}
+	}
+	a.fn.Code = append(a.fn.Code, instr)
+	a.prog.lines[a.fn.Name] = append(a.prog.lines[a.fn.Name], line)
+}
+
+// signature parses "name(params)"
+func (a *assembler) signature(line int, text string) (string, []vm.ABIParam, bool) {
+	open := strings.Index(text, "(")
+	if open <= 0 || !strings.HasSuffix(text, ")") {
+		a.errorf(line, "expected 'name(params)', got '%s'", text)
+		return "", nil, false
+	}
+	name := strings.TrimSpace(text[:open])
+	if !isIdent(name) {
+		a.errorf(line, "invalid name '%s'", name)
+		return "", nil, false
+	}
+	params, ok := a.params(line, text[open+1:len(text)-1])
+	return name, params, ok
+}
+
+// params parses "a:int64, b:bool, c"; untyped parameters are int64
+func (a *assembler) params(line int, text string) ([]vm.ABIParam, bool) {
+	params := make([]vm.ABIParam, 0)
+	if strings.TrimSpace(text) == "" {
+		return params, true
+	}
+	for _, part := range strings.Split(text, ",") {
+		name, typ := strings.TrimSpace(part), vm.ABITypeInt64
+		if idx := strings.Index(name, ":"); idx >= 0 {
+			name, typ = strings.TrimSpace(name[:idx]), strings.TrimSpace(name[idx+1:])
+		}
+		if !isIdent(name) {
+			a.errorf(line, "invalid parameter name '%s'", name)
+			return nil, false
+		}
+		switch typ {
+		case vm.ABITypeInt64, vm.ABITypeUint64, vm.ABITypeBool:
+		default:
+			a.errorf(line, "unsupported parameter type '%s'", typ)
+			return nil, false
+		}
+		params = append(params, vm.ABIParam{Name: name, Type: typ})
+	}
+	return params, true
+}
+
+// value resolves an integer literal, boolean or constant name
+func (a *assembler) value(text string) (int64, bool) {
+	switch text {
+	case "true":
+		return 1, true
+	case "false":
+		return 0, true
+	}
+	if v, ok := a.consts[text]; ok {
+		return v, true
+	}
+	v, err := strconv.ParseInt(text, 0, 64)
+	return v, err == nil
+}
+
+func stripComment(line string) string {
+	if idx := strings.IndexAny(line, ";#"); idx >= 0 {
+		line = line[:idx]
+	}
+	return strings.TrimSpace(line)
+}
+
+func splitFirst(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if idx := strings.IndexAny(text, " \t"); idx >= 0 {
+		return text[:idx], strings.TrimSpace(text[idx+1:])
+	}
+	return text, ""
+}
+
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package asm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"atlas-blockchain/pkg/vm"
+)
+
+const counterSource = `
+.contract Counter
+.version 1.0.0
+.const LIMIT 10
+.storage counter 0
+.event Incremented(amount:int64)
+
+; Adds amount to the counter and returns the new total
+.func increment(amount:int64) -> (total:int64)
+    DUP
+    EMIT Incremented
+    LOAD counter
+    ADD
+    DUP
+    STORE counter
+    RETURN
+.end
+
+# Sums 1..n with a loop
+.func sumTo(n:int64) -> (sum:int64) view
+    PUSH 0
+    STORE acc
+loop:
+    DUP
+    JUMPIF body
+    POP
+    LOAD acc
+    RETURN
+body:
+    DUP
+    LOAD acc
+    ADD
+    STORE acc
+    PUSH 1
+    SUB
+    JUMP loop
+.end
+
+.func atLimit(value:int64) -> (ok:bool) view
+    PUSH LIMIT
+    EQ
+.end
+
+.func double(x:int64) -> (y:int64) view
+    DUP
+    CALL addTwo
+.end
+
+.func addTwo(a:int64, b:int64) -> (sum:int64) view
+    ADD
+.end
+`
+
+func TestAssemble(t *testing.T) {
+	prog, err := Assemble(counterSource)
+	if err != nil {
+		t.Fatalf("Failed to assemble: %v", err)
+	}
+
+	t.Run("Metadata", func(t *testing.T) {
+		if prog.Name != "Counter" || prog.Version != "1.0.0" {
+			t.Errorf("Expected Counter 1.0.0, got %s %s", prog.Name, prog.Version)
+		}
+		if len(prog.Functions) != 5 {
+			t.Errorf("Expected 5 functions, got %d", len(prog.Functions))
+		}
+		if len(prog.Events) != 1 || prog.Events[0].Name != "Incremented" {
+			t.Errorf("Expected event Incremented, got %v", prog.Events)
+		}
+		if !prog.Functions["sumTo"].View || prog.Functions["increment"].View {
+			t.Errorf("Expected only sumTo to be a view function")
+		}
+	})
+
+	t.Run("Labels And Constants Resolved", func(t *testing.T) {
+		code := prog.Functions["sumTo"].Code
+		if code[3].Opcode != "JUMPIF" || code[3].Operands[0] != int64(7) {
+			t.Errorf("Expected JUMPIF 7, got %v", code[3])
+		}
+		if code[13].Opcode != "JUMP" || code[13].Operands[0] != int64(2) {
+			t.Errorf("Expected JUMP 2, got %v", code[13])
+		}
+		if prog.Functions["atLimit"].Code[0].Operands[0] != int64(10) {
+			t.Errorf("Expected constant LIMIT to resolve to 10")
+		}
+	})
+
+	t.Run("Deploy And Execute", func(t *testing.T) {
+		data, err := json.Marshal(prog.JSONContract())
+		if err != nil {
+			t.Fatalf("Failed to marshal contract: %v", err)
+		}
+		var jsonContract vm.JSONContract
+		if err := json.Unmarshal(data, &jsonContract); err != nil {
+			t.Fatalf("Failed to unmarshal contract: %v", err)
+		}
+		contract, err := vm.DeployJSONContract("owner1", &jsonContract, true)
+		if err != nil {
+			t.Fatalf("Failed to deploy contract: %v", err)
+		}
+
+		machine := vm.NewVM()
+		machine.RegisterDeployedContract(contract)
+
+		results, err := contract.Call("increment", []interface{}{5}, machine, vm.NewExecutionContext("caller", 100000))
+		if err != nil {
+			t.Fatalf("Failed to call increment: %v", err)
+		}
+		if len(results) != 1 || results[0].Value != int64(5) {
+			t.Errorf("Expected total=5, got %v", results)
+		}
+		if len(machine.Logs) != 1 {
+			t.Fatalf("Expected 1 event, got %v", machine.Logs)
+		}
+		if l := machine.Logs[0]; l.Address != contract.Address || l.Event != "Incremented" || len(l.Fields) != 1 || l.Fields[0].Value != int64(5) {
+			t.Errorf("Expected Incremented(amount=5) from the contract, got %+v", l)
+		}
+
+		results, err = contract.Call("double", []interface{}{21}, machine, vm.NewExecutionContext("caller", 100000))
+		if err != nil {
+			t.Fatalf("Failed to call double: %v", err)
+		}
+		if len(results) != 1 || results[0].Value != int64(42) {
+			t.Errorf("Expected y=42, got %v", results)
+		}
+	})
+
+	t.Run("Disassemble Round Trip", func(t *testing.T) {
+		contract := &vm.Contract{
+			Name:      prog.Name,
+			Version:   prog.Version,
+			Functions: prog.Functions,
+			Storage:   prog.Storage,
+			ABI:       vm.BuildABI(prog.Functions, prog.Events),
+		}
+		src := Disassemble(contract)
+		again, err := Assemble(src)
+		if err != nil {
+			t.Fatalf("Failed to reassemble disassembly: %v\n%s", err, src)
+		}
+		for name, fn := range prog.Functions {
+			other := again.Functions[name]
+			if other == nil || len(other.Code) != len(fn.Code) {
+				t.Errorf("Function %s did not round trip", name)
+				continue
+			}
+			for pc := range fn.Code {
+				if fn.Code[pc].Opcode != other.Code[pc].Opcode {
+					t.Errorf("%s[%d]: expected %s, got %s", name, pc, fn.Code[pc].Opcode, other.Code[pc].Opcode)
+				}
+			}
+		}
+		if len(again.Events) != 1 {
+			t.Errorf("Expected events to round trip, got %v", again.Events)
+		}
+	})
+}
+
+func TestAssembleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Unknown Opcode", ".func f()\n    FOO\n.end", "line 2: f: unknown opcode 'FOO'"},
+		{"Undefined Label", ".func f()\n    JUMP nowhere\n.end", "line 2: f: undefined label 'nowhere'"},
+		{"Missing Operand", ".func f()\n    PUSH\n.end", "PUSH expects an operand"},
+		{"Bad Push Value", ".func f()\n    PUSH amount\n.end", "must be an integer"},
+		{"Undefined Call", ".func f()\n    CALL g\n.end", "CALL to undefined function 'g'"},
+		{"Stack Underflow", ".func f(a)\n    ADD\n.end", "line 2: f[0]: stack underflow: ADD needs 2 values, 1 available"},
+		{"Missing Outputs", ".func f() -> (x:int64)\n    PUSH 1\n    POP\n.end", "declares 1 outputs but leaves 0"},
+		{"Inconsistent Merge", ".func f(c)\n    JUMPIF skip\n    PUSH 1\nskip:\n    RETURN\n.end", "inconsistent stack depth"},
+		{"Missing End", ".func f()\n    PUSH 1", "missing .end"},
+		{"Undeclared Event", ".func f(a)\n    EMIT Missing\n.end", "line 2: f[0]: EMIT of undeclared event 'Missing'"},
+		{"Event Underflow", ".event Pair(a:int64, b:int64)\n.func f(a)\n    EMIT Pair\n.end", "stack underflow: EMIT needs 2 values, 1 available"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Assemble(tt.src)
+			if err == nil {
+				t.Fatalf("Expected error containing %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %q", tt.want, err.Error())
+			}
+		})
+	}
+}
+
+func TestCheckDeployedContract(t *testing.T) {
+	// JSON-decoded operands arrive as float64 and must still be accepted
+	functions := map[string]*vm.Function{
+		"f": {Name: "f", Code: []vm.Instruction{
+			{Opcode: "PUSH", Operands: []interface{}{float64(1)}},
+			{Opcode: "JUMP", Operands: []interface{}{float64(5)}},
+		}},
+	}
+	err := Check(functions)
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("Expected out of range jump error, got %v", err)
+	}
+}
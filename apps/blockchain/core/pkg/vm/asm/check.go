@@ -0,0 +1,270 @@
+package asm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"atlas-blockchain/pkg/vm"
+)
+
+// Error is a single assembler or static check failure
+type Error struct {
+	Line     int    // Source line, 0 when unknown
+	Function string // Function the error belongs to, if any
+	PC       int    // Instruction index within the function, -1 when not applicable
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Function != "" {
+		fmt.Fprintf(&b, "%s", e.Function)
+		if e.PC >= 0 {
+			fmt.Fprintf(&b, "[%d]", e.PC)
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Msg)
+	return b.String()
+}
+
+// ErrorList collects every error found in a source file or contract
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// err returns the list as an error, or nil when empty
+func (l ErrorList) err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// Check statically verifies contract functions: unknown opcodes, malformed
+// operands, out-of-range jumps, calls to missing functions, events missing
+// from events and stack depth.
+func Check(functions map[string]*vm.Function, events ...vm.ABIEvent) error {
+	c := &checker{
+		functions: functions,
+		events:    make(map[string]int, len(events)),
+		effects:   make(map[string]int),
+		visiting:  make(map[string]bool),
+	}
+	for _, event := range events {
+		c.events[event.Name] = len(event.Inputs)
+	}
+	for _, name := range sortedNames(functions) {
+		c.checkOperands(name, functions[name])
+	}
+	if len(c.errs) > 0 {
+		// Stack analysis assumes well-formed instructions
+		return c.errs.err()
+	}
+	for _, name := range sortedNames(functions) {
+		c.effect(name)
+	}
+	return c.errs.err()
+}
+
+type checker struct {
+	functions map[string]*vm.Function
+	events    map[string]int  // Number of fields of each declared event
+	effects   map[string]int  // Net stack effect of each analyzed function
+	visiting  map[string]bool // Functions currently being analyzed (recursion)
+	errs      ErrorList
+}
+
+func (c *checker) errorf(function string, pc int, format string, args ...interface{}) {
+	c.errs = append(c.errs, &Error{Function: function, PC: pc, Msg: fmt.Sprintf(format, args...)})
+}
+
+// checkOperands validates opcodes and operand shapes
+func (c *checker) checkOperands(name string, fn *vm.Function) {
+	for pc, instr := range fn.Code {
+		info, ok := Opcodes[instr.Opcode]
+		if !ok {
+			c.errorf(name, pc, "unknown opcode '%s'", instr.Opcode)
+			continue
+		}
+		if info.Operand == OperandNone {
+			if len(instr.Operands) != 0 {
+				c.errorf(name, pc, "%s takes no operand", instr.Opcode)
+			}
+			continue
+		}
+		if len(instr.Operands) != 1 {
+			c.errorf(name, pc, "%s expects 1 operand, got %d", instr.Opcode, len(instr.Operands))
+			continue
+		}
+		operand := instr.Operands[0]
+		switch info.Operand {
+		case OperandValue:
+			if _, ok := toInt64(operand); !ok {
+				c.errorf(name, pc, "%s operand must be an integer, got %v", instr.Opcode, operand)
+			}
+		case OperandKey:
+			if key, ok := operand.(string); !ok || key == "" {
+				c.errorf(name, pc, "%s operand must be a storage key", instr.Opcode)
+			}
+		case OperandTarget:
+			target, ok := toInt64(operand)
+			if !ok {
+				c.errorf(name, pc, "%s target must be an instruction index", instr.Opcode)
+			} else if target < 0 || target >= int64(len(fn.Code)) {
+				c.errorf(name, pc, "%s target %d out of range (function has %d instructions)", instr.Opcode, target, len(fn.Code))
+			}
+		case OperandFunction:
+			callee, ok := operand.(string)
+			if !ok {
+				c.errorf(name, pc, "CALL operand must be a function name")
+			} else if _, exists := c.functions[callee]; !exists {
+				c.errorf(name, pc, "CALL to undefined function '%s'", callee)
+			}
+		case OperandEvent:
+			event, ok := operand.(string)
+			if !ok {
+				c.errorf(name, pc, "EMIT operand must be an event name")
+			} else if _, exists := c.events[event]; !exists {
+				c.errorf(name, pc, "EMIT of undeclared event '%s'", event)
+			}
+		}
+	}
+}
+
+// effect returns the net stack effect of a function, analyzing it on first use
+func (c *checker) effect(name string) int {
+	if e, ok := c.effects[name]; ok {
+		return e
+	}
+	fn := c.functions[name]
+	if c.visiting[name] {
+		// Recursive call: trust the declared signature
+		return len(fn.Outputs) - entryDepth(fn)
+	}
+	c.visiting[name] = true
+	e := c.analyze(name, fn)
+	delete(c.visiting, name)
+	c.effects[name] = e
+	return e
+}
+
+// analyze walks every path through a function tracking the stack depth
+func (c *checker) analyze(name string, fn *vm.Function) int {
+	entry := entryDepth(fn)
+	if len(fn.Code) == 0 {
+		return 0
+	}
+
+	depth := make([]int, len(fn.Code))
+	for i := range depth {
+		depth[i] = -1
+	}
+	exit := -1
+	work := []int{0}
+	depth[0] = entry
+
+	flow := func(from, to, d int) {
+		if to >= len(fn.Code) {
+			if exit == -1 {
+				exit = d
+			} else if exit != d {
+				c.errorf(name, from, "inconsistent stack depth at function exit: %d vs %d", exit, d)
+			}
+			return
+		}
+		if depth[to] == -1 {
+			depth[to] = d
+			work = append(work, to)
+		} else if depth[to] != d {
+			c.errorf(name, from, "inconsistent stack depth at instruction %d: %d vs %d", to, depth[to], d)
+		}
+	}
+
+	for len(work) > 0 {
+		pc := work[len(work)-1]
+		work = work[:len(work)-1]
+		instr := fn.Code[pc]
+		info := Opcodes[instr.Opcode]
+		d := depth[pc]
+
+		pops, pushes := info.Pops, info.Pushes
+		if instr.Opcode == "CALL" {
+			callee := instr.Operands[0].(string)
+			pops = entryDepth(c.functions[callee])
+			pushes = pops + c.effect(callee)
+		}
+		if instr.Opcode == "EMIT" {
+			pops = c.events[instr.Operands[0].(string)]
+		}
+		if d < pops {
+			c.errorf(name, pc, "stack underflow: %s needs %d values, %d available", instr.Opcode, pops, d)
+			continue
+		}
+		next := d - pops + pushes
+
+		switch instr.Opcode {
+		case "RETURN":
+			flow(pc, len(fn.Code), next)
+		case "JUMP":
+			target, _ := toInt64(instr.Operands[0])
+			flow(pc, int(target), next)
+		case "JUMPIF":
+			target, _ := toInt64(instr.Operands[0])
+			flow(pc, pc+1, next)
+			flow(pc, int(target), next)
+		default:
+			flow(pc, pc+1, next)
+		}
+	}
+
+	if exit == -1 {
+		// No path reaches the end: every path loops forever (bounded only by gas)
+		return len(fn.Outputs) - entry
+	}
+	if exit < len(fn.Outputs) {
+		c.errorf(name, -1, "declares %d outputs but leaves %d values on the stack", len(fn.Outputs), exit)
+	}
+	return exit - entry
+}
+
+// entryDepth is the stack depth at function entry: one slot per parameter
+func entryDepth(fn *vm.Function) int {
+	if len(fn.Inputs) > 0 {
+		return len(fn.Inputs)
+	}
+	return len(fn.Parameters)
+}
+
+func sortedNames(functions map[string]*vm.Function) []string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// toInt64 mirrors the VM's operand conversion, including JSON-decoded floats
+func toInt64(val interface{}) (int64, bool) {
+	switch v := val.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), v == float64(int64(v))
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,111 @@
+package asm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"atlas-blockchain/pkg/vm"
+)
+
+// Disassemble renders a deployed contract as assembly source that can be
+// fed back into Assemble
+func Disassemble(c *vm.Contract) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "; address: %s\n", c.Address)
+	fmt.Fprintf(&b, ".contract %s\n", c.Name)
+	if c.Version != "" {
+		fmt.Fprintf(&b, ".version %s\n", c.Version)
+	}
+	if c.ContractType != "" {
+		fmt.Fprintf(&b, ".type %s\n", c.ContractType)
+	}
+
+	keys := make([]string, 0, len(c.Storage))
+	for k := range c.Storage {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if v, ok := toInt64(c.Storage[k]); ok {
+			fmt.Fprintf(&b, ".storage %s %d\n", k, v)
+		} else {
+			fmt.Fprintf(&b, "; .storage %s %v (non-integer value)\n", k, c.Storage[k])
+		}
+	}
+	if c.ABI != nil {
+		for _, ev := range c.ABI.Events {
+			fmt.Fprintf(&b, ".event %s(%s)\n", ev.Name, formatParams(ev.Inputs))
+		}
+	}
+
+	for _, name := range sortedNames(c.Functions) {
+		b.WriteString("\n")
+		b.WriteString(DisassembleFunction(c.Functions[name]))
+	}
+	return b.String()
+}
+
+// DisassembleFunction renders a single function section, naming jump
+// targets with generated labels
+func DisassembleFunction(fn *vm.Function) string {
+	var b strings.Builder
+
+	inputs := fn.Inputs
+	if len(inputs) == 0 {
+		for _, p := range fn.Parameters {
+			inputs = append(inputs, vm.ABIParam{Name: p, Type: vm.ABITypeInt64})
+		}
+	}
+	fmt.Fprintf(&b, ".func %s(%s)", fn.Name, formatParams(inputs))
+	if len(fn.Outputs) > 0 {
+		fmt.Fprintf(&b, " -> (%s)", formatParams(fn.Outputs))
+	}
+	if fn.View {
+		b.WriteString(" view")
+	}
+	b.WriteString("\n")
+
+	labels := make(map[int64]bool)
+	for _, instr := range fn.Code {
+		if Opcodes[instr.Opcode].Operand == OperandTarget && len(instr.Operands) == 1 {
+			if target, ok := toInt64(instr.Operands[0]); ok && target >= 0 && target < int64(len(fn.Code)) {
+				labels[target] = true
+			}
+		}
+	}
+
+	for pc, instr := range fn.Code {
+		if labels[int64(pc)] {
+			fmt.Fprintf(&b, "L%d:\n", pc)
+		}
+		fmt.Fprintf(&b, "    %-8s", instr.Opcode)
+		if len(instr.Operands) > 0 {
+			b.WriteString(formatOperand(instr))
+		}
+		fmt.Fprintf(&b, " ; %d\n", pc)
+	}
+	b.WriteString(".end\n")
+	return b.String()
+}
+
+func formatOperand(instr vm.Instruction) string {
+	operand := instr.Operands[0]
+	if Opcodes[instr.Opcode].Operand == OperandTarget {
+		if target, ok := toInt64(operand); ok {
+			return fmt.Sprintf("L%d", target)
+		}
+	}
+	if v, ok := toInt64(operand); ok {
+		return fmt.Sprintf("%d", v)
+	}
+	return fmt.Sprintf("%v", operand)
+}
+
+func formatParams(params []vm.ABIParam) string {
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = p.Name + ":" + p.Type
+	}
+	return strings.Join(parts, ", ")
+}
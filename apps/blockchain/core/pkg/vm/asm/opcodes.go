@@ -0,0 +1,46 @@
+package asm
+
+// OperandKind describes what an instruction operand refers to
+type OperandKind int
+
+const (
+	OperandNone     OperandKind = iota
+	OperandValue                // Integer literal or constant
+	OperandKey                  // Storage key
+	OperandTarget               // Jump target (label or instruction index)
+	OperandFunction             // Function name
+	OperandEvent                // Event name; pops one value per event field
+)
+
+// OpInfo describes the stack effect and operand of a VM opcode
+type OpInfo struct {
+	Pops    int
+	Pushes  int
+	Operand OperandKind
+}
+
+// Opcodes lists every opcode the VM understands
+var Opcodes = map[string]OpInfo{
+	"PUSH":   {Pops: 0, Pushes: 1, Operand: OperandValue},
+	"POP":    {Pops: 1, Pushes: 0},
+	"ADD":    {Pops: 2, Pushes: 1},
+	"SUB":    {Pops: 2, Pushes: 1},
+	"MUL":    {Pops: 2, Pushes: 1},
+	"DIV":    {Pops: 2, Pushes: 1},
+	"STORE":  {Pops: 1, Pushes: 0, Operand: OperandKey},
+	"LOAD":   {Pops: 0, Pushes: 1, Operand: OperandKey},
+	"JUMP":   {Pops: 0, Pushes: 0, Operand: OperandTarget},
+	"JUMPIF": {Pops: 1, Pushes: 0, Operand: OperandTarget},
+	"CALL":   {Pops: 0, Pushes: 0, Operand: OperandFunction},
+	"RETURN": {Pops: 0, Pushes: 0},
+	"DUP":    {Pops: 1, Pushes: 2},
+	"SWAP":   {Pops: 2, Pushes: 2},
+	"GT":     {Pops: 2, Pushes: 1},
+	"LT":     {Pops: 2, Pushes: 1},
+	"EQ":     {Pops: 2, Pushes: 1},
+	"NEQ":    {Pops: 2, Pushes: 1},
+	"AND":    {Pops: 2, Pushes: 1},
+	"OR":     {Pops: 2, Pushes: 1},
+	"NOT":    {Pops: 1, Pushes: 1},
+	"EMIT":   {Pops: 0, Pushes: 0, Operand: OperandEvent},
+}
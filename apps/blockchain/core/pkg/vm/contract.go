@@ -20,6 +20,8 @@ type Contract struct {
 	CreatedAt   int64
 	UpdatedAt   int64
 	ContractType ContractType // Type of contract (system, governance, custom, voting)
+	ABI          *ABI         // Interface schema generated on deploy
+	CodeHistory  []CodeVersion // Code hash of every deployed version, oldest first
 }
 
 // Function represents a contract function
@@ -27,6 +29,9 @@ type Function struct {
 	Name       string
 	Parameters []string
 	Code       []Instruction
+	Inputs     []ABIParam // Typed inputs; empty means Parameters are int64
+	Outputs    []ABIParam // Typed return values, read from the top of the stack
+	View       bool       // Function does not modify storage
 }
 
 // JSONContract represents a contract in JSON format
@@ -37,12 +42,16 @@ type JSONContract struct {
 	Functions map[string]*JSONFunction `json:"functions"`
 	Storage   map[string]interface{} `json:"storage"`
 	ContractType ContractType        `json:"contract_type,omitempty"`
+	Events    []ABIEvent             `json:"events,omitempty"`
 }
 
 // JSONFunction represents a function in JSON format
 type JSONFunction struct {
 	Parameters []string        `json:"params"`
 	Code       []JSONInstruction `json:"code"`
+	Inputs     []ABIParam        `json:"inputs,omitempty"`
+	Outputs    []ABIParam        `json:"outputs,omitempty"`
+	View       bool              `json:"view,omitempty"`
 }
 
 // JSONInstruction represents an instruction in JSON format
@@ -84,12 +93,48 @@ func DeployJSONContract(owner string, jsonContract *JSONContract, upgradable boo
 		ContractType: jsonContract.ContractType,
 	}
 	
-	// Parse functions
+	functions, err := parseJSONFunctions(jsonContract)
+	if err != nil {
+		return nil, err
+	}
+	contract.Functions = functions
+	contract.ABI = BuildABI(contract.Functions, jsonContract.Events)
+	contract.CodeHistory = []CodeVersion{{
+		Version:    contract.Version,
+		CodeHash:   CodeHash(contract.Functions),
+		UpgradedAt: contract.CreatedAt,
+	}}
+	
+	// Generate proper address based on contract content
+	contract.Address = generateContractAddress(owner, contract.Code)
+	
+	return contract, nil
+}
+
+// parseJSONFunctions converts and validates the functions and events of a JSON contract
+func parseJSONFunctions(jsonContract *JSONContract) (map[string]*Function, error) {
+	functions := make(map[string]*Function)
 	for funcName, jsonFunc := range jsonContract.Functions {
 		function := &Function{
 			Name:       funcName,
 			Parameters: jsonFunc.Parameters,
 			Code:       make([]Instruction, 0),
+			Inputs:     jsonFunc.Inputs,
+			Outputs:    jsonFunc.Outputs,
+			View:       jsonFunc.View,
+		}
+		
+		// Typed inputs take precedence over the legacy name list
+		if len(jsonFunc.Inputs) > 0 {
+			function.Parameters = make([]string, 0, len(jsonFunc.Inputs))
+			for _, input := range jsonFunc.Inputs {
+				function.Parameters = append(function.Parameters, input.Name)
+			}
+		}
+		for _, param := range append(append([]ABIParam{}, jsonFunc.Inputs...), jsonFunc.Outputs...) {
+			if err := validateABIType(param.Type); err != nil {
+				return nil, fmt.Errorf("function '%s': %v", funcName, err)
+			}
 		}
 		
 		// Convert JSON instructions to VM instructions
@@ -108,7 +153,7 @@ func DeployJSONContract(owner string, jsonContract *JSONContract, upgradable boo
 				if jsonInstr.Value != nil {
 					instr.Operands = []interface{}{jsonInstr.Value}
 				}
-			case "CALL":
+			case "CALL", "EMIT":
 				if jsonInstr.Key != "" {
 					instr.Operands = []interface{}{jsonInstr.Key}
 				}
@@ -117,13 +162,17 @@ func DeployJSONContract(owner string, jsonContract *JSONContract, upgradable boo
 			function.Code = append(function.Code, instr)
 		}
 		
-		contract.Functions[funcName] = function
+		functions[funcName] = function
 	}
 	
-	// Generate proper address based on contract content
-	contract.Address = generateContractAddress(owner, contract.Code)
-	
-	return contract, nil
+	for _, event := range jsonContract.Events {
+		for _, param := range event.Inputs {
+			if err := validateABIType(param.Type); err != nil {
+				return nil, fmt.Errorf("event '%s': %v", event.Name, err)
+			}
+		}
+	}
+	return functions, nil
 }
 
 // CallFunction executes a specific function on a contract
@@ -144,19 +193,59 @@ func (c *Contract) CallFunction(functionName string, params []interface{}, vm *V
 		return fmt.Errorf("function '%s' expects %d parameters, got %d", functionName, len(function.Parameters), len(params))
 	}
 	
-	// Push parameters onto stack
-	for _, param := range params {
-		if val, ok := toInt64(param); ok {
-			vm.stack = append(vm.stack, val)
-		} else {
-			return fmt.Errorf("invalid parameter type for function '%s'", functionName)
+	// Push parameters onto stack, encoded according to their ABI types
+	inputs := function.inputParams()
+	for i, param := range params {
+		val, err := encodeABIValue(inputs[i], param)
+		if err != nil {
+			return fmt.Errorf("invalid parameter type for function '%s': %v", functionName, err)
 		}
+		vm.stack = append(vm.stack, val)
 	}
 	
 	// Execute function code
 	return vm.Execute(function.Code, context)
 }
 
+// Call executes a function and decodes its declared outputs from the stack
+func (c *Contract) Call(functionName string, params []interface{}, vm *VM, context *ExecutionContext) ([]ReturnValue, error) {
+	// Arguments are pushed above the current stack. Values below the lowest
+	// height the function then reaches are arguments it left untouched, not
+	// return values.
+	vm.stackLow = len(vm.stack) + len(params)
+	if err := c.CallFunction(functionName, params, vm, context); err != nil {
+		return nil, err
+	}
+	
+	function := c.Functions[functionName]
+	outputs := function.Outputs
+	produced := len(vm.stack) - vm.stackLow
+	if produced < len(outputs) {
+		return nil, fmt.Errorf("function '%s' returned %d values, ABI declares %d", functionName, produced, len(outputs))
+	}
+	
+	results := make([]ReturnValue, len(outputs))
+	top := vm.stack[len(vm.stack)-len(outputs):]
+	for i, output := range outputs {
+		results[i] = decodeABIValue(output, top[i])
+	}
+	vm.stack = vm.stack[:len(vm.stack)-len(outputs)]
+	return results, nil
+}
+
+// CallNamed executes a function with arguments bound by parameter name
+func (c *Contract) CallNamed(functionName string, args map[string]interface{}, vm *VM, context *ExecutionContext) ([]ReturnValue, error) {
+	function, exists := c.Functions[functionName]
+	if !exists {
+		return nil, fmt.Errorf("function '%s' not found in contract", functionName)
+	}
+	params, err := function.BindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return c.Call(functionName, params, vm, context)
+}
+
 // generateContractAddress creates a unique address for a contract.
 func generateContractAddress(owner string, code []Instruction) string {
 	// Create a hash of owner + timestamp + code for uniqueness
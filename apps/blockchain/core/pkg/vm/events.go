@@ -0,0 +1,45 @@
+package vm
+
+import "fmt"
+
+// Log is an event emitted by a contract during execution. The event name
+// is its topic, which subscribers filter on.
+type Log struct {
+	Address string        `json:"address"` // Contract that emitted the event
+	Event   string        `json:"event"`
+	Fields  []ReturnValue `json:"fields"` // Values of the event's ABI inputs, in order
+}
+
+// findEvent looks up an event declared in the ABI of the running contract
+func (vm *VM) findEvent(name string) (*ABIEvent, bool) {
+	if vm.currentContract == nil || vm.currentContract.ABI == nil {
+		return nil, false
+	}
+	for i := range vm.currentContract.ABI.Events {
+		if vm.currentContract.ABI.Events[i].Name == name {
+			return &vm.currentContract.ABI.Events[i], true
+		}
+	}
+	return nil, false
+}
+
+// emit pops the fields of an event off the stack, first field deepest,
+// and records the event in vm.Logs
+func (vm *VM) emit(name string, context *ExecutionContext) error {
+	event, ok := vm.findEvent(name)
+	if !ok {
+		return fmt.Errorf("event '%s' is not declared by the contract", name)
+	}
+	n := len(event.Inputs)
+	if len(vm.stack) < n {
+		return fmt.Errorf("EMIT %s needs %d values on stack", name, n)
+	}
+	values := vm.stack[len(vm.stack)-n:]
+	fields := make([]ReturnValue, n)
+	for i, input := range event.Inputs {
+		fields[i] = decodeABIValue(input, values[i])
+	}
+	vm.drop(n)
+	vm.Logs = append(vm.Logs, Log{Address: context.ContractAddress, Event: name, Fields: fields})
+	return nil
+}
@@ -0,0 +1,99 @@
+package vm
+
+// Tracer observes VM execution one instruction at a time. Set VM.Tracer to
+// enable tracing; hooks run synchronously, so a tracer may block to pause
+// execution (e.g. an interactive debugger).
+type Tracer interface {
+	// BeforeStep is called before an instruction runs, with the stack it will see
+	BeforeStep(step *Step)
+	// AfterStep is called once the instruction has run, with the resulting
+	// stack, memory writes and gas, or the error that stopped execution
+	AfterStep(step *Step, err error)
+}
+
+// Step is the VM state around a single instruction
+type Step struct {
+	Depth        int                     `json:"depth"` // Call depth, 0 for the called function
+	Function     string                  `json:"function"`
+	PC           int                     `json:"pc"`
+	Opcode       string                  `json:"op"`
+	Operands     []interface{}           `json:"operands,omitempty"`
+	GasRemaining uint64                  `json:"gas"` // Gas left before the instruction
+	GasCost      uint64                  `json:"gas_cost"`
+	Stack        []int64                 `json:"stack"` // Before the instruction in BeforeStep, after it in AfterStep
+	MemoryDiff   map[string]MemoryChange `json:"memory_diff,omitempty"`
+	Error        string                  `json:"error,omitempty"`
+}
+
+// MemoryChange is a storage slot written by an instruction. Old is nil when
+// the slot did not exist before.
+type MemoryChange struct {
+	Old *int64 `json:"old"`
+	New int64  `json:"new"`
+}
+
+// StructLogger is a Tracer that records every completed step, for JSON traces
+type StructLogger struct {
+	Steps []*Step `json:"steps"`
+}
+
+// NewStructLogger creates an empty step recorder
+func NewStructLogger() *StructLogger {
+	return &StructLogger{Steps: make([]*Step, 0)}
+}
+
+// BeforeStep implements Tracer
+func (l *StructLogger) BeforeStep(step *Step) {}
+
+// AfterStep implements Tracer
+func (l *StructLogger) AfterStep(step *Step, err error) {
+	l.Steps = append(l.Steps, step)
+}
+
+// beginStep builds the step for the instruction at pc and notifies the tracer
+func (vm *VM) beginStep(instr Instruction, pc int, context *ExecutionContext) *Step {
+	function := vm.currentFunction
+	if function == "" {
+		function = context.FunctionName
+	}
+	step := &Step{
+		Depth:        len(vm.callStack),
+		Function:     function,
+		PC:           pc,
+		Opcode:       instr.Opcode,
+		Operands:     instr.Operands,
+		GasRemaining: vm.gasLimit - vm.gasUsed,
+		GasCost:      GasCosts[instr.Opcode],
+		Stack:        append([]int64(nil), vm.stack...),
+	}
+	if instr.Opcode == "STORE" && len(instr.Operands) == 1 {
+		// Remember the previous value so the write can be reported as a diff
+		if key, ok := instr.Operands[0].(string); ok {
+			change := MemoryChange{}
+			if old, exists := vm.Memory[key]; exists {
+				change.Old = &old
+			}
+			step.MemoryDiff = map[string]MemoryChange{key: change}
+		}
+	}
+	vm.Tracer.BeforeStep(step)
+	return step
+}
+
+// endStep completes a step with the post-instruction state and notifies the tracer
+func (vm *VM) endStep(step *Step, err error) {
+	done := *step
+	done.Stack = append([]int64(nil), vm.stack...)
+	if err != nil {
+		done.Error = err.Error()
+		done.MemoryDiff = nil
+	} else if len(step.MemoryDiff) > 0 {
+		diff := make(map[string]MemoryChange, len(step.MemoryDiff))
+		for key, change := range step.MemoryDiff {
+			change.New = vm.Memory[key]
+			diff[key] = change
+		}
+		done.MemoryDiff = diff
+	}
+	vm.Tracer.AfterStep(&done, err)
+}
@@ -0,0 +1,90 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTracer(t *testing.T) {
+	contract, err := DeployJSONContract("owner1", &JSONContract{
+		Name: "Traced",
+		Functions: map[string]*JSONFunction{
+			"double": {
+				Inputs: []ABIParam{{Name: "x", Type: ABITypeInt64}},
+				Code: []JSONInstruction{
+					{Op: "DUP"},
+					{Op: "CALL", Key: "add"},
+					{Op: "STORE", Key: "result"},
+				},
+			},
+			"add": {
+				Parameters: []string{"a", "b"},
+				Code:       []JSONInstruction{{Op: "ADD"}},
+			},
+			"fail": {
+				Code: []JSONInstruction{
+					{Op: "PUSH", Value: 1},
+					{Op: "PUSH", Value: 0},
+					{Op: "DIV"},
+				},
+			},
+		},
+	}, false)
+	if err != nil {
+		t.Fatalf("Failed to deploy contract: %v", err)
+	}
+
+	t.Run("Records Every Step", func(t *testing.T) {
+		vm := NewVM()
+		vm.RegisterDeployedContract(contract)
+		vm.Memory["result"] = 1
+		logger := NewStructLogger()
+		vm.Tracer = logger
+
+		if err := contract.CallFunction("double", []interface{}{21}, vm, NewExecutionContext("caller", 1000)); err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+
+		ops := make([]string, len(logger.Steps))
+		for i, step := range logger.Steps {
+			ops[i] = step.Function + ":" + step.Opcode
+		}
+		// The nested ADD completes before the CALL that entered it
+		want := "double:DUP add:ADD double:CALL double:STORE"
+		if got := strings.Join(ops, " "); got != want {
+			t.Errorf("Unexpected steps: %s", got)
+		}
+
+		add := logger.Steps[1]
+		if add.Depth != 1 {
+			t.Errorf("Expected nested step at depth 1, got %d", add.Depth)
+		}
+		if len(add.Stack) != 1 || add.Stack[0] != 42 {
+			t.Errorf("Expected stack [42] after ADD, got %v", add.Stack)
+		}
+
+		store := logger.Steps[3]
+		change, ok := store.MemoryDiff["result"]
+		if !ok || change.Old == nil || *change.Old != 1 || change.New != 42 {
+			t.Errorf("Expected memory diff result 1 -> 42, got %+v", store.MemoryDiff)
+		}
+		if store.GasCost != GasCosts["STORE"] {
+			t.Errorf("Expected STORE gas cost %d, got %d", GasCosts["STORE"], store.GasCost)
+		}
+	})
+
+	t.Run("Reports Faults", func(t *testing.T) {
+		vm := NewVM()
+		vm.RegisterDeployedContract(contract)
+		logger := NewStructLogger()
+		vm.Tracer = logger
+
+		if err := contract.CallFunction("fail", nil, vm, NewExecutionContext("caller", 1000)); err == nil {
+			t.Fatalf("Expected division by zero")
+		}
+		last := logger.Steps[len(logger.Steps)-1]
+		if last.Opcode != "DIV" || !strings.Contains(last.Error, "division by zero") {
+			t.Errorf("Expected faulting DIV step, got %+v", last)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package vm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNotUpgradable is returned when upgrading a contract deployed as immutable
+var ErrNotUpgradable = errors.New("contract is not upgradable")
+
+// CodeVersion records the code deployed under a contract version
+type CodeVersion struct {
+	Version     string `json:"version"`
+	CodeHash    string `json:"code_hash"`
+	BlockHeight int64  `json:"block_height"`
+	UpgradedAt  int64  `json:"upgraded_at"`
+}
+
+// CodeHash returns the SHA-256 of the functions' canonical JSON encoding
+func CodeHash(functions map[string]*Function) string {
+	// Map keys are sorted by encoding/json, so the encoding is stable
+	data, err := json.Marshal(functions)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// Upgraded returns a copy of the contract running the code of newCode.
+// Address, owner, type and storage are kept, the version is bumped and the
+// new code hash is appended to the history, stamped with the height and
+// time of the block applying the upgrade. The receiver is not modified.
+func (c *Contract) Upgraded(newCode *JSONContract, blockHeight, blockTime int64) (*Contract, error) {
+	if !c.Upgradable {
+		return nil, ErrNotUpgradable
+	}
+	if newCode == nil || len(newCode.Functions) == 0 {
+		return nil, fmt.Errorf("upgrade must contain at least one function")
+	}
+
+	functions, err := parseJSONFunctions(newCode)
+	if err != nil {
+		return nil, err
+	}
+	codeHash := CodeHash(functions)
+	if codeHash == CodeHash(c.Functions) {
+		return nil, fmt.Errorf("new code is identical to version %s", c.Version)
+	}
+
+	version := newCode.Version
+	if version == "" {
+		version = nextVersion(c.Version)
+	}
+	for _, prev := range c.history() {
+		if prev.Version == version {
+			return nil, fmt.Errorf("version %s was already deployed", version)
+		}
+	}
+
+	upgraded := *c
+	upgraded.Version = version
+	upgraded.Functions = functions
+	upgraded.Storage = make(map[string]interface{}, len(c.Storage))
+	for k, v := range c.Storage {
+		upgraded.Storage[k] = v
+	}
+	upgraded.ABI = BuildABI(functions, newCode.Events)
+	upgraded.UpdatedAt = blockTime
+	upgraded.CodeHistory = append(c.history(), CodeVersion{
+		Version:     version,
+		CodeHash:    codeHash,
+		BlockHeight: blockHeight,
+		UpgradedAt:  blockTime,
+	})
+	return &upgraded, nil
+}
+
+// history returns a copy of the code history, seeding it with the current
+// code for contracts deployed before history was kept
+func (c *Contract) history() []CodeVersion {
+	if len(c.CodeHistory) == 0 {
+		return []CodeVersion{{Version: c.Version, CodeHash: CodeHash(c.Functions), UpgradedAt: c.CreatedAt}}
+	}
+	return append([]CodeVersion(nil), c.CodeHistory...)
+}
+
+// nextVersion increments the last numeric component of a version ("1.0.0" -> "1.0.1")
+func nextVersion(version string) string {
+	if version == "" {
+		return "1.0.1"
+	}
+	idx := strings.LastIndex(version, ".")
+	n, err := strconv.Atoi(version[idx+1:])
+	if err != nil {
+		return version + ".1"
+	}
+	return version[:idx+1] + strconv.Itoa(n+1)
+}
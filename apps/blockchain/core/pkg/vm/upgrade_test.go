@@ -0,0 +1,96 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestContractUpgrade(t *testing.T) {
+	counter := func(step int) *JSONContract {
+		return &JSONContract{
+			Name: "Counter",
+			Functions: map[string]*JSONFunction{
+				"increment": {
+					Code: []JSONInstruction{
+						{Op: "LOAD", Key: "counter"},
+						{Op: "PUSH", Value: step},
+						{Op: "ADD"},
+						{Op: "STORE", Key: "counter"},
+					},
+				},
+			},
+		}
+	}
+	v1 := counter(1)
+	v1.Version = "1.0.0"
+	v1.Storage = map[string]interface{}{"counter": int64(5)}
+
+	contract, err := DeployJSONContract("owner1", v1, true)
+	if err != nil {
+		t.Fatalf("Failed to deploy contract: %v", err)
+	}
+
+	t.Run("Keeps Address And Storage", func(t *testing.T) {
+		upgraded, err := contract.Upgraded(counter(2), 10, 1700000000)
+		if err != nil {
+			t.Fatalf("Failed to upgrade: %v", err)
+		}
+		if upgraded.Address != contract.Address || upgraded.Owner != contract.Owner {
+			t.Errorf("Expected address and owner to be preserved")
+		}
+		if upgraded.Storage["counter"] != int64(5) {
+			t.Errorf("Expected storage to be preserved, got %v", upgraded.Storage)
+		}
+		if upgraded.Version != "1.0.1" {
+			t.Errorf("Expected version 1.0.1, got %s", upgraded.Version)
+		}
+		if len(upgraded.CodeHistory) != 2 || upgraded.CodeHistory[1].BlockHeight != 10 {
+			t.Errorf("Expected code history with 2 entries, got %v", upgraded.CodeHistory)
+		}
+		if upgraded.CodeHistory[1].UpgradedAt != 1700000000 || upgraded.UpdatedAt != 1700000000 {
+			t.Errorf("Expected the upgrade to be stamped with the block time, got %v", upgraded.CodeHistory[1])
+		}
+		if upgraded.CodeHistory[1].CodeHash != CodeHash(upgraded.Functions) {
+			t.Errorf("Expected history to record the new code hash")
+		}
+		if contract.Version != "1.0.0" || len(contract.CodeHistory) != 1 {
+			t.Errorf("Expected original contract to be unchanged")
+		}
+
+		upgraded.Storage["counter"] = int64(7)
+		if contract.Storage["counter"] != int64(5) {
+			t.Errorf("Expected upgraded storage to be a copy")
+		}
+	})
+
+	t.Run("Explicit Version", func(t *testing.T) {
+		next := counter(3)
+		next.Version = "2.0.0"
+		upgraded, err := contract.Upgraded(next, 11, 1700000000)
+		if err != nil {
+			t.Fatalf("Failed to upgrade: %v", err)
+		}
+		if upgraded.Version != "2.0.0" {
+			t.Errorf("Expected version 2.0.0, got %s", upgraded.Version)
+		}
+
+		next.Version = "1.0.0"
+		if _, err := contract.Upgraded(next, 11, 1700000000); err == nil {
+			t.Errorf("Expected error when reusing a deployed version")
+		}
+	})
+
+	t.Run("Rejected Upgrades", func(t *testing.T) {
+		if _, err := contract.Upgraded(counter(1), 12, 1700000000); err == nil {
+			t.Errorf("Expected error for identical code")
+		}
+
+		immutable, err := DeployJSONContract("owner1", counter(1), false)
+		if err != nil {
+			t.Fatalf("Failed to deploy contract: %v", err)
+		}
+		if _, err := immutable.Upgraded(counter(2), 12, 1700000000); !errors.Is(err, ErrNotUpgradable) {
+			t.Errorf("Expected ErrNotUpgradable, got %v", err)
+		}
+	})
+}
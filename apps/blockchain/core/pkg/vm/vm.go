@@ -34,6 +34,9 @@ type VM struct {
 	// Minimal stack and memory for contract execution
 	stack  []int64
 	Memory map[string]int64
+	// Lowest stack height reached, which Contract.Call uses to tell
+	// arguments a function left untouched from its return values
+	stackLow int
 	
 	// Gas metering
 	gasUsed  uint64
@@ -42,8 +45,15 @@ type VM struct {
 	// Permissioned contract system
 	contractRegistry map[string]*ContractPermission
 	currentContract  *Contract
+	currentFunction  string // Function entered through CALL, for tracing
 	callStack        []*ExecutionContext
 	maxCallDepth     int
+
+	// Tracer, when set, is notified before and after every instruction
+	Tracer Tracer
+
+	// Logs holds the events emitted so far, in order
+	Logs []Log
 }
 
 // Gas costs for different operations
@@ -69,6 +79,7 @@ var GasCosts = map[string]uint64{
 	"AND":    3,
 	"OR":     3,
 	"NOT":    2,
+	"EMIT":   8,
 }
 
 // Instruction represents a single VM instruction.
@@ -142,6 +153,33 @@ func (vm *VM) ApproveCustomContract(address string, allowedFunctions []string, a
 	return nil
 }
 
+// RegisterDeployedContract registers a contract that was deployed on chain,
+// granting permissions according to its contract type
+func (vm *VM) RegisterDeployedContract(contract *Contract) {
+	functions := make([]string, 0, len(contract.Functions))
+	for name := range contract.Functions {
+		functions = append(functions, name)
+	}
+
+	switch contract.ContractType {
+	case ContractTypeSystem:
+		vm.RegisterSystemContract(contract.Address, functions)
+	case ContractTypeGovernance:
+		vm.RegisterGovernanceContract(contract.Address, functions, contract.Owner)
+	case ContractTypeVoting:
+		vm.RegisterVotingContract(contract.Address, functions)
+	default:
+		vm.contractRegistry[contract.Address] = &ContractPermission{
+			ContractAddress:  contract.Address,
+			ContractType:     ContractTypeCustom,
+			AllowedFunctions: functions,
+			IsActive:         true,
+			ApprovedBy:       contract.Owner,
+			ApprovedAt:       contract.CreatedAt,
+		}
+	}
+}
+
 // IsContractApproved checks if a contract is approved for execution
 func (vm *VM) IsContractApproved(address string) bool {
 	permission, exists := vm.contractRegistry[address]
@@ -172,7 +210,7 @@ func (vm *VM) IsFunctionAllowed(contractAddress, functionName string) bool {
 }
 
 // Execute runs a sequence of instructions in the VM context.
-func (vm *VM) Execute(instructions []Instruction, context *ExecutionContext) error {
+func (vm *VM) Execute(instructions []Instruction, context *ExecutionContext) (err error) {
 	// Initialize stack and memory for each execution (but preserve existing stack for contract calls)
 	if context.ContractAddress == "" {
 		// Only reset stack for non-contract execution
@@ -194,7 +232,22 @@ func (vm *VM) Execute(instructions []Instruction, context *ExecutionContext) err
 		}
 	}
 
+	// The step being traced; completed by the deferred call if an
+	// instruction returns early (error or RETURN)
+	var step *Step
+	if vm.Tracer != nil {
+		defer func() {
+			if step != nil {
+				vm.endStep(step, err)
+			}
+		}()
+	}
+
 	for i, instr := range instructions {
+		if vm.Tracer != nil {
+			step = vm.beginStep(instr, i, context)
+		}
+
 		// Charge gas for this instruction
 		if cost, exists := GasCosts[instr.Opcode]; exists {
 			if !vm.chargeGas(cost) {
@@ -216,27 +269,27 @@ func (vm *VM) Execute(instructions []Instruction, context *ExecutionContext) err
 			if len(vm.stack) < 1 {
 				return fmt.Errorf("POP on empty stack at instruction %d", i)
 			}
-			vm.stack = vm.stack[:len(vm.stack)-1]
+			vm.drop(1)
 		case "ADD":
 			if len(vm.stack) < 2 {
 				return fmt.Errorf("ADD needs 2 values on stack at instruction %d", i)
 			}
 			a, b := vm.stack[len(vm.stack)-2], vm.stack[len(vm.stack)-1]
-			vm.stack = vm.stack[:len(vm.stack)-2]
+			vm.drop(2)
 			vm.stack = append(vm.stack, a+b)
 		case "SUB":
 			if len(vm.stack) < 2 {
 				return fmt.Errorf("SUB needs 2 values on stack at instruction %d", i)
 			}
 			a, b := vm.stack[len(vm.stack)-2], vm.stack[len(vm.stack)-1]
-			vm.stack = vm.stack[:len(vm.stack)-2]
+			vm.drop(2)
 			vm.stack = append(vm.stack, a-b)
 		case "MUL":
 			if len(vm.stack) < 2 {
 				return fmt.Errorf("MUL needs 2 values on stack at instruction %d", i)
 			}
 			a, b := vm.stack[len(vm.stack)-2], vm.stack[len(vm.stack)-1]
-			vm.stack = vm.stack[:len(vm.stack)-2]
+			vm.drop(2)
 			vm.stack = append(vm.stack, a*b)
 		case "DIV":
 			if len(vm.stack) < 2 {
@@ -246,7 +299,7 @@ func (vm *VM) Execute(instructions []Instruction, context *ExecutionContext) err
 			if b == 0 {
 				return fmt.Errorf("division by zero at instruction %d", i)
 			}
-			vm.stack = vm.stack[:len(vm.stack)-2]
+			vm.drop(2)
 			vm.stack = append(vm.stack, a/b)
 		case "STORE":
 			if len(instr.Operands) != 1 {
@@ -260,7 +313,7 @@ func (vm *VM) Execute(instructions []Instruction, context *ExecutionContext) err
 				return fmt.Errorf("STORE key must be string at instruction %d", i)
 			}
 			value := vm.stack[len(vm.stack)-1]
-			vm.stack = vm.stack[:len(vm.stack)-1]
+			vm.drop(1)
 			vm.Memory[key] = value
 		case "LOAD":
 			if len(instr.Operands) != 1 {
@@ -295,7 +348,7 @@ func (vm *VM) Execute(instructions []Instruction, context *ExecutionContext) err
 				return fmt.Errorf("JUMPIF needs 1 value on stack at instruction %d", i)
 			}
 			condition := vm.stack[len(vm.stack)-1]
-			vm.stack = vm.stack[:len(vm.stack)-1]
+			vm.drop(1)
 			if condition != 0 {
 				target, ok := toInt64(instr.Operands[0])
 				if !ok {
@@ -346,12 +399,13 @@ func (vm *VM) Execute(instructions []Instruction, context *ExecutionContext) err
 				return fmt.Errorf("SWAP needs 2 values on stack at instruction %d", i)
 			}
 			vm.stack[len(vm.stack)-2], vm.stack[len(vm.stack)-1] = vm.stack[len(vm.stack)-1], vm.stack[len(vm.stack)-2]
+			vm.touch(len(vm.stack) - 2)
 		case "GT":
 			if len(vm.stack) < 2 {
 				return fmt.Errorf("GT needs 2 values on stack at instruction %d", i)
 			}
 			a, b := vm.stack[len(vm.stack)-2], vm.stack[len(vm.stack)-1]
-			vm.stack = vm.stack[:len(vm.stack)-2]
+			vm.drop(2)
 			if a > b {
 				vm.stack = append(vm.stack, 1)
 			} else {
@@ -362,7 +416,7 @@ func (vm *VM) Execute(instructions []Instruction, context *ExecutionContext) err
 				return fmt.Errorf("LT needs 2 values on stack at instruction %d", i)
 			}
 			a, b := vm.stack[len(vm.stack)-2], vm.stack[len(vm.stack)-1]
-			vm.stack = vm.stack[:len(vm.stack)-2]
+			vm.drop(2)
 			if a < b {
 				vm.stack = append(vm.stack, 1)
 			} else {
@@ -373,7 +427,7 @@ func (vm *VM) Execute(instructions []Instruction, context *ExecutionContext) err
 				return fmt.Errorf("EQ needs 2 values on stack at instruction %d", i)
 			}
 			a, b := vm.stack[len(vm.stack)-2], vm.stack[len(vm.stack)-1]
-			vm.stack = vm.stack[:len(vm.stack)-2]
+			vm.drop(2)
 			if a == b {
 				vm.stack = append(vm.stack, 1)
 			} else {
@@ -384,7 +438,7 @@ func (vm *VM) Execute(instructions []Instruction, context *ExecutionContext) err
 				return fmt.Errorf("NEQ needs 2 values on stack at instruction %d", i)
 			}
 			a, b := vm.stack[len(vm.stack)-2], vm.stack[len(vm.stack)-1]
-			vm.stack = vm.stack[:len(vm.stack)-2]
+			vm.drop(2)
 			if a != b {
 				vm.stack = append(vm.stack, 1)
 			} else {
@@ -395,7 +449,7 @@ func (vm *VM) Execute(instructions []Instruction, context *ExecutionContext) err
 				return fmt.Errorf("AND needs 2 values on stack at instruction %d", i)
 			}
 			a, b := vm.stack[len(vm.stack)-2], vm.stack[len(vm.stack)-1]
-			vm.stack = vm.stack[:len(vm.stack)-2]
+			vm.drop(2)
 			if a != 0 && b != 0 {
 				vm.stack = append(vm.stack, 1)
 			} else {
@@ -406,7 +460,7 @@ func (vm *VM) Execute(instructions []Instruction, context *ExecutionContext) err
 				return fmt.Errorf("OR needs 2 values on stack at instruction %d", i)
 			}
 			a, b := vm.stack[len(vm.stack)-2], vm.stack[len(vm.stack)-1]
-			vm.stack = vm.stack[:len(vm.stack)-2]
+			vm.drop(2)
 			if a != 0 || b != 0 {
 				vm.stack = append(vm.stack, 1)
 			} else {
@@ -417,15 +471,31 @@ func (vm *VM) Execute(instructions []Instruction, context *ExecutionContext) err
 				return fmt.Errorf("NOT needs 1 value on stack at instruction %d", i)
 			}
 			a := vm.stack[len(vm.stack)-1]
-			vm.stack = vm.stack[:len(vm.stack)-1]
+			vm.drop(1)
 			if a == 0 {
 				vm.stack = append(vm.stack, 1)
 			} else {
 				vm.stack = append(vm.stack, 0)
 			}
+		case "EMIT":
+			if len(instr.Operands) != 1 {
+				return fmt.Errorf("EMIT expects 1 operand (event name) at instruction %d", i)
+			}
+			eventName, ok := instr.Operands[0].(string)
+			if !ok {
+				return fmt.Errorf("EMIT operand must be string at instruction %d", i)
+			}
+			if err := vm.emit(eventName, context); err != nil {
+				return fmt.Errorf("%v at instruction %d", err, i)
+			}
 		default:
 			return fmt.Errorf("unknown opcode '%s' at instruction %d", instr.Opcode, i)
 		}
+
+		if step != nil {
+			vm.endStep(step, nil)
+			step = nil
+		}
 	}
 	return nil
 }
@@ -446,6 +516,9 @@ func (vm *VM) executeFunctionCall(functionName string, context *ExecutionContext
 	}
 
 	// Execute the function's instructions
+	caller := vm.currentFunction
+	vm.currentFunction = functionName
+	defer func() { vm.currentFunction = caller }()
 	if err := vm.Execute(function.Code, context); err != nil {
 		return fmt.Errorf("function %s execution failed: %v", functionName, err)
 	}
@@ -458,6 +531,19 @@ func (vm *VM) executeFunctionCall(functionName string, context *ExecutionContext
 	return nil
 }
 
+// drop pops n values off the stack
+func (vm *VM) drop(n int) {
+	vm.stack = vm.stack[:len(vm.stack)-n]
+	vm.touch(len(vm.stack))
+}
+
+// touch records that the stack was used down to height
+func (vm *VM) touch(height int) {
+	if height < vm.stackLow {
+		vm.stackLow = height
+	}
+}
+
 // chargeGas deducts gas for an operation
 func (vm *VM) chargeGas(amount uint64) bool {
 	if vm.gasUsed+amount > vm.gasLimit {
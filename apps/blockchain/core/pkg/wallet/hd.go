@@ -0,0 +1,182 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// HD keys follow BIP-32 as SLIP-10 extends it to the P-256 curve that
+// wallets sign with, and accounts follow the BIP-44 path layout
+// m/44'/coin'/account'/change/index.
+
+// HardenedOffset is added to the index of a hardened child
+const HardenedOffset uint32 = 0x80000000
+
+// CoinType is the BIP-44 coin type of Atlas accounts (SLIP-44 "testnet")
+const CoinType uint32 = 1
+
+// DefaultDerivationPath is the path of the first account of a seed
+var DefaultDerivationPath = AccountPath(0, 0)
+
+// masterKeySalt is the SLIP-10 HMAC key for P-256 master keys
+var masterKeySalt = []byte("Nist256p1 seed")
+
+// ExtendedKey is a private key together with the chain code needed to
+// derive its children
+type ExtendedKey struct {
+	Key       []byte // 32-byte private scalar
+	ChainCode []byte
+	Depth     int
+	Index     uint32 // child number of this key in its parent
+}
+
+// AccountPath returns the BIP-44 path of address index of an account
+func AccountPath(account, index uint32) string {
+	return fmt.Sprintf("m/44'/%d'/%d'/0/%d", CoinType, account, index)
+}
+
+// NewMasterKey derives the root key of an HD wallet from its seed
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("seed must be 16 to 64 bytes, not %d", len(seed))
+	}
+	mac := hmac.New(sha512.New, masterKeySalt)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	// An invalid key is retried with the previous output as data
+	for !validScalar(sum[:32]) {
+		mac.Reset()
+		mac.Write(sum)
+		sum = mac.Sum(nil)
+	}
+	return &ExtendedKey{Key: sum[:32], ChainCode: sum[32:]}, nil
+}
+
+// Child derives the child key at index. Indexes from HardenedOffset on give
+// hardened children.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	var data []byte
+	if index >= HardenedOffset {
+		data = append([]byte{0}, k.Key...)
+	} else {
+		curve := elliptic.P256()
+		x, y := curve.ScalarBaseMult(k.Key)
+		data = elliptic.MarshalCompressed(curve, x, y)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	n := elliptic.P256().Params().N
+	for {
+		mac := hmac.New(sha512.New, k.ChainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		if validScalar(sum[:32]) {
+			child := new(big.Int).SetBytes(sum[:32])
+			child.Add(child, new(big.Int).SetBytes(k.Key))
+			child.Mod(child, n)
+			if child.Sign() != 0 {
+				return &ExtendedKey{
+					Key:       child.FillBytes(make([]byte, 32)),
+					ChainCode: sum[32:],
+					Depth:     k.Depth + 1,
+					Index:     index,
+				}, nil
+			}
+		}
+		// Retry as SLIP-10 specifies for the rare invalid child
+		data = binary.BigEndian.AppendUint32(append([]byte{1}, sum[32:]...), index)
+	}
+}
+
+// Derive follows a path such as "m/44'/1'/0'/0/0" from a master key
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	key := k
+	for _, index := range indexes {
+		if key, err = key.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// ParseDerivationPath parses a path such as "m/44'/1'/0'/0/0". Hardened
+// indexes are marked with ' or h.
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q must start with m", path)
+	}
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(index) >= HardenedOffset {
+			return nil, fmt.Errorf("invalid index %q in derivation path %q", part, path)
+		}
+		if hardened {
+			index += uint64(HardenedOffset)
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
+
+// Wallet returns a wallet signing with the key
+func (k *ExtendedKey) Wallet() (*Wallet, error) {
+	curve := elliptic.P256()
+	privateKey := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(k.Key)}
+	privateKey.PublicKey.Curve = curve
+	privateKey.PublicKey.X, privateKey.PublicKey.Y = curve.ScalarBaseMult(k.Key)
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %v", err)
+	}
+	return &Wallet{PrivateKey: privateKey, PublicKey: publicKeyBytes}, nil
+}
+
+// NewHDWallet restores the wallet at a derivation path of a mnemonic. An
+// empty path selects DefaultDerivationPath.
+func NewHDWallet(mnemonic, passphrase, path string) (*Wallet, error) {
+	if path == "" {
+		path = DefaultDerivationPath
+	}
+	seed, err := MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	key, err := master.Derive(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := key.Wallet()
+	if err != nil {
+		return nil, err
+	}
+	w.DerivationPath = path
+	return w, nil
+}
+
+// validScalar reports whether b is a valid P-256 private key
+func validScalar(b []byte) bool {
+	d := new(big.Int).SetBytes(b)
+	return d.Sign() != 0 && d.Cmp(elliptic.P256().Params().N) < 0
+}
@@ -0,0 +1,148 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"atlas-blockchain/pkg/transaction"
+)
+
+const testMnemonic = "legal winner thank year wave sausage worth useful legal winner thank yellow"
+
+func TestMnemonic(t *testing.T) {
+	// Vectors from the BIP-39 reference implementation, passphrase "TREZOR"
+	vectors := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+	}
+
+	t.Run("Vectors", func(t *testing.T) {
+		for _, v := range vectors {
+			entropy, _ := hex.DecodeString(v.entropy)
+			mnemonic, err := MnemonicFromEntropy(entropy)
+			if err != nil || mnemonic != v.mnemonic {
+				t.Errorf("Expected %q, got %q (%v)", v.mnemonic, mnemonic, err)
+			}
+			seed, err := MnemonicToSeed(v.mnemonic, "TREZOR")
+			if err != nil || hex.EncodeToString(seed) != v.seed {
+				t.Errorf("Unexpected seed %x for %q (%v)", seed, v.mnemonic, err)
+			}
+		}
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		for _, words := range []int{12, 24} {
+			mnemonic, err := NewMnemonic(words)
+			if err != nil {
+				t.Fatalf("NewMnemonic(%d) failed: %v", words, err)
+			}
+			if got := len(strings.Fields(mnemonic)); got != words {
+				t.Errorf("Expected %d words, got %d", words, got)
+			}
+			if err := ValidateMnemonic(mnemonic); err != nil {
+				t.Errorf("Expected %q to validate, got %v", mnemonic, err)
+			}
+		}
+		if _, err := NewMnemonic(13); err == nil {
+			t.Errorf("Expected an error for 13 words")
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, mnemonic := range []string{
+			strings.Repeat("abandon ", 12),
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon bitcoin",
+			"abandon abandon about",
+		} {
+			if err := ValidateMnemonic(mnemonic); err == nil {
+				t.Errorf("Expected %q to be refused", mnemonic)
+			}
+		}
+	})
+}
+
+func TestHDDerivation(t *testing.T) {
+	// SLIP-10 test vector 1 for nist256p1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		t.Fatalf("NewMasterKey failed: %v", err)
+	}
+
+	t.Run("Vector", func(t *testing.T) {
+		if got := hex.EncodeToString(master.Key); got != "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2" {
+			t.Errorf("Unexpected master key %s", got)
+		}
+		if got := hex.EncodeToString(master.ChainCode); got != "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea" {
+			t.Errorf("Unexpected master chain code %s", got)
+		}
+		child, err := master.Derive("m/0'")
+		if err != nil {
+			t.Fatalf("Derive failed: %v", err)
+		}
+		if got := hex.EncodeToString(child.Key); got != "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c" {
+			t.Errorf("Unexpected key at m/0' %s", got)
+		}
+	})
+
+	t.Run("Accounts", func(t *testing.T) {
+		first, err := NewHDWallet(testMnemonic, "", "")
+		if err != nil {
+			t.Fatalf("NewHDWallet failed: %v", err)
+		}
+		again, _ := NewHDWallet(testMnemonic, "", AccountPath(0, 0))
+		second, _ := NewHDWallet(testMnemonic, "", AccountPath(0, 1))
+		if first.DerivationPath != "m/44'/1'/0'/0/0" || !bytes.Equal(first.PublicKey, again.PublicKey) {
+			t.Errorf("Expected the default path to be the first account")
+		}
+		if bytes.Equal(first.PublicKey, second.PublicKey) {
+			t.Errorf("Expected another address index to give another key")
+		}
+		other, _ := NewHDWallet(testMnemonic, "secret", "")
+		if bytes.Equal(first.PublicKey, other.PublicKey) {
+			t.Errorf("Expected a passphrase to give another wallet")
+		}
+	})
+
+	t.Run("Signs", func(t *testing.T) {
+		w, err := NewHDWallet(testMnemonic, "", AccountPath(2, 5))
+		if err != nil {
+			t.Fatalf("NewHDWallet failed: %v", err)
+		}
+		tx := transaction.Transaction{
+			ChainID:         "atlas-devnet",
+			Sender:          PublicKeyToAddress(w.PublicKey),
+			SenderPublicKey: w.PublicKeyStr(),
+			Recipient:       "cb49a4cefae13ad235beb40e5ad603ba757da61d",
+			Amount:          100,
+		}
+		if err := w.SignTransaction(&tx); err != nil {
+			t.Fatalf("SignTransaction failed: %v", err)
+		}
+		if ok, err := VerifyTransactionSignature(tx); !ok || err != nil {
+			t.Errorf("Expected an HD wallet signature to verify, got %v, %v", ok, err)
+		}
+	})
+
+	t.Run("BadPaths", func(t *testing.T) {
+		for _, path := range []string{"", "44'/0'", "m/x", "m/2147483648", "m/0''"} {
+			if _, err := ParseDerivationPath(path); err == nil {
+				t.Errorf("Expected path %q to be refused", path)
+			}
+		}
+	})
+}
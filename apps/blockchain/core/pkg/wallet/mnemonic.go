@@ -0,0 +1,112 @@
+package wallet
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Mnemonics follow BIP-39 with the English wordlist, so seed phrases can be
+// restored by any BIP-39 compatible wallet.
+
+//go:embed bip39_english.txt
+var englishWordlist string
+
+var (
+	wordList  = strings.Fields(englishWordlist)
+	wordIndex = func() map[string]int {
+		index := make(map[string]int, len(wordList))
+		for i, word := range wordList {
+			index[word] = i
+		}
+		return index
+	}()
+)
+
+// NewMnemonic generates a random mnemonic of 12, 15, 18, 21 or 24 words
+func NewMnemonic(words int) (string, error) {
+	if words < 12 || words > 24 || words%3 != 0 {
+		return "", fmt.Errorf("mnemonic must have 12, 15, 18, 21 or 24 words, not %d", words)
+	}
+	entropy := make([]byte, words/3*4)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", fmt.Errorf("failed to generate entropy: %v", err)
+	}
+	return MnemonicFromEntropy(entropy)
+}
+
+// MnemonicFromEntropy encodes 16 to 32 bytes of entropy as a mnemonic
+func MnemonicFromEntropy(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", fmt.Errorf("entropy must be 16, 20, 24, 28 or 32 bytes, not %d", len(entropy))
+	}
+	// The entropy is followed by the first len/32 bits of its hash, and every
+	// 11 bits select a word
+	checksum := sha256.Sum256(entropy)
+	data := append(append([]byte{}, entropy...), checksum[0])
+	words := make([]string, len(entropy)*8/32*3)
+	for i := range words {
+		index := 0
+		for bit := i * 11; bit < (i+1)*11; bit++ {
+			index = index<<1 | int(data[bit/8]>>(7-bit%8)&1)
+		}
+		words[i] = wordList[index]
+	}
+	return strings.Join(words, " "), nil
+}
+
+// ValidateMnemonic checks the words and the checksum of a mnemonic
+func ValidateMnemonic(mnemonic string) error {
+	_, err := mnemonicEntropy(mnemonic)
+	return err
+}
+
+// mnemonicEntropy decodes a mnemonic back into its entropy
+func mnemonicEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(norm.NFKD.String(mnemonic))
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("mnemonic must have 12, 15, 18, 21 or 24 words, not %d", len(words))
+	}
+	data := make([]byte, (len(words)*11+7)/8)
+	for i, word := range words {
+		index, ok := wordIndex[word]
+		if !ok {
+			return nil, fmt.Errorf("word %d (%q) is not in the BIP-39 wordlist", i+1, word)
+		}
+		for bit := 0; bit < 11; bit++ {
+			if index&(1<<(10-bit)) != 0 {
+				pos := i*11 + bit
+				data[pos/8] |= 1 << (7 - pos%8)
+			}
+		}
+	}
+
+	entropy := data[:len(words)/3*4]
+	checksumBits := len(words) / 3
+	checksum := sha256.Sum256(entropy)
+	if data[len(entropy)]>>(8-checksumBits) != checksum[0]>>(8-checksumBits) {
+		return nil, fmt.Errorf("invalid mnemonic checksum")
+	}
+	return entropy, nil
+}
+
+// MnemonicToSeed validates a mnemonic and derives the 64-byte seed of its
+// HD wallet. Each passphrase, including the empty one, gives another wallet.
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	words := strings.Join(strings.Fields(norm.NFKD.String(mnemonic)), " ")
+	salt := "mnemonic" + norm.NFKD.String(passphrase)
+	seed, err := pbkdf2.Key(sha512.New, words, []byte(salt), 2048, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive seed: %v", err)
+	}
+	return seed, nil
+}
@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"atlas-blockchain/pkg/transaction"
+)
+
+// MultisigAddress returns the address of an M-of-N account. It depends only
+// on the threshold and the set of keys, not on their order.
+func MultisigAddress(m *transaction.Multisig) string {
+	keys := make([]string, len(m.PublicKeys))
+	for i, key := range m.PublicKeys {
+		keys[i] = strings.ToLower(key)
+	}
+	sort.Strings(keys)
+	hash := sha256.Sum256([]byte(fmt.Sprintf("multisig:%d:%s", m.Threshold, strings.Join(keys, ","))))
+	return "0x" + hex.EncodeToString(hash[len(hash)-20:])
+}
+
+// SameAddress compares two addresses ignoring case and the 0x prefix
+func SameAddress(a, b string) bool {
+	return strings.EqualFold(strings.TrimPrefix(a, "0x"), strings.TrimPrefix(b, "0x"))
+}
+
+// SignMultisig adds the wallet's signature to a multisig transaction,
+// replacing an earlier signature by the same key
+func (w *Wallet) SignMultisig(tx *transaction.Transaction) error {
+	if tx.Multisig == nil {
+		return fmt.Errorf("transaction is not a multisig transaction")
+	}
+	publicKey := w.PublicKeyStr()
+	if !tx.Multisig.HasKey(publicKey) {
+		return fmt.Errorf("wallet is not a signer of multisig %s", MultisigAddress(tx.Multisig))
+	}
+	signature, err := w.SignHash(CalculateTxHash(*tx))
+	if err != nil {
+		return fmt.Errorf("failed to sign transaction: %v", err)
+	}
+	sig := transaction.MultisigSignature{PublicKey: publicKey, Signature: signature}
+	for i, existing := range tx.Signatures {
+		if strings.EqualFold(existing.PublicKey, publicKey) {
+			tx.Signatures[i] = sig
+			return nil
+		}
+	}
+	tx.Signatures = append(tx.Signatures, sig)
+	return nil
+}
+
+// VerifyMultisigSignature checks one co-signer's signature of a multisig
+// transaction, as collected before the transaction is complete
+func VerifyMultisigSignature(tx transaction.Transaction, sig transaction.MultisigSignature) (bool, error) {
+	if tx.Multisig == nil {
+		return false, fmt.Errorf("transaction is not a multisig transaction")
+	}
+	if !tx.Multisig.HasKey(sig.PublicKey) {
+		return false, fmt.Errorf("%s is not a signer of multisig %s", shortKey(sig.PublicKey), MultisigAddress(tx.Multisig))
+	}
+	return verifySignature(sig.PublicKey, sig.Signature, CalculateTxHash(tx))
+}
+
+// verifyMultisig checks that the sender is the multisig account and that at
+// least its threshold of distinct signers signed. Every signature present
+// must be valid.
+func verifyMultisig(tx transaction.Transaction) (bool, error) {
+	m := tx.Multisig
+	if err := m.Validate(); err != nil {
+		return false, err
+	}
+	if !SameAddress(tx.Sender, MultisigAddress(m)) {
+		return false, fmt.Errorf("sender %s is not the address of its multisig definition", tx.Sender)
+	}
+	hash := CalculateTxHash(tx)
+	signed := make(map[string]bool, len(tx.Signatures))
+	for _, sig := range tx.Signatures {
+		key := strings.ToLower(sig.PublicKey)
+		if !m.HasKey(key) {
+			return false, fmt.Errorf("%s is not a signer of multisig %s", shortKey(sig.PublicKey), tx.Sender)
+		}
+		if signed[key] {
+			return false, fmt.Errorf("%s signed more than once", shortKey(sig.PublicKey))
+		}
+		valid, err := verifySignature(sig.PublicKey, sig.Signature, hash)
+		if err != nil || !valid {
+			return valid, err
+		}
+		signed[key] = true
+	}
+	if len(signed) < m.Threshold {
+		return false, fmt.Errorf("multisig transaction has %d of %d required signatures", len(signed), m.Threshold)
+	}
+	return true, nil
+}
+
+// shortKey abbreviates a hex public key for error messages
+func shortKey(key string) string {
+	if len(key) > 16 {
+		return key[len(key)-16:]
+	}
+	return key
+}
@@ -0,0 +1,126 @@
+package wallet
+
+import (
+	"testing"
+
+	"atlas-blockchain/pkg/transaction"
+)
+
+func TestMultisig(t *testing.T) {
+	signers := make([]*Wallet, 3)
+	keys := make([]string, 3)
+	for i := range signers {
+		w, err := NewWallet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		signers[i], keys[i] = w, w.PublicKeyStr()
+	}
+	def := &transaction.Multisig{Threshold: 2, PublicKeys: keys}
+	newTx := func() transaction.Transaction {
+		return transaction.Transaction{
+			ChainID:   "atlas-devnet",
+			Sender:    MultisigAddress(def),
+			Recipient: "cb49a4cefae13ad235beb40e5ad603ba757da61d",
+			Amount:    100,
+			Multisig:  def,
+		}
+	}
+
+	t.Run("Address", func(t *testing.T) {
+		reordered := &transaction.Multisig{Threshold: 2, PublicKeys: []string{keys[2], keys[0], keys[1]}}
+		if MultisigAddress(reordered) != MultisigAddress(def) {
+			t.Errorf("Expected the address not to depend on key order")
+		}
+		if MultisigAddress(&transaction.Multisig{Threshold: 3, PublicKeys: keys}) == MultisigAddress(def) {
+			t.Errorf("Expected another threshold to give another address")
+		}
+	})
+
+	t.Run("Threshold", func(t *testing.T) {
+		tx := newTx()
+		signers[0].SignMultisig(&tx)
+		if ok, err := VerifyTransactionSignature(tx); ok || err == nil {
+			t.Errorf("Expected 1 of 2 signatures to be refused, got %v, %v", ok, err)
+		}
+		signers[2].SignMultisig(&tx)
+		if err := tx.Validate(); err != nil {
+			t.Errorf("Expected a multisig transaction to validate, got %v", err)
+		}
+		if ok, err := VerifyTransactionSignature(tx); !ok || err != nil {
+			t.Errorf("Expected 2 of 2 signatures to verify, got %v, %v", ok, err)
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		tx := newTx()
+		signers[1].SignMultisig(&tx)
+		signers[1].SignMultisig(&tx)
+		if len(tx.Signatures) != 1 {
+			t.Errorf("Expected signing twice to replace the signature, got %d", len(tx.Signatures))
+		}
+		tx.Signatures = append(tx.Signatures, tx.Signatures[0])
+		if ok, _ := VerifyTransactionSignature(tx); ok {
+			t.Errorf("Expected one signer counted twice to be refused")
+		}
+	})
+
+	t.Run("Outsider", func(t *testing.T) {
+		outsider, _ := NewWallet()
+		tx := newTx()
+		if err := outsider.SignMultisig(&tx); err == nil {
+			t.Errorf("Expected a non-member to be unable to sign")
+		}
+		signers[0].SignMultisig(&tx)
+		signers[1].SignMultisig(&tx)
+		other := newTx()
+		outsider.SignTransaction(&other)
+		tx.Signatures = append(tx.Signatures, transaction.MultisigSignature{PublicKey: outsider.PublicKeyStr(), Signature: other.Signature})
+		if ok, _ := VerifyTransactionSignature(tx); ok {
+			t.Errorf("Expected a non-member signature to be refused")
+		}
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		tx := newTx()
+		signers[0].SignMultisig(&tx)
+		signers[1].SignMultisig(&tx)
+		tx.Amount = 1000
+		if ok, _ := VerifyTransactionSignature(tx); ok {
+			t.Errorf("Expected a changed amount to invalidate the signatures")
+		}
+	})
+
+	t.Run("WrongSender", func(t *testing.T) {
+		tx := newTx()
+		tx.Sender = PublicKeyToAddress(signers[0].PublicKey)
+		signers[0].SignMultisig(&tx)
+		signers[1].SignMultisig(&tx)
+		if ok, err := VerifyTransactionSignature(tx); ok || err == nil {
+			t.Errorf("Expected a sender other than the multisig address to be refused, got %v, %v", ok, err)
+		}
+	})
+
+	t.Run("Collected", func(t *testing.T) {
+		tx := newTx()
+		signed := newTx()
+		signers[2].SignMultisig(&signed)
+		if ok, err := VerifyMultisigSignature(tx, signed.Signatures[0]); !ok || err != nil {
+			t.Errorf("Expected a co-signer's signature to verify on its own, got %v, %v", ok, err)
+		}
+	})
+
+	t.Run("BadDefinitions", func(t *testing.T) {
+		for _, m := range []transaction.Multisig{
+			{Threshold: 0, PublicKeys: keys},
+			{Threshold: 4, PublicKeys: keys},
+			{Threshold: 1, PublicKeys: []string{keys[0], keys[0]}},
+			{Threshold: 1, PublicKeys: []string{"not hex"}},
+			{Threshold: 1},
+		} {
+			if err := m.Validate(); err == nil {
+				t.Errorf("Expected %d of %d keys to be refused", m.Threshold, len(m.PublicKeys))
+			}
+		}
+	})
+}
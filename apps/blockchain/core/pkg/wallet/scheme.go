@@ -0,0 +1,274 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"atlas-blockchain/pkg/transaction"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secpecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+// Key and signature encodings per scheme:
+//
+//	p256       public key: DER (PKIX)           signature: r || s
+//	secp256k1  public key: 33 byte compressed   signature: r || s || v (65 bytes, v = 0 or 1)
+//	ed25519    public key: 32 bytes             signature: 64 bytes
+//
+// Private keys are imported as DER for P-256, as a 32 byte scalar for
+// secp256k1 and as a 32 byte seed for Ed25519.
+
+// NormalizeScheme returns the scheme name, P-256 for an empty one
+func NormalizeScheme(scheme string) (string, error) {
+	switch strings.ToLower(scheme) {
+	case "", transaction.SchemeP256:
+		return transaction.SchemeP256, nil
+	case transaction.SchemeSecp256k1:
+		return transaction.SchemeSecp256k1, nil
+	case transaction.SchemeEd25519:
+		return transaction.SchemeEd25519, nil
+	}
+	return "", fmt.Errorf("unknown signature scheme %q", scheme)
+}
+
+// NewWalletWithScheme creates a wallet with a fresh key pair of a signature scheme
+func NewWalletWithScheme(scheme string) (*Wallet, error) {
+	scheme, err := NormalizeScheme(scheme)
+	if err != nil {
+		return nil, err
+	}
+	switch scheme {
+	case transaction.SchemeSecp256k1:
+		key, err := secp256k1.GeneratePrivateKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate private key: %v", err)
+		}
+		return &Wallet{Scheme: scheme, secp256k1Key: key, PublicKey: key.PubKey().SerializeCompressed()}, nil
+	case transaction.SchemeEd25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate private key: %v", err)
+		}
+		return &Wallet{Scheme: scheme, ed25519Key: priv, PublicKey: pub}, nil
+	}
+	return NewWallet()
+}
+
+// ImportWalletWithScheme creates a wallet from a hex private key of a signature scheme
+func ImportWalletWithScheme(scheme, privateKeyHex string) (*Wallet, error) {
+	scheme, err := NormalizeScheme(scheme)
+	if err != nil {
+		return nil, err
+	}
+	if scheme == transaction.SchemeP256 {
+		return ImportWallet(privateKeyHex)
+	}
+	privBytes, err := hex.DecodeString(privateKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key hex: %v", err)
+	}
+	if len(privBytes) != 32 {
+		return nil, fmt.Errorf("invalid %s private key length %d (expected 32)", scheme, len(privBytes))
+	}
+	if scheme == transaction.SchemeEd25519 {
+		priv := ed25519.NewKeyFromSeed(privBytes)
+		return &Wallet{Scheme: scheme, ed25519Key: priv, PublicKey: priv.Public().(ed25519.PublicKey)}, nil
+	}
+	var scalar secp256k1.ModNScalar
+	if overflow := scalar.SetByteSlice(privBytes); overflow || scalar.IsZero() {
+		return nil, fmt.Errorf("invalid secp256k1 private key")
+	}
+	key := secp256k1.NewPrivateKey(&scalar)
+	return &Wallet{Scheme: scheme, secp256k1Key: key, PublicKey: key.PubKey().SerializeCompressed()}, nil
+}
+
+// SchemeName returns the wallet's signature scheme
+func (w *Wallet) SchemeName() string {
+	if w.Scheme == "" {
+		return transaction.SchemeP256
+	}
+	return w.Scheme
+}
+
+// PrivateKeyBytes returns the private key as ImportWalletWithScheme takes it
+func (w *Wallet) PrivateKeyBytes() ([]byte, error) {
+	switch w.SchemeName() {
+	case transaction.SchemeSecp256k1:
+		if w.secp256k1Key != nil {
+			return w.secp256k1Key.Serialize(), nil
+		}
+	case transaction.SchemeEd25519:
+		if w.ed25519Key != nil {
+			return w.ed25519Key.Seed(), nil
+		}
+	default:
+		if w.PrivateKey != nil {
+			der, err := x509.MarshalECPrivateKey(w.PrivateKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode private key: %v", err)
+			}
+			return der, nil
+		}
+	}
+	return nil, fmt.Errorf("wallet has no private key")
+}
+
+// SignHash signs a 32 byte hash and returns the hex signature
+func (w *Wallet) SignHash(hash []byte) (string, error) {
+	switch w.SchemeName() {
+	case transaction.SchemeSecp256k1:
+		if w.secp256k1Key == nil {
+			return "", fmt.Errorf("wallet has no private key")
+		}
+		// Compact signatures are v || r || s with v = 27 + recovery id (+4 compressed)
+		compact := secpecdsa.SignCompact(w.secp256k1Key, hash, true)
+		sig := append(compact[1:], (compact[0]-27)&3)
+		return hex.EncodeToString(sig), nil
+	case transaction.SchemeEd25519:
+		if w.ed25519Key == nil {
+			return "", fmt.Errorf("wallet has no private key")
+		}
+		return hex.EncodeToString(ed25519.Sign(w.ed25519Key, hash)), nil
+	}
+	if w.PrivateKey == nil {
+		return "", fmt.Errorf("wallet has no private key")
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, w.PrivateKey, hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign: %v", err)
+	}
+	// r and s are padded to the curve size; verification splits the
+	// signature in halves
+	size := (w.PrivateKey.Curve.Params().BitSize + 7) / 8
+	sig := make([]byte, 2*size)
+	r.FillBytes(sig[:size])
+	s.FillBytes(sig[size:])
+	return hex.EncodeToString(sig), nil
+}
+
+// PublicKeyScheme tells the scheme of a public key from its encoding
+func PublicKeyScheme(pubKey []byte) string {
+	switch {
+	case len(pubKey) == 33 && (pubKey[0] == 2 || pubKey[0] == 3), len(pubKey) == 65 && pubKey[0] == 4:
+		return transaction.SchemeSecp256k1
+	case len(pubKey) == ed25519.PublicKeySize:
+		return transaction.SchemeEd25519
+	}
+	return transaction.SchemeP256
+}
+
+// SchemeAddress derives the address of a public key of a scheme:
+// the last 20 bytes of its SHA-256 for P-256, the last 20 bytes of the
+// Keccak-256 of the uncompressed point for secp256k1 (as Ethereum does) and
+// the first 20 bytes of its SHA-256 for Ed25519
+func SchemeAddress(scheme string, pubKey []byte) string {
+	switch scheme {
+	case transaction.SchemeSecp256k1:
+		key, err := secp256k1.ParsePubKey(pubKey)
+		if err != nil {
+			break
+		}
+		h := sha3.NewLegacyKeccak256()
+		h.Write(key.SerializeUncompressed()[1:])
+		return "0x" + hex.EncodeToString(h.Sum(nil)[12:])
+	case transaction.SchemeEd25519:
+		hash := sha256.Sum256(pubKey)
+		return "0x" + hex.EncodeToString(hash[:20])
+	}
+	hash := sha256.Sum256(pubKey)
+	return "0x" + hex.EncodeToString(hash[len(hash)-20:])
+}
+
+// VerifySignature checks a hex signature of hash by a hex public key of a scheme
+func VerifySignature(scheme, publicKey, signature string, hash []byte) (bool, error) {
+	scheme, err := NormalizeScheme(scheme)
+	if err != nil {
+		return false, err
+	}
+	pubKeyBytes, err := hex.DecodeString(publicKey)
+	if err != nil {
+		return false, fmt.Errorf("invalid public key encoding: %v", err)
+	}
+	sigBytes, err := hex.DecodeString(signature)
+	if err != nil {
+		return false, fmt.Errorf("invalid signature encoding: %v", err)
+	}
+	if PublicKeyScheme(pubKeyBytes) != scheme {
+		return false, fmt.Errorf("public key is not a %s key", scheme)
+	}
+
+	switch scheme {
+	case transaction.SchemeSecp256k1:
+		recovered, err := recoverSecp256k1(sigBytes, hash)
+		if err != nil {
+			return false, err
+		}
+		key, err := secp256k1.ParsePubKey(pubKeyBytes)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse public key: %v", err)
+		}
+		return key.IsEqual(recovered), nil
+	case transaction.SchemeEd25519:
+		if len(sigBytes) != ed25519.SignatureSize {
+			return false, fmt.Errorf("invalid signature length")
+		}
+		return ed25519.Verify(pubKeyBytes, hash, sigBytes), nil
+	}
+
+	pubKeyInterface, err := x509.ParsePKIXPublicKey(pubKeyBytes)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse public key: %v", err)
+	}
+	pubKey, ok := pubKeyInterface.(*ecdsa.PublicKey)
+	if !ok {
+		return false, fmt.Errorf("public key is not ECDSA")
+	}
+	if len(sigBytes)%2 != 0 {
+		return false, fmt.Errorf("invalid signature length")
+	}
+	r := new(big.Int).SetBytes(sigBytes[:len(sigBytes)/2])
+	s := new(big.Int).SetBytes(sigBytes[len(sigBytes)/2:])
+	return ecdsa.Verify(pubKey, hash, r, s), nil
+}
+
+// RecoverPublicKey returns the compressed secp256k1 public key that made a
+// hex r || s || v signature of hash
+func RecoverPublicKey(signature string, hash []byte) ([]byte, error) {
+	sigBytes, err := hex.DecodeString(signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %v", err)
+	}
+	key, err := recoverSecp256k1(sigBytes, hash)
+	if err != nil {
+		return nil, err
+	}
+	return key.SerializeCompressed(), nil
+}
+
+func recoverSecp256k1(sig, hash []byte) (*secp256k1.PublicKey, error) {
+	if len(sig) != 65 {
+		return nil, fmt.Errorf("invalid signature length")
+	}
+	v := sig[64]
+	if v >= 27 {
+		v -= 27 // Ethereum style recovery ids
+	}
+	if v > 3 {
+		return nil, fmt.Errorf("invalid signature recovery id %d", sig[64])
+	}
+	compact := append([]byte{27 + v + 4}, sig[:64]...)
+	key, _, err := secpecdsa.RecoverCompact(compact, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover public key: %v", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,104 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"atlas-blockchain/pkg/transaction"
+)
+
+func TestSchemes(t *testing.T) {
+	for _, scheme := range []string{transaction.SchemeP256, transaction.SchemeSecp256k1, transaction.SchemeEd25519} {
+		t.Run(scheme, func(t *testing.T) {
+			w, err := NewWalletWithScheme(scheme)
+			if err != nil {
+				t.Fatalf("NewWalletWithScheme failed: %v", err)
+			}
+			if got := PublicKeyScheme(w.PublicKey); got != scheme {
+				t.Errorf("Expected the public key to be detected as %s, got %s", scheme, got)
+			}
+			tx := transaction.Transaction{
+				ChainID:         "atlas-devnet",
+				Sender:          PublicKeyToAddress(w.PublicKey),
+				SenderPublicKey: w.PublicKeyStr(),
+				Recipient:       "cb49a4cefae13ad235beb40e5ad603ba757da61d",
+				Amount:          100,
+			}
+			if err := w.SignTransaction(&tx); err != nil {
+				t.Fatalf("SignTransaction failed: %v", err)
+			}
+			if err := tx.Validate(); err != nil {
+				t.Errorf("Expected the transaction to validate, got %v", err)
+			}
+			if ok, err := VerifyTransactionSignature(tx); !ok || err != nil {
+				t.Errorf("Expected the signature to verify, got %v, %v", ok, err)
+			}
+			tx.Amount = 1000
+			if ok, _ := VerifyTransactionSignature(tx); ok {
+				t.Errorf("Expected a changed amount to invalidate the signature")
+			}
+
+			privateKey, err := w.PrivateKeyBytes()
+			if err != nil {
+				t.Fatalf("PrivateKeyBytes failed: %v", err)
+			}
+			imported, err := ImportWalletWithScheme(scheme, hex.EncodeToString(privateKey))
+			if err != nil || !bytes.Equal(imported.PublicKey, w.PublicKey) {
+				t.Errorf("Expected the wallet back from its private key, got %v", err)
+			}
+		})
+	}
+
+	t.Run("Recovery", func(t *testing.T) {
+		w, _ := NewWalletWithScheme(transaction.SchemeSecp256k1)
+		tx := transaction.Transaction{
+			Sender:    PublicKeyToAddress(w.PublicKey),
+			Recipient: "cb49a4cefae13ad235beb40e5ad603ba757da61d",
+			Amount:    100,
+		}
+		w.SignTransaction(&tx)
+		if err := tx.Validate(); err != nil {
+			t.Errorf("Expected a secp256k1 transaction without public key to validate, got %v", err)
+		}
+		if ok, err := VerifyTransactionSignature(tx); !ok || err != nil {
+			t.Errorf("Expected the recovered key to match the sender, got %v, %v", ok, err)
+		}
+		other, _ := NewWalletWithScheme(transaction.SchemeSecp256k1)
+		tx.Sender = PublicKeyToAddress(other.PublicKey)
+		if ok, _ := VerifyTransactionSignature(tx); ok {
+			t.Errorf("Expected a signature by another key to be refused")
+		}
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		ed, _ := NewWalletWithScheme(transaction.SchemeEd25519)
+		p256, _ := NewWallet()
+		tx := transaction.Transaction{Sender: "a", SenderPublicKey: p256.PublicKeyStr(), Recipient: "b", Amount: 1}
+		ed.SignTransaction(&tx)
+		if ok, err := VerifyTransactionSignature(tx); ok || err == nil {
+			t.Errorf("Expected an Ed25519 signature with a P-256 key to be refused, got %v, %v", ok, err)
+		}
+		if err := p256.SignTransaction(&tx); err == nil {
+			t.Errorf("Expected a P-256 wallet to refuse signing an Ed25519 transaction")
+		}
+	})
+
+	t.Run("Address", func(t *testing.T) {
+		// Ethereum's address of private key 1
+		key, _ := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000001")
+		w, err := ImportWalletWithScheme(transaction.SchemeSecp256k1, hex.EncodeToString(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := PublicKeyToAddress(w.PublicKey); got != "0x7e5f4552091a69125d5dfcb7b8c2659029395bdf" {
+			t.Errorf("Unexpected secp256k1 address %s", got)
+		}
+		if _, err := ImportWalletWithScheme(transaction.SchemeSecp256k1, hex.EncodeToString(make([]byte, 32))); err == nil {
+			t.Errorf("Expected a zero secp256k1 key to be refused")
+		}
+		if _, err := NewWalletWithScheme("rsa"); err == nil {
+			t.Errorf("Expected an unknown scheme to be refused")
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"atlas-blockchain/pkg/transaction"
+)
+
+// DefaultSignatureCacheSize is the number of verified transactions the
+// shared cache remembers, enough for a full mempool and the blocks made from it
+const DefaultSignatureCacheSize = 20000
+
+// VerifiedSignatures is the cache shared by the mempool and block import,
+// so a transaction checked on arrival is not verified again in its block
+var VerifiedSignatures = NewSignatureCache(DefaultSignatureCacheSize)
+
+// SignatureCache remembers transactions whose signatures verified. The
+// oldest entries are evicted first once it is full.
+type SignatureCache struct {
+	mu      sync.Mutex
+	entries map[[32]byte]struct{}
+	order   [][32]byte // Ring of cached keys, oldest at next
+	next    int
+}
+
+// NewSignatureCache creates a cache of up to size transactions
+func NewSignatureCache(size int) *SignatureCache {
+	if size < 1 {
+		size = 1
+	}
+	return &SignatureCache{entries: make(map[[32]byte]struct{}, size), order: make([][32]byte, 0, size)}
+}
+
+// signatureCacheKey covers everything verification depends on: the signed
+// hash, the keys and the signatures. Fields are length-prefixed so that
+// bytes cannot move from one field to the next.
+func signatureCacheKey(tx transaction.Transaction) [32]byte {
+	h := sha256.New()
+	field := func(s string) { fmt.Fprintf(h, "%d:%s", len(s), s) }
+	h.Write(CalculateTxHash(tx))
+	field(tx.Sender)
+	field(tx.SenderPublicKey)
+	field(tx.Signature)
+	if tx.Multisig != nil {
+		fmt.Fprintf(h, "multisig:%d:%d", tx.Multisig.Threshold, len(tx.Multisig.PublicKeys))
+		for _, key := range tx.Multisig.PublicKeys {
+			field(key)
+		}
+		fmt.Fprintf(h, "signatures:%d", len(tx.Signatures))
+		for _, sig := range tx.Signatures {
+			field(sig.PublicKey)
+			field(sig.Signature)
+		}
+	}
+	var key [32]byte
+	h.Sum(key[:0])
+	return key
+}
+
+// Verify checks a transaction's signature, skipping transactions that
+// already verified. A nil cache verifies every time.
+func (c *SignatureCache) Verify(tx transaction.Transaction) (bool, error) {
+	if c == nil {
+		return VerifyTransactionSignature(tx)
+	}
+	key := signatureCacheKey(tx)
+	c.mu.Lock()
+	_, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return true, nil
+	}
+
+	valid, err := VerifyTransactionSignature(tx)
+	if err != nil || !valid {
+		return valid, err
+	}
+	c.add(key)
+	return true, nil
+}
+
+// add remembers a verified key, evicting the oldest one when full
+func (c *SignatureCache) add(key [32]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	if len(c.order) < cap(c.order) {
+		c.order = append(c.order, key)
+	} else {
+		delete(c.entries, c.order[c.next])
+		c.order[c.next] = key
+		c.next = (c.next + 1) % len(c.order)
+	}
+	c.entries[key] = struct{}{}
+}
+
+// Len returns the number of cached transactions
+func (c *SignatureCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
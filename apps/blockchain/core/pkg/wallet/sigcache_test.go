@@ -0,0 +1,63 @@
+package wallet
+
+import (
+	"testing"
+
+	"atlas-blockchain/pkg/transaction"
+)
+
+func TestSignatureCache(t *testing.T) {
+	w, err := NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTx := func(amount int64) transaction.Transaction {
+		tx := transaction.Transaction{
+			ChainID:         "atlas-devnet",
+			Sender:          PublicKeyToAddress(w.PublicKey),
+			SenderPublicKey: w.PublicKeyStr(),
+			Recipient:       "cb49a4cefae13ad235beb40e5ad603ba757da61d",
+			Amount:          amount,
+		}
+		w.SignTransaction(&tx)
+		return tx
+	}
+
+	t.Run("Verified", func(t *testing.T) {
+		cache := NewSignatureCache(10)
+		tx := newTx(1)
+		if ok, err := cache.Verify(tx); !ok || err != nil {
+			t.Fatalf("Expected the signature to verify, got %v, %v", ok, err)
+		}
+		if cache.Len() != 1 {
+			t.Errorf("Expected the transaction to be cached, got %d entries", cache.Len())
+		}
+		tx.Amount = 2
+		if ok, _ := cache.Verify(tx); ok {
+			t.Errorf("Expected a changed transaction not to be taken from the cache")
+		}
+		if cache.Len() != 1 {
+			t.Errorf("Expected an invalid transaction not to be cached, got %d entries", cache.Len())
+		}
+	})
+
+	t.Run("Eviction", func(t *testing.T) {
+		cache := NewSignatureCache(3)
+		for i := int64(1); i <= 5; i++ {
+			cache.Verify(newTx(i))
+		}
+		if cache.Len() != 3 {
+			t.Errorf("Expected the cache to stay at 3 entries, got %d", cache.Len())
+		}
+		if _, ok := cache.entries[signatureCacheKey(newTx(1))]; ok {
+			t.Errorf("Expected the oldest entry to be evicted")
+		}
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		var cache *SignatureCache
+		if ok, err := cache.Verify(newTx(1)); !ok || err != nil {
+			t.Errorf("Expected a nil cache to verify, got %v, %v", ok, err)
+		}
+	})
+}
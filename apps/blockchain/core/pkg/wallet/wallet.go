@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/x509"
@@ -10,7 +11,8 @@ import (
 	"sync"
 	"atlas-blockchain/pkg/transaction"
 	"crypto/sha256"
-	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 )
 
 // Wallet represents a cryptographic wallet that can sign transactions and manage stake.
@@ -20,6 +22,14 @@ type Wallet struct {
 	PublicKey  []byte
 	Balance    int64  // Available balance (not staked)
 	Staked     uint64 // Amount currently staked as validator
+	// DerivationPath is the HD path the key was derived from, empty for
+	// random and imported keys
+	DerivationPath string
+	// Scheme is the signature scheme of the key, empty for P-256. Keys of
+	// the other schemes are held below instead of PrivateKey.
+	Scheme       string
+	secp256k1Key *secp256k1.PrivateKey
+	ed25519Key   ed25519.PrivateKey
 	mu         sync.RWMutex
 }
 
@@ -137,19 +147,19 @@ func (w *Wallet) Unstake(amount uint64) error {
 
 // Exported version of SignTransaction
 func (w *Wallet) SignTransaction(tx *transaction.Transaction) error {
-	if w.PrivateKey == nil {
-		return fmt.Errorf("wallet has no private key")
+	// P-256 transactions keep an empty scheme so their hash is unchanged
+	if w.Scheme != "" && w.Scheme != transaction.SchemeP256 {
+		tx.Scheme = w.Scheme
+	} else if tx.Scheme != "" && tx.Scheme != transaction.SchemeP256 {
+		return fmt.Errorf("cannot sign a %s transaction with a %s key", tx.Scheme, w.SchemeName())
 	}
 	// Calculate the transaction hash (excluding the signature field)
 	hash := CalculateTxHash(*tx)
-	r, s, err := ecdsa.Sign(rand.Reader, w.PrivateKey, hash)
+	sig, err := w.SignHash(hash)
 	if err != nil {
 		return fmt.Errorf("failed to sign transaction: %v", err)
 	}
-	rBytes := r.Bytes()
-	sBytes := s.Bytes()
-	sig := append(rBytes, sBytes...)
-	tx.Signature = hex.EncodeToString(sig)
+	tx.Signature = sig
 	return nil
 }
 
@@ -157,6 +167,14 @@ func (w *Wallet) SignTransaction(tx *transaction.Transaction) error {
 func CalculateTxHash(tx transaction.Transaction) []byte {
 	// Exclude the signature field from the hash
 	record := tx.Sender + tx.Recipient + fmt.Sprintf("%d", tx.Amount) + fmt.Sprintf("%d", tx.Nonce) + tx.SenderPublicKey
+	// Bind the signature to one network. Transactions without a chain ID
+	// keep their original hash.
+	if tx.ChainID != "" {
+		record = tx.ChainID + ":" + record
+	}
+	if tx.Scheme != "" {
+		record = tx.Scheme + ":" + record
+	}
 	h := sha256.New()
 	h.Write([]byte(record))
 	return h.Sum(nil)
@@ -164,41 +182,44 @@ func CalculateTxHash(tx transaction.Transaction) []byte {
 
 // Exported version of VerifyTransactionSignature
 func VerifyTransactionSignature(tx transaction.Transaction) (bool, error) {
+	if tx.Multisig != nil {
+		return verifyMultisig(tx)
+	}
 	if tx.Signature == "" {
 		return false, fmt.Errorf("transaction signature is empty")
 	}
+	hash := CalculateTxHash(tx)
+	if tx.Scheme == transaction.SchemeSecp256k1 && tx.SenderPublicKey == "" {
+		// The key is recovered from the signature and must be the sender's
+		pubKey, err := RecoverPublicKey(tx.Signature, hash)
+		if err != nil {
+			return false, err
+		}
+		if !SameAddress(tx.Sender, SchemeAddress(transaction.SchemeSecp256k1, pubKey)) && !SameAddress(tx.Sender, hex.EncodeToString(pubKey)) {
+			return false, fmt.Errorf("signature was not made by sender %s", tx.Sender)
+		}
+		return true, nil
+	}
 	if tx.SenderPublicKey == "" {
 		return false, fmt.Errorf("transaction sender public key is empty")
 	}
-	pubKeyBytes, err := hex.DecodeString(tx.SenderPublicKey)
+	return VerifySignature(tx.Scheme, tx.SenderPublicKey, tx.Signature, hash)
+}
+
+// verifySignature checks a hex signature of hash by a hex public key of any
+// scheme, telling the scheme from the key's encoding
+func verifySignature(publicKey, signature string, hash []byte) (bool, error) {
+	pubKeyBytes, err := hex.DecodeString(publicKey)
 	if err != nil {
 		return false, fmt.Errorf("invalid sender public key encoding: %v", err)
 	}
-	pubKeyInterface, err := x509.ParsePKIXPublicKey(pubKeyBytes)
-	if err != nil {
-		return false, fmt.Errorf("failed to parse sender public key: %v", err)
-	}
-	pubKey, ok := pubKeyInterface.(*ecdsa.PublicKey)
-	if !ok {
-		return false, fmt.Errorf("sender public key is not ECDSA")
-	}
-	hash := CalculateTxHash(tx)
-	sigBytes, err := hex.DecodeString(tx.Signature)
-	if err != nil {
-		return false, fmt.Errorf("invalid transaction signature encoding: %v", err)
-	}
-	if len(sigBytes)%2 != 0 {
-		return false, fmt.Errorf("invalid signature length")
-	}
-	r := new(big.Int).SetBytes(sigBytes[:len(sigBytes)/2])
-	s := new(big.Int).SetBytes(sigBytes[len(sigBytes)/2:])
-	return ecdsa.Verify(pubKey, hash, r, s), nil
+	return VerifySignature(PublicKeyScheme(pubKeyBytes), publicKey, signature, hash)
 }
 
+// PublicKeyToAddress derives the address of a public key, following the
+// address derivation of the key's scheme
 func PublicKeyToAddress(pubKey []byte) string {
-	hash := sha256.Sum256(pubKey)
-	address := hash[len(hash)-20:]
-	return "0x" + hex.EncodeToString(address)
+	return SchemeAddress(PublicKeyScheme(pubKey), pubKey)
 }
 
 // ImportWallet creates a wallet from a given private key (hex string)
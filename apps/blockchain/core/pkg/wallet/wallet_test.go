@@ -0,0 +1,53 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"atlas-blockchain/pkg/transaction"
+)
+
+func TestChainIDSignature(t *testing.T) {
+	w, err := NewWallet()
+	if err != nil {
+		t.Fatalf("NewWallet failed: %v", err)
+	}
+	newTx := func(chainID string) transaction.Transaction {
+		return transaction.Transaction{
+			ChainID:         chainID,
+			Sender:          PublicKeyToAddress(w.PublicKey),
+			SenderPublicKey: w.PublicKeyStr(),
+			Recipient:       "cb49a4cefae13ad235beb40e5ad603ba757da61d",
+			Amount:          100,
+			Nonce:           3,
+		}
+	}
+
+	t.Run("SignedForOneChain", func(t *testing.T) {
+		tx := newTx("atlas-devnet")
+		if err := w.SignTransaction(&tx); err != nil {
+			t.Fatalf("SignTransaction failed: %v", err)
+		}
+		if valid, err := VerifyTransactionSignature(tx); !valid || err != nil {
+			t.Errorf("Expected the signature to verify, got %v %v", valid, err)
+		}
+		for _, other := range []string{"atlas-mainnet", ""} {
+			replayed := tx
+			replayed.ChainID = other
+			if valid, _ := VerifyTransactionSignature(replayed); valid {
+				t.Errorf("Expected the signature not to verify for chain %q", other)
+			}
+		}
+	})
+
+	t.Run("LegacyHash", func(t *testing.T) {
+		tx := newTx("")
+		legacy := sha256.Sum256([]byte(tx.Sender + tx.Recipient + "100" + "3" + tx.SenderPublicKey))
+		if string(CalculateTxHash(tx)) != string(legacy[:]) {
+			t.Errorf("Expected transactions without a chain ID to keep their hash")
+		}
+		if string(CalculateTxHash(newTx("atlas-devnet"))) == string(legacy[:]) {
+			t.Errorf("Expected the chain ID to change the hash")
+		}
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"context"
+	"crypto/ed25519"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
@@ -12,6 +14,9 @@ import (
 	"strings"
 	"time"
 
+	"atlas-blockchain/pkg/database"
+	"atlas-blockchain/pkg/database/destination"
+	"atlas-blockchain/pkg/network"
 	"atlas-blockchain/pkg/storage"
 )
 
@@ -30,7 +35,9 @@ type BackupConfig struct {
 	MaxBackupSize      int64                 `json:"max_backup_size_gb"` // in GB
 	MaxFileSize        int64                 `json:"max_file_size"`      // max individual file size
 	CompressionLevel   int                  `json:"compression_level"`
-	EncryptionEnabled  bool                 `json:"encryption_enabled"`
+	EncryptionEnabled  bool                 `json:"encryption_enabled"`  // Password from ATLAS_BACKUP_PASSWORD
+	NodeKeyPath        string               `json:"node_key_path"`       // Node key that signs encrypted archives
+	Destinations       []string             `json:"destinations"`        // Directories, sftp:// or s3:// URLs
 	TestRestoresEnabled bool                `json:"test_restores_enabled"`
 	Paths              []BackupPath          `json:"paths"`
 	NotificationConfig *NotificationConfig   `json:"notification_config"`
@@ -177,6 +184,11 @@ func (bm *BackupManager) CreateFullBackup(ctx context.Context, trigger string) *
 		}
 	}
 
+	// Encrypt the archive and copy it to the configured destinations
+	if err := bm.shipArchive(backupID, archiveResult.ArchivePath, metadata); err != nil {
+		errors = append(errors, err)
+	}
+
 	// Update metadata
 	metadata.Status = "completed"
 	metadata.Size = totalSize
@@ -338,6 +350,67 @@ func (bm *BackupManager) createCompressedArchive(backupID string, paths []Backup
 	return &ArchiveResult{Success: true, ArchivePath: archivePath, Size: totalSize}
 }
 
+// shipArchive seals the archive when encryption is enabled and uploads it to
+// every destination, applying each destination's retention policy. Without
+// a retention policy, archives older than the retention period are removed.
+func (bm *BackupManager) shipArchive(backupID, archivePath string, metadata *BackupMetadata) error {
+	if bm.config.EncryptionEnabled {
+		nodeKey, err := network.NodeSigningKey(bm.config.NodeKeyPath)
+		if err != nil {
+			return fmt.Errorf("failed to load node key: %v", err)
+		}
+		data, err := os.ReadFile(archivePath)
+		if err != nil {
+			return err
+		}
+		sealed, err := database.SealArchive(data, &database.ArchiveKeys{
+			Password: os.Getenv("ATLAS_BACKUP_PASSWORD"),
+			NodeKey:  nodeKey,
+		})
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(archivePath+".sealed", sealed, 0600); err != nil {
+			return err
+		}
+		os.Remove(archivePath)
+		archivePath += ".sealed"
+		metadata.EncryptionKeyID = hex.EncodeToString(nodeKey.Public().(ed25519.PublicKey))
+	}
+
+	var failed []string
+	for _, raw := range bm.config.Destinations {
+		dest, retention, err := destination.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("invalid backup destination %q: %v", raw, err)
+		}
+		if retention.KeepChains == 0 && retention.MaxAge == 0 {
+			retention.MaxAge = bm.config.RetentionPeriod
+		}
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return err
+		}
+		err = dest.Put(backupID+"/"+filepath.Base(archivePath), file)
+		file.Close()
+		if err != nil {
+			log.Printf("❌ Failed to upload backup %s to %s: %v", backupID, dest.Name(), err)
+			failed = append(failed, dest.Name())
+			continue
+		}
+		log.Printf("📤 Uploaded backup %s to %s", backupID, dest.Name())
+		if deleted, err := destination.ApplyRetention(dest, retention); err != nil {
+			log.Printf("⚠️  Failed to apply retention on %s: %v", dest.Name(), err)
+		} else if deleted > 0 {
+			log.Printf("🗑️  Removed %d old backup file(s) from %s", deleted, dest.Name())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("upload failed for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
 type ArchiveResult struct {
 	Success      bool   `json:"success"`
 	ArchivePath  string `json:"archive_path"`
@@ -652,6 +725,7 @@ func DefaultBackupConfig() *BackupConfig {
 		MaxFileSize:        1 * 1024 * 1024 * 1024,   // 1GB max file size
 		CompressionLevel:   6,
 		EncryptionEnabled:  true,
+		NodeKeyPath:        "products/blockchain/core/nodekey.priv",
 		TestRestoresEnabled: true,
 		Paths: []BackupPath{
 			{